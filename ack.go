@@ -0,0 +1,96 @@
+package ebus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrNacked 处理函数调用了 Nack 但没有提供原因
+	ErrNacked = errors.New("ebus: 事件被拒绝确认")
+)
+
+// AckMode 消息确认模式
+type AckMode int
+
+const (
+	// AckModeAuto 根据处理函数的返回值确认消息 (默认)
+	AckModeAuto AckMode = iota
+
+	// AckModeManual 处理函数返回 nil 之后, 等待调用 EventContext 的 Ack 或者 Nack
+	//
+	// 适用于长时间运行的处理函数, 在持久化的副作用完成之后再确认消息
+	// 处理函数返回错误时, 视为 Nack
+	AckModeManual
+)
+
+func (mode AckMode) String() string {
+	switch mode {
+	case AckModeAuto:
+		return "auto"
+	case AckModeManual:
+		return "manual"
+	default:
+		return fmt.Sprintf("AckMode(%d)", int(mode))
+	}
+}
+
+// EventContext 手动确认模式下的确认控制
+//
+// Ack 和 Nack 只有第一次调用生效, 可以在其它协程中调用
+type EventContext struct {
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+func newEventContext() *EventContext {
+	return &EventContext{
+		done: make(chan struct{}),
+	}
+}
+
+// Ack 确认消息
+func (ec *EventContext) Ack() {
+	ec.complete(nil)
+}
+
+// Nack 拒绝确认消息, 消息会重新投递
+//
+// - err 拒绝的原因, 为 nil 时使用 ErrNacked
+func (ec *EventContext) Nack(err error) {
+	if err == nil {
+		err = ErrNacked
+	}
+	ec.complete(err)
+}
+
+func (ec *EventContext) complete(err error) {
+	ec.once.Do(func() {
+		ec.err = err
+		close(ec.done)
+	})
+}
+
+// wait 等待确认, 上下文结束时视为 Nack
+func (ec *EventContext) wait(ctx context.Context) error {
+	select {
+	case <-ec.done:
+		return ec.err
+	case <-ctx.Done():
+		return fmt.Errorf("ebus: 等待确认时上下文结束: %w", ctx.Err())
+	}
+}
+
+// eventContextKey 确认控制在上下文中的键
+type eventContextKey struct{}
+
+// EventContextFromContext 从处理函数的上下文中获取确认控制
+//
+// 只有手动确认模式下才存在
+func EventContextFromContext(ctx context.Context) (*EventContext, bool) {
+	ec, ok := ctx.Value(eventContextKey{}).(*EventContext)
+	return ec, ok && ec != nil
+}
@@ -0,0 +1,259 @@
+package ebus
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	// AsyncAPIVersion 导出的 AsyncAPI 文档的规范版本
+	AsyncAPIVersion = "2.6.0"
+)
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	rawMessageType    = reflect.TypeFor[json.RawMessage]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// ExportAsyncAPI 从全局注册表导出 AsyncAPI 文档, 参考 Registry.ExportAsyncAPI
+// - topicsByType 事件类型对应的主题
+func ExportAsyncAPI(topicsByType map[EventType]string) ([]byte, error) {
+	return defaultRegistry.ExportAsyncAPI(topicsByType)
+}
+
+// ExportAsyncAPI 从注册表导出 AsyncAPI 2.x 文档 (JSON)
+//
+// 每个已注册的事件工厂对应一个消息, 消息的负载是事件信封, 事件负载的模式通过反射事件的结构体生成
+// 事件类型在 topicsByType 中有对应的主题时, 消息归入该主题的通道, 否则只出现在 components.messages 中
+// 自定义了 JSON 编码的事件 (例如 GenericEvent) 无法推断负载的模式, 负载的模式为空
+// - topicsByType 事件类型对应的主题
+func (r *Registry) ExportAsyncAPI(topicsByType map[EventType]string) ([]byte, error) {
+	factories := r.snapshot()
+
+	messages := make(map[string]any)
+	channels := make(map[string]map[string]any)
+
+	for _, info := range r.Factories() {
+		event, err := factories[info.key()].factory()
+		if err != nil {
+			return nil, fmt.Errorf("ebus: 事件工厂(%s)创建事件失败: %w", r.describeKey(info.key()), err)
+		}
+		if event == nil {
+			return nil, fmt.Errorf("ebus: 事件工厂(%s)返回了空事件", r.describeKey(info.key()))
+		}
+
+		name := fmt.Sprintf("%s.%s.%s", info.EventSource, info.EventType, info.SchemaVersion)
+		messages[name] = map[string]any{
+			"name":        name,
+			"title":       fmt.Sprintf("%s/%s", info.EventSource, info.EventType),
+			"contentType": ContentTypeJson,
+			"headers":     asyncAPIHeadersSchema(),
+			"payload": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"envelopeVersion": map[string]any{"type": "integer", "const": EnvelopeVersion},
+					"metadata":        map[string]any{"$ref": "#/components/schemas/Metadata"},
+					"payload":         newSchemaBuilder().schemaOf(reflect.TypeOf(event)),
+				},
+				"required": []string{"metadata", "payload"},
+			},
+			"x-schema-version": string(info.SchemaVersion),
+			"x-event-source":   string(info.EventSource),
+			"x-event-type":     string(info.EventType),
+		}
+
+		topic, exists := topicsByType[info.EventType]
+		if !exists {
+			continue
+		}
+
+		channel, exists := channels[topic]
+		if !exists {
+			channel = map[string]any{"oneOf": []any{}}
+			channels[topic] = channel
+		}
+		channel["oneOf"] = append(channel["oneOf"].([]any), map[string]any{"$ref": "#/components/messages/" + name})
+	}
+
+	title := "ebus"
+	if len(r.name) > 0 {
+		title = fmt.Sprintf("ebus (%s)", r.name)
+	}
+
+	document := map[string]any{
+		"asyncapi": AsyncAPIVersion,
+		"info": map[string]any{
+			"title":   title,
+			"version": "1.0.0",
+		},
+		"defaultContentType": ContentTypeJson,
+		"channels":           asyncAPIChannels(channels),
+		"components": map[string]any{
+			"messages": messages,
+			"schemas": map[string]any{
+				"Metadata": newSchemaBuilder().schemaOf(reflect.TypeFor[Metadata]()),
+			},
+		},
+	}
+
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("ebus: AsyncAPI 文档编码失败: %w", err)
+	}
+	return data, nil
+}
+
+// asyncAPIChannels 生成通道, 订阅者从通道接收消息
+func asyncAPIChannels(channels map[string]map[string]any) map[string]any {
+	result := make(map[string]any, len(channels))
+	for topic, message := range channels {
+		if oneOf := message["oneOf"].([]any); len(oneOf) == 1 {
+			message = oneOf[0].(map[string]any)
+		}
+		result[topic] = map[string]any{
+			"subscribe": map[string]any{
+				"message": message,
+			},
+		}
+	}
+	return result
+}
+
+// asyncAPIHeadersSchema 生成消息头的模式
+func asyncAPIHeadersSchema() map[string]any {
+	properties := make(map[string]any)
+	for _, header := range []string{
+		HeaderSchemaVersion,
+		HeaderEventId,
+		HeaderEventSource,
+		HeaderEventType,
+		HeaderEventTime,
+		HeaderCorrelationId,
+		HeaderCausationId,
+		HeaderTenantId,
+		HeaderEnvelopeVersion,
+		HeaderPublishTime,
+	} {
+		properties[header] = map[string]any{"type": "string"}
+	}
+
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+// schemaBuilder 通过反射生成 JSON Schema
+type schemaBuilder struct {
+	visiting map[reflect.Type]bool // 正在生成的结构体, 用于检测递归类型
+}
+
+// newSchemaBuilder 创建 JSON Schema 生成器
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{visiting: make(map[reflect.Type]bool)}
+}
+
+// schemaOf 生成类型的 JSON Schema, 与 encoding/json 的编码规则一致
+func (b *schemaBuilder) schemaOf(typ reflect.Type) map[string]any {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch {
+	case typ == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case typ == rawMessageType:
+		return map[string]any{}
+	case typ.Implements(jsonMarshalerType) || reflect.PointerTo(typ).Implements(jsonMarshalerType):
+		return map[string]any{}
+	case typ.Implements(textMarshalerType) || reflect.PointerTo(typ).Implements(textMarshalerType):
+		return map[string]any{"type": "string"}
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 && typ.Kind() == reflect.Slice {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": b.schemaOf(typ.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schemaOf(typ.Elem())}
+	case reflect.Struct:
+		return b.structSchema(typ)
+	default:
+		return map[string]any{}
+	}
+}
+
+// structSchema 生成结构体的 JSON Schema
+func (b *schemaBuilder) structSchema(typ reflect.Type) map[string]any {
+	if b.visiting[typ] {
+		return map[string]any{"type": "object"}
+	}
+	b.visiting[typ] = true
+	defer delete(b.visiting, typ)
+
+	properties := make(map[string]any)
+	required := make([]string, 0)
+	b.collectFields(typ, properties, &required)
+
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// collectFields 收集结构体的字段, 匿名嵌入的结构体字段提升到外层
+func (b *schemaBuilder) collectFields(typ reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+
+		if field.Anonymous && len(name) == 0 && fieldType.Kind() == reflect.Struct {
+			b.collectFields(fieldType, properties, required)
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if len(name) == 0 {
+			name = field.Name
+		}
+
+		properties[name] = b.schemaOf(field.Type)
+		if !strings.Contains(options, "omitempty") && !strings.Contains(options, "omitzero") && field.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package ebus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrPublishQueueFull 异步发布队列已满, 事件没有发布
+	ErrPublishQueueFull = errors.New("ebus: 异步发布队列已满")
+)
+
+const (
+	DefaultAsyncQueueSize = 1024             // 异步发布队列的默认容量
+	DefaultAsyncWorkers   = 4                // 异步发布的默认发送协程数量
+	DefaultAsyncTimeout   = 30 * time.Second // 异步发布的默认发送超时
+)
+
+// AsyncPublish 异步发布的队列设置
+type AsyncPublish struct {
+	QueueSize int           // 队列容量, 队列已满时 PublishAsync 立即返回 ErrPublishQueueFull
+	Workers   int           // 最多同时发送的协程数量
+	Timeout   time.Duration // 每个事件的发送超时, 从事件开始发送时计算
+}
+
+// normalize 补充默认值
+func (async *AsyncPublish) normalize() {
+	if async.QueueSize <= 0 {
+		async.QueueSize = DefaultAsyncQueueSize
+	}
+
+	if async.Workers <= 0 {
+		async.Workers = DefaultAsyncWorkers
+	}
+
+	if async.Timeout <= 0 {
+		async.Timeout = DefaultAsyncTimeout
+	}
+}
+
+// asyncJob 等待发送的异步发布任务
+type asyncJob struct {
+	ctx     context.Context
+	topic   string
+	batch   []*outgoingMessage // 事件以及双版本发布的旧版本事件
+	buffers []*encodeBuffer    // 消息体可能引用的缓冲区, 发送之后释放
+	result  chan error
+}
+
+// finish 释放缓冲区并且通知发布结果
+func (job *asyncJob) finish(err error) {
+	for _, buffer := range job.buffers {
+		buffer.release()
+	}
+	job.result <- err
+	close(job.result)
+}
+
+// asyncQueue 异步发布队列
+//
+// 发送协程按需启动, 队列为空时退出, 所以发布者不需要关闭; 停机之前使用 flush 排空队列
+type asyncQueue struct {
+	pub     *publisher
+	options AsyncPublish
+	jobs    chan *asyncJob
+
+	lock    sync.Mutex
+	workers int           // 正在运行的发送协程数量
+	pending int           // 已经放入队列但是还没有完成的任务数量
+	idle    chan struct{} // 没有未完成的任务时关闭
+}
+
+// newAsyncQueue 创建异步发布队列
+func newAsyncQueue(pub *publisher, options *AsyncPublish) *asyncQueue {
+	var async AsyncPublish
+	if options != nil {
+		async = *options
+	}
+	async.normalize()
+
+	idle := make(chan struct{})
+	close(idle)
+
+	return &asyncQueue{
+		pub:     pub,
+		options: async,
+		jobs:    make(chan *asyncJob, async.QueueSize),
+		idle:    idle,
+	}
+}
+
+// enqueue 把任务放入队列, 队列已满时返回 false
+func (queue *asyncQueue) enqueue(job *asyncJob) bool {
+	// 先登记任务, 避免 flush 在任务放入队列之后, 登记之前返回
+	queue.lock.Lock()
+	if queue.pending == 0 {
+		queue.idle = make(chan struct{})
+	}
+	queue.pending++
+	queue.lock.Unlock()
+
+	select {
+	case queue.jobs <- job:
+	default:
+		queue.done()
+		return false
+	}
+
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+
+	// 发送协程在持有锁的情况下确认队列为空之后才退出, 所以任务不会留在队列中无人处理
+	if queue.workers < queue.options.Workers {
+		queue.workers++
+		go queue.run()
+	}
+
+	return true
+}
+
+// done 任务完成, 没有未完成的任务时通知 flush
+func (queue *asyncQueue) done() {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+
+	queue.pending--
+	if queue.pending == 0 {
+		close(queue.idle)
+	}
+}
+
+// flush 等待所有未完成的任务完成
+func (queue *asyncQueue) flush(ctx context.Context) error {
+	queue.lock.Lock()
+	idle := queue.idle
+	queue.lock.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run 发送队列中的任务, 队列为空时退出
+func (queue *asyncQueue) run() {
+	for {
+		select {
+		case job := <-queue.jobs:
+			queue.process(job)
+			queue.done()
+
+		default:
+			queue.lock.Lock()
+			if len(queue.jobs) == 0 {
+				queue.workers--
+				queue.lock.Unlock()
+				return
+			}
+			queue.lock.Unlock()
+		}
+	}
+}
+
+// process 发送单个任务
+//
+// 发布配额和速率限制在这里生效, RateLimitBlock 模式的等待计入发送超时, 被丢弃的消息视为发送成功
+func (queue *asyncQueue) process(job *asyncJob) {
+	var err error
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("ebus: 异步发布发生 panic: %v", r)
+		}
+		job.finish(err)
+	}()
+
+	ctx, cancel := context.WithTimeout(job.ctx, queue.options.Timeout)
+	defer cancel()
+
+	for _, outgoing := range job.batch {
+		allowed, admitErr := queue.pub.admit(ctx, job.topic, outgoing.metadata)
+		if admitErr != nil {
+			err = admitErr
+			return
+		}
+		if !allowed {
+			continue
+		}
+
+		if err = queue.pub.sendOutgoing(ctx, job.topic, outgoing); err != nil {
+			return
+		}
+	}
+}
+
+// PublishAsync 异步发布事件
+func (pub *publisher) PublishAsync(ctx context.Context, topic string, event Event) <-chan error {
+	result := make(chan error, 1)
+
+	if err := pub.enqueueAsync(ctx, topic, event, result); err != nil {
+		result <- err
+		close(result)
+	}
+
+	return result
+}
+
+// enqueueAsync 编码事件并放入异步发布队列
+//
+// 返回 nil 时由发送协程通知发布结果
+func (pub *publisher) enqueueAsync(ctx context.Context, topic string, event Event, result chan error) error {
+	topic, err := pub.options.resolveTopic(topic)
+	if err != nil {
+		return err
+	}
+
+	lineage, err := nextLineage(ctx, pub.options)
+	if err != nil {
+		return err
+	}
+
+	// 事件在调用者的协程中编码, 调用返回之后修改事件不会影响发布的内容
+	// 发送时不继承调用者上下文的取消, 请求结束之后事件仍然会发布
+	job := &asyncJob{
+		ctx:    context.WithoutCancel(ctx),
+		topic:  topic,
+		result: result,
+	}
+
+	releaseBuffers := func() {
+		for _, buffer := range job.buffers {
+			buffer.release()
+		}
+	}
+
+	encode := func(event Event, legacyOf SchemaVersion) error {
+		buffer := acquireEncodeBuffer()
+		job.buffers = append(job.buffers, buffer)
+
+		outgoing, err := pub.encodeMessage(ctx, topic, lineage, event, legacyOf, buffer)
+		if err != nil {
+			return err
+		}
+		job.batch = append(job.batch, outgoing)
+		return nil
+	}
+
+	if err := encode(event, ""); err != nil {
+		releaseBuffers()
+		return err
+	}
+
+	legacyEvent, exists, err := pub.options.downcastForDualVersion(event)
+	if err != nil {
+		releaseBuffers()
+		return err
+	}
+	if exists {
+		if err := encode(legacyEvent, event.Metadata().SchemaVersion); err != nil {
+			releaseBuffers()
+			return err
+		}
+	}
+
+	if !pub.async.enqueue(job) {
+		releaseBuffers()
+		pub.options.Metrics.IncPublishFailed(topic, job.batch[0].metadata)
+		return fmt.Errorf("%w: 事件(%s)", ErrPublishQueueFull, job.batch[0].metadata.EventId)
+	}
+
+	return nil
+}
+
+// Flush 等待异步发布队列中的事件全部发送完成
+func (pub *publisher) Flush(ctx context.Context) error {
+	return pub.async.flush(ctx)
+}
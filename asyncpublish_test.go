@@ -0,0 +1,82 @@
+package ebus_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nf5lab/ebus"
+	"github.com/nf5lab/ebus/memory"
+)
+
+func TestPublishAsyncRateLimitBlock(t *testing.T) {
+	const events = 3
+
+	// 在调用者的协程中等待令牌时, 发布三个事件至少需要 1 秒
+	bus := memory.New(
+		ebus.WithRegistry(newAccountRegistry(t)),
+		ebus.WithPublishRateLimit(ebus.RateLimit{Rate: 2, Burst: 1, Mode: ebus.RateLimitBlock}),
+		ebus.WithAsyncPublish(ebus.AsyncPublish{Workers: 1}),
+	)
+	defer bus.Close()
+
+	var handled atomic.Int32
+	handler := func(ctx context.Context, topic string, event ebus.Event) error {
+		handled.Add(1)
+		return nil
+	}
+	if _, err := bus.Subscribe(context.Background(), "accounts", "ledger", handler); err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+
+	// 令牌在发送协程中等待, 调用者不会被速率限制阻塞
+	start := time.Now()
+	for i := range events {
+		bus.PublishAsync(context.Background(), "accounts", newAccountEvent("account", i))
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("PublishAsync 耗时 %v, 期望不等待速率限制的令牌", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := bus.Flush(ctx); err != nil {
+		t.Fatalf("排空异步发布队列失败: %v", err)
+	}
+	if got := handled.Load(); got != events {
+		t.Errorf("排空之后处理 %d 个事件, 期望 %d", got, events)
+	}
+}
+
+func TestFlushContextCanceled(t *testing.T) {
+	bus := memory.New(
+		ebus.WithRegistry(newAccountRegistry(t)),
+		ebus.WithPublishRateLimit(ebus.RateLimit{Rate: 10, Burst: 1, Mode: ebus.RateLimitBlock}),
+	)
+	defer bus.Close()
+
+	// 没有未完成的事件时立即返回
+	if err := bus.Flush(context.Background()); err != nil {
+		t.Fatalf("空队列排空失败: %v", err)
+	}
+
+	// 第二个事件需要等待令牌, 排空在上下文取消时返回
+	for i := range 2 {
+		bus.PublishAsync(context.Background(), "accounts", newAccountEvent("account", i))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := bus.Flush(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("上下文取消之后排空的错误 = %v, 期望 context.Canceled", err)
+	}
+
+	// 取消不会丢弃队列中的事件
+	if err := bus.Flush(context.Background()); err != nil {
+		t.Fatalf("排空异步发布队列失败: %v", err)
+	}
+}
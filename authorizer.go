@@ -0,0 +1,53 @@
+package ebus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnauthorized 授权检查拒绝了发布或者订阅
+	ErrUnauthorized = errors.New("ebus: 未授权")
+)
+
+// Authorizer 发布和订阅的授权检查
+//
+// 在调用消息队列之前执行, 平台团队可以在一处实施各个服务的主题访问控制, 不只依赖消息队列自身的权限
+// 返回错误表示拒绝, 错误会包装 ErrUnauthorized
+type Authorizer interface {
+
+	// AuthorizePublish 检查是否允许向主题发布事件
+	// - topic 解析之后的主题
+	// - meta  事件元数据
+	AuthorizePublish(ctx context.Context, topic string, meta *Metadata) error
+
+	// AuthorizeSubscribe 检查是否允许以订阅组订阅主题
+	// - topic 解析之后的主题
+	// - group 解析之后的订阅组
+	AuthorizeSubscribe(ctx context.Context, topic string, group string) error
+}
+
+// authorizePublish 按照选项检查是否允许发布
+func (opts *Options) authorizePublish(ctx context.Context, topic string, meta *Metadata) error {
+	if opts.Authorizer == nil {
+		return nil
+	}
+
+	if err := opts.Authorizer.AuthorizePublish(ctx, topic, meta); err != nil {
+		return fmt.Errorf("%w: 发布事件(%s)到主题(%s): %w", ErrUnauthorized, meta.EventId, topic, err)
+	}
+	return nil
+}
+
+// authorizeSubscribe 按照选项检查是否允许订阅
+func (opts *Options) authorizeSubscribe(ctx context.Context, topic string, group string) error {
+	if opts.Authorizer == nil {
+		return nil
+	}
+
+	if err := opts.Authorizer.AuthorizeSubscribe(ctx, topic, group); err != nil {
+		return fmt.Errorf("%w: 订阅主题(%s), 订阅组(%s): %w", ErrUnauthorized, topic, group, err)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package ebus
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/nf5lab/broker"
+)
+
+// BatchPublisher 批量发布接口
+//
+// 支持批量发布的 broker.Publisher 可以实现此接口, 例如 Kafka 的批量生产
+// 没有实现此接口时, 批量发布退化为逐条发布
+type BatchPublisher interface {
+
+	// PublishBatch 把多条消息发布到同一个主题
+	//
+	// 参数:
+	// - topic:    主题
+	// - messages: 消息列表, 按照顺序发布
+	//
+	// 返回错误时, 所有消息都视为发布失败 (部分消息可能已经发布, 订阅者需要幂等处理)
+	PublishBatch(ctx context.Context, topic string, messages []*broker.Message, opts ...broker.PublishOption) error
+}
+
+// TopicEvent 发布到指定主题的事件 (参考 PublishBatchTopics)
+type TopicEvent struct {
+	Topic string // 主题
+	Event Event  // 事件
+}
+
+// PublishBatch 批量发布事件到同一个主题
+func (pub *publisher) PublishBatch(ctx context.Context, topic string, events []Event) error {
+	topic, err := pub.options.resolveTopic(topic)
+	if err != nil {
+		return err
+	}
+
+	lineage, err := nextLineage(ctx, pub.options)
+	if err != nil {
+		return err
+	}
+
+	if len(events) == 0 {
+		return fmt.Errorf("ebus: 事件列表不能为空")
+	}
+
+	return pub.publishBatch(ctx, topic, lineage, events)
+}
+
+// PublishBatchTopics 批量发布事件到多个主题
+func (pub *publisher) PublishBatchTopics(ctx context.Context, events []TopicEvent) error {
+	lineage, err := nextLineage(ctx, pub.options)
+	if err != nil {
+		return err
+	}
+
+	if len(events) == 0 {
+		return fmt.Errorf("ebus: 事件列表不能为空")
+	}
+
+	// 按照主题分组, 主题按照第一次出现的顺序发布, 同一个主题的事件保持原来的顺序
+	var topics []string
+	groups := make(map[string][]Event)
+	for _, item := range events {
+		topic, err := pub.options.resolveTopic(item.Topic)
+		if err != nil {
+			return err
+		}
+
+		if _, exists := groups[topic]; !exists {
+			topics = append(topics, topic)
+		}
+		groups[topic] = append(groups[topic], item.Event)
+	}
+
+	for _, topic := range topics {
+		if err := pub.publishBatch(ctx, topic, lineage, groups[topic]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// publishBatch 编码所有事件, 然后一次发送
+//
+// 任何一个事件编码失败时, 不会发送任何消息, 也不会消耗发布配额和速率限制的令牌
+func (pub *publisher) publishBatch(ctx context.Context, topic string, lineage Lineage, events []Event) error {
+	// 消息体可能引用缓冲区, 所以缓冲区在整个批次发送之后才释放
+	var buffers []*encodeBuffer
+	defer func() {
+		for _, buffer := range buffers {
+			buffer.release()
+		}
+	}()
+
+	encode := func(event Event, legacyOf SchemaVersion) (*outgoingMessage, error) {
+		buffer := acquireEncodeBuffer()
+		buffers = append(buffers, buffer)
+		return pub.encodeMessage(ctx, topic, lineage, event, legacyOf, buffer)
+	}
+
+	encoded := make([]*outgoingMessage, 0, len(events))
+	for _, event := range events {
+		outgoing, err := encode(event, "")
+		if err != nil {
+			return err
+		}
+		encoded = append(encoded, outgoing)
+
+		legacyEvent, exists, err := pub.options.downcastForDualVersion(event)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+
+		if outgoing, err = encode(legacyEvent, event.Metadata().SchemaVersion); err != nil {
+			return err
+		}
+		encoded = append(encoded, outgoing)
+	}
+
+	// 所有事件编码成功之后, 逐条消息检查发布配额并获取令牌, 被速率限制丢弃的消息不发送
+	batch := encoded[:0]
+	for _, outgoing := range encoded {
+		allowed, err := pub.admit(ctx, topic, outgoing.metadata)
+		if err != nil {
+			return err
+		}
+		if allowed {
+			batch = append(batch, outgoing)
+		}
+	}
+
+	return pub.sendBatch(ctx, topic, batch)
+}
+
+// sendBatch 发送编码之后的消息
+//
+// 消息队列支持批量发布时 (参考 BatchPublisher) 一次发送所有消息, 否则逐条发送, 遇到第一个错误时停止
+func (pub *publisher) sendBatch(ctx context.Context, topic string, batch []*outgoingMessage) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	batcher, ok := pub.inner.(BatchPublisher)
+	if _, staging := stagingTx(ctx); !ok || staging {
+		for _, outgoing := range batch {
+			if err := pub.sendOutgoing(ctx, topic, outgoing); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	messages := make([]*broker.Message, len(batch))
+	for i, outgoing := range batch {
+		messages[i] = outgoing.message
+	}
+
+	if err := batcher.PublishBatch(ctx, topic, messages); err != nil {
+		for _, outgoing := range batch {
+			pub.options.Metrics.IncPublishFailed(topic, outgoing.metadata)
+		}
+		// 批次可能很大, 只记录一条日志
+		pub.options.Logger.ErrorContext(ctx, "ebus: 事件批量发布失败",
+			slog.String("topic", topic),
+			slog.Int("count", len(batch)),
+			slog.String("firstEventId", batch[0].metadata.EventId),
+			slog.Any("error", err),
+		)
+		return fmt.Errorf("ebus: %d 个事件批量发布失败: %w", len(batch), err)
+	}
+
+	for _, outgoing := range batch {
+		pub.published(topic, outgoing)
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package ebus
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPublishBatchEncodeFailureKeepsTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  Option
+	}{
+		{name: "rate limit", opt: WithPublishRateLimit(RateLimit{Rate: 0.001, Burst: 1, Mode: RateLimitError})},
+		{name: "source quota", opt: WithSourceQuota(NewSourceQuota(0.001, 1))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			capture := &capturePublisher{}
+			publisher := NewPublisher(capture, WithRegistry(newTestRegistry(t)), tt.opt)
+
+			// 第二个事件没有元数据, 编码失败, 整个批次不发送
+			invalid := &testEvent{OrderId: "order-2"}
+			if err := publisher.PublishBatch(context.Background(), "orders", []Event{newTestEvent("order-1", 1), invalid}); err == nil {
+				t.Fatal("批次中的事件无效时期望返回错误")
+			}
+
+			// 唯一的令牌没有被失败的批次消耗
+			if err := publisher.Publish(context.Background(), "orders", newTestEvent("order-3", 3)); err != nil {
+				t.Fatalf("批次失败之后发布事件失败: %v", err)
+			}
+			if published := capture.published(); len(published) != 1 {
+				t.Errorf("发布 %d 条消息, 期望 1 条", len(published))
+			}
+		})
+	}
+}
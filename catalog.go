@@ -0,0 +1,95 @@
+package ebus
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var (
+	// 确保实现了 http.Handler 接口
+	_ http.Handler = (*catalogHandler)(nil)
+)
+
+// CatalogEntry 事件目录中的事件工厂
+type CatalogEntry struct {
+	SchemaVersion SchemaVersion  `json:"schemaVersion"`    // 模型版本
+	EventSource   EventSource    `json:"eventSource"`      // 事件来源
+	EventType     EventType      `json:"eventType"`        // 事件类型
+	RegisteredAt  time.Time      `json:"registeredAt"`     // 注册时间
+	JSONSchema    bool           `json:"jsonSchema"`       // 是否附加了 JSON Schema (参考 RegisterEventFactoryWithSchema)
+	Fields        map[string]any `json:"fields,omitempty"` // 反射事件结构体生成的负载模式, 只在请求时返回
+}
+
+// Catalog 事件目录
+type Catalog struct {
+	Registry  string         `json:"registry,omitempty"` // 注册表的命名空间
+	Factories []CatalogEntry `json:"factories"`          // 已注册的事件工厂
+}
+
+// CatalogHandler 提供全局注册表的事件目录, 参考 Registry.CatalogHandler
+func CatalogHandler() http.Handler {
+	return defaultRegistry.CatalogHandler()
+}
+
+// CatalogHandler 提供事件目录的 HTTP 接口, 返回 JSON 格式的 Catalog
+//
+// 用于运维人员查看运行中的服务能够解码哪些事件
+// 查询参数 fields=true 时返回反射事件结构体生成的负载模式 (需要为每个事件工厂创建一个事件实例)
+func (r *Registry) CatalogHandler() http.Handler {
+	return &catalogHandler{registry: r}
+}
+
+type catalogHandler struct {
+	registry *Registry
+}
+
+// ServeHTTP 返回事件目录
+func (handler *catalogHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	withFields, _ := strconv.ParseBool(req.URL.Query().Get("fields"))
+
+	w.Header().Set("Content-Type", ContentTypeJson)
+	_ = json.NewEncoder(w).Encode(handler.registry.catalog(withFields))
+}
+
+// catalog 生成事件目录
+// - withFields 是否反射事件结构体生成负载模式
+func (r *Registry) catalog(withFields bool) *Catalog {
+	factories := r.snapshot()
+
+	catalog := &Catalog{
+		Registry:  r.name,
+		Factories: make([]CatalogEntry, 0, len(factories)),
+	}
+
+	for _, info := range r.Factories() {
+		entry := factories[info.key()]
+
+		item := CatalogEntry{
+			SchemaVersion: info.SchemaVersion,
+			EventSource:   info.EventSource,
+			EventType:     info.EventType,
+			RegisteredAt:  info.RegisteredAt,
+			JSONSchema:    entry.schema != nil,
+		}
+
+		if withFields {
+			// 事件工厂失败时只省略负载模式, 不影响目录的其它内容
+			if event, err := entry.factory(); err == nil && event != nil {
+				item.Fields = newSchemaBuilder().schemaOf(reflect.TypeOf(event))
+			}
+		}
+
+		catalog.Factories = append(catalog.Factories, item)
+	}
+
+	return catalog
+}
@@ -0,0 +1,54 @@
+package ebus
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nf5lab/broker"
+)
+
+var (
+	// ErrChecksumMismatch 消息体的校验和不匹配, 消息在传输或者存储过程中被破坏
+	ErrChecksumMismatch = errors.New("ebus: 消息体校验和不匹配")
+)
+
+const (
+	// HeaderChecksum 消息体的校验和, 格式为 "sha256:十六进制摘要"
+	HeaderChecksum = "x-event-checksum"
+
+	// checksumAlgorithmSHA256 SHA-256 校验和的前缀
+	checksumAlgorithmSHA256 = "sha256:"
+)
+
+// payloadChecksum 计算 SHA-256 校验和
+func payloadChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return checksumAlgorithmSHA256 + hex.EncodeToString(sum[:])
+}
+
+// addChecksum 按照选项设置消息体的校验和, 在压缩之前调用, 校验和对应未压缩的消息体
+func (opts *Options) addChecksum(message *broker.Message) {
+	if opts.PayloadChecksum {
+		message.AddHeader(HeaderChecksum, payloadChecksum(message.Body))
+	}
+}
+
+// verifyChecksum 校验解压之后的消息体, 没有校验和消息头时不校验
+func verifyChecksum(headers map[string]any, body []byte) error {
+	expected, _ := headers[HeaderChecksum].(string)
+	if len(expected) == 0 {
+		return nil
+	}
+
+	if !strings.HasPrefix(strings.ToLower(expected), checksumAlgorithmSHA256) {
+		return fmt.Errorf("ebus: 不支持的校验和算法: %s", expected)
+	}
+
+	if actual := payloadChecksum(body); !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("%w: 期望 %s, 实际 %s", ErrChecksumMismatch, expected, actual)
+	}
+	return nil
+}
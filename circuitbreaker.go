@@ -0,0 +1,228 @@
+package ebus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCircuitOpen 断路器已打开, 事件没有交给处理函数
+	ErrCircuitOpen = errors.New("ebus: 断路器已打开")
+)
+
+// CircuitState 断路器状态
+type CircuitState int
+
+const (
+	CircuitClosed   CircuitState = iota // 关闭: 正常处理事件, 统计失败比例
+	CircuitOpen                         // 打开: 不处理事件, 要求延迟重新投递
+	CircuitHalfOpen                     // 半开: 只允许少量试探事件, 根据结果关闭或者重新打开
+)
+
+func (state CircuitState) String() string {
+	switch state {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(state))
+	}
+}
+
+// CircuitBreakerOptions 断路器选项
+type CircuitBreakerOptions struct {
+
+	// FailureRatio 打开断路器的失败比例, 取值范围 (0, 1], 默认 0.5
+	FailureRatio float64
+
+	// MinRequests 统计窗口内至少处理的事件数量, 达到之后才计算失败比例, 默认 10
+	MinRequests int
+
+	// Window 统计窗口, 每个窗口结束时清空统计, 默认 1 分钟
+	Window time.Duration
+
+	// OpenTimeout 断路器保持打开的时间, 之后进入半开状态, 默认 30 秒
+	OpenTimeout time.Duration
+
+	// HalfOpenRequests 半开状态允许的试探事件数量, 全部成功之后关闭断路器, 默认 1
+	HalfOpenRequests int
+
+	// OnStateChange 断路器状态变化的回调, 可以为 nil
+	//
+	// 回调在持有断路器锁的情况下同步调用, 不能阻塞
+	OnStateChange func(topic string, from CircuitState, to CircuitState)
+}
+
+// normalize 补充默认值
+func (opts *CircuitBreakerOptions) normalize() {
+	if opts.FailureRatio <= 0 || opts.FailureRatio > 1 {
+		opts.FailureRatio = 0.5
+	}
+
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = 10
+	}
+
+	if opts.Window <= 0 {
+		opts.Window = time.Minute
+	}
+
+	if opts.OpenTimeout <= 0 {
+		opts.OpenTimeout = 30 * time.Second
+	}
+
+	if opts.HalfOpenRequests <= 0 {
+		opts.HalfOpenRequests = 1
+	}
+}
+
+// circuitBreaker 单个主题的断路器
+type circuitBreaker struct {
+	topic   string
+	options *CircuitBreakerOptions
+
+	lock        sync.Mutex
+	state       CircuitState
+	windowStart time.Time // 关闭状态的统计窗口开始时间
+	openedAt    time.Time // 打开的时间
+	requests    int       // 关闭状态: 窗口内的事件数量; 半开状态: 已经放行的试探事件数量
+	failures    int       // 关闭状态: 窗口内的失败数量
+	successes   int       // 半开状态: 成功的试探事件数量
+}
+
+// setState 切换状态, 调用者必须持有锁
+func (cb *circuitBreaker) setState(state CircuitState, now time.Time) {
+	from := cb.state
+	cb.state = state
+	cb.requests = 0
+	cb.failures = 0
+	cb.successes = 0
+
+	switch state {
+	case CircuitClosed:
+		cb.windowStart = now
+	case CircuitOpen:
+		cb.openedAt = now
+	}
+
+	if cb.options.OnStateChange != nil && from != state {
+		cb.options.OnStateChange(cb.topic, from, state)
+	}
+}
+
+// allow 是否允许处理事件
+//
+// 不允许时返回断路器重新进入半开状态之前的剩余时间
+func (cb *circuitBreaker) allow(now time.Time) (bool, time.Duration) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		remaining := cb.options.OpenTimeout - now.Sub(cb.openedAt)
+		if remaining > 0 {
+			return false, remaining
+		}
+		cb.setState(CircuitHalfOpen, now)
+		fallthrough
+
+	case CircuitHalfOpen:
+		if cb.requests >= cb.options.HalfOpenRequests {
+			// 试探事件还没有结果, 稍后重试
+			return false, cb.options.OpenTimeout
+		}
+		cb.requests++
+		return true, 0
+
+	default:
+		if now.Sub(cb.windowStart) >= cb.options.Window {
+			cb.windowStart = now
+			cb.requests = 0
+			cb.failures = 0
+		}
+		cb.requests++
+		return true, 0
+	}
+}
+
+// record 记录处理结果
+func (cb *circuitBreaker) record(failed bool, now time.Time) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	switch cb.state {
+	case CircuitHalfOpen:
+		if failed {
+			cb.setState(CircuitOpen, now)
+			return
+		}
+
+		cb.successes++
+		if cb.successes >= cb.options.HalfOpenRequests {
+			cb.setState(CircuitClosed, now)
+		}
+
+	case CircuitClosed:
+		if !failed {
+			return
+		}
+
+		cb.failures++
+		if cb.requests >= cb.options.MinRequests &&
+			float64(cb.failures) >= cb.options.FailureRatio*float64(cb.requests) {
+			cb.setState(CircuitOpen, now)
+		}
+	}
+}
+
+// CircuitBreaker 按照主题的断路器中间件
+//
+// 处理函数的失败比例超过阈值时断路器打开, 之后的事件不再交给处理函数,
+// 而是返回 RetryAfter(ErrCircuitOpen, 剩余时间), 由订阅者按照 DelayedRetryMode 延迟重新投递,
+// 避免故障的下游被紧密循环的重新投递持续冲击; 打开超时之后进入半开状态, 用少量试探事件决定是否恢复
+//
+// 每个主题一个独立的断路器, 同一个中间件可以用于多个订阅
+func CircuitBreaker(options CircuitBreakerOptions) Middleware {
+	options.normalize()
+
+	var breakers sync.Map // 主题 -> *circuitBreaker
+
+	breakerFor := func(topic string) *circuitBreaker {
+		breaker, exists := breakers.Load(topic)
+		if !exists {
+			breaker, _ = breakers.LoadOrStore(topic, &circuitBreaker{
+				topic:       topic,
+				options:     &options,
+				windowStart: time.Now(),
+			})
+		}
+		return breaker.(*circuitBreaker)
+	}
+
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, topic string, event Event) (err error) {
+			breaker := breakerFor(topic)
+
+			allowed, remaining := breaker.allow(time.Now())
+			if !allowed {
+				return RetryAfter(fmt.Errorf("%w: 主题(%s)", ErrCircuitOpen, topic), remaining)
+			}
+
+			// 处理函数发生 panic 时同样记录为失败
+			failed := true
+			defer func() {
+				breaker.record(failed, time.Now())
+			}()
+
+			err = next(ctx, topic, event)
+			failed = err != nil
+			return err
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package ebus
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	// 确保实现了 Clock 接口
+	_ Clock = SystemClock{}
+	_ Clock = ClockFunc(nil)
+)
+
+// Clock 时钟接口
+//
+// 用于元数据校验和元数据构建, 测试时可以替换为固定时钟
+type Clock interface {
+
+	// Now 当前时间
+	Now() time.Time
+}
+
+// SystemClock 系统时钟
+type SystemClock struct{}
+
+// Now 当前时间
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// ClockFunc 函数形式的时钟
+type ClockFunc func() time.Time
+
+// Now 当前时间
+func (fn ClockFunc) Now() time.Time {
+	return fn()
+}
+
+// FixedClock 固定时钟, 总是返回指定的时间
+func FixedClock(t time.Time) Clock {
+	return ClockFunc(func() time.Time {
+		return t
+	})
+}
+
+var (
+	defaultClock     Clock = SystemClock{}  // 默认时钟
+	defaultClockLock       = sync.RWMutex{} // 默认时钟锁
+)
+
+// DefaultClock 获取默认时钟
+func DefaultClock() Clock {
+	defaultClockLock.RLock()
+	defer defaultClockLock.RUnlock()
+
+	return defaultClock
+}
+
+// SetDefaultClock 设置默认时钟
+//
+// 传入 nil 表示恢复为系统时钟
+func SetDefaultClock(clock Clock) {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
+	defaultClockLock.Lock()
+	defer defaultClockLock.Unlock()
+
+	defaultClock = clock
+}
@@ -0,0 +1,231 @@
+package ebus
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+var (
+	// 确保实现了 Codec 接口
+	_ Codec = CloudEventsCodec{}
+)
+
+const (
+	// ContentTypeCloudEventsJson CloudEvents 结构化模式的内容类型
+	ContentTypeCloudEventsJson = "application/cloudevents+json"
+
+	// CloudEventsSpecVersion 支持的 CloudEvents 规范版本
+	CloudEventsSpecVersion = "1.0"
+)
+
+// CloudEvents 扩展属性的名称
+//
+// 元数据中没有对应 CloudEvents 上下文属性的字段, 使用扩展属性传递
+// partitionkey 来自 CloudEvents 的 Partitioning 扩展
+const (
+	cloudEventsCorrelationId = "correlationid"
+	cloudEventsCausationId   = "causationid"
+	cloudEventsTenantId      = "tenantid"
+	cloudEventsPartitionKey  = "partitionkey"
+)
+
+// cloudEventsAttributes CloudEvents 规范定义的上下文属性, 以及元数据占用的扩展属性
+var cloudEventsAttributes = map[string]struct{}{
+	"specversion":            {},
+	"id":                     {},
+	"source":                 {},
+	"type":                   {},
+	"subject":                {},
+	"time":                   {},
+	"dataschema":             {},
+	"datacontenttype":        {},
+	"data":                   {},
+	"data_base64":            {},
+	cloudEventsCorrelationId: {},
+	cloudEventsCausationId:   {},
+	cloudEventsTenantId:      {},
+	cloudEventsPartitionKey:  {},
+}
+
+// CloudEventsCodec CloudEvents 1.0 结构化模式 (JSON) 的编解码器
+//
+// 事件信封编码为 CloudEvents 事件, 用于与 Knative 等 CloudEvents 工具互通:
+//   - SchemaVersion → dataschema
+//   - EventSource   → source
+//   - EventType     → type
+//   - EventId       → id
+//   - EventTime     → time
+//   - 关联ID, 因果ID, 租户ID, 分区键和扩展属性 → CloudEvents 扩展属性
+//
+// 扩展属性的名称必须符合 CloudEvents 规范 (小写字母和数字), 否则发布失败
+// 订阅者总是支持 CloudEvents 结构化模式, 不需要配置
+type CloudEventsCodec struct{}
+
+// ContentType 消息的内容类型
+func (codec CloudEventsCodec) ContentType() string {
+	return ContentTypeCloudEventsJson
+}
+
+// Marshal 把事件信封编码为 CloudEvents 事件, 事件编码为 JSON
+func (codec CloudEventsCodec) Marshal(value any) ([]byte, error) {
+	envelope, ok := value.(*Envelope)
+	if !ok {
+		return json.Marshal(value)
+	}
+
+	attributes, err := metadataToCloudEvents(envelope.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	event := make(map[string]any, len(attributes)+2)
+	for name, value := range attributes {
+		event[name] = value
+	}
+
+	event["datacontenttype"] = ContentTypeJson
+	if len(envelope.Payload) > 0 {
+		event["data"] = envelope.Payload
+	}
+
+	return json.Marshal(event)
+}
+
+// Unmarshal 从 CloudEvents 事件解码事件信封, 从 JSON 解码事件
+func (codec CloudEventsCodec) Unmarshal(data []byte, value any) error {
+	envelope, ok := value.(*Envelope)
+	if !ok {
+		return json.Unmarshal(data, value)
+	}
+
+	var event map[string]json.RawMessage
+	if err := json.Unmarshal(data, &event); err != nil {
+		return err
+	}
+
+	// 上下文属性都是字符串, 非字符串的扩展属性保留 JSON 形式
+	attributes := make(map[string]string, len(event))
+	for name, raw := range event {
+		if name == "data" || name == "data_base64" {
+			continue
+		}
+
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+		}
+		attributes[name] = value
+	}
+
+	metadata, err := metadataFromCloudEvents(attributes)
+	if err != nil {
+		return err
+	}
+
+	envelope.Metadata = metadata
+	envelope.Payload = nil
+
+	if raw, exists := event["data_base64"]; exists {
+		var payload []byte
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return fmt.Errorf("ebus: CloudEvents 属性(data_base64)解码失败: %w", err)
+		}
+		envelope.Payload = payload
+	} else if raw, exists := event["data"]; exists && string(raw) != "null" {
+		envelope.Payload = raw
+	}
+
+	return nil
+}
+
+// metadataToCloudEvents 把元数据转换为 CloudEvents 属性 (不包括 data 和 datacontenttype)
+func metadataToCloudEvents(metadata *Metadata) (map[string]string, error) {
+	if metadata == nil {
+		return nil, fmt.Errorf("ebus: 事件信封缺少元数据")
+	}
+
+	attributes := map[string]string{
+		"specversion": CloudEventsSpecVersion,
+		"id":          metadata.EventId,
+		"source":      string(metadata.EventSource),
+		"type":        string(metadata.EventType),
+		"dataschema":  string(metadata.SchemaVersion),
+	}
+
+	if metadata.EventTime > 0 {
+		attributes["time"] = time.Unix(metadata.EventTime, 0).UTC().Format(time.RFC3339)
+	}
+
+	for name, value := range map[string]string{
+		cloudEventsCorrelationId: metadata.CorrelationId,
+		cloudEventsCausationId:   metadata.CausationId,
+		cloudEventsTenantId:      metadata.TenantId,
+		cloudEventsPartitionKey:  metadata.PartitionKey,
+	} {
+		if len(value) > 0 {
+			attributes[name] = value
+		}
+	}
+
+	for name, value := range metadata.Extensions {
+		if !isCloudEventsAttributeName(name) {
+			return nil, fmt.Errorf("ebus: 扩展属性(%s)的名称不符合 CloudEvents 规范", name)
+		}
+		if _, reserved := cloudEventsAttributes[name]; reserved {
+			return nil, fmt.Errorf("ebus: 扩展属性(%s)与 CloudEvents 属性冲突", name)
+		}
+		attributes[name] = value
+	}
+
+	return attributes, nil
+}
+
+// metadataFromCloudEvents 从 CloudEvents 属性解析元数据, 其余的属性作为扩展属性
+func metadataFromCloudEvents(attributes map[string]string) (*Metadata, error) {
+	if specVersion := attributes["specversion"]; specVersion != CloudEventsSpecVersion {
+		return nil, fmt.Errorf("ebus: 不支持的 CloudEvents 规范版本: %s", specVersion)
+	}
+
+	metadata := &Metadata{
+		SchemaVersion: SchemaVersion(attributes["dataschema"]),
+		EventId:       attributes["id"],
+		EventSource:   EventSource(attributes["source"]),
+		EventType:     EventType(attributes["type"]),
+		CorrelationId: attributes[cloudEventsCorrelationId],
+		CausationId:   attributes[cloudEventsCausationId],
+		TenantId:      attributes[cloudEventsTenantId],
+		PartitionKey:  attributes[cloudEventsPartitionKey],
+	}
+
+	if value := attributes["time"]; len(value) > 0 {
+		eventTime, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("ebus: CloudEvents 属性(time)解析失败: %w", err)
+		}
+		metadata.EventTime = eventTime.Unix()
+	}
+
+	for name, value := range attributes {
+		if _, reserved := cloudEventsAttributes[name]; !reserved {
+			metadata.SetExtension(name, value)
+		}
+	}
+
+	metadata.Normalize()
+	return metadata, nil
+}
+
+// isCloudEventsAttributeName 名称是否符合 CloudEvents 规范: 小写字母和数字
+func isCloudEventsAttributeName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+
+	for _, char := range name {
+		if (char < 'a' || char > 'z') && (char < '0' || char > '9') {
+			return false
+		}
+	}
+	return true
+}
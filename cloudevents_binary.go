@@ -0,0 +1,93 @@
+package ebus
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+var (
+	// 确保实现了 PayloadOnlyCodec 接口
+	_ PayloadOnlyCodec = CloudEventsBinaryCodec{}
+)
+
+const (
+	// HeaderCloudEventsPrefix CloudEvents 二进制模式的消息头前缀
+	HeaderCloudEventsPrefix = "ce-"
+
+	// HeaderCloudEventsSpecVersion CloudEvents 二进制模式的规范版本消息头, 订阅者以此识别二进制模式
+	HeaderCloudEventsSpecVersion = HeaderCloudEventsPrefix + "specversion"
+)
+
+// headerMetadataCodec 自行在消息头中保存元数据的编解码器
+//
+// 消息体只包含事件负载时, 默认使用 x-event-* 消息头保存元数据 (参考 PeekMetadataFromHeaders)
+type headerMetadataCodec interface {
+	PayloadOnlyCodec
+
+	// metadataToHeaders 把元数据转换为消息头
+	metadataToHeaders(metadata *Metadata) (map[string]string, error)
+
+	// metadataFromHeaders 从消息头解析元数据
+	metadataFromHeaders(headers map[string]any) (*Metadata, error)
+}
+
+// CloudEventsBinaryCodec CloudEvents 1.0 二进制模式的编解码器
+//
+// 元数据作为 ce-* 消息头传递 (属性的映射参考 CloudEventsCodec), 消息体只包含 JSON 负载
+// 避免事件信封的二次编码, 便于与基于 HTTP 的 CloudEvents 接收端互通
+// 订阅者按照 ce-specversion 消息头识别二进制模式, 不需要配置
+type CloudEventsBinaryCodec struct{}
+
+// ContentType 消息的内容类型, 即负载的内容类型 (datacontenttype)
+func (codec CloudEventsBinaryCodec) ContentType() string {
+	return ContentTypeJson
+}
+
+// Marshal 把事件编码为 JSON
+func (codec CloudEventsBinaryCodec) Marshal(value any) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+// Unmarshal 从 JSON 解码事件
+func (codec CloudEventsBinaryCodec) Unmarshal(data []byte, value any) error {
+	return json.Unmarshal(data, value)
+}
+
+// PayloadOnly 标记消息体只包含事件负载
+func (codec CloudEventsBinaryCodec) PayloadOnly() {}
+
+// metadataToHeaders 把元数据转换为 ce-* 消息头
+func (codec CloudEventsBinaryCodec) metadataToHeaders(metadata *Metadata) (map[string]string, error) {
+	attributes, err := metadataToCloudEvents(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]string, len(attributes))
+	for name, value := range attributes {
+		headers[HeaderCloudEventsPrefix+name] = value
+	}
+	return headers, nil
+}
+
+// metadataFromHeaders 从 ce-* 消息头解析元数据
+func (codec CloudEventsBinaryCodec) metadataFromHeaders(headers map[string]any) (*Metadata, error) {
+	attributes := make(map[string]string)
+	for key, value := range headers {
+		name, ok := strings.CutPrefix(strings.ToLower(key), HeaderCloudEventsPrefix)
+		if !ok {
+			continue
+		}
+		if str, ok := value.(string); ok {
+			attributes[name] = str
+		}
+	}
+
+	return metadataFromCloudEvents(attributes)
+}
+
+// isCloudEventsBinary 消息是否为 CloudEvents 二进制模式
+func isCloudEventsBinary(headers map[string]any) bool {
+	_, exists := headers[HeaderCloudEventsSpecVersion]
+	return exists
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+	"unicode"
+
+	"github.com/nf5lab/ebus"
+	"gopkg.in/yaml.v3"
+)
+
+// Definition 事件定义文件
+type Definition struct {
+	Package string            `yaml:"package"` // 生成代码的包名
+	Source  string            `yaml:"source"`  // 默认的事件来源
+	Events  []EventDefinition `yaml:"events"`  // 事件列表
+}
+
+// EventDefinition 事件定义
+type EventDefinition struct {
+	Name    string            `yaml:"name"`    // 结构体名称
+	Source  string            `yaml:"source"`  // 事件来源, 为空时使用文件的 source
+	Type    string            `yaml:"type"`    // 事件类型
+	Version string            `yaml:"version"` // 模型版本
+	Doc     string            `yaml:"doc"`     // 注释
+	Fields  []FieldDefinition `yaml:"fields"`  // 负载字段
+}
+
+// FieldDefinition 负载字段定义
+type FieldDefinition struct {
+	Name      string `yaml:"name"`      // 字段名称
+	Type      string `yaml:"type"`      // Go 类型, 例如 string, int64, []string, time.Time
+	JSON      string `yaml:"json"`      // JSON 名称, 为空时使用首字母小写的字段名称
+	Required  bool   `yaml:"required"`  // 是否必需, Validate 检查必需字段不为零值
+	OmitEmpty bool   `yaml:"omitempty"` // JSON 是否省略零值
+	Doc       string `yaml:"doc"`       // 注释
+}
+
+// parseDefinition 解析并校验事件定义, JSON 是 YAML 的子集, 两种格式使用同一个解析器
+func parseDefinition(data []byte) (*Definition, error) {
+	var def Definition
+	if err := yaml.Unmarshal(data, &def); err != nil {
+		return nil, fmt.Errorf("解析事件定义失败: %w", err)
+	}
+
+	if len(def.Events) == 0 {
+		return nil, fmt.Errorf("没有定义事件")
+	}
+
+	names := make(map[string]bool)
+	keys := make(map[string]bool)
+
+	for i := range def.Events {
+		event := &def.Events[i]
+		if len(event.Source) == 0 {
+			event.Source = def.Source
+		}
+
+		if !isExportedIdent(event.Name) {
+			return nil, fmt.Errorf("事件[%d]的名称(%s)不是导出的 Go 标识符", i, event.Name)
+		}
+
+		// 与注册表使用相同的规范化规则, 注册时会冲突的事件在生成时就报错
+		scmVersion := ebus.SchemaVersion(event.Version).Normalize()
+		evtSource := ebus.EventSource(event.Source).Normalize()
+		evtType := ebus.EventType(event.Type).Normalize()
+		if scmVersion.IsEmpty() || evtSource.IsEmpty() || evtType.IsEmpty() {
+			return nil, fmt.Errorf("事件(%s)缺少 source, type 或者 version", event.Name)
+		}
+
+		if names[event.Name] {
+			return nil, fmt.Errorf("事件名称(%s)重复", event.Name)
+		}
+		names[event.Name] = true
+
+		key := string(scmVersion) + "|" + string(evtSource) + "|" + string(evtType)
+		if keys[key] {
+			return nil, fmt.Errorf("事件(%s)的模型版本, 事件来源和事件类型与其它事件重复", event.Name)
+		}
+		keys[key] = true
+
+		fields := map[string]bool{"Meta": true}
+		for j := range event.Fields {
+			field := &event.Fields[j]
+			if !isExportedIdent(field.Name) {
+				return nil, fmt.Errorf("事件(%s)字段[%d]的名称(%s)不是导出的 Go 标识符", event.Name, j, field.Name)
+			}
+			if fields[field.Name] {
+				return nil, fmt.Errorf("事件(%s)字段名称(%s)重复", event.Name, field.Name)
+			}
+			fields[field.Name] = true
+
+			if len(strings.TrimSpace(field.Type)) == 0 {
+				return nil, fmt.Errorf("事件(%s)字段(%s)缺少 type", event.Name, field.Name)
+			}
+			if len(field.JSON) == 0 {
+				field.JSON = lowerFirst(field.Name)
+			}
+		}
+	}
+
+	return &def, nil
+}
+
+// isExportedIdent 是否是导出的 Go 标识符
+func isExportedIdent(name string) bool {
+	return token.IsIdentifier(name) && token.IsExported(name)
+}
+
+// lowerFirst 首字母小写
+func lowerFirst(name string) string {
+	runes := []rune(name)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
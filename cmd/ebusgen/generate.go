@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"text/template"
+)
+
+// generate 生成 Go 代码
+func generate(def *Definition) ([]byte, error) {
+	if len(def.Package) == 0 {
+		return nil, fmt.Errorf("缺少包名, 在定义文件中设置 package 或者使用 -package 参数")
+	}
+
+	imports := map[string]bool{"fmt": true}
+	for _, event := range def.Events {
+		for _, field := range event.Fields {
+			if field.Required && field.Type == "string" {
+				imports["strings"] = true
+			}
+			if strings.Contains(field.Type, "time.") {
+				imports["time"] = true
+			}
+			if strings.Contains(field.Type, "json.") {
+				imports["encoding/json"] = true
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := codeTemplate.Execute(&buf, map[string]any{
+		"Definition": def,
+		"Imports":    imports,
+	}); err != nil {
+		return nil, err
+	}
+
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("格式化生成的代码失败 (字段类型是否有效?): %w", err)
+	}
+	return code, nil
+}
+
+// requiredCheck 必需字段的零值检查表达式, 无法判断零值的类型返回空
+func requiredCheck(field FieldDefinition) string {
+	name := "evt." + field.Name
+
+	switch typ := field.Type; {
+	case typ == "string":
+		return fmt.Sprintf("len(strings.TrimSpace(%s)) == 0", name)
+	case strings.HasPrefix(typ, "[]"), strings.HasPrefix(typ, "map["):
+		return fmt.Sprintf("len(%s) == 0", name)
+	case strings.HasPrefix(typ, "*"):
+		return fmt.Sprintf("%s == nil", name)
+	case typ == "time.Time":
+		return fmt.Sprintf("%s.IsZero()", name)
+	case strings.HasPrefix(typ, "int"), strings.HasPrefix(typ, "uint"), strings.HasPrefix(typ, "float"):
+		return fmt.Sprintf("%s == 0", name)
+	default:
+		return ""
+	}
+}
+
+// jsonTag 字段的 JSON 标签
+func jsonTag(field FieldDefinition) string {
+	if field.OmitEmpty {
+		return fmt.Sprintf("`json:%q`", field.JSON+",omitempty")
+	}
+	return fmt.Sprintf("`json:%q`", field.JSON)
+}
+
+// comment 把多行文本转换为注释
+func comment(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	return "// " + strings.Join(lines, "\n// ")
+}
+
+var codeTemplate = template.Must(template.New("ebusgen").Funcs(template.FuncMap{
+	"requiredCheck": requiredCheck,
+	"jsonTag":       jsonTag,
+	"comment":       comment,
+	"inline":        func(text string) string { return strings.Join(strings.Fields(text), " ") },
+}).Parse(`// Code generated by ebusgen. DO NOT EDIT.
+
+package {{ .Definition.Package }}
+
+import (
+{{- range $path, $_ := .Imports }}
+	"{{ $path }}"
+{{- end }}
+
+	"github.com/nf5lab/ebus"
+)
+
+// 事件的标识
+const (
+{{- range .Definition.Events }}
+	{{ .Name }}SchemaVersion ebus.SchemaVersion = {{ printf "%q" .Version }}
+	{{ .Name }}Source        ebus.EventSource   = {{ printf "%q" .Source }}
+	{{ .Name }}Type          ebus.EventType     = {{ printf "%q" .Type }}
+{{- end }}
+)
+
+func init() {
+{{- range .Definition.Events }}
+	ebus.MustRegisterEventFactory({{ .Name }}SchemaVersion, {{ .Name }}Source, {{ .Name }}Type, func() (ebus.Event, error) {
+		return &{{ .Name }}{}, nil
+	})
+{{- end }}
+}
+{{ range .Definition.Events }}
+{{ if .Doc }}{{ comment (printf "%s %s" .Name .Doc) }}{{ else }}// {{ .Name }} 事件 {{ .Source }}/{{ .Type }} ({{ .Version }}){{ end }}
+type {{ .Name }} struct {
+	Meta *ebus.Metadata ` + "`json:\"metadata\"`" + ` // 事件元数据
+{{- if .Fields }}
+{{ end }}
+{{- range .Fields }}
+	{{ .Name }} {{ .Type }} {{ jsonTag . }}{{ if .Doc }} // {{ inline .Doc }}{{ end }}
+{{- end }}
+}
+
+// New{{ .Name }} 创建事件, 元数据使用事件的标识
+func New{{ .Name }}(opts ...ebus.MetadataOption) *{{ .Name }} {
+	return &{{ .Name }}{
+		Meta: ebus.NewMetadata({{ .Name }}Source, {{ .Name }}Type, {{ .Name }}SchemaVersion, opts...),
+	}
+}
+
+// Metadata 获取事件元数据
+func (evt *{{ .Name }}) Metadata() *ebus.Metadata {
+	return evt.Meta
+}
+
+// Validate 验证事件是否有效
+func (evt *{{ .Name }}) Validate() error {
+	if evt.Meta == nil {
+		return fmt.Errorf("{{ $.Definition.Package }}: 事件元数据不能为空")
+	}
+{{ range .Fields }}{{ if .Required }}{{ $check := requiredCheck . }}{{ if $check }}
+	if {{ $check }} {
+		return fmt.Errorf("{{ $.Definition.Package }}: 字段({{ .JSON }})不能为空")
+	}
+{{ end }}{{ end }}{{ end }}
+	return nil
+}
+{{ end }}`))
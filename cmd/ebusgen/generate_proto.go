@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"text/template"
+)
+
+// generateProto 为标注了 ebus 选项的 protobuf 消息生成事件包装类型和注册代码
+func generateProto(def *ProtoDefinition) ([]byte, error) {
+	if len(def.Package) == 0 {
+		return nil, fmt.Errorf("缺少包名, 在 .proto 文件中设置 go_package 或者使用 -package 参数")
+	}
+
+	var buf bytes.Buffer
+	if err := protoTemplate.Execute(&buf, def); err != nil {
+		return nil, err
+	}
+
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("格式化生成的代码失败: %w", err)
+	}
+	return code, nil
+}
+
+var protoTemplate = template.Must(template.New("ebusgen-proto").Parse(`// Code generated by ebusgen. DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+	"github.com/nf5lab/ebus"
+	protoebus "github.com/nf5lab/ebus/codec/proto"
+)
+
+// 事件的标识
+const (
+{{- range .Events }}
+	{{ .Message }}SchemaVersion ebus.SchemaVersion = {{ printf "%q" .Version }}
+	{{ .Message }}Source        ebus.EventSource   = {{ printf "%q" .Source }}
+	{{ .Message }}Type          ebus.EventType     = {{ printf "%q" .Type }}
+{{- end }}
+)
+
+func init() {
+{{- range .Events }}
+	protoebus.MustRegisterEvent[*{{ .Message }}]({{ .Message }}SchemaVersion, {{ .Message }}Source, {{ .Message }}Type)
+{{- end }}
+}
+{{ range .Events }}
+// {{ .Message }}Event 包装 {{ .Message }} 的事件 {{ .Source }}/{{ .Type }} ({{ .Version }})
+type {{ .Message }}Event = protoebus.Event[*{{ .Message }}]
+
+// New{{ .Message }}Event 创建事件, 元数据使用事件的标识
+func New{{ .Message }}Event(message *{{ .Message }}, opts ...ebus.MetadataOption) *{{ .Message }}Event {
+	return protoebus.NewEvent(ebus.NewMetadata({{ .Message }}Source, {{ .Message }}Type, {{ .Message }}SchemaVersion, opts...), message)
+}
+{{ end }}`))
@@ -0,0 +1,10 @@
+module github.com/nf5lab/ebus/cmd/ebusgen
+
+go 1.24.0
+
+require (
+	github.com/nf5lab/ebus v0.0.0-20261016011243-5686471bbfc5
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require github.com/nf5lab/broker v0.4.0 // indirect
@@ -0,0 +1,121 @@
+// ebusgen 根据事件定义文件 (YAML 或者 JSON) 生成事件结构体
+//
+// 生成的代码包括事件结构体, Metadata 和 Validate 方法, 以及注册事件工厂的 init 函数
+// 各个服务使用同一份定义生成代码, 避免手写的样板代码逐渐偏离
+//
+// ebusgen 是独立的模块 (github.com/nf5lab/ebus/cmd/ebusgen), 服务的模块依赖 ebus 并不会引入它,
+// 需要作为工具依赖添加到服务的 go.mod 中 (Go 1.24), 版本与其它依赖一起记录:
+//
+//	go get -tool github.com/nf5lab/ebus/cmd/ebusgen@latest
+//
+// 用法:
+//
+//	//go:generate go tool ebusgen -in events.yaml -out events_gen.go
+//
+// 不添加工具依赖时, 也可以直接运行指定的版本 (服务的 go.mod 仍然需要依赖 ebus, 生成的代码导入了 ebus):
+//
+//	//go:generate go run github.com/nf5lab/ebus/cmd/ebusgen@latest -in events.yaml -out events_gen.go
+//
+// 也可以读取标注了 ebus 选项的 .proto 文件 (参考 codec/proto/ebus/options.proto),
+// 为每个标注的消息生成 codec/proto 的事件包装类型和注册代码, 生成的文件应该与 protoc-gen-go 的输出放在同一个包中:
+//
+//	//go:generate go tool ebusgen -in order.proto -out order_ebus.go
+//
+// 定义文件示例:
+//
+//	package: orderevents
+//	source: order
+//	events:
+//	  - name: OrderCreated
+//	    type: created
+//	    version: v1
+//	    doc: 订单已创建
+//	    fields:
+//	      - name: OrderId
+//	        type: string
+//	        required: true
+//	        doc: 订单ID
+//	      - name: Amount
+//	        type: int64
+//	        json: amountInCents
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func main() {
+	in := flag.String("in", "", "事件定义文件 (YAML 或者 JSON)")
+	out := flag.String("out", "", "生成的 Go 文件, 为空时输出到标准输出")
+	pkg := flag.String("package", "", "生成代码的包名, 覆盖定义文件中的 package, 为空时使用 $GOPACKAGE")
+	flag.Parse()
+
+	if err := run(*in, *out, *pkg); err != nil {
+		fmt.Fprintln(os.Stderr, "ebusgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(in string, out string, pkg string) error {
+	if len(in) == 0 {
+		return fmt.Errorf("缺少参数 -in")
+	}
+
+	data, err := os.ReadFile(in)
+	if err != nil {
+		return err
+	}
+
+	var code []byte
+	if strings.HasSuffix(in, ".proto") {
+		code, err = generateFromProto(data, pkg)
+	} else {
+		code, err = generateFromDefinition(data, pkg)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+
+	if len(out) == 0 {
+		_, err = os.Stdout.Write(code)
+		return err
+	}
+	return os.WriteFile(out, code, 0o644)
+}
+
+// generateFromDefinition 从事件定义文件生成代码
+func generateFromDefinition(data []byte, pkg string) ([]byte, error) {
+	def, err := parseDefinition(data)
+	if err != nil {
+		return nil, err
+	}
+
+	def.Package = packageName(pkg, def.Package)
+	return generate(def)
+}
+
+// generateFromProto 从 .proto 文件生成代码
+func generateFromProto(data []byte, pkg string) ([]byte, error) {
+	def, err := parseProtoDefinition(data)
+	if err != nil {
+		return nil, err
+	}
+
+	def.Package = packageName(pkg, def.Package)
+	return generateProto(def)
+}
+
+// packageName 生成代码的包名, 依次使用 -package 参数, 定义文件中的包名, $GOPACKAGE
+func packageName(flagValue string, defined string) string {
+	switch {
+	case len(flagValue) > 0:
+		return flagValue
+	case len(defined) > 0:
+		return defined
+	default:
+		return os.Getenv("GOPACKAGE")
+	}
+}
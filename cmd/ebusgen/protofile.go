@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ProtoDefinition 从 .proto 文件中读取的事件定义
+type ProtoDefinition struct {
+	Package string       // 生成代码的包名, 默认取 go_package
+	Events  []ProtoEvent // 标注了 ebus 选项的消息
+}
+
+// ProtoEvent 标注了 ebus 选项的 protobuf 消息
+type ProtoEvent struct {
+	Message string // protoc-gen-go 生成的 Go 类型名称, 嵌套消息为 Outer_Inner
+	Source  string // 事件来源
+	Type    string // 事件类型
+	Version string // 模型版本
+}
+
+// protoBlock .proto 文件中的语法块
+type protoBlock struct {
+	message string            // 消息名称, 其它语法块为空
+	options map[string]string // 消息上的 ebus 选项
+}
+
+// parseProtoDefinition 解析 .proto 文件中标注了 ebus 选项的消息
+//
+// 只识别 option (ebus.xxx) = "..."; 形式的选项, 不需要 protoc
+func parseProtoDefinition(data []byte) (*ProtoDefinition, error) {
+	tokens, err := tokenizeProto(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	def := &ProtoDefinition{}
+	defaultSource := ""
+
+	var (
+		stack    []*protoBlock
+		messages []*protoBlock
+	)
+
+	for i := 0; i < len(tokens); i++ {
+		switch token := tokens[i]; token {
+		case "message":
+			// 字段名称也可能是 message, 只有 message Name { 才是消息定义
+			if i+2 >= len(tokens) || tokens[i+2] != "{" {
+				continue
+			}
+			name := tokens[i+1]
+			if len(stack) > 0 && len(stack[len(stack)-1].message) > 0 {
+				name = stack[len(stack)-1].message + "_" + name
+			}
+			block := &protoBlock{message: name, options: make(map[string]string)}
+			stack = append(stack, block)
+			messages = append(messages, block)
+			i += 2
+
+		case "{":
+			stack = append(stack, &protoBlock{})
+
+		case "}":
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("括号不匹配")
+			}
+			stack = stack[:len(stack)-1]
+
+		case "option":
+			name, value, next, ok := parseProtoOption(tokens, i+1)
+			if !ok {
+				continue
+			}
+			i = next
+
+			if len(stack) == 0 {
+				switch name {
+				case "go_package":
+					def.Package = goPackageName(value)
+				case "(ebus.default_source)":
+					defaultSource = value
+				}
+				continue
+			}
+
+			if block := stack[len(stack)-1]; len(block.message) > 0 {
+				if option, ok := strings.CutPrefix(name, "(ebus."); ok {
+					block.options[strings.TrimSuffix(option, ")")] = value
+				}
+			}
+		}
+	}
+
+	if len(stack) != 0 {
+		return nil, fmt.Errorf("括号不匹配")
+	}
+
+	for _, block := range messages {
+		if len(block.options) == 0 {
+			continue
+		}
+
+		event := ProtoEvent{
+			Message: block.message,
+			Source:  block.options["source"],
+			Type:    block.options["type"],
+			Version: block.options["version"],
+		}
+		if len(event.Source) == 0 {
+			event.Source = defaultSource
+		}
+
+		if len(event.Source) == 0 || len(event.Type) == 0 || len(event.Version) == 0 {
+			return nil, fmt.Errorf("消息(%s)缺少 ebus.source, ebus.type 或者 ebus.version", block.message)
+		}
+		def.Events = append(def.Events, event)
+	}
+
+	if len(def.Events) == 0 {
+		return nil, fmt.Errorf("没有标注了 ebus 选项的消息")
+	}
+
+	return def, nil
+}
+
+// parseProtoOption 解析 name = "value"; 形式的选项, 返回最后一个 token 的位置
+func parseProtoOption(tokens []string, i int) (name string, value string, next int, ok bool) {
+	switch {
+	case i+3 < len(tokens) && tokens[i] == "(" && tokens[i+2] == ")":
+		name = "(" + tokens[i+1] + ")"
+		i += 3
+	case i < len(tokens):
+		name = tokens[i]
+		i++
+	default:
+		return "", "", 0, false
+	}
+
+	if i+2 >= len(tokens) || tokens[i] != "=" || !strings.HasPrefix(tokens[i+1], `"`) || tokens[i+2] != ";" {
+		return "", "", 0, false
+	}
+
+	return name, strings.Trim(tokens[i+1], `"`), i + 2, true
+}
+
+// goPackageName 从 go_package 选项中取包名, 例如 "example.com/orderpb;orderpb" 或者 "example.com/orderpb"
+func goPackageName(goPackage string) string {
+	if _, name, ok := strings.Cut(goPackage, ";"); ok {
+		return name
+	}
+	return goPackage[strings.LastIndex(goPackage, "/")+1:]
+}
+
+// tokenizeProto 把 .proto 文件拆分为 token, 忽略注释
+func tokenizeProto(src string) ([]string, error) {
+	var tokens []string
+
+	for i := 0; i < len(src); {
+		char := src[i]
+
+		switch {
+		case unicode.IsSpace(rune(char)):
+			i++
+
+		case strings.HasPrefix(src[i:], "//"):
+			if end := strings.IndexByte(src[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(src)
+			}
+
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("注释没有结束")
+			}
+			i += end + 4
+
+		case char == '"' || char == '\'':
+			end := i + 1
+			for end < len(src) && src[end] != char {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("字符串没有结束")
+			}
+			tokens = append(tokens, `"`+src[i+1:end]+`"`)
+			i = end + 1
+
+		case isProtoIdentChar(char):
+			end := i
+			for end < len(src) && isProtoIdentChar(src[end]) {
+				end++
+			}
+			tokens = append(tokens, src[i:end])
+			i = end
+
+		default:
+			tokens = append(tokens, string(char))
+			i++
+		}
+	}
+
+	return tokens, nil
+}
+
+func isProtoIdentChar(char byte) bool {
+	return char == '_' || char == '.' || (char >= '0' && char <= '9') || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z')
+}
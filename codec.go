@@ -0,0 +1,157 @@
+package ebus
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+var (
+	// 确保实现了 Codec 接口
+	_ Codec = JSONCodec{}
+)
+
+// Codec 编解码器
+//
+// 发布者先编码事件作为事件信封的负载, 再编码事件信封作为消息体, 订阅者按照相反的顺序解码
+// 订阅者按照消息的内容类型选择编解码器
+// 事件容器 (PublishPacked) 只支持 JSON
+type Codec interface {
+
+	// ContentType 消息的内容类型
+	ContentType() string
+
+	// Marshal 编码事件或者事件信封 (*Envelope)
+	Marshal(value any) ([]byte, error)
+
+	// Unmarshal 解码事件或者事件信封 (*Envelope)
+	Unmarshal(data []byte, value any) error
+}
+
+// PayloadOnlyCodec 消息体只包含事件负载的编解码器
+//
+// 用于与不使用事件信封的系统互通 (例如 Kafka 的 Avro 管道)
+// 发布者不编码事件信封, 把元数据 (包括扩展属性) 写入消息头, 订阅者从消息头读取元数据 (参考 PeekMetadataFromHeaders)
+type PayloadOnlyCodec interface {
+	Codec
+
+	// PayloadOnly 标记消息体只包含事件负载
+	PayloadOnly()
+}
+
+// isPayloadOnlyCodec 编解码器的消息体是否只包含事件负载
+func isPayloadOnlyCodec(codec Codec) bool {
+	_, ok := codec.(PayloadOnlyCodec)
+	return ok
+}
+
+// decodeEnvelopeWith 使用编解码器解码事件信封
+//
+// 消息体只包含事件负载时, 从消息头读取元数据, 否则按照事件信封格式的版本解码负载
+// - headers 消息头
+// - data    消息体
+func decodeEnvelopeWith(codec Codec, headers map[string]any, data []byte) (*Envelope, error) {
+	if codec, ok := codec.(headerMetadataCodec); ok {
+		metadata, err := codec.metadataFromHeaders(headers)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrEnvelopeDecode, err)
+		}
+		return &Envelope{Metadata: metadata, Payload: data}, nil
+	}
+
+	if isPayloadOnlyCodec(codec) {
+		metadata, err := PeekMetadataFromHeaders(headers)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrEnvelopeDecode, err)
+		}
+		return &Envelope{Metadata: metadata, Payload: data}, nil
+	}
+
+	var envelope Envelope
+	if err := codec.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEnvelopeDecode, err)
+	}
+
+	// 事件信封中没有版本时 (例如 protobuf 事件信封), 使用消息头中的版本
+	if envelope.Version == EnvelopeVersionUnknown {
+		envelope.Version = envelopeVersionFromHeaders(headers)
+	}
+
+	if err := envelope.decodeVersion(codec); err != nil {
+		return nil, err
+	}
+
+	return &envelope, nil
+}
+
+// JSONCodec JSON 编解码器 (默认)
+type JSONCodec struct {
+	DisallowUnknownFields bool // 是否不允许未知字段 (参考 StrictDecode)
+}
+
+// ContentType 消息的内容类型
+func (codec JSONCodec) ContentType() string {
+	return ContentTypeJson
+}
+
+// Marshal 编码为 JSON
+func (codec JSONCodec) Marshal(value any) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+// Unmarshal 从 JSON 解码
+func (codec JSONCodec) Unmarshal(data []byte, value any) error {
+	if !codec.DisallowUnknownFields {
+		return json.Unmarshal(data, value)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(value); err != nil {
+		return err
+	}
+
+	if decoder.More() {
+		return fmt.Errorf("ebus: JSON 数据之后存在多余的内容")
+	}
+
+	return nil
+}
+
+// isJSONCodec 编解码器是否使用 JSON 格式
+func isJSONCodec(codec Codec) bool {
+	return strings.HasPrefix(strings.ToLower(codec.ContentType()), ContentTypeJson)
+}
+
+// codecFor 按照消息的内容类型选择解码使用的编解码器
+//
+// 依次匹配 Codec, Codecs, 最后总是支持 JSON 和 CloudEvents 结构化模式
+// - contentType 已经规范化的内容类型
+func (opts *Options) codecFor(contentType string) (Codec, bool) {
+	codecs := append([]Codec{opts.Codec}, opts.Codecs...)
+
+	for _, codec := range codecs {
+		if codec == nil || !strings.HasPrefix(contentType, strings.ToLower(codec.ContentType())) {
+			continue
+		}
+
+		if jsonCodec, ok := codec.(JSONCodec); ok && opts.StrictDecode {
+			jsonCodec.DisallowUnknownFields = true
+			return jsonCodec, true
+		}
+
+		return codec, true
+	}
+
+	if strings.HasPrefix(contentType, ContentTypeJson) {
+		return JSONCodec{DisallowUnknownFields: opts.StrictDecode}, true
+	}
+
+	if strings.HasPrefix(contentType, ContentTypeCloudEventsJson) {
+		return CloudEventsCodec{}, true
+	}
+
+	return nil, false
+}
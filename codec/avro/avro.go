@@ -0,0 +1,174 @@
+// Package avro 提供 ebus 的 Avro 编解码器
+//
+// 消息体使用 Confluent 的线格式: 魔数 0, 4 字节大端序的模式ID, Avro 二进制编码的事件
+// 消息体只包含事件负载, 元数据保存在消息头中, 可以与现有的 Kafka/Avro 管道以及 ksqlDB 互通
+// 模式通过模式注册中心解析, 主题按照 RecordNameStrategy 命名, 即记录的全名
+//
+// 事件需要实现 Event 接口提供 Avro 模式, 字段使用 avro 标签命名
+// 负载中不包含元数据, 事件需要实现 ebus.EnvelopeMetadataSetter 接收消息头中的元数据
+//
+// 使用方法:
+//
+//	codec := avro.NewCodec(avro.NewRegistryClient("http://localhost:8081", nil))
+//	publisher := ebus.NewPublisher(brokerPublisher, ebus.WithCodec(codec))
+//	subscriber := ebus.NewSubscriber(brokerSubscriber, ebus.WithCodec(codec))
+package avro
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	hamba "github.com/hamba/avro/v2"
+	"github.com/nf5lab/ebus"
+)
+
+const (
+	// ContentType Avro 消息的内容类型
+	ContentType = "avro/binary"
+
+	// magicByte Confluent 线格式的魔数
+	magicByte = 0
+
+	// headerSize Confluent 线格式的头部长度: 魔数 + 模式ID
+	headerSize = 5
+)
+
+var (
+	// 确保实现了 PayloadOnlyCodec 接口
+	_ ebus.PayloadOnlyCodec = (*Codec)(nil)
+)
+
+// Event 可以使用 Avro 编码的事件
+type Event interface {
+	ebus.Event
+
+	// AvroSchema 获取事件的 Avro 模式 (JSON 格式)
+	AvroSchema() string
+}
+
+// registeredSchema 已经注册的模式
+type registeredSchema struct {
+	id     int
+	schema hamba.Schema
+}
+
+// Codec Avro 编解码器
+type Codec struct {
+	registry SchemaRegistry
+
+	// 按照模式文本缓存已经注册的模式, 避免每个事件都请求模式注册中心
+	registered sync.Map // string -> *registeredSchema
+
+	// 按照模式ID缓存写入者的模式
+	writers sync.Map // int -> hamba.Schema
+}
+
+// NewCodec 创建 Avro 编解码器
+// - registry 模式注册中心客户端
+func NewCodec(registry SchemaRegistry) *Codec {
+	return &Codec{
+		registry: registry,
+	}
+}
+
+// ContentType 消息的内容类型
+func (codec *Codec) ContentType() string {
+	return ContentType
+}
+
+// PayloadOnly 标记消息体只包含事件负载
+func (codec *Codec) PayloadOnly() {}
+
+// Marshal 编码事件为 Confluent 线格式
+func (codec *Codec) Marshal(value any) ([]byte, error) {
+	event, ok := value.(Event)
+	if !ok {
+		return nil, fmt.Errorf("ebus: 不支持 Avro 编码的类型: %T", value)
+	}
+
+	registered, err := codec.register(event.AvroSchema())
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := hamba.Marshal(registered.schema, event)
+	if err != nil {
+		return nil, fmt.Errorf("ebus: Avro 编码失败: %w", err)
+	}
+
+	frame := make([]byte, headerSize, headerSize+len(data))
+	frame[0] = magicByte
+	binary.BigEndian.PutUint32(frame[1:headerSize], uint32(registered.id))
+	return append(frame, data...), nil
+}
+
+// Unmarshal 从 Confluent 线格式解码事件
+//
+// 使用写入者的模式解码, 事件中不存在的字段被忽略
+func (codec *Codec) Unmarshal(data []byte, value any) error {
+	if len(data) < headerSize || data[0] != magicByte {
+		return fmt.Errorf("ebus: 不是 Confluent Avro 线格式的数据")
+	}
+
+	id := int(binary.BigEndian.Uint32(data[1:headerSize]))
+
+	schema, err := codec.writerSchema(id)
+	if err != nil {
+		return err
+	}
+
+	if err := hamba.Unmarshal(schema, data[headerSize:], value); err != nil {
+		return fmt.Errorf("ebus: Avro 解码失败: %w", err)
+	}
+
+	return nil
+}
+
+// register 解析并注册模式
+func (codec *Codec) register(schemaText string) (*registeredSchema, error) {
+	if cached, ok := codec.registered.Load(schemaText); ok {
+		return cached.(*registeredSchema), nil
+	}
+
+	schema, err := hamba.Parse(schemaText)
+	if err != nil {
+		return nil, fmt.Errorf("ebus: Avro 模式无效: %w", err)
+	}
+
+	named, ok := schema.(hamba.NamedSchema)
+	if !ok {
+		return nil, fmt.Errorf("ebus: Avro 模式必须是命名的记录")
+	}
+
+	id, err := codec.registry.RegisterSchema(context.Background(), named.FullName(), schemaText)
+	if err != nil {
+		return nil, err
+	}
+
+	registered := &registeredSchema{id: id, schema: schema}
+	codec.registered.Store(schemaText, registered)
+	codec.writers.Store(id, schema)
+	return registered, nil
+}
+
+// writerSchema 获取写入者的模式
+func (codec *Codec) writerSchema(id int) (hamba.Schema, error) {
+	if cached, ok := codec.writers.Load(id); ok {
+		return cached.(hamba.Schema), nil
+	}
+
+	schemaText, err := codec.registry.GetSchema(context.Background(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := hamba.Parse(schemaText)
+	if err != nil {
+		return nil, fmt.Errorf("ebus: Avro 模式(%d)无效: %w", id, err)
+	}
+
+	codec.writers.Store(id, schema)
+	return schema, nil
+}
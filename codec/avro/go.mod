@@ -0,0 +1,16 @@
+module github.com/nf5lab/ebus/codec/avro
+
+go 1.24.0
+
+require (
+	github.com/hamba/avro/v2 v2.28.0
+	github.com/nf5lab/ebus v0.0.0-20261016011243-5686471bbfc5
+)
+
+require (
+	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/mitchellh/mapstructure v1.5.0 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	github.com/nf5lab/broker v0.4.0 // indirect
+)
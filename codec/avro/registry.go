@@ -0,0 +1,121 @@
+package avro
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SchemaRegistry 模式注册中心客户端
+type SchemaRegistry interface {
+
+	// GetSchema 按照模式ID获取模式
+	GetSchema(ctx context.Context, id int) (string, error)
+
+	// RegisterSchema 在主题下注册模式, 返回模式ID
+	//
+	// 模式已经注册时返回已有的模式ID
+	RegisterSchema(ctx context.Context, subject string, schema string) (int, error)
+}
+
+const (
+	// registryContentType 模式注册中心 REST API 的内容类型
+	registryContentType = "application/vnd.schemaregistry.v1+json"
+
+	// defaultRegistryTimeout 默认的请求超时时间
+	defaultRegistryTimeout = 10 * time.Second
+)
+
+// RegistryClient Confluent 模式注册中心的 REST 客户端
+type RegistryClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewRegistryClient 创建模式注册中心客户端
+// - baseURL    模式注册中心地址, 例如 http://localhost:8081
+// - httpClient HTTP 客户端, 为 nil 时使用默认超时的客户端
+func NewRegistryClient(baseURL string, httpClient *http.Client) *RegistryClient {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultRegistryTimeout}
+	}
+
+	return &RegistryClient{
+		baseURL:    strings.TrimRight(strings.TrimSpace(baseURL), "/"),
+		httpClient: httpClient,
+	}
+}
+
+// GetSchema 按照模式ID获取模式
+func (client *RegistryClient) GetSchema(ctx context.Context, id int) (string, error) {
+	var resp struct {
+		Schema string `json:"schema"`
+	}
+
+	if err := client.do(ctx, http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, &resp); err != nil {
+		return "", fmt.Errorf("ebus: 获取模式(%d)失败: %w", id, err)
+	}
+
+	return resp.Schema, nil
+}
+
+// RegisterSchema 在主题下注册模式, 返回模式ID
+func (client *RegistryClient) RegisterSchema(ctx context.Context, subject string, schema string) (int, error) {
+	req := struct {
+		Schema string `json:"schema"`
+	}{
+		Schema: schema,
+	}
+
+	var resp struct {
+		Id int `json:"id"`
+	}
+
+	path := "/subjects/" + url.PathEscape(subject) + "/versions"
+	if err := client.do(ctx, http.MethodPost, path, req, &resp); err != nil {
+		return 0, fmt.Errorf("ebus: 注册模式(%s)失败: %w", subject, err)
+	}
+
+	return resp.Id, nil
+}
+
+// do 发送请求并解码响应
+func (client *RegistryClient) do(ctx context.Context, method string, path string, body any, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, client.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", registryContentType)
+	if body != nil {
+		req.Header.Set("Content-Type", registryContentType)
+	}
+
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("模式注册中心返回 %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
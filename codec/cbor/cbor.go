@@ -0,0 +1,54 @@
+// Package cbor 提供 ebus 的 CBOR 编解码器
+//
+// 事件信封和负载都使用 CBOR 编码, 负载以字节串嵌入事件信封
+// 适用于 IoT 和边缘设备上的生产者, 避免在受限设备上解析 JSON 的开销
+// 结构体字段优先使用 cbor 标签命名, 没有 cbor 标签时使用 json 标签
+//
+// 使用方法:
+//
+//	publisher := ebus.NewPublisher(brokerPublisher, ebus.WithCodec(cbor.Codec{}))
+//	subscriber := ebus.NewSubscriber(brokerSubscriber, ebus.WithCodec(cbor.Codec{}))
+//
+// 订阅者按照消息的内容类型 (application/cbor) 选择编解码器, 同时仍然支持 JSON
+package cbor
+
+import (
+	"github.com/fxamacker/cbor/v2"
+	"github.com/nf5lab/ebus"
+)
+
+const (
+	// ContentType CBOR 消息的内容类型
+	ContentType = "application/cbor"
+)
+
+var (
+	// 确保实现了 Codec 接口
+	_ ebus.Codec = Codec{}
+)
+
+var (
+	// encMode 使用确定性编码, 相同的事件总是得到相同的字节
+	encMode, _ = cbor.CoreDetEncOptions().EncMode()
+
+	// decMode 默认的解码选项
+	decMode, _ = cbor.DecOptions{}.DecMode()
+)
+
+// Codec CBOR 编解码器
+type Codec struct{}
+
+// ContentType 消息的内容类型
+func (codec Codec) ContentType() string {
+	return ContentType
+}
+
+// Marshal 编码为 CBOR
+func (codec Codec) Marshal(value any) ([]byte, error) {
+	return encMode.Marshal(value)
+}
+
+// Unmarshal 从 CBOR 解码
+func (codec Codec) Unmarshal(data []byte, value any) error {
+	return decMode.Unmarshal(data, value)
+}
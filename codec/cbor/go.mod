@@ -0,0 +1,13 @@
+module github.com/nf5lab/ebus/codec/cbor
+
+go 1.24.0
+
+require (
+	github.com/fxamacker/cbor/v2 v2.8.0
+	github.com/nf5lab/ebus v0.0.0-20261016011243-5686471bbfc5
+)
+
+require (
+	github.com/nf5lab/broker v0.4.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+)
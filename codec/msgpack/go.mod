@@ -0,0 +1,13 @@
+module github.com/nf5lab/ebus/codec/msgpack
+
+go 1.24.0
+
+require (
+	github.com/nf5lab/ebus v0.0.0-20261016011243-5686471bbfc5
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+)
+
+require (
+	github.com/nf5lab/broker v0.4.0 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+)
@@ -0,0 +1,79 @@
+// Package msgpack 提供 ebus 的 MessagePack 编解码器
+//
+// 事件信封和负载都使用 MessagePack 编码, 负载以二进制嵌入事件信封, 适用于对带宽敏感的部署
+// 结构体字段使用 json 标签命名, 事件类型不需要额外的 msgpack 标签
+//
+// 使用方法:
+//
+//	publisher := ebus.NewPublisher(brokerPublisher, ebus.WithCodec(msgpack.Codec{}))
+//	subscriber := ebus.NewSubscriber(brokerSubscriber, ebus.WithCodec(msgpack.Codec{}))
+//
+// 订阅者按照消息的内容类型 (application/x-msgpack) 选择编解码器, 同时仍然支持 JSON
+package msgpack
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/nf5lab/ebus"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	// ContentType MessagePack 消息的内容类型
+	ContentType = "application/x-msgpack"
+
+	// structTag 字段命名使用的结构体标签
+	structTag = "json"
+)
+
+var (
+	// 确保实现了 Codec 接口
+	_ ebus.Codec = Codec{}
+)
+
+// encoder 可以复用的编码器
+type encoder struct {
+	buf bytes.Buffer
+	enc *msgpack.Encoder
+}
+
+var encoderPool = sync.Pool{
+	New: func() any {
+		e := &encoder{}
+		e.enc = msgpack.NewEncoder(&e.buf)
+		e.enc.SetCustomStructTag(structTag)
+		e.enc.UseCompactInts(true)
+		return e
+	},
+}
+
+// Codec MessagePack 编解码器
+type Codec struct{}
+
+// ContentType 消息的内容类型
+func (codec Codec) ContentType() string {
+	return ContentType
+}
+
+// Marshal 编码为 MessagePack
+func (codec Codec) Marshal(value any) ([]byte, error) {
+	e := encoderPool.Get().(*encoder)
+	defer encoderPool.Put(e)
+
+	// 编码器直接写入缓冲区, 只需要重置缓冲区, Encoder.Reset 会清除结构体标签的设置
+	e.buf.Reset()
+	if err := e.enc.Encode(value); err != nil {
+		return nil, err
+	}
+
+	// 缓冲区会被复用, 返回副本
+	return bytes.Clone(e.buf.Bytes()), nil
+}
+
+// Unmarshal 从 MessagePack 解码
+func (codec Codec) Unmarshal(data []byte, value any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag(structTag)
+	return dec.Decode(value)
+}
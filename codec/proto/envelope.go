@@ -0,0 +1,245 @@
+package proto
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/nf5lab/ebus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// 字段编号, 与 envelope.proto 保持一致
+const (
+	envelopeMetadataField protowire.Number = 1
+	envelopePayloadField  protowire.Number = 2
+
+	metadataSchemaVersionField protowire.Number = 1
+	metadataEventIdField       protowire.Number = 2
+	metadataEventSourceField   protowire.Number = 3
+	metadataEventTypeField     protowire.Number = 4
+	metadataEventTimeField     protowire.Number = 5
+	metadataCorrelationIdField protowire.Number = 6
+	metadataCausationIdField   protowire.Number = 7
+	metadataTenantIdField      protowire.Number = 8
+	metadataPartitionKeyField  protowire.Number = 9
+	metadataExtensionsField    protowire.Number = 10
+
+	mapEntryKeyField   protowire.Number = 1
+	mapEntryValueField protowire.Number = 2
+)
+
+// marshalEnvelope 编码事件信封
+func marshalEnvelope(envelope *ebus.Envelope) ([]byte, error) {
+	if envelope.Metadata == nil {
+		return nil, fmt.Errorf("ebus: 事件信封元数据为空")
+	}
+
+	var b []byte
+	b = protowire.AppendTag(b, envelopeMetadataField, protowire.BytesType)
+	b = protowire.AppendBytes(b, marshalMetadata(envelope.Metadata))
+
+	if len(envelope.Payload) > 0 {
+		b = protowire.AppendTag(b, envelopePayloadField, protowire.BytesType)
+		b = protowire.AppendBytes(b, envelope.Payload)
+	}
+
+	return b, nil
+}
+
+// marshalMetadata 编码事件元数据
+func marshalMetadata(meta *ebus.Metadata) []byte {
+	var b []byte
+	b = appendString(b, metadataSchemaVersionField, string(meta.SchemaVersion))
+	b = appendString(b, metadataEventIdField, meta.EventId)
+	b = appendString(b, metadataEventSourceField, string(meta.EventSource))
+	b = appendString(b, metadataEventTypeField, string(meta.EventType))
+
+	if meta.EventTime != 0 {
+		b = protowire.AppendTag(b, metadataEventTimeField, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(meta.EventTime))
+	}
+
+	b = appendString(b, metadataCorrelationIdField, meta.CorrelationId)
+	b = appendString(b, metadataCausationIdField, meta.CausationId)
+	b = appendString(b, metadataTenantIdField, meta.TenantId)
+	b = appendString(b, metadataPartitionKeyField, meta.PartitionKey)
+
+	// 按键排序, 保证编码结果稳定
+	for _, key := range slices.Sorted(maps.Keys(meta.Extensions)) {
+		var entry []byte
+		entry = appendString(entry, mapEntryKeyField, key)
+		entry = appendString(entry, mapEntryValueField, meta.Extensions[key])
+
+		b = protowire.AppendTag(b, metadataExtensionsField, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+
+	return b
+}
+
+// appendString 编码字符串字段, 空字符串是 proto3 的默认值, 不编码
+func appendString(b []byte, num protowire.Number, value string) []byte {
+	if len(value) == 0 {
+		return b
+	}
+
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+// unmarshalEnvelope 解码事件信封
+func unmarshalEnvelope(data []byte, envelope *ebus.Envelope) error {
+	var nestedErr error
+
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, data []byte) int {
+		if typ != protowire.BytesType {
+			return 0
+		}
+
+		switch num {
+		case envelopeMetadataField:
+			value, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n
+			}
+			envelope.Metadata, nestedErr = unmarshalMetadata(value)
+			return n
+
+		case envelopePayloadField:
+			value, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n
+			}
+			envelope.Payload = slices.Clone(value)
+			return n
+		}
+
+		return 0
+	})
+	if err != nil {
+		return fmt.Errorf("ebus: protobuf 事件信封解码失败: %w", err)
+	}
+
+	if nestedErr != nil {
+		return fmt.Errorf("ebus: protobuf 事件元数据解码失败: %w", nestedErr)
+	}
+
+	return nil
+}
+
+// unmarshalMetadata 解码事件元数据
+func unmarshalMetadata(data []byte) (*ebus.Metadata, error) {
+	meta := &ebus.Metadata{}
+
+	var nestedErr error
+
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, data []byte) int {
+		if typ == protowire.VarintType && num == metadataEventTimeField {
+			value, n := protowire.ConsumeVarint(data)
+			meta.EventTime = int64(value)
+			return n
+		}
+
+		if typ != protowire.BytesType {
+			return 0
+		}
+
+		if num == metadataExtensionsField {
+			value, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n
+			}
+
+			key, val, err := unmarshalMapEntry(value)
+			if err != nil {
+				nestedErr = err
+				return n
+			}
+
+			meta.SetExtension(key, val)
+			return n
+		}
+
+		value, n := protowire.ConsumeString(data)
+
+		switch num {
+		case metadataSchemaVersionField:
+			meta.SchemaVersion = ebus.SchemaVersion(value)
+		case metadataEventIdField:
+			meta.EventId = value
+		case metadataEventSourceField:
+			meta.EventSource = ebus.EventSource(value)
+		case metadataEventTypeField:
+			meta.EventType = ebus.EventType(value)
+		case metadataCorrelationIdField:
+			meta.CorrelationId = value
+		case metadataCausationIdField:
+			meta.CausationId = value
+		case metadataTenantIdField:
+			meta.TenantId = value
+		case metadataPartitionKeyField:
+			meta.PartitionKey = value
+		default:
+			return 0
+		}
+
+		return n
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if nestedErr != nil {
+		return nil, nestedErr
+	}
+
+	return meta, nil
+}
+
+// unmarshalMapEntry 解码 map<string, string> 的条目
+func unmarshalMapEntry(data []byte) (key string, value string, err error) {
+	err = consumeFields(data, func(num protowire.Number, typ protowire.Type, data []byte) int {
+		if typ != protowire.BytesType {
+			return 0
+		}
+
+		str, n := protowire.ConsumeString(data)
+
+		switch num {
+		case mapEntryKeyField:
+			key = str
+		case mapEntryValueField:
+			value = str
+		default:
+			return 0
+		}
+
+		return n
+	})
+	return key, value, err
+}
+
+// consumeFields 遍历消息的字段
+//
+// consume 返回消费的字节数, 返回 0 表示未知字段, 由 consumeFields 跳过, 返回负数表示解析错误
+func consumeFields(data []byte, consume func(num protowire.Number, typ protowire.Type, data []byte) int) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		n = consume(num, typ, data)
+		if n == 0 {
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package proto
+
+import (
+	"fmt"
+
+	"github.com/nf5lab/ebus"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+// Event 把 protobuf 消息包装为 ebus 事件
+//
+// 负载只包含 protobuf 消息, 元数据保存在事件信封中
+// 类型参数 T 为生成代码中的消息指针类型, 例如 *orderpb.OrderCreated
+type Event[T protobuf.Message] struct {
+	meta    *ebus.Metadata
+	Message T // protobuf 消息
+}
+
+var (
+	// 确保实现了 EnvelopeMetadataSetter 接口
+	_ ebus.EnvelopeMetadataSetter = (*Event[protobuf.Message])(nil)
+)
+
+// NewEvent 创建事件
+// - meta    事件元数据
+// - message protobuf 消息
+func NewEvent[T protobuf.Message](meta *ebus.Metadata, message T) *Event[T] {
+	return &Event[T]{
+		meta:    meta,
+		Message: message,
+	}
+}
+
+// Metadata 获取事件元数据
+func (evt *Event[T]) Metadata() *ebus.Metadata {
+	return evt.meta
+}
+
+// SetEnvelopeMetadata 使用事件信封的元数据
+func (evt *Event[T]) SetEnvelopeMetadata(meta *ebus.Metadata) {
+	evt.meta = meta
+}
+
+// Validate 验证事件是否有效
+//
+// 如果消息实现了 Validate() error (例如 protoc-gen-validate 生成的代码), 调用该方法
+func (evt *Event[T]) Validate() error {
+	if any(evt.Message) == nil || !evt.Message.ProtoReflect().IsValid() {
+		return fmt.Errorf("ebus: protobuf 消息为空")
+	}
+
+	if validator, ok := any(evt.Message).(interface{ Validate() error }); ok {
+		return validator.Validate()
+	}
+
+	return nil
+}
+
+// protoMessage 获取 protobuf 消息
+// - alloc 消息为空时是否创建新的消息, 用于解码
+func (evt *Event[T]) protoMessage(alloc bool) (protobuf.Message, error) {
+	if any(evt.Message) == nil {
+		return nil, fmt.Errorf("ebus: protobuf 消息类型无效")
+	}
+
+	if alloc && !evt.Message.ProtoReflect().IsValid() {
+		message, ok := evt.Message.ProtoReflect().Type().New().Interface().(T)
+		if !ok {
+			return nil, fmt.Errorf("ebus: protobuf 消息类型无效")
+		}
+		evt.Message = message
+	}
+
+	return evt.Message, nil
+}
+
+// messageEvent 包装了 protobuf 消息的事件
+type messageEvent interface {
+	protoMessage(alloc bool) (protobuf.Message, error)
+}
+
+// RegisterEvent 在全局注册表中注册 protobuf 事件
+// - scmVersion 模型版本
+// - evtSource  事件来源
+// - evtType    事件类型
+func RegisterEvent[T protobuf.Message](scmVersion ebus.SchemaVersion, evtSource ebus.EventSource, evtType ebus.EventType) error {
+	return RegisterEventIn[T](ebus.DefaultRegistry(), scmVersion, evtSource, evtType)
+}
+
+// RegisterEventIn 在指定的注册表中注册 protobuf 事件
+// - registry   事件工厂注册表
+// - scmVersion 模型版本
+// - evtSource  事件来源
+// - evtType    事件类型
+func RegisterEventIn[T protobuf.Message](registry *ebus.Registry, scmVersion ebus.SchemaVersion, evtSource ebus.EventSource, evtType ebus.EventType) error {
+	return registry.Register(scmVersion, evtSource, evtType, func() (ebus.Event, error) {
+		return &Event[T]{}, nil
+	})
+}
+
+// MustRegisterEvent 在全局注册表中注册 protobuf 事件, 如果注册失败则 panic
+// - scmVersion 模型版本
+// - evtSource  事件来源
+// - evtType    事件类型
+func MustRegisterEvent[T protobuf.Message](scmVersion ebus.SchemaVersion, evtSource ebus.EventSource, evtType ebus.EventType) {
+	if err := RegisterEvent[T](scmVersion, evtSource, evtType); err != nil {
+		panic(err)
+	}
+}
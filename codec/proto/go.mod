@@ -0,0 +1,10 @@
+module github.com/nf5lab/ebus/codec/proto
+
+go 1.24.0
+
+require (
+	github.com/nf5lab/ebus v0.0.0-20261016011243-5686471bbfc5
+	google.golang.org/protobuf v1.36.6
+)
+
+require github.com/nf5lab/broker v0.4.0 // indirect
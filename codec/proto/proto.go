@@ -0,0 +1,80 @@
+// Package proto 提供 ebus 的 protobuf 编解码器
+//
+// 事件信封按照 envelope.proto 编码, 负载是事件的 protobuf 编码
+// 事件使用 Event 包装生成代码中的消息, 或者由消息类型自己实现 ebus.Event
+//
+// 使用方法:
+//
+//	proto.MustRegisterEvent[*orderpb.OrderCreated]("v1", "order", "created")
+//	publisher := ebus.NewPublisher(brokerPublisher, ebus.WithCodec(proto.Codec{}))
+//	subscriber := ebus.NewSubscriber(brokerSubscriber, ebus.WithCodec(proto.Codec{}))
+//
+// 订阅者按照消息的内容类型 (application/x-protobuf) 选择编解码器, 同时仍然支持 JSON
+package proto
+
+import (
+	"fmt"
+
+	"github.com/nf5lab/ebus"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+const (
+	// ContentType protobuf 消息的内容类型
+	ContentType = "application/x-protobuf"
+)
+
+var (
+	// 确保实现了 Codec 接口
+	_ ebus.Codec = Codec{}
+)
+
+// Codec protobuf 编解码器
+type Codec struct{}
+
+// ContentType 消息的内容类型
+func (codec Codec) ContentType() string {
+	return ContentType
+}
+
+// Marshal 编码事件或者事件信封
+func (codec Codec) Marshal(value any) ([]byte, error) {
+	switch v := value.(type) {
+	case *ebus.Envelope:
+		return marshalEnvelope(v)
+
+	case messageEvent:
+		message, err := v.protoMessage(false)
+		if err != nil {
+			return nil, err
+		}
+		return protobuf.Marshal(message)
+
+	case protobuf.Message:
+		return protobuf.Marshal(v)
+
+	default:
+		return nil, fmt.Errorf("ebus: 不支持 protobuf 编码的类型: %T", value)
+	}
+}
+
+// Unmarshal 解码事件或者事件信封
+func (codec Codec) Unmarshal(data []byte, value any) error {
+	switch v := value.(type) {
+	case *ebus.Envelope:
+		return unmarshalEnvelope(data, v)
+
+	case messageEvent:
+		message, err := v.protoMessage(true)
+		if err != nil {
+			return err
+		}
+		return protobuf.Unmarshal(data, message)
+
+	case protobuf.Message:
+		return protobuf.Unmarshal(data, v)
+
+	default:
+		return fmt.Errorf("ebus: 不支持 protobuf 解码的类型: %T", value)
+	}
+}
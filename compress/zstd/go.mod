@@ -0,0 +1,9 @@
+module github.com/nf5lab/ebus/compress/zstd
+
+go 1.24.0
+
+require (
+	github.com/klauspost/compress v1.18.0
+	github.com/nf5lab/broker v0.4.0
+	github.com/nf5lab/ebus v0.0.0-20261016011243-5686471bbfc5
+)
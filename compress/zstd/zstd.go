@@ -0,0 +1,92 @@
+// Package zstd 提供 ebus 的 zstd 消息体压缩器
+//
+// 使用方法 (只压缩超过 16KB 的消息体):
+//
+//	compressor, err := zstd.New()
+//	publisher := ebus.NewPublisher(brokerPublisher, ebus.WithCompression(compressor, 16<<10))
+//	subscriber := ebus.NewSubscriber(brokerSubscriber, ebus.WithCompression(compressor, 16<<10))
+//
+// 解压之后的大小有上限 (默认 DefaultMaxDecompressedSize), 防止很小的压缩炸弹耗尽订阅者的内存
+package zstd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/nf5lab/broker"
+	"github.com/nf5lab/ebus"
+)
+
+const (
+	// ContentEncoding zstd 内容编码
+	ContentEncoding = "zstd"
+
+	// DefaultMaxDecompressedSize 默认的解压之后的最大字节数
+	DefaultMaxDecompressedSize = 64 << 20
+)
+
+var (
+	// 确保实现了 Compressor 接口
+	_ ebus.Compressor = (*Compressor)(nil)
+)
+
+// Compressor zstd 压缩器, 可以在多个协程中并发使用
+type Compressor struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+	maxSize uint64 // 解压之后的最大字节数
+}
+
+// New 创建 zstd 压缩器, 解压之后的大小不能超过 DefaultMaxDecompressedSize
+// - opts 编码器选项, 例如 zstd.WithEncoderLevel(zstd.SpeedBetterCompression)
+func New(opts ...zstd.EOption) (*Compressor, error) {
+	return NewWithMaxSize(DefaultMaxDecompressedSize, opts...)
+}
+
+// NewWithMaxSize 创建 zstd 压缩器, 并指定解压之后的最大字节数
+//
+// 超过上限的消息解压失败, 返回不可重试的错误 (重新投递也不会成功)
+// - maxSize 解压之后的最大字节数, 为 0 时使用 DefaultMaxDecompressedSize
+// - opts    编码器选项
+func NewWithMaxSize(maxSize uint64, opts ...zstd.EOption) (*Compressor, error) {
+	if maxSize == 0 {
+		maxSize = DefaultMaxDecompressedSize
+	}
+
+	encoder, err := zstd.NewWriter(nil, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("ebus: 创建 zstd 编码器失败: %w", err)
+	}
+
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxSize))
+	if err != nil {
+		return nil, fmt.Errorf("ebus: 创建 zstd 解码器失败: %w", err)
+	}
+
+	return &Compressor{
+		encoder: encoder,
+		decoder: decoder,
+		maxSize: maxSize,
+	}, nil
+}
+
+// Encoding 内容编码的名称
+func (c *Compressor) Encoding() string {
+	return ContentEncoding
+}
+
+// Compress 压缩数据
+func (c *Compressor) Compress(data []byte) ([]byte, error) {
+	return c.encoder.EncodeAll(data, nil), nil
+}
+
+// Decompress 解压数据
+func (c *Compressor) Decompress(data []byte) ([]byte, error) {
+	decoded, err := c.decoder.DecodeAll(data, nil)
+	// 窗口大小同样受上限约束, 声明了超大窗口的数据也视为超过上限
+	if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+		return nil, broker.NewNonRetryableError(fmt.Errorf("ebus: zstd 解压之后超过 %d 字节: %w", c.maxSize, err))
+	}
+	return decoded, err
+}
@@ -0,0 +1,50 @@
+package zstd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nf5lab/broker"
+)
+
+func TestCompressorRoundTrip(t *testing.T) {
+	compressor, err := New()
+	if err != nil {
+		t.Fatalf("创建压缩器失败: %v", err)
+	}
+
+	data := bytes.Repeat([]byte(`{"orderId":"order-1","amount":42}`), 1000)
+	compressed, err := compressor.Compress(data)
+	if err != nil {
+		t.Fatalf("压缩失败: %v", err)
+	}
+
+	decompressed, err := compressor.Decompress(compressed)
+	if err != nil {
+		t.Fatalf("解压失败: %v", err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Error("解压之后的数据与原始数据不同")
+	}
+}
+
+func TestCompressorMaxSize(t *testing.T) {
+	compressor, err := NewWithMaxSize(1 << 20)
+	if err != nil {
+		t.Fatalf("创建压缩器失败: %v", err)
+	}
+
+	// 很小的压缩数据解压之后远远超过上限
+	bomb, err := compressor.Compress(make([]byte, 16<<20))
+	if err != nil {
+		t.Fatalf("压缩失败: %v", err)
+	}
+
+	_, err = compressor.Decompress(bomb)
+	if err == nil {
+		t.Fatal("超过上限时应该解压失败")
+	}
+	if !broker.IsNonRetryableError(err) {
+		t.Errorf("超过上限应该返回不可重试的错误: %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package ebus
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nf5lab/broker"
+)
+
+const (
+	// ContentEncodingGzip gzip 内容编码
+	ContentEncodingGzip = "gzip"
+
+	// DefaultMaxDecompressedSize 默认的解压之后的最大字节数
+	DefaultMaxDecompressedSize = 64 << 20
+)
+
+var (
+	// ErrDecompressedTooLarge 解压之后的消息体超过上限
+	//
+	// 以不可重试的错误返回, 防止很小的压缩炸弹耗尽订阅者的内存
+	ErrDecompressedTooLarge = errors.New("ebus: 解压之后的消息体超过上限")
+)
+
+var (
+	// 确保实现了 Compressor 接口
+	_ Compressor = GzipCompressor{}
+)
+
+// Compressor 消息体压缩器
+//
+// 发布者压缩消息体并设置 content-encoding 消息头, 订阅者按照消息头透明地解压
+type Compressor interface {
+
+	// Encoding 内容编码的名称, 写入 content-encoding 消息头, 例如 gzip
+	Encoding() string
+
+	// Compress 压缩数据
+	Compress(data []byte) ([]byte, error)
+
+	// Decompress 解压数据
+	Decompress(data []byte) ([]byte, error)
+}
+
+// GzipCompressor gzip 压缩器
+type GzipCompressor struct {
+	Level   int   // 压缩级别, 为 0 时使用 gzip.DefaultCompression
+	MaxSize int64 // 解压之后的最大字节数, 为 0 时使用 DefaultMaxDecompressedSize
+}
+
+// Encoding 内容编码的名称
+func (c GzipCompressor) Encoding() string {
+	return ContentEncodingGzip
+}
+
+// Compress 压缩数据
+func (c GzipCompressor) Compress(data []byte) ([]byte, error) {
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	var buf bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Decompress 解压数据
+//
+// 解压之后超过 MaxSize 时返回不可重试的错误 (ErrDecompressedTooLarge), 重新投递也不会成功
+func (c GzipCompressor) Decompress(data []byte) ([]byte, error) {
+	maxSize := c.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxDecompressedSize
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	// 多读一个字节, 用于判断是否超过上限
+	body, err := io.ReadAll(io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(body)) > maxSize {
+		return nil, broker.NewNonRetryableError(fmt.Errorf("%w: 超过 %d 字节", ErrDecompressedTooLarge, maxSize))
+	}
+
+	return body, nil
+}
+
+// compressMessage 按照选项压缩消息体, 并设置 content-encoding 消息头
+func (opts *Options) compressMessage(message *broker.Message) error {
+	compressor := opts.Compressor
+	if compressor == nil || len(message.Body) < opts.CompressionThreshold {
+		return nil
+	}
+
+	body, err := compressor.Compress(message.Body)
+	if err != nil {
+		return fmt.Errorf("ebus: 消息(%s)压缩失败: %w", message.Id, err)
+	}
+
+	message.Body = body
+	message.AddHeader(HeaderContentEncoding, compressor.Encoding())
+	return nil
+}
+
+// decompressBody 按照 content-encoding 消息头解压消息体
+//
+// 支持 Compressor 对应的内容编码, gzip 总是支持
+// - body 解密之后的消息体
+func (opts *Options) decompressBody(message *broker.Message, body []byte) ([]byte, error) {
+	encoding, _ := message.GetHeaderString(HeaderContentEncoding)
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+
+	if len(encoding) == 0 || encoding == "identity" {
+		return body, nil
+	}
+
+	var compressor Compressor
+	switch {
+	case opts.Compressor != nil && strings.EqualFold(opts.Compressor.Encoding(), encoding):
+		compressor = opts.Compressor
+	case encoding == ContentEncodingGzip:
+		compressor = GzipCompressor{}
+	default:
+		return nil, fmt.Errorf("ebus: 不支持的内容编码: %s", encoding)
+	}
+
+	body, err := compressor.Decompress(body)
+	if err != nil {
+		return nil, fmt.Errorf("ebus: 消息(%s)解压失败: %w", message.Id, err)
+	}
+
+	return body, nil
+}
@@ -0,0 +1,51 @@
+package ebus
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/nf5lab/broker"
+)
+
+func TestGzipCompressorRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("ebus"), 1024)
+
+	compressor := GzipCompressor{MaxSize: int64(len(data))}
+	compressed, err := compressor.Compress(data)
+	if err != nil {
+		t.Fatalf("压缩失败: %v", err)
+	}
+
+	// 恰好等于上限时可以解压
+	decompressed, err := compressor.Decompress(compressed)
+	if err != nil {
+		t.Fatalf("解压失败: %v", err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Fatal("解压之后的数据与原始数据不一致")
+	}
+}
+
+func TestGzipCompressorMaxSize(t *testing.T) {
+	// 高度重复的数据压缩之后很小, 解压之后超过上限
+	compressed, err := GzipCompressor{}.Compress(make([]byte, 1<<20))
+	if err != nil {
+		t.Fatalf("压缩失败: %v", err)
+	}
+
+	_, err = GzipCompressor{MaxSize: 1 << 10}.Decompress(compressed)
+	if !errors.Is(err, ErrDecompressedTooLarge) {
+		t.Fatalf("错误 = %v, 期望 ErrDecompressedTooLarge", err)
+	}
+	if !broker.IsNonRetryableError(err) {
+		t.Fatal("超过上限的错误应该不可重试")
+	}
+
+	// 订阅者解压时保留不可重试的错误
+	options := NewOptions(WithCompression(GzipCompressor{MaxSize: 1 << 10}, 0))
+	message := &broker.Message{Id: "message-1", Headers: map[string]any{HeaderContentEncoding: ContentEncodingGzip}}
+	if _, err := options.decompressBody(message, compressed); !broker.IsNonRetryableError(err) {
+		t.Fatalf("decompressBody 错误 = %v, 期望不可重试", err)
+	}
+}
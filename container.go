@@ -0,0 +1,19 @@
+package ebus
+
+const (
+	// ContentTypeContainerJson 容器消息的内容类型
+	//
+	// 一条容器消息包含多个事件信封
+	ContentTypeContainerJson = "application/vnd.ebus.container+json"
+)
+
+const (
+	HeaderEventCount = "x-event-count" // 容器消息包含的事件数量
+)
+
+// Container 表示事件容器
+//
+// 用于把多个小事件打包成一条消息发布, 分摊消息队列的开销
+type Container struct {
+	Envelopes []*Envelope `json:"envelopes"` // 事件信封列表
+}
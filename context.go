@@ -0,0 +1,160 @@
+package ebus
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nf5lab/broker"
+)
+
+// correlationIdContextKey 关联ID在上下文中的键
+type correlationIdContextKey struct{}
+
+// causationIdContextKey 因果ID在上下文中的键
+type causationIdContextKey struct{}
+
+// deliveryContextKey 正在处理的投递在上下文中的键
+type deliveryContextKey struct{}
+
+// validationErrorContextKey 跳过的事件校验错误在上下文中的键
+type validationErrorContextKey struct{}
+
+// consumedDelivery 正在处理的投递
+type consumedDelivery struct {
+	delivery *broker.Delivery
+	envelope []byte // 事件信封的原始数据
+}
+
+// ContextWithCorrelationId 在上下文中设置关联ID
+//
+// 发布者发布没有关联ID的事件时, 会自动使用上下文中的关联ID
+func ContextWithCorrelationId(ctx context.Context, correlationId string) context.Context {
+	return context.WithValue(ctx, correlationIdContextKey{}, strings.TrimSpace(correlationId))
+}
+
+// CorrelationIdFromContext 从上下文中获取关联ID, 不存在时返回空字符串
+//
+// 订阅者在调用处理函数之前, 会把正在处理的事件的关联ID放入上下文
+func CorrelationIdFromContext(ctx context.Context) string {
+	correlationId, _ := ctx.Value(correlationIdContextKey{}).(string)
+	return correlationId
+}
+
+// ContextWithCausationId 在上下文中设置因果ID
+//
+// 发布者开启了 CausationChaining 时, 没有因果ID的事件会使用上下文中的因果ID
+func ContextWithCausationId(ctx context.Context, causationId string) context.Context {
+	return context.WithValue(ctx, causationIdContextKey{}, strings.TrimSpace(causationId))
+}
+
+// CausationIdFromContext 从上下文中获取因果ID, 不存在时返回空字符串
+//
+// 订阅者在调用处理函数之前, 会把正在处理的事件的ID放入上下文
+func CausationIdFromContext(ctx context.Context) string {
+	causationId, _ := ctx.Value(causationIdContextKey{}).(string)
+	return causationId
+}
+
+// contextWithConsumedEvent 把正在处理的事件的信息放入上下文
+func contextWithConsumedEvent(ctx context.Context, delivery *broker.Delivery, envelope []byte, metadata *Metadata) context.Context {
+	// 事件没有关联ID时, 该事件就是业务流程的起点, 使用事件ID作为关联ID
+	correlationId := metadata.CorrelationId
+	if len(correlationId) == 0 {
+		correlationId = metadata.EventId
+	}
+	ctx = ContextWithCorrelationId(ctx, correlationId)
+	ctx = ContextWithCausationId(ctx, metadata.EventId)
+	ctx = context.WithValue(ctx, deliveryContextKey{}, &consumedDelivery{delivery: delivery, envelope: envelope})
+	ctx = ContextWithLineage(ctx, lineageFromMessage(&delivery.Message))
+	if tp, ok := traceParentFromMessage(&delivery.Message); ok {
+		ctx = ContextWithTraceParent(ctx, tp)
+	}
+	return ctx
+}
+
+// applyContextMetadata 使用上下文中的信息补充待发布事件的元数据
+func applyContextMetadata(ctx context.Context, metadata *Metadata, opts *Options) {
+	if len(strings.TrimSpace(metadata.CorrelationId)) == 0 {
+		metadata.CorrelationId = CorrelationIdFromContext(ctx)
+	}
+
+	if opts.CausationChaining && len(strings.TrimSpace(metadata.CausationId)) == 0 {
+		metadata.CausationId = CausationIdFromContext(ctx)
+	}
+}
+
+// DeliveryInfo 投递信息
+type DeliveryInfo struct {
+	Attempts    int       // 消息队列的投递尝试次数 (包括首次)
+	Redelivered bool      // 是否是重新投递
+	RetryCount  int       // 通过 RetryAfter 延迟重新发布的次数
+	ReceiveTime time.Time // 接收时间
+	PublishTime time.Time // 最初的发布时间 (入队时间), 消息头缺失时为零值
+}
+
+// DeliveryInfoFromContext 从处理函数的上下文中获取投递信息
+//
+// 处理函数可以据此实现 "尝试N次之后放弃" 之类的逻辑
+func DeliveryInfoFromContext(ctx context.Context) (DeliveryInfo, bool) {
+	consumed, ok := ctx.Value(deliveryContextKey{}).(*consumedDelivery)
+	if !ok || consumed == nil {
+		return DeliveryInfo{}, false
+	}
+
+	delivery := consumed.delivery
+
+	retryCount := retryCountFromMessage(&delivery.Message)
+
+	info := DeliveryInfo{
+		Attempts:    delivery.Attempts,
+		Redelivered: delivery.IsRetry() || retryCount > 0,
+		RetryCount:  retryCount,
+		ReceiveTime: delivery.ReceiveTime,
+	}
+
+	if value, ok := delivery.Message.GetHeaderString(HeaderPublishTime); ok {
+		if millis, err := strconv.ParseInt(value, 10, 64); err == nil && millis > 0 {
+			info.PublishTime = time.UnixMilli(millis)
+		}
+	}
+
+	return info, true
+}
+
+// Delivery 原始投递
+//
+// 处理函数只能读取, 不能修改其中的消息头和字节切片
+type Delivery struct {
+	Topic       string         // 主题
+	MessageId   string         // 消息队列的消息ID
+	ContentType string         // 消息内容类型
+	Headers     map[string]any // 原始消息头
+	Envelope    []byte         // 事件信封的原始数据 (容器消息中为当前事件的信封)
+}
+
+// DeliveryFromContext 从处理函数的上下文中获取原始投递
+func DeliveryFromContext(ctx context.Context) (*Delivery, bool) {
+	consumed, ok := ctx.Value(deliveryContextKey{}).(*consumedDelivery)
+	if !ok || consumed == nil {
+		return nil, false
+	}
+
+	delivery := consumed.delivery
+	return &Delivery{
+		Topic:       delivery.Topic,
+		MessageId:   delivery.Message.Id,
+		ContentType: delivery.Message.ContentType,
+		Headers:     delivery.Message.Headers,
+		Envelope:    consumed.envelope,
+	}, true
+}
+
+// ValidationErrorFromContext 从处理函数的上下文中获取事件校验错误
+//
+// 只有开启 SkipPayloadValidation 并且事件校验失败时才返回错误
+func ValidationErrorFromContext(ctx context.Context) error {
+	err, _ := ctx.Value(validationErrorContextKey{}).(error)
+	return err
+}
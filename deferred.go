@@ -0,0 +1,150 @@
+package ebus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrNoDeferredPublisher 上下文中没有延迟发布器
+	ErrNoDeferredPublisher = errors.New("ebus: 上下文中没有延迟发布器")
+
+	// ErrDeferredPublisherDone 延迟发布器已经完成 (已刷出或者已丢弃)
+	ErrDeferredPublisherDone = errors.New("ebus: 延迟发布器已完成")
+)
+
+// deferredContextKey 延迟发布器在上下文中的键
+type deferredContextKey struct{}
+
+// stagedEvent 暂存的事件
+type stagedEvent struct {
+	topic string
+	event Event
+}
+
+// DeferredPublisher 延迟发布器
+//
+// 事件先暂存在内存中, 只有在请求或者事务成功完成之后才真正发布, 操作回滚时丢弃
+// 注意: 进程在刷出之前崩溃会丢失事件, 需要可靠投递时应当使用发件箱模式
+type DeferredPublisher struct {
+	publisher Publisher
+
+	lock   sync.Mutex
+	staged []stagedEvent
+	done   bool
+}
+
+// WithDeferredPublisher 在上下文中绑定延迟发布器
+//
+// 返回的上下文用于调用 PublishOnSuccess, 返回的延迟发布器用于刷出或者丢弃事件
+// - publisher 刷出时使用的发布者
+func WithDeferredPublisher(ctx context.Context, publisher Publisher) (context.Context, *DeferredPublisher) {
+	deferred := &DeferredPublisher{
+		publisher: publisher,
+	}
+	return context.WithValue(ctx, deferredContextKey{}, deferred), deferred
+}
+
+// DeferredPublisherFromContext 从上下文中获取延迟发布器
+func DeferredPublisherFromContext(ctx context.Context) (*DeferredPublisher, bool) {
+	deferred, ok := ctx.Value(deferredContextKey{}).(*DeferredPublisher)
+	return deferred, ok && deferred != nil
+}
+
+// PublishOnSuccess 把事件暂存到上下文绑定的延迟发布器中
+//
+// 事件在延迟发布器刷出时才发布, 如果上下文中没有延迟发布器, 返回 ErrNoDeferredPublisher
+func PublishOnSuccess(ctx context.Context, topic string, event Event) error {
+	deferred, ok := DeferredPublisherFromContext(ctx)
+	if !ok {
+		return ErrNoDeferredPublisher
+	}
+	return deferred.Stage(topic, event)
+}
+
+// Stage 暂存事件
+//
+// 主题和事件的校验在刷出时由发布者完成
+func (d *DeferredPublisher) Stage(topic string, event Event) error {
+	if event == nil {
+		return fmt.Errorf("ebus: 事件不能为空")
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.done {
+		return ErrDeferredPublisherDone
+	}
+
+	d.staged = append(d.staged, stagedEvent{topic: topic, event: event})
+	return nil
+}
+
+// Len 暂存的事件数量
+func (d *DeferredPublisher) Len() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return len(d.staged)
+}
+
+// Flush 按暂存顺序发布全部事件
+//
+// 如果某个事件发布失败, 该事件及其之后的事件仍然保留, 可以再次调用 Flush 重试
+// 全部发布成功后, 延迟发布器进入完成状态, 不能再暂存事件
+func (d *DeferredPublisher) Flush(ctx context.Context) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.done {
+		return ErrDeferredPublisherDone
+	}
+
+	if d.publisher == nil {
+		return fmt.Errorf("ebus: 发布者不能为空")
+	}
+
+	for len(d.staged) > 0 {
+		staged := d.staged[0]
+		if err := d.publisher.Publish(ctx, staged.topic, staged.event); err != nil {
+			return err
+		}
+		d.staged[0] = stagedEvent{}
+		d.staged = d.staged[1:]
+	}
+
+	d.staged = nil
+	d.done = true
+	return nil
+}
+
+// Discard 丢弃全部暂存的事件
+//
+// 丢弃后延迟发布器进入完成状态, 不能再暂存事件
+func (d *DeferredPublisher) Discard() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.staged = nil
+	d.done = true
+}
+
+// Complete 根据操作结果完成延迟发布
+//
+// - err 为 nil 时刷出事件, 返回刷出的错误
+// - err 不为 nil 时丢弃事件, 原样返回 err
+//
+// 适合在 defer 中使用:
+//
+//	ctx, deferred := ebus.WithDeferredPublisher(ctx, publisher)
+//	defer func() { err = deferred.Complete(ctx, err) }()
+func (d *DeferredPublisher) Complete(ctx context.Context, err error) error {
+	if err != nil {
+		d.Discard()
+		return err
+	}
+	return d.Flush(ctx)
+}
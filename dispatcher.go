@@ -0,0 +1,165 @@
+package ebus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	errDispatcherClosed = errors.New("ebus: 分发器已关闭")
+
+	// ErrQueueFull 分发队列已满
+	//
+	// 溢出策略为 OverflowNack 时返回, 消息会由消息队列重新投递
+	ErrQueueFull = errors.New("ebus: 分发队列已满")
+)
+
+// OverflowPolicy 分发队列已满时的处理策略
+type OverflowPolicy int
+
+const (
+	// OverflowBlock 等待队列出现空位
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowNack 立即返回 ErrQueueFull, 由消息队列重新投递
+	OverflowNack
+)
+
+func (policy OverflowPolicy) String() string {
+	switch policy {
+	case OverflowBlock:
+		return "block"
+	case OverflowNack:
+		return "nack"
+	default:
+		return fmt.Sprintf("OverflowPolicy(%d)", int(policy))
+	}
+}
+
+// dispatchTask 分发任务
+type dispatchTask struct {
+	ctx  context.Context
+	run  func(ctx context.Context) error
+	done chan error
+}
+
+// dispatcher 进程内事件分发器
+//
+// 投递先进入队列, 由工作协程取出执行, 控制事件的队列优先于数据事件的队列
+// 调用者会等待任务执行完成, 所以消息确认语义不变
+type dispatcher struct {
+	control  chan *dispatchTask // 控制事件队列
+	data     chan *dispatchTask // 数据事件队列
+	overflow OverflowPolicy     // 队列已满时的处理策略
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	wait      sync.WaitGroup
+}
+
+// newDispatcher 创建分发器
+// - workers   工作协程数量
+// - queueSize 每个队列的容量
+// - overflow  队列已满时的处理策略
+func newDispatcher(workers int, queueSize int, overflow OverflowPolicy) *dispatcher {
+	workers = max(workers, 1)
+	queueSize = max(queueSize, 0)
+
+	d := &dispatcher{
+		control:  make(chan *dispatchTask, queueSize),
+		data:     make(chan *dispatchTask, queueSize),
+		overflow: overflow,
+		closed:   make(chan struct{}),
+	}
+
+	d.wait.Add(workers)
+	for range workers {
+		go d.work()
+	}
+
+	return d
+}
+
+func (d *dispatcher) work() {
+	defer d.wait.Done()
+
+	for {
+		// 优先处理控制事件
+		select {
+		case task := <-d.control:
+			d.execute(task)
+			continue
+		default:
+		}
+
+		select {
+		case task := <-d.control:
+			d.execute(task)
+		case task := <-d.data:
+			d.execute(task)
+		case <-d.closed:
+			return
+		}
+	}
+}
+
+func (d *dispatcher) execute(task *dispatchTask) {
+	// 排队期间调用者已经放弃, 不再执行
+	if err := task.ctx.Err(); err != nil {
+		task.done <- err
+		return
+	}
+
+	task.done <- task.run(task.ctx)
+}
+
+// submit 提交任务并等待执行完成
+// - control 是否是控制事件
+func (d *dispatcher) submit(ctx context.Context, control bool, run func(ctx context.Context) error) error {
+	task := &dispatchTask{
+		ctx:  ctx,
+		run:  run,
+		done: make(chan error, 1),
+	}
+
+	queue := d.data
+	if control {
+		queue = d.control
+	}
+
+	if d.overflow == OverflowNack {
+		select {
+		case queue <- task:
+		case <-d.closed:
+			return errDispatcherClosed
+		default:
+			return ErrQueueFull
+		}
+	} else {
+		select {
+		case queue <- task:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-d.closed:
+			return errDispatcherClosed
+		}
+	}
+
+	// 任务已经入队, 必须等待结果, 保证处理完成之后才确认消息
+	select {
+	case err := <-task.done:
+		return err
+	case <-d.closed:
+		return errDispatcherClosed
+	}
+}
+
+// close 关闭分发器, 等待工作协程退出
+func (d *dispatcher) close() {
+	d.closeOnce.Do(func() {
+		close(d.closed)
+	})
+	d.wait.Wait()
+}
@@ -0,0 +1,130 @@
+package ebus
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/nf5lab/broker"
+)
+
+const testControlType EventType = "order.paused"
+
+// handlerCapture 保存订阅的处理函数和订阅选项的 broker.Subscriber, 由测试直接投递
+type handlerCapture struct {
+	handler broker.Handler
+	options *broker.SubscribeOptions
+}
+
+func (c *handlerCapture) Subscribe(ctx context.Context, topic string, handler broker.Handler, opts ...broker.SubscribeOption) (string, error) {
+	c.handler = handler
+	c.options = broker.NewSubscribeOptions(opts...)
+	return "subscription", nil
+}
+
+func (c *handlerCapture) Unsubscribe(ctx context.Context, subscriptionId string) error {
+	return nil
+}
+
+func (c *handlerCapture) Close() error {
+	return nil
+}
+
+// waitUntil 让出调度直到条件成立, 不依赖固定的等待时间
+func waitUntil(condition func() bool) {
+	for !condition() {
+		runtime.Gosched()
+	}
+}
+
+func TestControlEventOvertakesQueuedData(t *testing.T) {
+	const queued = 3
+
+	registry := newTestRegistry(t)
+	if err := RegisterEventIn[testEvent](registry, testVersion, testSource, testControlType); err != nil {
+		t.Fatalf("注册控制事件失败: %v", err)
+	}
+
+	// 数据事件 0 ~ 3, 然后是控制事件
+	capturePub := &capturePublisher{}
+	publisher := NewPublisher(capturePub, WithRegistry(registry))
+	for i := range queued + 1 {
+		if err := publisher.Publish(context.Background(), "orders", newTestEvent(strconv.Itoa(i), i)); err != nil {
+			t.Fatalf("编码数据事件失败: %v", err)
+		}
+	}
+	control := newTestEvent("control", 0)
+	control.Meta.EventType = testControlType
+	if err := publisher.Publish(context.Background(), "orders", control); err != nil {
+		t.Fatalf("编码控制事件失败: %v", err)
+	}
+	messages := capturePub.published()
+
+	var (
+		lock    sync.Mutex
+		order   []string
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+
+	handler := func(ctx context.Context, topic string, event Event) error {
+		orderId := event.(*testEvent).OrderId
+		if orderId == "0" {
+			close(started)
+			<-release
+		}
+
+		lock.Lock()
+		order = append(order, orderId)
+		lock.Unlock()
+		return nil
+	}
+
+	capture := &handlerCapture{}
+	sub := NewSubscriber(capture, WithRegistry(registry), WithControlEvents(testControlType)).(*subscriber)
+	subscriptionId, err := sub.Subscribe(context.Background(), "orders", "billing", handler)
+	if err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+
+	// 消息队列的并发处理数必须允许数据事件在队列中等待
+	if got, want := capture.options.Concurrency, 1+DefaultControlQueueSize; got != want {
+		t.Fatalf("消息队列的并发处理数 = %d, 期望 %d", got, want)
+	}
+
+	sub.lock.Lock()
+	dispatcher := sub.subscriptions[subscriptionId].dispatchers[0]
+	sub.lock.Unlock()
+
+	var wait sync.WaitGroup
+	deliver := func(message *broker.Message) {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			if err := capture.handler(context.Background(), &broker.Delivery{Message: *message, Topic: "orders", Attempts: 1}); err != nil {
+				t.Errorf("处理投递失败: %v", err)
+			}
+		}()
+	}
+
+	// 第一个数据事件占用工作协程, 其余的数据事件在队列中等待
+	deliver(messages[0])
+	<-started
+	for _, message := range messages[1 : queued+1] {
+		deliver(message)
+	}
+	waitUntil(func() bool { return len(dispatcher.data) == queued })
+
+	deliver(messages[queued+1])
+	waitUntil(func() bool { return len(dispatcher.control) == 1 })
+
+	close(release)
+	wait.Wait()
+	_ = sub.Unsubscribe(context.Background(), subscriptionId)
+
+	if len(order) != queued+2 || order[1] != "control" {
+		t.Fatalf("处理顺序 = %v, 期望控制事件越过队列中的数据事件", order)
+	}
+}
@@ -0,0 +1,146 @@
+package ebus
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrDowncasterExists = errors.New("ebus: 降级函数已存在")
+)
+
+// Downcaster 事件降级函数
+//
+// 把新模型版本的事件转换为旧模型版本的事件, 用于双版本发布
+// 返回的事件必须使用新的元数据实例, 其模型版本会被设置为目标版本
+type Downcaster func(event Event) (Event, error)
+
+func buildDowncasterKey(fromVersion SchemaVersion, toVersion SchemaVersion, evtSource EventSource, evtType EventType) string {
+	return buildEventFactoryKey(fromVersion, evtSource, evtType) + "|" + string(toVersion)
+}
+
+// RegisterDowncaster 在全局注册表中注册事件降级函数 (参考 Registry.RegisterDowncaster)
+func RegisterDowncaster(fromVersion SchemaVersion, toVersion SchemaVersion, evtSource EventSource, evtType EventType, downcaster Downcaster) error {
+	return defaultRegistry.RegisterDowncaster(fromVersion, toVersion, evtSource, evtType, downcaster)
+}
+
+// MustRegisterDowncaster 在全局注册表中注册事件降级函数, 如果注册失败则 panic
+func MustRegisterDowncaster(fromVersion SchemaVersion, toVersion SchemaVersion, evtSource EventSource, evtType EventType, downcaster Downcaster) {
+	defaultRegistry.MustRegisterDowncaster(fromVersion, toVersion, evtSource, evtType, downcaster)
+}
+
+// RegisterDowncaster 注册事件降级函数
+//
+// 发布者按照 DualVersions 查找发布者注册表中的降级函数
+// - fromVersion 源模型版本 (新版本)
+// - toVersion   目标模型版本 (旧版本)
+// - evtSource   事件来源
+// - evtType     事件类型
+// - downcaster  降级函数
+func (r *Registry) RegisterDowncaster(fromVersion SchemaVersion, toVersion SchemaVersion, evtSource EventSource, evtType EventType, downcaster Downcaster) error {
+	fromVersion = fromVersion.Normalize()
+	if fromVersion.IsEmpty() {
+		return fmt.Errorf("ebus: 源模型版本不能为空")
+	}
+
+	toVersion = toVersion.Normalize()
+	if toVersion.IsEmpty() {
+		return fmt.Errorf("ebus: 目标模型版本不能为空")
+	}
+
+	if fromVersion == toVersion {
+		return fmt.Errorf("ebus: 源模型版本和目标模型版本不能相同")
+	}
+
+	evtSource = evtSource.Normalize()
+	if evtSource.IsEmpty() {
+		return fmt.Errorf("ebus: 事件来源不能为空")
+	}
+
+	evtType = evtType.Normalize()
+	if evtType.IsEmpty() {
+		return fmt.Errorf("ebus: 事件类型不能为空")
+	}
+
+	if downcaster == nil {
+		return fmt.Errorf("ebus: 降级函数不能为空")
+	}
+
+	// 在锁外面构建key, 减少锁的持有时间
+	downcasterKey := buildDowncasterKey(fromVersion, toVersion, evtSource, evtType)
+
+	r.convertLock.Lock()
+	defer r.convertLock.Unlock()
+
+	if _, exists := r.downcasters[downcasterKey]; exists {
+		return fmt.Errorf("%w: %s", ErrDowncasterExists, r.describeKey(downcasterKey))
+	}
+
+	if r.downcasters == nil {
+		r.downcasters = make(map[string]Downcaster)
+	}
+	r.downcasters[downcasterKey] = downcaster
+	return nil
+}
+
+// MustRegisterDowncaster 注册事件降级函数, 如果注册失败则 panic
+// - fromVersion 源模型版本 (新版本)
+// - toVersion   目标模型版本 (旧版本)
+// - evtSource   事件来源
+// - evtType     事件类型
+// - downcaster  降级函数
+func (r *Registry) MustRegisterDowncaster(fromVersion SchemaVersion, toVersion SchemaVersion, evtSource EventSource, evtType EventType, downcaster Downcaster) {
+	if err := r.RegisterDowncaster(fromVersion, toVersion, evtSource, evtType, downcaster); err != nil {
+		panic(err)
+	}
+}
+
+// SkipLegacyCopies 跳过双版本发布的旧版本消息的消息头过滤函数 (参考 WithDualVersion)
+//
+// 升级之后的订阅者已经能够处理新的模型版本, 使用该函数避免同一个事件处理两次:
+//
+//	ebus.WithHeaderFilter(ebus.SkipLegacyCopies)
+func SkipLegacyCopies(headers map[string]any) bool {
+	_, legacy := headers[HeaderLegacyCopyOf]
+	return !legacy
+}
+
+// downcastForDualVersion 按照双版本发布的配置降级事件
+//
+// 没有配置双版本或者没有注册降级函数时, 返回 false
+func (opts *Options) downcastForDualVersion(event Event) (Event, bool, error) {
+	metadata := event.Metadata()
+	fromVersion := metadata.SchemaVersion.Normalize()
+
+	toVersion, exists := opts.DualVersions[fromVersion]
+	if !exists {
+		return nil, false, nil
+	}
+
+	downcasterKey := buildDowncasterKey(fromVersion, toVersion, metadata.EventSource.Normalize(), metadata.EventType.Normalize())
+
+	registry := opts.Registry
+	registry.convertLock.RLock()
+	downcaster, exists := registry.downcasters[downcasterKey]
+	registry.convertLock.RUnlock()
+
+	if !exists {
+		return nil, false, nil
+	}
+
+	downcasted, err := downcaster(event)
+	if err != nil {
+		return nil, false, fmt.Errorf("ebus: 事件(%s)从版本[%s]降级到[%s]失败: %w", metadata.EventId, fromVersion, toVersion, err)
+	}
+
+	if downcasted == nil || downcasted.Metadata() == nil {
+		return nil, false, fmt.Errorf("ebus: 事件(%s)从版本[%s]降级到[%s]之后元数据为空", metadata.EventId, fromVersion, toVersion)
+	}
+
+	if downcasted.Metadata() == metadata {
+		return nil, false, fmt.Errorf("ebus: 事件(%s)降级之后必须使用新的元数据实例", metadata.EventId)
+	}
+
+	downcasted.Metadata().SchemaVersion = toVersion
+	return downcasted, true, nil
+}
@@ -0,0 +1,89 @@
+package ebus_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/nf5lab/ebus"
+	"github.com/nf5lab/ebus/memory"
+)
+
+const dualSource ebus.EventSource = "ebus.test.dual"
+
+// newDualRegistry 创建同时注册了两个模型版本和降级函数的注册表
+func newDualRegistry(t testing.TB) *ebus.Registry {
+	t.Helper()
+
+	registry := ebus.NewRegistry()
+	for _, version := range []ebus.SchemaVersion{"v1", "v2"} {
+		if err := ebus.RegisterEventIn[accountEvent](registry, version, dualSource, "account.changed"); err != nil {
+			t.Fatalf("注册测试事件失败: %v", err)
+		}
+	}
+
+	registry.MustRegisterDowncaster("v2", "v1", dualSource, "account.changed", func(event ebus.Event) (ebus.Event, error) {
+		evt := event.(*accountEvent)
+
+		metadata := *evt.Meta
+		return &accountEvent{Meta: &metadata, AccountId: evt.AccountId, Sequence: evt.Sequence}, nil
+	})
+	return registry
+}
+
+func TestSkipLegacyCopies(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter ebus.HeaderFilterFunc
+		want   []ebus.SchemaVersion
+	}{
+		{name: "without filter", want: []ebus.SchemaVersion{"v2", "v1"}},
+		{name: "skip legacy copies", filter: ebus.SkipLegacyCopies, want: []ebus.SchemaVersion{"v2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := memory.NewBroker()
+			defer b.Close()
+
+			registry := newDualRegistry(t)
+			publisher := ebus.NewPublisher(b, ebus.WithRegistry(registry), ebus.WithDualVersion("v2", "v1"))
+			subscriber := ebus.NewSubscriber(b, ebus.WithRegistry(registry), ebus.WithHeaderFilter(tt.filter))
+
+			var (
+				lock     sync.Mutex
+				versions []ebus.SchemaVersion
+			)
+			handler := func(ctx context.Context, topic string, event ebus.Event) error {
+				lock.Lock()
+				defer lock.Unlock()
+
+				versions = append(versions, event.Metadata().SchemaVersion)
+				return nil
+			}
+
+			if _, err := subscriber.Subscribe(context.Background(), "accounts", "ledger", handler); err != nil {
+				t.Fatalf("订阅失败: %v", err)
+			}
+
+			event := newAccountEvent("account-1", 1)
+			event.Meta.EventSource = dualSource
+			event.Meta.SchemaVersion = "v2"
+			if err := publisher.Publish(context.Background(), "accounts", event); err != nil {
+				t.Fatalf("发布事件失败: %v", err)
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if len(versions) != len(tt.want) {
+				t.Fatalf("收到的模型版本 = %v, 期望 %v", versions, tt.want)
+			}
+			for i, version := range versions {
+				if version != tt.want[i] {
+					t.Fatalf("收到的模型版本 = %v, 期望 %v", versions, tt.want)
+				}
+			}
+		})
+	}
+}
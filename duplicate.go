@@ -0,0 +1,64 @@
+package ebus
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+const (
+	// DefaultDuplicateWindow 默认的重复检测窗口大小 (最近成功处理的事件数量)
+	DefaultDuplicateWindow = 10000
+)
+
+// DuplicateHandler 重复投递回调
+//
+// 只用于统计和上报, 事件仍然会交给处理函数
+type DuplicateHandler func(ctx context.Context, topic string, event Event)
+
+// lruSet 有界的最近最少使用集合
+type lruSet struct {
+	lock     sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List // 队头是最近使用的
+}
+
+func newLRUSet(capacity int) *lruSet {
+	return &lruSet{
+		capacity: max(capacity, 1),
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// contains 检查是否存在, 存在时刷新为最近使用
+func (set *lruSet) contains(key string) bool {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+
+	if elem, exists := set.items[key]; exists {
+		set.order.MoveToFront(elem)
+		return true
+	}
+	return false
+}
+
+// add 添加元素, 超出容量时淘汰最久未使用的元素
+func (set *lruSet) add(key string) {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+
+	if elem, exists := set.items[key]; exists {
+		set.order.MoveToFront(elem)
+		return
+	}
+
+	set.items[key] = set.order.PushFront(key)
+
+	for set.order.Len() > set.capacity {
+		oldest := set.order.Back()
+		set.order.Remove(oldest)
+		delete(set.items, oldest.Value.(string))
+	}
+}
@@ -0,0 +1,207 @@
+package ebustest
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/nf5lab/ebus"
+)
+
+var (
+	// ErrInjectedFailure 故障注入产生的处理失败
+	ErrInjectedFailure = errors.New("ebustest: 注入的处理失败")
+)
+
+// ChaosOptions 故障注入选项
+//
+// 概率的取值范围是 [0, 1], 0 表示不注入
+type ChaosOptions struct {
+
+	// Seed 随机数种子, 相同的种子和相同的事件顺序得到相同的故障序列, 便于复现
+	Seed uint64
+
+	// DelayProbability 延迟处理的概率
+	DelayProbability float64
+
+	// MaxDelay 最大延迟, 实际延迟在 (0, MaxDelay] 之间随机选择
+	MaxDelay time.Duration
+
+	// DuplicateProbability 重复投递的概率, 处理函数对同一个事件连续调用两次
+	DuplicateProbability float64
+
+	// ReorderProbability 乱序投递的概率
+	//
+	// 被选中的事件立即确认, 在下一个事件处理之后 (或者 ReorderWindow 到期之后) 才交给处理函数
+	ReorderProbability float64
+
+	// ReorderWindow 乱序事件最多推迟的时间, 默认 100 毫秒
+	ReorderWindow time.Duration
+
+	// FailureProbability 处理失败的概率, 失败的事件不会交给处理函数
+	FailureProbability float64
+
+	// Failure 注入的错误, 默认 ErrInjectedFailure
+	Failure error
+
+	// OnDeferredError 乱序事件处理失败的回调, 可以为 nil
+	//
+	// 乱序事件已经被确认, 处理结果无法返回给消息队列
+	OnDeferredError func(topic string, event ebus.Event, err error)
+}
+
+// deferredEvent 推迟处理的事件
+type deferredEvent struct {
+	topic string
+	event ebus.Event
+	timer *time.Timer
+}
+
+// chaos 故障注入的状态
+type chaos struct {
+	options ChaosOptions
+	next    ebus.EventHandler
+
+	lock     sync.Mutex
+	random   *rand.Rand
+	deferred []*deferredEvent
+}
+
+// chance 按照概率返回是否命中
+func (c *chaos) chance(probability float64) bool {
+	if probability <= 0 {
+		return false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.random.Float64() < probability
+}
+
+// delay 随机选择延迟
+func (c *chaos) delay() time.Duration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return time.Duration(c.random.Int64N(int64(c.options.MaxDelay))) + 1
+}
+
+// hold 推迟处理事件
+func (c *chaos) hold(topic string, event ebus.Event) {
+	deferred := &deferredEvent{topic: topic, event: event}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	deferred.timer = time.AfterFunc(c.options.ReorderWindow, func() {
+		if c.take(deferred) {
+			c.run(deferred)
+		}
+	})
+	c.deferred = append(c.deferred, deferred)
+}
+
+// take 从推迟列表中取出事件, 事件已经被处理时返回 false
+func (c *chaos) take(target *deferredEvent) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for i, deferred := range c.deferred {
+		if deferred == target {
+			c.deferred = append(c.deferred[:i], c.deferred[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// release 处理所有推迟的事件
+func (c *chaos) release() {
+	c.lock.Lock()
+	deferred := c.deferred
+	c.deferred = nil
+	c.lock.Unlock()
+
+	for _, d := range deferred {
+		d.timer.Stop()
+		c.run(d)
+	}
+}
+
+// run 处理推迟的事件
+func (c *chaos) run(deferred *deferredEvent) {
+	// 原投递的上下文已经结束, 使用独立的上下文
+	err := c.next(context.Background(), deferred.topic, deferred.event)
+	if err != nil && c.options.OnDeferredError != nil {
+		c.options.OnDeferredError(deferred.topic, deferred.event, err)
+	}
+}
+
+// handle 处理事件并注入故障
+func (c *chaos) handle(ctx context.Context, topic string, event ebus.Event) error {
+	if c.options.MaxDelay > 0 && c.chance(c.options.DelayProbability) {
+		timer := time.NewTimer(c.delay())
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if c.chance(c.options.FailureProbability) {
+		return c.options.Failure
+	}
+
+	if c.chance(c.options.ReorderProbability) {
+		c.hold(topic, event)
+		return nil
+	}
+
+	// 推迟的事件排在当前事件之后处理
+	defer c.release()
+
+	if c.chance(c.options.DuplicateProbability) {
+		if err := c.next(ctx, topic, event); err != nil {
+			return err
+		}
+	}
+
+	return c.next(ctx, topic, event)
+}
+
+// Chaos 故障注入中间件, 只用于测试
+//
+// 按照配置的概率注入延迟, 重复投递, 乱序投递和处理失败,
+// 用于在上线之前验证消费者是幂等的, 并且能够容忍至少一次投递的语义
+//
+//	handler := ebus.Chain(handler, ebustest.Chaos(ebustest.ChaosOptions{
+//		Seed:                 42,
+//		DuplicateProbability: 0.1,
+//		ReorderProbability:   0.1,
+//		FailureProbability:   0.05,
+//	}))
+//
+// 注意: 同一个中间件包装的每个处理函数有独立的随机序列和推迟列表
+func Chaos(options ChaosOptions) ebus.Middleware {
+	if options.ReorderWindow <= 0 {
+		options.ReorderWindow = 100 * time.Millisecond
+	}
+
+	if options.Failure == nil {
+		options.Failure = ErrInjectedFailure
+	}
+
+	return func(next ebus.EventHandler) ebus.EventHandler {
+		c := &chaos{
+			options: options,
+			next:    next,
+			random:  rand.New(rand.NewPCG(options.Seed, options.Seed)),
+		}
+		return c.handle
+	}
+}
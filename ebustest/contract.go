@@ -0,0 +1,247 @@
+package ebustest
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nf5lab/broker"
+	"github.com/nf5lab/ebus"
+)
+
+var (
+	metadataType      = reflect.TypeFor[ebus.Metadata]()
+	timeType          = reflect.TypeFor[time.Time]()
+	rawMessageType    = reflect.TypeFor[json.RawMessage]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+const (
+	// sampleDepth 填充样例时嵌套结构的最大深度, 避免递归类型无限展开
+	sampleDepth = 5
+)
+
+// VerifyContracts 验证注册表中每一个事件类型的生产者和消费者契约
+//
+// 每个事件类型用确定的非零值填充导出字段, 经过发布者编码和订阅者解码之后必须与原事件一致,
+// 用于在持续集成中低成本地发现不对称的编解码 (例如未导出的字段, 与 UnmarshalJSON 不匹配的 MarshalJSON)
+// 自动填充的样例无法通过事件校验时, 使用 VerifyContract 提供有效的样例
+// - registry 事件工厂注册表
+// - opts     发布者和订阅者共用的选项, 注册表总是使用 registry
+func VerifyContracts(t testing.TB, registry *ebus.Registry, opts ...ebus.Option) {
+	t.Helper()
+
+	opts = append(opts, ebus.WithRegistry(registry))
+	for _, info := range registry.Factories() {
+		meta := ebus.NewMetadata(info.EventSource, info.EventType, info.SchemaVersion)
+
+		factory, err := registry.Get(info.SchemaVersion, info.EventSource, info.EventType)
+		if err != nil {
+			t.Errorf("ebustest: 获取事件工厂(%s)失败: %v", contractName(meta), err)
+			continue
+		}
+
+		event, err := factory()
+		if err != nil {
+			t.Errorf("ebustest: 创建事件实例(%s)失败: %v", contractName(meta), err)
+			continue
+		}
+
+		fillSample(reflect.ValueOf(event), meta, sampleDepth)
+		if setter, ok := event.(ebus.EnvelopeMetadataSetter); ok {
+			setter.SetEnvelopeMetadata(meta)
+		}
+
+		VerifyContract(t, event, opts...)
+	}
+}
+
+// VerifyContract 验证单个事件的生产者和消费者契约
+//
+// 事件经过发布者编码和订阅者解码之后必须与原事件一致, 并且事件结构中没有未导出的字段
+// - event 有效的样例事件, 事件类型必须已经注册
+// - opts  发布者和订阅者共用的选项
+func VerifyContract(t testing.TB, event ebus.Event, opts ...ebus.Option) {
+	t.Helper()
+
+	meta := event.Metadata()
+	if meta == nil {
+		t.Errorf("ebustest: 事件(%T)的元数据为空, 无法验证契约", event)
+		return
+	}
+	name := contractName(meta)
+
+	if !implements(reflect.TypeOf(event)) {
+		for _, field := range unexportedFields(reflect.TypeOf(event), "", sampleDepth) {
+			t.Errorf("ebustest: 事件(%s)的字段 %s 没有导出, 不会出现在线上格式中", name, field)
+		}
+	}
+
+	publisher := NewRecordingPublisher(opts...)
+	if err := publisher.Publish(context.Background(), DefaultTopic, event); err != nil {
+		t.Errorf("ebustest: 事件(%s)编码失败 (自动填充的样例无效时使用 VerifyContract): %v", name, err)
+		return
+	}
+
+	delivery := &broker.Delivery{
+		Message:     *publisher.Events()[0].Message,
+		Topic:       DefaultTopic,
+		Attempts:    1,
+		ReceiveTime: time.Now(),
+	}
+
+	var decoded ebus.Event
+	capture := func(ctx context.Context, topic string, event ebus.Event) error {
+		decoded = event
+		return nil
+	}
+	if err := DeliverMessage(t, capture, delivery, opts...); err != nil {
+		t.Errorf("ebustest: 事件(%s)解码失败: %v", name, err)
+		return
+	}
+
+	if !reflect.DeepEqual(event, decoded) {
+		t.Errorf("ebustest: 事件(%s)编码再解码之后不一致\n  原事件: %+v\n  解码后: %+v", name, describeEvent(event), describeEvent(decoded))
+	}
+}
+
+// describeEvent 描述事件, 指针解引用之后显示字段
+func describeEvent(event ebus.Event) any {
+	value := reflect.ValueOf(event)
+	for value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem()
+	}
+	return value.Interface()
+}
+
+// implements 类型是否自定义了 JSON 或者文本编码
+func implements(typ reflect.Type) bool {
+	return typ.Implements(jsonMarshalerType) || typ.Implements(textMarshalerType) ||
+		reflect.PointerTo(typ).Implements(jsonMarshalerType) || reflect.PointerTo(typ).Implements(textMarshalerType)
+}
+
+// unexportedFields 列出结构中没有导出的字段, 自定义编码的类型不检查
+// - prefix 字段路径的前缀
+func unexportedFields(typ reflect.Type, prefix string, depth int) []string {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	if depth <= 0 || typ.Kind() != reflect.Struct || implements(typ) || typ == timeType {
+		return nil
+	}
+
+	var fields []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Tag.Get("json") == "-" {
+			continue
+		}
+
+		path := prefix + field.Name
+		if !field.IsExported() {
+			if !field.Anonymous {
+				fields = append(fields, path)
+			}
+			continue
+		}
+		fields = append(fields, unexportedFields(field.Type, path+".", depth-1)...)
+	}
+	return fields
+}
+
+// fillSample 用确定的非零值填充导出的字段
+// - meta 元数据类型的字段使用的元数据
+func fillSample(value reflect.Value, meta *ebus.Metadata, depth int) {
+	if depth <= 0 || !value.IsValid() {
+		return
+	}
+
+	typ := value.Type()
+	switch {
+	case typ == reflect.PointerTo(metadataType):
+		if value.CanSet() {
+			value.Set(reflect.ValueOf(meta))
+		}
+		return
+	case typ == metadataType:
+		if value.CanSet() {
+			value.Set(reflect.ValueOf(*meta))
+		}
+		return
+	case typ == timeType:
+		value.Set(reflect.ValueOf(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+		return
+	case typ == rawMessageType:
+		value.Set(reflect.ValueOf(json.RawMessage(`"sample"`)))
+		return
+	}
+
+	switch value.Kind() {
+	case reflect.Pointer:
+		if value.IsNil() {
+			if !value.CanSet() {
+				return
+			}
+			value.Set(reflect.New(typ.Elem()))
+		}
+		fillSample(value.Elem(), meta, depth)
+
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if !field.IsExported() || field.Tag.Get("json") == "-" {
+				continue
+			}
+			fillSample(value.Field(i), meta, depth-1)
+		}
+
+	case reflect.String:
+		value.SetString("sample")
+	case reflect.Bool:
+		value.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value.SetInt(7)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value.SetUint(7)
+	case reflect.Float32, reflect.Float64:
+		value.SetFloat(1.5)
+
+	case reflect.Slice:
+		slice := reflect.MakeSlice(typ, 1, 1)
+		fillSample(slice.Index(0), meta, depth-1)
+		value.Set(slice)
+
+	case reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			fillSample(value.Index(i), meta, depth-1)
+		}
+
+	case reflect.Map:
+		// JSON 对象的键只能是字符串或者整数
+		key := reflect.New(typ.Key()).Elem()
+		switch key.Kind() {
+		case reflect.String:
+			key.SetString("sample")
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			key.SetInt(7)
+		default:
+			return
+		}
+		item := reflect.New(typ.Elem()).Elem()
+		fillSample(item, meta, depth-1)
+		m := reflect.MakeMap(typ)
+		m.SetMapIndex(key, item)
+		value.Set(m)
+	}
+}
+
+// contractName 事件的契约名称
+func contractName(meta *ebus.Metadata) string {
+	return strings.Join([]string{meta.SchemaVersion.String(), meta.EventSource.String(), meta.EventType.String()}, "/")
+}
@@ -0,0 +1,4 @@
+// Package ebustest 提供测试 ebus 生产者和消费者的工具
+//
+// 只应该在测试代码中使用
+package ebustest
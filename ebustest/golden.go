@@ -0,0 +1,165 @@
+package ebustest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nf5lab/ebus"
+)
+
+const (
+	// GoldenDir 黄金文件的目录, 相对于测试所在的包
+	GoldenDir = "testdata"
+
+	// GoldenUpdateEnv 设置该环境变量为 1 时, AssertGolden 重新生成黄金文件而不是比较
+	//
+	//	EBUSTEST_UPDATE_GOLDEN=1 go test ./...
+	GoldenUpdateEnv = "EBUSTEST_UPDATE_GOLDEN"
+)
+
+const (
+	goldenEventId       = "<event-id>"       // 事件ID的占位符
+	goldenCorrelationId = "<correlation-id>" // 关联ID的占位符
+	goldenCausationId   = "<causation-id>"   // 因果ID的占位符
+)
+
+// CanonicalEnvelope 把事件编码为规范化的线上事件信封
+//
+// 事件经过发布者真实的编码流程, 每次发布都会变化的值 (事件ID, 关联ID, 因果ID, 事件时间) 替换为占位符,
+// 对象的键按照字母顺序排列并缩进, 相同的线上格式总是得到相同的字节
+// - event 事件
+// - opts  发布者选项, 只支持 JSON 编解码器, 并且没有开启压缩和加密
+func CanonicalEnvelope(t testing.TB, event ebus.Event, opts ...ebus.Option) []byte {
+	t.Helper()
+
+	publisher := NewRecordingPublisher(opts...)
+	if err := publisher.Publish(t.Context(), DefaultTopic, event); err != nil {
+		t.Fatalf("ebustest: 事件编码失败: %v", err)
+		return nil
+	}
+
+	rec := publisher.Events()[0]
+	envelope, err := rec.Envelope()
+	if err != nil {
+		t.Fatalf("%v", err)
+		return nil
+	}
+
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatalf("ebustest: 事件信封编码失败: %v", err)
+		return nil
+	}
+
+	var tree any
+	if err := json.Unmarshal(data, &tree); err != nil {
+		t.Fatalf("ebustest: 事件信封解码失败: %v", err)
+		return nil
+	}
+
+	// 序列化 map 时键按照字母顺序排列
+	var canonical bytes.Buffer
+	encoder := json.NewEncoder(&canonical)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(canonicalize(tree, rec.Metadata())); err != nil {
+		t.Fatalf("ebustest: 事件信封编码失败: %v", err)
+		return nil
+	}
+	return canonical.Bytes()
+}
+
+// canonicalize 把每次发布都会变化的值替换为占位符
+func canonicalize(value any, meta *ebus.Metadata) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if number, ok := item.(float64); ok && key == "eventTime" && int64(number) == meta.EventTime {
+				v[key] = 0
+				continue
+			}
+			v[key] = canonicalize(item, meta)
+		}
+		return v
+
+	case []any:
+		for i, item := range v {
+			v[i] = canonicalize(item, meta)
+		}
+		return v
+
+	case string:
+		switch {
+		case len(v) == 0:
+			return v
+		case v == meta.EventId:
+			return goldenEventId
+		case v == meta.CorrelationId:
+			return goldenCorrelationId
+		case v == meta.CausationId:
+			return goldenCausationId
+		}
+		return v
+
+	default:
+		return v
+	}
+}
+
+// AssertGolden 断言事件的规范化线上事件信封与黄金文件 testdata/<name>.golden.json 一致
+//
+// 用于在拥有事件的服务的持续集成中发现意外的线上格式变化 (例如字段改名, 类型变化)
+// 黄金文件不存在, 或者设置了环境变量 EBUSTEST_UPDATE_GOLDEN=1 时, 写入当前的线上格式
+// - name  黄金文件的名称, 例如 "order.created.v1"
+// - event 事件
+// - opts  发布者选项
+func AssertGolden(t testing.TB, name string, event ebus.Event, opts ...ebus.Option) {
+	t.Helper()
+
+	actual := CanonicalEnvelope(t, event, opts...)
+	path := filepath.Join(GoldenDir, name+".golden.json")
+
+	expected, err := os.ReadFile(path)
+	if os.Getenv(GoldenUpdateEnv) == "1" || os.IsNotExist(err) {
+		if err := os.MkdirAll(GoldenDir, 0o755); err != nil {
+			t.Fatalf("ebustest: 创建黄金文件目录失败: %v", err)
+		}
+		if err := os.WriteFile(path, actual, 0o644); err != nil {
+			t.Fatalf("ebustest: 写入黄金文件失败: %v", err)
+		}
+		t.Logf("ebustest: 已写入黄金文件 %s", path)
+		return
+	}
+	if err != nil {
+		t.Fatalf("ebustest: 读取黄金文件失败: %v", err)
+	}
+
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("ebustest: 事件(%s)的线上格式与黄金文件 %s 不一致\n%s\n如果是有意的变化, 使用 %s=1 重新生成",
+			name, path, describeDiff(expected, actual), GoldenUpdateEnv)
+	}
+}
+
+// describeDiff 描述两个文本第一处不一致的行
+func describeDiff(expected []byte, actual []byte) string {
+	expectedLines := bytes.Split(expected, []byte("\n"))
+	actualLines := bytes.Split(actual, []byte("\n"))
+
+	for i := 0; i < max(len(expectedLines), len(actualLines)); i++ {
+		var want, got []byte
+		if i < len(expectedLines) {
+			want = expectedLines[i]
+		}
+		if i < len(actualLines) {
+			got = actualLines[i]
+		}
+		if !bytes.Equal(want, got) {
+			return fmt.Sprintf("第 %d 行:\n  期望: %s\n  实际: %s", i+1, want, got)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package ebustest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nf5lab/broker"
+	"github.com/nf5lab/ebus"
+)
+
+const (
+	// DefaultTopic Deliver 使用的主题
+	DefaultTopic = "ebustest"
+
+	// DefaultGroup 订阅者使用的订阅组
+	DefaultGroup = "ebustest"
+)
+
+// handlerCapture 只接受一个订阅的消息队列, 保存订阅者注册的消息处理函数
+type handlerCapture struct {
+	lock    sync.Mutex
+	handler broker.Handler
+}
+
+func (c *handlerCapture) Subscribe(ctx context.Context, topic string, handler broker.Handler, opts ...broker.SubscribeOption) (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.handler = handler
+	return "ebustest", nil
+}
+
+func (c *handlerCapture) Unsubscribe(ctx context.Context, subscriptionId string) error {
+	return nil
+}
+
+func (c *handlerCapture) Close() error {
+	return nil
+}
+
+// NewDelivery 使用真实的编码流程把事件编码为投递
+// - topic 主题
+// - event 事件
+// - opts  发布者选项, 例如编解码器, 压缩, 签名
+func NewDelivery(t testing.TB, topic string, event ebus.Event, opts ...ebus.Option) *broker.Delivery {
+	t.Helper()
+
+	publisher := NewRecordingPublisher(opts...)
+	if err := publisher.Publish(context.Background(), topic, event); err != nil {
+		t.Fatalf("ebustest: 事件编码失败: %v", err)
+		return nil
+	}
+
+	return &broker.Delivery{
+		Message:     *publisher.Events()[0].Message,
+		Topic:       topic,
+		Attempts:    1,
+		ReceiveTime: time.Now(),
+	}
+}
+
+// DeliverMessage 把投递交给订阅者的真实解码流程, 然后调用处理函数
+//
+// 用于测试无效或者篡改的消息; 返回值是消息队列会收到的处理结果
+// - handler 事件处理函数
+// - delivery 投递
+// - opts    订阅者选项, 例如注册表, 中间件, 校验器
+func DeliverMessage(t testing.TB, handler ebus.EventHandler, delivery *broker.Delivery, opts ...ebus.Option) error {
+	t.Helper()
+
+	capture := &handlerCapture{}
+	subscriber := ebus.NewSubscriber(capture, opts...)
+	if _, err := subscriber.Subscribe(context.Background(), delivery.Topic, DefaultGroup, handler); err != nil {
+		t.Fatalf("ebustest: 订阅失败: %v", err)
+		return nil
+	}
+
+	return capture.handler(context.Background(), delivery)
+}
+
+// DeliverOn 把事件编码为投递, 经过订阅者的真实解码和校验流程交给处理函数
+// - topic   主题
+// - handler 事件处理函数
+// - event   事件
+// - opts    发布者和订阅者共用的选项
+func DeliverOn(t testing.TB, topic string, handler ebus.EventHandler, event ebus.Event, opts ...ebus.Option) error {
+	t.Helper()
+
+	return DeliverMessage(t, handler, NewDelivery(t, topic, event, opts...), opts...)
+}
+
+// Deliver 把事件发布到 DefaultTopic, 经过订阅者的真实解码和校验流程交给处理函数
+//
+// 不需要消息队列就能同时测试处理逻辑和解码校验, 例如:
+//
+//	err := ebustest.Deliver(t, service.OnOrderCreated, &OrderCreated{...}, ebus.WithRegistry(registry))
+//
+// - handler 事件处理函数
+// - event   事件
+// - opts    发布者和订阅者共用的选项
+func Deliver(t testing.TB, handler ebus.EventHandler, event ebus.Event, opts ...ebus.Option) error {
+	t.Helper()
+
+	return DeliverOn(t, DefaultTopic, handler, event, opts...)
+}
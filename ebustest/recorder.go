@@ -0,0 +1,297 @@
+package ebustest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/nf5lab/broker"
+	"github.com/nf5lab/ebus"
+)
+
+var (
+	// 确保实现了 Publisher 接口
+	_ ebus.Publisher = (*RecordingPublisher)(nil)
+)
+
+// RecordedEvent 记录的已发布事件
+type RecordedEvent struct {
+	Topic   string          // 发布的主题
+	Event   ebus.Event      // 发布的事件
+	Message *broker.Message // 发布到消息队列的消息 (打包发布时是容器消息)
+}
+
+// Metadata 获取事件元数据
+func (rec RecordedEvent) Metadata() *ebus.Metadata {
+	return rec.Event.Metadata()
+}
+
+// Envelope 从消息体解码事件信封, 用于检查线上格式
+//
+// 只支持 JSON 编解码器, 并且没有开启压缩和加密的消息; 容器消息返回事件对应的信封
+func (rec RecordedEvent) Envelope() (*ebus.Envelope, error) {
+	switch rec.Message.ContentType {
+	case ebus.ContentTypeJson:
+		var envelope ebus.Envelope
+		if err := json.Unmarshal(rec.Message.Body, &envelope); err != nil {
+			return nil, fmt.Errorf("ebustest: 事件信封解码失败: %w", err)
+		}
+		return &envelope, nil
+
+	case ebus.ContentTypeContainerJson:
+		var container ebus.Container
+		if err := json.Unmarshal(rec.Message.Body, &container); err != nil {
+			return nil, fmt.Errorf("ebustest: 事件容器解码失败: %w", err)
+		}
+		for _, envelope := range container.Envelopes {
+			if envelope.Metadata != nil && envelope.Metadata.EventId == rec.Metadata().EventId {
+				return envelope, nil
+			}
+		}
+		return nil, fmt.Errorf("ebustest: 事件容器中没有事件(%s)", rec.Metadata().EventId)
+
+	default:
+		return nil, fmt.Errorf("ebustest: 不支持的消息内容类型(%s)", rec.Message.ContentType)
+	}
+}
+
+// messageRecorder 记录发布到消息队列的消息
+type messageRecorder struct {
+	lock     sync.Mutex
+	messages map[string]*broker.Message // 消息ID -> 消息
+}
+
+func (r *messageRecorder) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	if err := msg.Validate(); err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.messages[msg.Id] = msg.Clone()
+	return nil
+}
+
+func (r *messageRecorder) Close() error {
+	return nil
+}
+
+// take 取出消息
+func (r *messageRecorder) take(id string) *broker.Message {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	msg := r.messages[id]
+	delete(r.messages, id)
+	return msg
+}
+
+// RecordingPublisher 记录已发布事件的发布者
+//
+// 事件经过 ebus 完整的编码流程, 但是不会发送到消息队列, 用于在服务的测试中声明式地验证发布的事件
+type RecordingPublisher struct {
+	inner    ebus.Publisher
+	recorder *messageRecorder
+
+	lock   sync.Mutex
+	events []RecordedEvent
+}
+
+// NewRecordingPublisher 创建记录已发布事件的发布者
+// - opts 发布者选项
+func NewRecordingPublisher(opts ...ebus.Option) *RecordingPublisher {
+	recorder := &messageRecorder{messages: make(map[string]*broker.Message)}
+	return &RecordingPublisher{
+		inner:    ebus.NewPublisher(recorder, opts...),
+		recorder: recorder,
+	}
+}
+
+// Publish 发布事件, 发布成功时记录事件
+func (pub *RecordingPublisher) Publish(ctx context.Context, topic string, event ebus.Event) error {
+	if err := pub.inner.Publish(ctx, topic, event); err != nil {
+		return err
+	}
+
+	pub.record(topic, []ebus.Event{event})
+	return nil
+}
+
+// PublishPacked 打包发布事件, 发布成功时记录每一个事件
+func (pub *RecordingPublisher) PublishPacked(ctx context.Context, topic string, events []ebus.Event) error {
+	if err := pub.inner.PublishPacked(ctx, topic, events); err != nil {
+		return err
+	}
+
+	pub.record(topic, events)
+	return nil
+}
+
+// PublishBatch 批量发布事件, 发布成功时记录每一个事件
+func (pub *RecordingPublisher) PublishBatch(ctx context.Context, topic string, events []ebus.Event) error {
+	if err := pub.inner.PublishBatch(ctx, topic, events); err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		pub.record(topic, []ebus.Event{event})
+	}
+	return nil
+}
+
+// PublishBatchTopics 批量发布事件到多个主题, 发布成功时记录每一个事件
+func (pub *RecordingPublisher) PublishBatchTopics(ctx context.Context, events []ebus.TopicEvent) error {
+	if err := pub.inner.PublishBatchTopics(ctx, events); err != nil {
+		return err
+	}
+
+	for _, item := range events {
+		pub.record(item.Topic, []ebus.Event{item.Event})
+	}
+	return nil
+}
+
+// PublishAsync 同步发布事件并记录, 返回已经包含发布结果的通道
+//
+// 测试中不需要异步发送, 调用返回之后就可以检查记录的事件
+func (pub *RecordingPublisher) PublishAsync(ctx context.Context, topic string, event ebus.Event) <-chan error {
+	result := make(chan error, 1)
+	result <- pub.Publish(ctx, topic, event)
+	close(result)
+	return result
+}
+
+// Flush 事件已经同步发布, 不需要等待
+func (pub *RecordingPublisher) Flush(ctx context.Context) error {
+	return nil
+}
+
+// PublishTx 发布事件并记录, 不会使用数据库事务
+//
+// 测试中不需要发件箱, 事件视为在事务提交之后发布
+func (pub *RecordingPublisher) PublishTx(ctx context.Context, tx *sql.Tx, topic string, event ebus.Event) error {
+	if tx == nil {
+		return fmt.Errorf("ebustest: 数据库事务不能为空")
+	}
+	return pub.Publish(ctx, topic, event)
+}
+
+// record 记录事件, 事件共享第一个事件ID对应的消息
+func (pub *RecordingPublisher) record(topic string, events []ebus.Event) {
+	message := pub.recorder.take(events[0].Metadata().EventId)
+
+	pub.lock.Lock()
+	defer pub.lock.Unlock()
+
+	for _, event := range events {
+		pub.events = append(pub.events, RecordedEvent{
+			Topic:   topic,
+			Event:   event,
+			Message: message,
+		})
+	}
+}
+
+// Close 关闭发布者 (不会执行任何操作)
+func (pub *RecordingPublisher) Close() error {
+	return nil
+}
+
+// Events 获取所有已发布的事件, 按照发布的顺序
+func (pub *RecordingPublisher) Events() []RecordedEvent {
+	pub.lock.Lock()
+	defer pub.lock.Unlock()
+
+	events := make([]RecordedEvent, len(pub.events))
+	copy(events, pub.events)
+	return events
+}
+
+// EventsOn 获取发布到指定主题的事件
+// - topic 主题, 空字符串表示所有主题
+// - evtType 事件类型, 空字符串表示所有类型
+func (pub *RecordingPublisher) EventsOn(topic string, evtType ebus.EventType) []RecordedEvent {
+	evtType = evtType.Normalize()
+
+	var events []RecordedEvent
+	for _, rec := range pub.Events() {
+		if len(topic) > 0 && rec.Topic != topic {
+			continue
+		}
+		if !evtType.IsEmpty() && rec.Metadata().EventType.Normalize() != evtType {
+			continue
+		}
+		events = append(events, rec)
+	}
+	return events
+}
+
+// Reset 清空已记录的事件
+func (pub *RecordingPublisher) Reset() {
+	pub.lock.Lock()
+	defer pub.lock.Unlock()
+
+	pub.events = nil
+}
+
+// AssertPublished 断言发布了指定类型的事件到指定主题, 返回最后一个匹配的事件
+func (pub *RecordingPublisher) AssertPublished(t testing.TB, topic string, evtType ebus.EventType) RecordedEvent {
+	t.Helper()
+
+	events := pub.EventsOn(topic, evtType)
+	if len(events) == 0 {
+		t.Fatalf("ebustest: 没有发布事件(%s)到主题(%s), 已发布: %s", evtType, topic, pub.describe())
+		return RecordedEvent{}
+	}
+	return events[len(events)-1]
+}
+
+// AssertNotPublished 断言没有发布指定类型的事件到指定主题
+func (pub *RecordingPublisher) AssertNotPublished(t testing.TB, topic string, evtType ebus.EventType) {
+	t.Helper()
+
+	if events := pub.EventsOn(topic, evtType); len(events) > 0 {
+		t.Fatalf("ebustest: 不应该发布事件(%s)到主题(%s), 实际发布了 %d 个", evtType, topic, len(events))
+	}
+}
+
+// AssertPublishedCount 断言发布到指定主题的事件数量
+// - topic 主题, 空字符串表示所有主题
+func (pub *RecordingPublisher) AssertPublishedCount(t testing.TB, topic string, count int) {
+	t.Helper()
+
+	if events := pub.EventsOn(topic, ""); len(events) != count {
+		t.Fatalf("ebustest: 期望发布 %d 个事件到主题(%s), 实际 %d 个, 已发布: %s", count, topic, len(events), pub.describe())
+	}
+}
+
+// describe 描述已发布的事件, 用于断言失败的消息
+func (pub *RecordingPublisher) describe() string {
+	events := pub.Events()
+	if len(events) == 0 {
+		return "无"
+	}
+
+	descriptions := make([]string, 0, len(events))
+	for _, rec := range events {
+		descriptions = append(descriptions, fmt.Sprintf("%s:%s", rec.Topic, rec.Metadata().EventType))
+	}
+	return fmt.Sprint(descriptions)
+}
+
+// PublishedEvents 获取已发布的指定 Go 类型的事件, 按照发布的顺序
+//
+// 例如: ebustest.PublishedEvents[*OrderCreated](publisher)
+func PublishedEvents[T ebus.Event](pub *RecordingPublisher) []T {
+	var events []T
+	for _, rec := range pub.Events() {
+		if event, ok := rec.Event.(T); ok {
+			events = append(events, event)
+		}
+	}
+	return events
+}
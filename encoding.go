@@ -0,0 +1,51 @@
+package ebus
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// maxPooledBufferSize 超过该容量的缓冲区不放回池中, 避免偶发的大事件长期占用内存
+const maxPooledBufferSize = 64 << 10
+
+// encodeBuffer 可以复用的编码缓冲区
+type encodeBuffer struct {
+	buf     bytes.Buffer
+	encoder *json.Encoder
+}
+
+var encodeBufferPool = sync.Pool{
+	New: func() any {
+		b := &encodeBuffer{}
+		b.encoder = json.NewEncoder(&b.buf)
+		return b
+	},
+}
+
+// acquireEncodeBuffer 从池中获取编码缓冲区, 使用完毕之后必须调用 release
+func acquireEncodeBuffer() *encodeBuffer {
+	return encodeBufferPool.Get().(*encodeBuffer)
+}
+
+// release 把编码缓冲区放回池中, 之后不能再使用 encode 返回的数据
+func (b *encodeBuffer) release() {
+	if b.buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	b.buf.Reset()
+	encodeBufferPool.Put(b)
+}
+
+// encode 编码为 JSON, 与 json.Marshal 的结果相同
+//
+// 返回的数据引用缓冲区, 只在下一次 encode 或者 release 之前有效
+func (b *encodeBuffer) encode(value any) ([]byte, error) {
+	b.buf.Reset()
+	if err := b.encoder.Encode(value); err != nil {
+		return nil, err
+	}
+
+	// 去掉 json.Encoder 追加的换行符
+	return bytes.TrimSuffix(b.buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,96 @@
+package ebus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/nf5lab/broker"
+)
+
+// discardPublisher 丢弃所有消息的 broker.Publisher, 基准测试只统计 ebus 自身的开销
+type discardPublisher struct{}
+
+func (discardPublisher) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	return nil
+}
+
+func (discardPublisher) Close() error {
+	return nil
+}
+
+func TestEncodeBufferMatchesMarshal(t *testing.T) {
+	event := newTestEvent("order-<1>", 42)
+
+	want, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("json.Marshal 失败: %v", err)
+	}
+
+	buffer := acquireEncodeBuffer()
+	defer buffer.release()
+
+	// 连续编码两次, 确认缓冲区复用之后结果不变
+	for range 2 {
+		got, err := buffer.encode(event)
+		if err != nil {
+			t.Fatalf("编码失败: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("编码结果 = %s, 期望 %s", got, want)
+		}
+	}
+}
+
+// BenchmarkEncodePayload 比较负载编码使用池化缓冲区之前 (json.Marshal) 与之后的分配
+func BenchmarkEncodePayload(b *testing.B) {
+	event := newTestEvent("order-1", 42)
+
+	b.Run("marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := json.Marshal(event); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			buffer := acquireEncodeBuffer()
+			if _, err := buffer.encode(event); err != nil {
+				b.Fatal(err)
+			}
+			buffer.release()
+		}
+	})
+}
+
+// BenchmarkPublish 发布单个事件的完整开销
+//
+// JSONCodec 还需要编码事件信封, 消息体只包含负载的编解码器直接使用池化缓冲区中的负载
+func BenchmarkPublish(b *testing.B) {
+	codecs := []struct {
+		name  string
+		codec Codec
+	}{
+		{name: "envelope", codec: JSONCodec{}},
+		{name: "payload-only", codec: CloudEventsBinaryCodec{}},
+	}
+
+	for _, tt := range codecs {
+		b.Run(tt.name, func(b *testing.B) {
+			publisher := NewPublisher(discardPublisher{}, WithRegistry(newTestRegistry(b)), WithCodec(tt.codec))
+			ctx := context.Background()
+
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := publisher.Publish(ctx, "orders", newTestEvent("order-1", 42)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
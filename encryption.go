@@ -0,0 +1,114 @@
+package ebus
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nf5lab/broker"
+)
+
+var (
+	// ErrEncryptionKeyMissing 消息已加密, 但是订阅者没有设置解密的密钥提供者
+	ErrEncryptionKeyMissing = errors.New("ebus: 消息已加密, 没有解密的密钥")
+)
+
+const (
+	// HeaderEncryption 消息体的加密算法
+	HeaderEncryption = "x-event-encryption"
+
+	// HeaderEncryptionKeyId 加密使用的密钥ID, 用于密钥轮换
+	HeaderEncryptionKeyId = "x-event-encryption-key-id"
+
+	// EncryptionAESGCM AES-GCM 加密, 密钥长度决定 AES-128, AES-192 或者 AES-256
+	EncryptionAESGCM = "aes-gcm"
+)
+
+// newAESGCM 创建 AES-GCM 加密器
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptionAdditionalData 认证的附加数据, 把密文绑定到事件ID, 防止在消息之间替换密文
+func encryptionAdditionalData(message *broker.Message) []byte {
+	eventId, _ := message.GetHeaderString(HeaderEventId)
+	return []byte(eventId)
+}
+
+// encryptMessage 按照选项加密消息体, 在压缩之后调用
+//
+// 密文的格式为 随机数 + AES-GCM 密文
+func (opts *Options) encryptMessage(ctx context.Context, message *broker.Message) error {
+	if opts.EncryptionKeys == nil {
+		return nil
+	}
+
+	keyId, key, err := opts.EncryptionKeys.CurrentKey(ctx)
+	if err != nil {
+		return fmt.Errorf("ebus: 获取加密密钥失败: %w", err)
+	}
+
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return fmt.Errorf("ebus: 加密密钥(%s)无效: %w", keyId, err)
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(message.Body)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("ebus: 生成随机数失败: %w", err)
+	}
+
+	message.Body = aead.Seal(nonce, nonce, message.Body, encryptionAdditionalData(message))
+	message.AddHeader(HeaderEncryption, EncryptionAESGCM)
+	message.AddHeader(HeaderEncryptionKeyId, keyId)
+	return nil
+}
+
+// decryptBody 按照消息头解密消息体, 没有加密的消息原样返回
+func (opts *Options) decryptBody(ctx context.Context, message *broker.Message) ([]byte, error) {
+	algorithm, _ := message.GetHeaderString(HeaderEncryption)
+	algorithm = strings.ToLower(strings.TrimSpace(algorithm))
+
+	if len(algorithm) == 0 {
+		return message.Body, nil
+	}
+
+	if algorithm != EncryptionAESGCM {
+		return nil, fmt.Errorf("ebus: 不支持的加密算法: %s", algorithm)
+	}
+
+	if opts.EncryptionKeys == nil {
+		return nil, ErrEncryptionKeyMissing
+	}
+
+	keyId, _ := message.GetHeaderString(HeaderEncryptionKeyId)
+	key, err := opts.EncryptionKeys.LookupKey(ctx, keyId)
+	if err != nil {
+		return nil, fmt.Errorf("ebus: 获取解密密钥失败: %w", err)
+	}
+
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, fmt.Errorf("ebus: 解密密钥(%s)无效: %w", keyId, err)
+	}
+
+	if len(message.Body) < aead.NonceSize() {
+		return nil, fmt.Errorf("ebus: 消息(%s)解密失败: 密文太短", message.Id)
+	}
+
+	nonce, ciphertext := message.Body[:aead.NonceSize()], message.Body[aead.NonceSize():]
+	body, err := aead.Open(nil, nonce, ciphertext, encryptionAdditionalData(message))
+	if err != nil {
+		return nil, fmt.Errorf("ebus: 消息(%s)解密失败: %w", message.Id, err)
+	}
+
+	return body, nil
+}
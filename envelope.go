@@ -0,0 +1,125 @@
+package ebus
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var (
+	// ErrUnsupportedEnvelopeVersion 事件信封格式的版本不受支持, 一般是生产者先升级了
+	ErrUnsupportedEnvelopeVersion = errors.New("ebus: 不支持的事件信封版本")
+)
+
+// 解码失败的类别, 订阅者返回的错误支持 errors.Is, 调用者和死信策略可以据此分支处理
+var (
+	// ErrEmptyBody 消息体为空
+	ErrEmptyBody = errors.New("ebus: 事件数据为空")
+
+	// ErrEnvelopeDecode 事件信封或者事件容器无法解码
+	ErrEnvelopeDecode = errors.New("ebus: 事件信封解码失败")
+
+	// ErrMetadataInvalid 事件信封中的元数据缺失或者无效
+	ErrMetadataInvalid = errors.New("ebus: 事件元数据无效")
+
+	// ErrPayloadDecode 事件负载缺失或者无法解码为注册的事件类型
+	ErrPayloadDecode = errors.New("ebus: 事件负载解码失败")
+
+	// ErrMetadataMismatch 解码之后事件的元数据与事件信封中的元数据不一致
+	ErrMetadataMismatch = errors.New("ebus: 事件元数据不匹配")
+)
+
+const (
+	// EnvelopeVersionUnknown 没有标记版本的事件信封, 按照负载的形式推断 (旧版本的发布者)
+	EnvelopeVersionUnknown = 0
+
+	// EnvelopeVersion1 负载编码为 base64 字符串
+	EnvelopeVersion1 = 1
+
+	// EnvelopeVersion2 负载直接以 JSON 嵌入事件信封
+	EnvelopeVersion2 = 2
+
+	// EnvelopeVersion 发布者使用的事件信封格式的版本
+	EnvelopeVersion = EnvelopeVersion2
+)
+
+// envelopeDecoder 按照事件信封格式的版本解码负载
+type envelopeDecoder func(env *Envelope, codec Codec) error
+
+// envelopeDecoders 各个版本的解码函数
+//
+// 事件信封格式变化时 (例如二进制负载, 压缩标记) 增加新的版本, 已有的消费者可以明确地拒绝不认识的版本
+var envelopeDecoders = map[int]envelopeDecoder{
+	EnvelopeVersionUnknown: decodeEnvelopeUnknown,
+	EnvelopeVersion1:       decodeEnvelopeV1,
+	EnvelopeVersion2:       decodeEnvelopeV2,
+}
+
+// envelopeVersionFromHeaders 从消息头读取事件信封格式的版本, 不存在时返回 EnvelopeVersionUnknown
+func envelopeVersionFromHeaders(headers map[string]any) int {
+	value, _ := headers[HeaderEnvelopeVersion].(string)
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		return EnvelopeVersionUnknown
+	}
+	return version
+}
+
+// decodeVersion 按照事件信封格式的版本解码负载
+func (env *Envelope) decodeVersion(codec Codec) error {
+	decoder, exists := envelopeDecoders[env.Version]
+	if !exists {
+		return fmt.Errorf("%w: %d", ErrUnsupportedEnvelopeVersion, env.Version)
+	}
+	return decoder(env, codec)
+}
+
+// decodeEnvelopeUnknown 没有标记版本时, 按照负载的形式推断: JSON 字符串为 base64 负载
+func decodeEnvelopeUnknown(env *Envelope, codec Codec) error {
+	if !isJSONCodec(codec) {
+		return nil
+	}
+
+	payload := bytes.TrimSpace(env.Payload)
+	if len(payload) > 0 && payload[0] == '"' {
+		return decodeEnvelopeV1(env, codec)
+	}
+
+	return decodeEnvelopeV2(env, codec)
+}
+
+// decodeEnvelopeV1 把 base64 负载解码为 JSON
+func decodeEnvelopeV1(env *Envelope, codec Codec) error {
+	if !isJSONCodec(codec) {
+		return nil
+	}
+
+	payload := bytes.TrimSpace(env.Payload)
+	if len(payload) == 0 || bytes.Equal(payload, []byte("null")) {
+		env.Payload = nil
+		return nil
+	}
+
+	var legacy []byte
+	if err := json.Unmarshal(payload, &legacy); err != nil {
+		return fmt.Errorf("%w: %w", ErrPayloadDecode, err)
+	}
+
+	env.Payload = legacy
+	return nil
+}
+
+// decodeEnvelopeV2 负载已经是 JSON, 把 null 视为空负载
+func decodeEnvelopeV2(env *Envelope, codec Codec) error {
+	if !isJSONCodec(codec) {
+		return nil
+	}
+
+	if payload := bytes.TrimSpace(env.Payload); len(payload) == 0 || bytes.Equal(payload, []byte("null")) {
+		env.Payload = nil
+	}
+
+	return nil
+}
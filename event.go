@@ -1,9 +1,8 @@
 package ebus
 
 import (
-	"fmt"
+	"encoding/json"
 	"strings"
-	"time"
 )
 
 const (
@@ -20,10 +19,24 @@ type Event interface {
 	Validate() error
 }
 
+// EnvelopeMetadataSetter 元数据取自事件信封的事件
+//
+// 负载中不包含元数据的事件 (例如 GenericEvent, protobuf 事件) 实现此接口, 订阅者解码负载之后设置元数据
+type EnvelopeMetadataSetter interface {
+
+	// SetEnvelopeMetadata 设置事件信封的元数据
+	SetEnvelopeMetadata(meta *Metadata)
+}
+
 // Envelope 表示事件信封
+//
+// 负载直接以 JSON 嵌入事件信封, 不再编码为 base64 字符串
+// 订阅者兼容旧版本发布者的 base64 负载 (参考 WithLegacyPayloadEncoding 和 EnvelopeVersion)
+// 使用其它编解码器 (参考 Codec) 时, 负载是该编解码器编码的数据
 type Envelope struct {
-	Metadata *Metadata `json:"metadata"` // 事件元数据
-	Payload  []byte    `json:"payload"`  // 事件负载
+	Version  int             `json:"envelopeVersion,omitempty"` // 事件信封格式的版本, 参考 EnvelopeVersion
+	Metadata *Metadata       `json:"metadata"`                  // 事件元数据
+	Payload  json.RawMessage `json:"payload"`                   // 事件负载
 }
 
 // SchemaVersion 表示事件模型版本
@@ -85,11 +98,16 @@ func (et EventType) IsEmpty() bool {
 
 // Metadata 表示事件元数据
 type Metadata struct {
-	SchemaVersion SchemaVersion `json:"schemaVersion"` // 模型版本
-	EventId       string        `json:"eventId"`       // 事件ID, 全局唯一
-	EventSource   EventSource   `json:"eventSource"`   // 事件来源
-	EventType     EventType     `json:"eventType"`     // 事件类型
-	EventTime     int64         `json:"eventTime"`     // 事件时间, Unix时间戳, 单位秒
+	SchemaVersion SchemaVersion     `json:"schemaVersion"`           // 模型版本
+	EventId       string            `json:"eventId"`                 // 事件ID, 全局唯一
+	EventSource   EventSource       `json:"eventSource"`             // 事件来源
+	EventType     EventType         `json:"eventType"`               // 事件类型
+	EventTime     int64             `json:"eventTime"`               // 事件时间, Unix时间戳, 单位秒
+	CorrelationId string            `json:"correlationId,omitempty"` // 关联ID, 用于串联同一业务流程的事件
+	CausationId   string            `json:"causationId,omitempty"`   // 因果ID, 导致该事件产生的事件的ID
+	TenantId      string            `json:"tenantId,omitempty"`      // 租户ID, 多租户共享主题时使用
+	PartitionKey  string            `json:"partitionKey,omitempty"`  // 分区键, 同一分区键的事件保持顺序, 一般为聚合ID
+	Extensions    map[string]string `json:"extensions,omitempty"`    // 扩展属性, 例如区域, 功能开关
 }
 
 func (meta *Metadata) Normalize() {
@@ -97,38 +115,53 @@ func (meta *Metadata) Normalize() {
 	meta.EventId = strings.TrimSpace(meta.EventId)
 	meta.EventSource = meta.EventSource.Normalize()
 	meta.EventType = meta.EventType.Normalize()
+	meta.CorrelationId = strings.TrimSpace(meta.CorrelationId)
+	meta.CausationId = strings.TrimSpace(meta.CausationId)
+	meta.TenantId = strings.TrimSpace(meta.TenantId)
+	meta.PartitionKey = strings.TrimSpace(meta.PartitionKey)
+	meta.Extensions = normalizeExtensions(meta.Extensions)
 }
 
-func (meta *Metadata) Validate() error {
-	meta.Normalize()
-
-	if meta.SchemaVersion.IsEmpty() {
-		return fmt.Errorf("ebus: 模型版本不能为空")
+// normalizeExtensions 规范扩展属性: 去除键的首尾空白, 丢弃空键
+func normalizeExtensions(extensions map[string]string) map[string]string {
+	if len(extensions) == 0 {
+		return nil
 	}
 
-	if len(meta.EventId) == 0 {
-		return fmt.Errorf("ebus: 事件ID不能为空")
+	normalized := make(map[string]string, len(extensions))
+	for key, value := range extensions {
+		if key = strings.TrimSpace(key); len(key) > 0 {
+			normalized[key] = value
+		}
 	}
 
-	if meta.EventSource.IsEmpty() {
-		return fmt.Errorf("ebus: 事件来源不能为空")
+	if len(normalized) == 0 {
+		return nil
 	}
+	return normalized
+}
 
-	if meta.EventType.IsEmpty() {
-		return fmt.Errorf("ebus: 事件类型不能为空")
-	}
+// Extension 获取扩展属性
+func (meta *Metadata) Extension(key string) (string, bool) {
+	value, ok := meta.Extensions[key]
+	return value, ok
+}
 
-	if meta.EventTime <= 0 {
-		return fmt.Errorf("ebus: 事件时间必须大于0")
+// SetExtension 设置扩展属性
+func (meta *Metadata) SetExtension(key string, value string) {
+	if key = strings.TrimSpace(key); len(key) == 0 {
+		return
 	}
 
-	// 如果这条消息来自未来的时间
-	// 说明发送者的时钟比接收者快
-	// 允许最大300秒的时钟漂移
-	const maxFutureSecs = 300
-	if meta.EventTime > (time.Now().Unix() + maxFutureSecs) {
-		return fmt.Errorf("ebus: 事件时间超出允许范围(>%d秒)", maxFutureSecs)
+	if meta.Extensions == nil {
+		meta.Extensions = make(map[string]string)
 	}
+	meta.Extensions[key] = value
+}
 
-	return nil
+// Validate 使用默认的元数据校验器校验元数据
+//
+// 校验规则可以通过 AddMetadataRule / RemoveMetadataRule 调整
+func (meta *Metadata) Validate() error {
+	return defaultMetadataValidator.Validate(meta)
 }
@@ -3,8 +3,12 @@ package ebus
 import (
 	"errors"
 	"fmt"
+	"maps"
 	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var (
@@ -17,21 +21,158 @@ var (
 // 用于解码时创建事件实例
 type EventFactory func() (Event, error)
 
+// Registry 事件工厂注册表
+//
+// 默认使用全局注册表 (参考 DefaultRegistry)
+// 同一个程序中的多个模块注册了冲突的事件工厂时, 可以为每个事件总线创建独立的注册表, 通过 WithRegistry 传给发布者和订阅者
+// 升级函数, 降级函数, 兜底事件工厂和模型版本回退的配置也属于注册表, 包级别的函数使用全局注册表
+type Registry struct {
+	name      string                                   // 命名空间, 全局注册表和匿名注册表为空
+	factories atomic.Pointer[map[string]registryEntry] // 事件工厂的只读快照, 写时复制, 读取不需要加锁
+	hooks     []FactoryHook                            // 注册回调
+	lock      sync.Mutex                               // 写锁, 保护修改快照和注册回调
+
+	convertLock     sync.RWMutex                 // 保护事件转换和回退的配置
+	upcasters       map[string]upcasterEntry     // 升级函数, 键为源模型版本的事件工厂键
+	downcasters     map[string]Downcaster        // 降级函数
+	fallbacks       map[EventSource]EventFactory // 兜底事件工厂, 空来源表示全局
+	versionFallback bool                         // 是否开启模型版本回退
+	versionVeto     SchemaVersionVetoFunc        // 模型版本回退的否决函数, 可以为 nil
+}
+
+// FactoryInfo 已注册的事件工厂信息
+type FactoryInfo struct {
+	SchemaVersion SchemaVersion // 模型版本
+	EventSource   EventSource   // 事件来源
+	EventType     EventType     // 事件类型
+	RegisteredAt  time.Time     // 注册时间
+}
+
+// key 构建事件工厂键
+func (info FactoryInfo) key() string {
+	return buildEventFactoryKey(info.SchemaVersion, info.EventSource, info.EventType)
+}
+
+// FactoryHook 事件工厂的注册回调
+type FactoryHook func(info FactoryInfo)
+
+// registryEntry 注册表中的事件工厂
+type registryEntry struct {
+	factory EventFactory
+	info    FactoryInfo
+	schema  *jsonSchema // 负载的 JSON Schema, 没有时为空
+}
+
+// NewRegistry 创建空的事件工厂注册表
+func NewRegistry() *Registry {
+	registry := &Registry{}
+	registry.factories.Store(&map[string]registryEntry{})
+	return registry
+}
+
+// snapshot 获取事件工厂的只读快照
+func (r *Registry) snapshot() map[string]registryEntry {
+	if factories := r.factories.Load(); factories != nil {
+		return *factories
+	}
+	return nil
+}
+
+// update 复制快照, 修改之后替换快照, 调用者必须持有写锁
+//
+// 注册事件工厂一般只发生在启动阶段, 写时复制让高吞吐的解码路径不需要竞争锁
+func (r *Registry) update(modify func(factories map[string]registryEntry)) {
+	factories := maps.Clone(r.snapshot())
+	if factories == nil {
+		factories = make(map[string]registryEntry)
+	}
+	modify(factories)
+	r.factories.Store(&factories)
+}
+
 var (
-	eventFactoryRegistry     = map[string]EventFactory{} // 事件工厂注册表
-	eventFactoryRegistryLock = sync.RWMutex{}            // 事件工厂注册表锁
+	defaultRegistry = NewRegistry() // 全局事件工厂注册表
+
+	registryNamespaces     = map[string]*Registry{} // 命名空间注册表
+	registryNamespacesLock = sync.Mutex{}           // 命名空间注册表锁
 )
 
+// NewRegistryNamespace 获取命名空间的事件工厂注册表, 不存在时创建
+//
+// 同一个程序中的不同团队可以在各自的命名空间中注册相同的 (模型版本, 事件来源, 事件类型), 不会发生冲突
+// 相同名称 (忽略大小写) 返回同一个注册表, 各个包可以在 init 中向同一个命名空间注册
+// - name 命名空间名称, 为空时返回全局注册表
+func NewRegistryNamespace(name string) *Registry {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if len(name) == 0 {
+		return defaultRegistry
+	}
+
+	registryNamespacesLock.Lock()
+	defer registryNamespacesLock.Unlock()
+
+	if registry, exists := registryNamespaces[name]; exists {
+		return registry
+	}
+
+	registry := NewRegistry()
+	registry.name = name
+	registryNamespaces[name] = registry
+	return registry
+}
+
+// Name 获取注册表的命名空间, 全局注册表和 NewRegistry 创建的注册表为空
+func (r *Registry) Name() string {
+	return r.name
+}
+
+// describeKey 在事件工厂键前面加上命名空间, 用于错误信息
+func (r *Registry) describeKey(factoryKey string) string {
+	if len(r.name) == 0 {
+		return factoryKey
+	}
+	return r.name + ":" + factoryKey
+}
+
+// DefaultRegistry 获取全局事件工厂注册表
+//
+// 包级别的注册函数 (RegisterEventFactory 等) 都使用全局注册表
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
 func buildEventFactoryKey(scmVersion SchemaVersion, evtSource EventSource, evtType EventType) string {
 	return string(scmVersion) + "|" + string(evtSource) + "|" + string(evtType)
 }
 
-// RegisterEventFactory 注册事件工厂
+// Register 注册事件工厂
 // - scmVersion 模型版本
 // - evtSource  事件来源
 // - evtType    事件类型
 // - evtFactory 事件工厂
-func RegisterEventFactory(scmVersion SchemaVersion, evtSource EventSource, evtType EventType, evtFactory EventFactory) error {
+func (r *Registry) Register(scmVersion SchemaVersion, evtSource EventSource, evtType EventType, evtFactory EventFactory) error {
+	return r.register(scmVersion, evtSource, evtType, evtFactory, nil)
+}
+
+// RegisterWithSchema 注册事件工厂, 并附加负载的 JSON Schema
+//
+// 发布者在编码之后, 订阅者在解码之前按照模式校验 JSON 负载, 补充事件的 Validate 遗漏的契约检查
+// 支持的关键字参考 jsonSchema, Replace 替换事件工厂时同时移除模式
+// - scmVersion 模型版本
+// - evtSource  事件来源
+// - evtType    事件类型
+// - evtFactory 事件工厂
+// - schema     JSON Schema 文档
+func (r *Registry) RegisterWithSchema(scmVersion SchemaVersion, evtSource EventSource, evtType EventType, evtFactory EventFactory, schema []byte) error {
+	compiled, err := compileJSONSchema(schema)
+	if err != nil {
+		return err
+	}
+	return r.register(scmVersion, evtSource, evtType, evtFactory, compiled)
+}
+
+// register 注册事件工厂
+func (r *Registry) register(scmVersion SchemaVersion, evtSource EventSource, evtType EventType, evtFactory EventFactory, schema *jsonSchema) error {
 	scmVersion = scmVersion.Normalize()
 	if scmVersion.IsEmpty() {
 		return fmt.Errorf("ebus: 模型版本不能为空")
@@ -54,34 +195,56 @@ func RegisterEventFactory(scmVersion SchemaVersion, evtSource EventSource, evtTy
 	// 在锁外面构建key, 减少锁的持有时间
 	factoryKey := buildEventFactoryKey(scmVersion, evtSource, evtType)
 
-	eventFactoryRegistryLock.Lock()
-	defer eventFactoryRegistryLock.Unlock()
+	info := FactoryInfo{
+		SchemaVersion: scmVersion,
+		EventSource:   evtSource,
+		EventType:     evtType,
+		RegisteredAt:  time.Now(),
+	}
+
+	r.lock.Lock()
+	if _, exists := r.snapshot()[factoryKey]; exists {
+		r.lock.Unlock()
+		return fmt.Errorf("%w: %s", ErrEventFactoryExists, r.describeKey(factoryKey))
+	}
+	r.update(func(factories map[string]registryEntry) {
+		factories[factoryKey] = registryEntry{
+			factory: evtFactory,
+			info:    info,
+			schema:  schema,
+		}
+	})
+	hooks := r.hooks
+	r.lock.Unlock()
 
-	if _, exists := eventFactoryRegistry[factoryKey]; exists {
-		return fmt.Errorf("%w: %s", ErrEventFactoryExists, factoryKey)
-	} else {
-		eventFactoryRegistry[factoryKey] = evtFactory
+	// 在锁外面调用回调, 避免回调中访问注册表时死锁
+	for _, hook := range hooks {
+		hook(info)
 	}
 
 	return nil
 }
 
-// MustRegisterEventFactory 注册事件工厂, 如果注册失败则 panic
+// MustRegister 注册事件工厂, 如果注册失败则 panic
 // - scmVersion 模型版本
 // - evtSource  事件来源
 // - evtType    事件类型
 // - evtFactory 事件工厂
-func MustRegisterEventFactory(scmVersion SchemaVersion, evtSource EventSource, evtType EventType, evtFactory EventFactory) {
-	if err := RegisterEventFactory(scmVersion, evtSource, evtType, evtFactory); err != nil {
+func (r *Registry) MustRegister(scmVersion SchemaVersion, evtSource EventSource, evtType EventType, evtFactory EventFactory) {
+	if err := r.Register(scmVersion, evtSource, evtType, evtFactory); err != nil {
 		panic(err)
 	}
 }
 
-// GetEventFactory 获取事件工厂
+// Get 获取事件工厂
+//
+// 没有精确匹配的事件工厂时, 依次尝试:
+// - 同一事件来源和事件类型的最高模型版本 (需要开启, 参考 EnableSchemaVersionFallback)
+// - 兜底事件工厂 (参考 RegisterFallbackFactory)
 // - scmVersion 模型版本
 // - evtSource  事件来源
 // - evtType    事件类型
-func GetEventFactory(scmVersion SchemaVersion, evtSource EventSource, evtType EventType) (EventFactory, error) {
+func (r *Registry) Get(scmVersion SchemaVersion, evtSource EventSource, evtType EventType) (EventFactory, error) {
 	scmVersion = scmVersion.Normalize()
 	if scmVersion.IsEmpty() {
 		return nil, fmt.Errorf("ebus: 模型版本不能为空")
@@ -97,24 +260,36 @@ func GetEventFactory(scmVersion SchemaVersion, evtSource EventSource, evtType Ev
 		return nil, fmt.Errorf("ebus: 事件类型不能为空")
 	}
 
-	// 在锁外面构建key, 减少锁的持有时间
+	factory, _, err := r.lookup(scmVersion, evtSource, evtType)
+	return factory, err
+}
+
+// lookup 查找事件工厂, 参数已经规范化
+//
+// exact 表示是否精确匹配, 回退的结果取决于可以修改的回退配置, 不能缓存
+func (r *Registry) lookup(scmVersion SchemaVersion, evtSource EventSource, evtType EventType) (factory EventFactory, exact bool, err error) {
 	factoryKey := buildEventFactoryKey(scmVersion, evtSource, evtType)
 
-	eventFactoryRegistryLock.RLock()
-	defer eventFactoryRegistryLock.RUnlock()
+	if entry, exists := r.snapshot()[factoryKey]; exists {
+		return entry.factory, true, nil
+	}
+
+	if factory, exists := r.getVersionFallback(scmVersion, evtSource, evtType); exists {
+		return factory, false, nil
+	}
 
-	if factory, exists := eventFactoryRegistry[factoryKey]; exists {
-		return factory, nil
-	} else {
-		return nil, fmt.Errorf("%w: %s", ErrEventFactoryNotFound, factoryKey)
+	if factory, exists := r.getFallbackFactory(evtSource); exists {
+		return factory, false, nil
 	}
+
+	return nil, false, fmt.Errorf("%w: %s", ErrEventFactoryNotFound, r.describeKey(factoryKey))
 }
 
-// ExistsEventFactory 检查事件工厂是否存在
+// Exists 检查事件工厂是否存在 (不包括兜底事件工厂)
 // - scmVersion 模型版本
 // - evtSource  事件来源
 // - evtType    事件类型
-func ExistsEventFactory(scmVersion SchemaVersion, evtSource EventSource, evtType EventType) bool {
+func (r *Registry) Exists(scmVersion SchemaVersion, evtSource EventSource, evtType EventType) bool {
 	scmVersion = scmVersion.Normalize()
 	if scmVersion.IsEmpty() {
 		return false
@@ -130,28 +305,293 @@ func ExistsEventFactory(scmVersion SchemaVersion, evtSource EventSource, evtType
 		return false
 	}
 
-	// 在锁外面构建key, 减少锁的持有时间
 	factoryKey := buildEventFactoryKey(scmVersion, evtSource, evtType)
 
-	eventFactoryRegistryLock.RLock()
-	defer eventFactoryRegistryLock.RUnlock()
-
-	_, exists := eventFactoryRegistry[factoryKey]
+	_, exists := r.snapshot()[factoryKey]
 	return exists
 }
 
-// ListEventFactoryKeys 列出已注册的事件工厂键
+// Keys 列出已注册的事件工厂键
 //
 // 返回的键格式为 "模型版本|事件来源|事件类型"
-func ListEventFactoryKeys() []string {
-	eventFactoryRegistryLock.RLock()
-	defer eventFactoryRegistryLock.RUnlock()
+func (r *Registry) Keys() []string {
+	factories := r.snapshot()
 
-	keys := make([]string, 0, len(eventFactoryRegistry))
-	for key := range eventFactoryRegistry {
+	keys := make([]string, 0, len(factories))
+	for key := range factories {
 		keys = append(keys, key)
 	}
 
 	slices.Sort(keys)
 	return keys
 }
+
+// Factories 列出已注册的事件工厂信息
+//
+// 按照事件来源, 事件类型, 模型版本排序
+func (r *Registry) Factories() []FactoryInfo {
+	factories := r.snapshot()
+
+	infos := make([]FactoryInfo, 0, len(factories))
+	for _, entry := range factories {
+		infos = append(infos, entry.info)
+	}
+
+	slices.SortFunc(infos, func(a, b FactoryInfo) int {
+		if c := strings.Compare(string(a.EventSource), string(b.EventSource)); c != 0 {
+			return c
+		}
+		if c := strings.Compare(string(a.EventType), string(b.EventType)); c != 0 {
+			return c
+		}
+		return CompareSchemaVersion(a.SchemaVersion, b.SchemaVersion)
+	})
+	return infos
+}
+
+// Replace 注册或者替换事件工厂
+//
+// 主要用于测试中替换事件类型的桩实现
+// - scmVersion 模型版本
+// - evtSource  事件来源
+// - evtType    事件类型
+// - evtFactory 事件工厂
+func (r *Registry) Replace(scmVersion SchemaVersion, evtSource EventSource, evtType EventType, evtFactory EventFactory) error {
+	info, err := newFactoryInfo(scmVersion, evtSource, evtType)
+	if err != nil {
+		return err
+	}
+
+	if evtFactory == nil {
+		return fmt.Errorf("ebus: 事件工厂不能为空")
+	}
+
+	info.RegisteredAt = time.Now()
+
+	r.lock.Lock()
+	r.update(func(factories map[string]registryEntry) {
+		factories[info.key()] = registryEntry{
+			factory: evtFactory,
+			info:    info,
+		}
+	})
+	hooks := r.hooks
+	r.lock.Unlock()
+
+	for _, hook := range hooks {
+		hook(info)
+	}
+
+	return nil
+}
+
+// Unregister 注销事件工厂, 返回事件工厂是否存在
+// - scmVersion 模型版本
+// - evtSource  事件来源
+// - evtType    事件类型
+func (r *Registry) Unregister(scmVersion SchemaVersion, evtSource EventSource, evtType EventType) bool {
+	info, err := newFactoryInfo(scmVersion, evtSource, evtType)
+	if err != nil {
+		return false
+	}
+
+	factoryKey := info.key()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, exists := r.snapshot()[factoryKey]; !exists {
+		return false
+	}
+
+	r.update(func(factories map[string]registryEntry) {
+		delete(factories, factoryKey)
+	})
+	return true
+}
+
+// OnRegistered 添加注册回调
+//
+// 注册或者替换事件工厂之后调用, 例如把事件目录同步到集中的模型服务
+// 添加回调时, 会对已注册的事件工厂立即调用一次, 因此不会错过 init 中注册的事件
+// 回调在注册者的协程中同步调用, 不应该阻塞
+// - hook 注册回调
+func (r *Registry) OnRegistered(hook FactoryHook) {
+	if hook == nil {
+		return
+	}
+
+	r.lock.Lock()
+	r.hooks = append(slices.Clip(r.hooks), hook)
+	factories := r.snapshot()
+	r.lock.Unlock()
+
+	infos := make([]FactoryInfo, 0, len(factories))
+	for _, entry := range factories {
+		infos = append(infos, entry.info)
+	}
+
+	for _, info := range infos {
+		hook(info)
+	}
+}
+
+// Reset 注销所有事件工厂 (不会清除注册回调)
+func (r *Registry) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.factories.Store(&map[string]registryEntry{})
+}
+
+// newFactoryInfo 规范化并校验参数, 然后构建事件工厂信息 (不包括注册时间)
+func newFactoryInfo(scmVersion SchemaVersion, evtSource EventSource, evtType EventType) (FactoryInfo, error) {
+	scmVersion = scmVersion.Normalize()
+	if scmVersion.IsEmpty() {
+		return FactoryInfo{}, fmt.Errorf("ebus: 模型版本不能为空")
+	}
+
+	evtSource = evtSource.Normalize()
+	if evtSource.IsEmpty() {
+		return FactoryInfo{}, fmt.Errorf("ebus: 事件来源不能为空")
+	}
+
+	evtType = evtType.Normalize()
+	if evtType.IsEmpty() {
+		return FactoryInfo{}, fmt.Errorf("ebus: 事件类型不能为空")
+	}
+
+	return FactoryInfo{
+		SchemaVersion: scmVersion,
+		EventSource:   evtSource,
+		EventType:     evtType,
+	}, nil
+}
+
+// RegisterEventFactory 在全局注册表中注册事件工厂
+// - scmVersion 模型版本
+// - evtSource  事件来源
+// - evtType    事件类型
+// - evtFactory 事件工厂
+func RegisterEventFactory(scmVersion SchemaVersion, evtSource EventSource, evtType EventType, evtFactory EventFactory) error {
+	return defaultRegistry.Register(scmVersion, evtSource, evtType, evtFactory)
+}
+
+// RegisterEventFactoryWithSchema 在全局注册表中注册事件工厂, 并附加负载的 JSON Schema, 参考 Registry.RegisterWithSchema
+// - scmVersion 模型版本
+// - evtSource  事件来源
+// - evtType    事件类型
+// - evtFactory 事件工厂
+// - schema     JSON Schema 文档
+func RegisterEventFactoryWithSchema(scmVersion SchemaVersion, evtSource EventSource, evtType EventType, evtFactory EventFactory, schema []byte) error {
+	return defaultRegistry.RegisterWithSchema(scmVersion, evtSource, evtType, evtFactory, schema)
+}
+
+// MustRegisterEventFactory 在全局注册表中注册事件工厂, 如果注册失败则 panic
+// - scmVersion 模型版本
+// - evtSource  事件来源
+// - evtType    事件类型
+// - evtFactory 事件工厂
+func MustRegisterEventFactory(scmVersion SchemaVersion, evtSource EventSource, evtType EventType, evtFactory EventFactory) {
+	defaultRegistry.MustRegister(scmVersion, evtSource, evtType, evtFactory)
+}
+
+// GetEventFactory 从全局注册表中获取事件工厂 (参考 Registry.Get)
+// - scmVersion 模型版本
+// - evtSource  事件来源
+// - evtType    事件类型
+func GetEventFactory(scmVersion SchemaVersion, evtSource EventSource, evtType EventType) (EventFactory, error) {
+	return defaultRegistry.Get(scmVersion, evtSource, evtType)
+}
+
+// ReplaceEventFactory 在全局注册表中注册或者替换事件工厂 (参考 Registry.Replace)
+// - scmVersion 模型版本
+// - evtSource  事件来源
+// - evtType    事件类型
+// - evtFactory 事件工厂
+func ReplaceEventFactory(scmVersion SchemaVersion, evtSource EventSource, evtType EventType, evtFactory EventFactory) error {
+	return defaultRegistry.Replace(scmVersion, evtSource, evtType, evtFactory)
+}
+
+// UnregisterEventFactory 从全局注册表中注销事件工厂, 返回事件工厂是否存在
+// - scmVersion 模型版本
+// - evtSource  事件来源
+// - evtType    事件类型
+func UnregisterEventFactory(scmVersion SchemaVersion, evtSource EventSource, evtType EventType) bool {
+	return defaultRegistry.Unregister(scmVersion, evtSource, evtType)
+}
+
+// ResetRegistryForTesting 注销全局注册表中的所有事件工厂
+//
+// 只用于测试, 会同时注销包初始化时注册的内置事件 (例如 ResultEvent)
+func ResetRegistryForTesting() {
+	defaultRegistry.Reset()
+}
+
+// ExistsEventFactory 检查全局注册表中事件工厂是否存在 (不包括兜底事件工厂)
+// - scmVersion 模型版本
+// - evtSource  事件来源
+// - evtType    事件类型
+func ExistsEventFactory(scmVersion SchemaVersion, evtSource EventSource, evtType EventType) bool {
+	return defaultRegistry.Exists(scmVersion, evtSource, evtType)
+}
+
+// ListEventFactoryKeys 列出全局注册表中已注册的事件工厂键
+//
+// 返回的键格式为 "模型版本|事件来源|事件类型"
+// 运维工具建议使用 ListEventFactories, 不需要解析字符串
+func ListEventFactoryKeys() []string {
+	return defaultRegistry.Keys()
+}
+
+// OnFactoryRegistered 为全局注册表添加注册回调 (参考 Registry.OnRegistered)
+// - hook 注册回调
+func OnFactoryRegistered(hook FactoryHook) {
+	defaultRegistry.OnRegistered(hook)
+}
+
+// ListEventFactories 列出全局注册表中已注册的事件工厂信息 (参考 Registry.Factories)
+func ListEventFactories() []FactoryInfo {
+	return defaultRegistry.Factories()
+}
+
+// RegisterEvent 注册事件类型, 自动生成事件工厂
+//
+// 类型参数 T 为事件的结构体类型, *T 必须实现 Event 接口
+// 例如: RegisterEvent[OrderCreated]("v1", "order", "created")
+// - scmVersion 模型版本
+// - evtSource  事件来源
+// - evtType    事件类型
+func RegisterEvent[T any, PT interface {
+	*T
+	Event
+}](scmVersion SchemaVersion, evtSource EventSource, evtType EventType) error {
+	return RegisterEventIn[T, PT](defaultRegistry, scmVersion, evtSource, evtType)
+}
+
+// RegisterEventIn 在指定的注册表中注册事件类型, 自动生成事件工厂
+// - registry   事件工厂注册表
+// - scmVersion 模型版本
+// - evtSource  事件来源
+// - evtType    事件类型
+func RegisterEventIn[T any, PT interface {
+	*T
+	Event
+}](registry *Registry, scmVersion SchemaVersion, evtSource EventSource, evtType EventType) error {
+	return registry.Register(scmVersion, evtSource, evtType, func() (Event, error) {
+		return PT(new(T)), nil
+	})
+}
+
+// MustRegisterEvent 注册事件类型, 如果注册失败则 panic
+// - scmVersion 模型版本
+// - evtSource  事件来源
+// - evtType    事件类型
+func MustRegisterEvent[T any, PT interface {
+	*T
+	Event
+}](scmVersion SchemaVersion, evtSource EventSource, evtType EventType) {
+	if err := RegisterEvent[T, PT](scmVersion, evtSource, evtType); err != nil {
+		panic(err)
+	}
+}
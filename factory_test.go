@@ -0,0 +1,82 @@
+package ebus
+
+import (
+	"errors"
+	"testing"
+)
+
+// upcastTestEvent 把测试事件升级到 v2, 并且记录经过的注册表
+func upcastTestEvent(tag string) Upcaster {
+	return func(old Event) (Event, error) {
+		evt := old.(*testEvent)
+
+		metadata := *evt.Meta
+		return &testEvent{Meta: &metadata, OrderId: evt.OrderId + "@" + tag, Amount: evt.Amount}, nil
+	}
+}
+
+func TestRegistryScopedConverters(t *testing.T) {
+	first := newTestRegistry(t)
+	second := newTestRegistry(t)
+
+	// 两个注册表注册冲突的升级函数, 互不影响
+	first.MustRegisterUpcaster(testVersion, "v2", testSource, testType, upcastTestEvent("first"))
+	second.MustRegisterUpcaster(testVersion, "v2", testSource, testType, upcastTestEvent("second"))
+
+	for registry, want := range map[*Registry]string{first: "order-1@first", second: "order-1@second"} {
+		upcasted, err := registry.Upcast(newTestEvent("order-1", 1))
+		if err != nil {
+			t.Fatalf("升级失败: %v", err)
+		}
+		if got := upcasted.(*testEvent).OrderId; got != want {
+			t.Errorf("升级结果 = %s, 期望 %s", got, want)
+		}
+		if version := upcasted.Metadata().SchemaVersion; version != "v2" {
+			t.Errorf("升级之后的模型版本 = %s, 期望 v2", version)
+		}
+	}
+
+	// 全局注册表没有注册升级函数
+	event, err := Upcast(newTestEvent("order-1", 1))
+	if err != nil || event.(*testEvent).OrderId != "order-1" {
+		t.Errorf("全局注册表的升级结果 = %v, %v, 期望原样返回", event, err)
+	}
+
+	// 降级函数同样只属于注册的注册表
+	downcaster := func(event Event) (Event, error) { return event, nil }
+	if err := first.RegisterDowncaster("v2", testVersion, testSource, testType, downcaster); err != nil {
+		t.Fatalf("注册降级函数失败: %v", err)
+	}
+	if err := second.RegisterDowncaster("v2", testVersion, testSource, testType, downcaster); err != nil {
+		t.Fatalf("另一个注册表注册降级函数失败: %v", err)
+	}
+	if err := first.RegisterDowncaster("v2", testVersion, testSource, testType, downcaster); !errors.Is(err, ErrDowncasterExists) {
+		t.Errorf("重复注册降级函数的错误 = %v, 期望 ErrDowncasterExists", err)
+	}
+}
+
+func TestRegistryScopedFallbacks(t *testing.T) {
+	scoped := NewRegistry()
+	if err := scoped.RegisterFallbackFactory("", NewGenericEvent); err != nil {
+		t.Fatalf("注册兜底事件工厂失败: %v", err)
+	}
+
+	if _, err := scoped.Get(testVersion, testSource, testType); err != nil {
+		t.Errorf("注册了兜底事件工厂的注册表查找失败: %v", err)
+	}
+
+	// 其他注册表不使用该兜底事件工厂
+	if _, err := NewRegistry().Get(testVersion, testSource, testType); !errors.Is(err, ErrEventFactoryNotFound) {
+		t.Errorf("其他注册表的错误 = %v, 期望 ErrEventFactoryNotFound", err)
+	}
+
+	// 模型版本回退同样按照注册表开启
+	versioned := newTestRegistry(t)
+	versioned.EnableSchemaVersionFallback(nil)
+	if _, err := versioned.Get("v9", testSource, testType); err != nil {
+		t.Errorf("开启模型版本回退之后查找失败: %v", err)
+	}
+	if _, err := newTestRegistry(t).Get("v9", testSource, testType); !errors.Is(err, ErrEventFactoryNotFound) {
+		t.Errorf("没有开启模型版本回退的错误 = %v, 期望 ErrEventFactoryNotFound", err)
+	}
+}
@@ -0,0 +1,73 @@
+package ebus
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// factoryCacheKey 事件工厂缓存的键, 避免每条消息拼接字符串
+type factoryCacheKey struct {
+	scmVersion SchemaVersion
+	evtSource  EventSource
+	evtType    EventType
+}
+
+// factoryCache 订阅级别的事件工厂缓存
+//
+// 只缓存精确匹配的事件工厂, 注册表的快照变化时整体失效
+type factoryCache struct {
+	registry *Registry
+	state    atomic.Pointer[factoryCacheState]
+}
+
+// factoryCacheState 对应注册表某个快照的缓存
+type factoryCacheState struct {
+	factories *map[string]registryEntry // 缓存对应的注册表快照
+	entries   sync.Map                  // factoryCacheKey -> EventFactory
+}
+
+func newFactoryCache(registry *Registry) *factoryCache {
+	return &factoryCache{
+		registry: registry,
+	}
+}
+
+// get 获取事件工厂, 未命中时查找注册表 (参考 Registry.Get)
+func (cache *factoryCache) get(scmVersion SchemaVersion, evtSource EventSource, evtType EventType) (EventFactory, error) {
+	cacheKey := factoryCacheKey{
+		scmVersion: scmVersion,
+		evtSource:  evtSource,
+		evtType:    evtType,
+	}
+
+	current := cache.registry.factories.Load()
+	state := cache.state.Load()
+	if state == nil || state.factories != current {
+		state = &factoryCacheState{factories: current}
+		cache.state.Store(state)
+	}
+
+	if factory, exists := state.entries.Load(cacheKey); exists {
+		return factory.(EventFactory), nil
+	}
+
+	scmVersion = scmVersion.Normalize()
+	evtSource = evtSource.Normalize()
+	evtType = evtType.Normalize()
+
+	if scmVersion.IsEmpty() || evtSource.IsEmpty() || evtType.IsEmpty() {
+		// 交给注册表返回具体的错误
+		return cache.registry.Get(scmVersion, evtSource, evtType)
+	}
+
+	factory, exact, err := cache.registry.lookup(scmVersion, evtSource, evtType)
+	if err != nil {
+		return nil, err
+	}
+
+	if exact {
+		state.entries.Store(cacheKey, factory)
+	}
+
+	return factory, nil
+}
@@ -0,0 +1,110 @@
+package ebus
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+var (
+	// 确保实现了 Event 接口
+	_ Event = (*GenericEvent)(nil)
+
+	// 确保实现了 EnvelopeMetadataSetter 接口
+	_ EnvelopeMetadataSetter = (*GenericEvent)(nil)
+)
+
+// GenericEvent 通用事件
+//
+// 负载解码为 map[string]any, 元数据取自事件信封
+// 用于兜底事件工厂: 生产者先发布了新的事件类型, 消费者尚未升级时仍然可以接收
+type GenericEvent struct {
+	meta   *Metadata
+	Fields map[string]any // 负载字段
+}
+
+// NewGenericEvent 创建通用事件, 可以直接作为兜底事件工厂
+func NewGenericEvent() (Event, error) {
+	return &GenericEvent{}, nil
+}
+
+// Metadata 获取事件元数据
+func (evt *GenericEvent) Metadata() *Metadata {
+	return evt.meta
+}
+
+// Validate 验证事件是否有效 (通用事件不校验负载)
+func (evt *GenericEvent) Validate() error {
+	return nil
+}
+
+// MarshalJSON 编码负载字段
+func (evt *GenericEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(evt.Fields)
+}
+
+// UnmarshalJSON 解码负载字段
+func (evt *GenericEvent) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &evt.Fields)
+}
+
+// SetEnvelopeMetadata 使用事件信封的元数据
+func (evt *GenericEvent) SetEnvelopeMetadata(meta *Metadata) {
+	evt.meta = meta
+}
+
+// RegisterFallbackFactory 在全局注册表中注册兜底事件工厂 (参考 Registry.RegisterFallbackFactory)
+func RegisterFallbackFactory(evtSource EventSource, evtFactory EventFactory) error {
+	return defaultRegistry.RegisterFallbackFactory(evtSource, evtFactory)
+}
+
+// UnregisterFallbackFactory 注销全局注册表中的兜底事件工厂
+func UnregisterFallbackFactory(evtSource EventSource) {
+	defaultRegistry.UnregisterFallbackFactory(evtSource)
+}
+
+// RegisterFallbackFactory 注册兜底事件工厂
+//
+// 没有精确匹配 (模型版本, 事件来源, 事件类型) 的事件工厂时, 先查找该事件来源的兜底工厂, 再查找全局兜底工厂
+// 注册已经存在的兜底工厂会替换原有的工厂
+// - evtSource  事件来源, 为空表示所有事件来源
+// - evtFactory 事件工厂, 一般使用 NewGenericEvent
+func (r *Registry) RegisterFallbackFactory(evtSource EventSource, evtFactory EventFactory) error {
+	if evtFactory == nil {
+		return fmt.Errorf("ebus: 事件工厂不能为空")
+	}
+
+	evtSource = evtSource.Normalize()
+
+	r.convertLock.Lock()
+	defer r.convertLock.Unlock()
+
+	if r.fallbacks == nil {
+		r.fallbacks = make(map[EventSource]EventFactory)
+	}
+	r.fallbacks[evtSource] = evtFactory
+	return nil
+}
+
+// UnregisterFallbackFactory 注销兜底事件工厂
+// - evtSource 事件来源, 为空表示所有事件来源
+func (r *Registry) UnregisterFallbackFactory(evtSource EventSource) {
+	evtSource = evtSource.Normalize()
+
+	r.convertLock.Lock()
+	defer r.convertLock.Unlock()
+
+	delete(r.fallbacks, evtSource)
+}
+
+// getFallbackFactory 获取兜底事件工厂, 事件来源已经规范化
+func (r *Registry) getFallbackFactory(evtSource EventSource) (EventFactory, bool) {
+	r.convertLock.RLock()
+	defer r.convertLock.RUnlock()
+
+	if factory, exists := r.fallbacks[evtSource]; exists {
+		return factory, true
+	}
+
+	factory, exists := r.fallbacks[""]
+	return factory, exists
+}
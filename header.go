@@ -10,6 +10,26 @@ const (
 	HeaderEventSource   = "x-event-source"
 	HeaderEventType     = "x-event-type"
 	HeaderEventTime     = "x-event-time"
+	HeaderCorrelationId = "x-event-correlation-id"
+	HeaderCausationId   = "x-event-causation-id"
+	HeaderTenantId      = "x-event-tenant-id"
+	HeaderHopCount      = "x-event-hop-count"
+	HeaderPath          = "x-event-path"
+	HeaderTraceParent   = "x-trace-parent"
+	HeaderRetryCount    = "x-event-retry-count"
+	HeaderPublishTime   = "x-event-publish-time"
+
+	// HeaderEnvelopeVersion 事件信封格式的版本
+	HeaderEnvelopeVersion = "x-event-envelope-version"
+
+	// HeaderLegacyCopyOf 双版本发布的旧版本消息, 值为同时发布的新模型版本 (参考 SkipLegacyCopies)
+	HeaderLegacyCopyOf = "x-event-legacy-copy-of"
+
+	// HeaderContentEncoding 消息体的内容编码, 例如 gzip
+	HeaderContentEncoding = "content-encoding"
+
+	// HeaderExtensionPrefix 扩展属性消息头的前缀
+	HeaderExtensionPrefix = "x-ext-"
 )
 
 func metadataToHeaders(meta *Metadata) map[string]string {
@@ -39,5 +59,26 @@ func metadataToHeaders(meta *Metadata) map[string]string {
 		headers[HeaderEventTime] = strconv.FormatInt(meta.EventTime, 10)
 	}
 
+	if len(meta.CorrelationId) > 0 {
+		headers[HeaderCorrelationId] = meta.CorrelationId
+	}
+
+	if len(meta.CausationId) > 0 {
+		headers[HeaderCausationId] = meta.CausationId
+	}
+
+	if len(meta.TenantId) > 0 {
+		headers[HeaderTenantId] = meta.TenantId
+	}
+
+	return headers
+}
+
+// extensionsToHeaders 把扩展属性转换为消息头
+func extensionsToHeaders(meta *Metadata) map[string]string {
+	headers := make(map[string]string, len(meta.Extensions))
+	for key, value := range meta.Extensions {
+		headers[HeaderExtensionPrefix+key] = value
+	}
 	return headers
 }
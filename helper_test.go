@@ -0,0 +1,78 @@
+package ebus
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/nf5lab/broker"
+)
+
+const (
+	testSource  EventSource   = "ebus.test"
+	testType    EventType     = "order.created"
+	testVersion SchemaVersion = "v1"
+)
+
+// testEvent 测试使用的事件
+type testEvent struct {
+	Meta    *Metadata `json:"metadata"`
+	OrderId string    `json:"orderId"`
+	Amount  int       `json:"amount"`
+}
+
+func (evt *testEvent) Metadata() *Metadata {
+	return evt.Meta
+}
+
+func (evt *testEvent) Validate() error {
+	return nil
+}
+
+// newTestEvent 创建测试事件
+func newTestEvent(orderId string, amount int) *testEvent {
+	return &testEvent{
+		Meta:    NewMetadata(testSource, testType, testVersion),
+		OrderId: orderId,
+		Amount:  amount,
+	}
+}
+
+// newTestRegistry 创建注册了测试事件的注册表
+func newTestRegistry(t testing.TB) *Registry {
+	t.Helper()
+
+	registry := NewRegistry()
+	if err := RegisterEventIn[testEvent](registry, testVersion, testSource, testType); err != nil {
+		t.Fatalf("注册测试事件失败: %v", err)
+	}
+	return registry
+}
+
+// capturePublisher 记录发布的消息的 broker.Publisher
+type capturePublisher struct {
+	lock     sync.Mutex
+	topics   []string
+	messages []*broker.Message
+}
+
+func (pub *capturePublisher) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	pub.lock.Lock()
+	defer pub.lock.Unlock()
+
+	pub.topics = append(pub.topics, topic)
+	pub.messages = append(pub.messages, msg.Clone())
+	return nil
+}
+
+func (pub *capturePublisher) Close() error {
+	return nil
+}
+
+// published 获取已发布的消息
+func (pub *capturePublisher) published() []*broker.Message {
+	pub.lock.Lock()
+	defer pub.lock.Unlock()
+
+	return append([]*broker.Message(nil), pub.messages...)
+}
@@ -0,0 +1,158 @@
+package ebus
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// 确保实现了 IdGenerator 接口
+	_ IdGenerator = (*UUIDv7Generator)(nil)
+	_ IdGenerator = (*ULIDGenerator)(nil)
+	_ IdGenerator = IdGeneratorFunc(nil)
+)
+
+// IdGenerator 事件ID生成器接口
+//
+// 实现必须是并发安全的
+type IdGenerator interface {
+
+	// NewId 生成新的ID
+	NewId() string
+}
+
+// IdGeneratorFunc 函数形式的事件ID生成器
+type IdGeneratorFunc func() string
+
+// NewId 生成新的ID
+func (fn IdGeneratorFunc) NewId() string {
+	return fn()
+}
+
+// UUIDv7Generator UUID v7 生成器 (RFC 9562)
+//
+// 前48位是毫秒时间戳, 生成的ID按时间有序
+type UUIDv7Generator struct{}
+
+// NewUUIDv7Generator 创建 UUID v7 生成器
+func NewUUIDv7Generator() *UUIDv7Generator {
+	return &UUIDv7Generator{}
+}
+
+// NewId 生成新的ID
+func (gen *UUIDv7Generator) NewId() string {
+	var uuid [16]byte
+	_, _ = rand.Read(uuid[6:])
+
+	ms := uint64(time.Now().UnixMilli())
+	uuid[0] = byte(ms >> 40)
+	uuid[1] = byte(ms >> 32)
+	uuid[2] = byte(ms >> 24)
+	uuid[3] = byte(ms >> 16)
+	uuid[4] = byte(ms >> 8)
+	uuid[5] = byte(ms)
+
+	uuid[6] = (uuid[6] & 0x0f) | 0x70 // 版本 7
+	uuid[8] = (uuid[8] & 0x3f) | 0x80 // 变体 RFC 9562
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16])
+}
+
+// crockfordAlphabet ULID 使用的 Crockford Base32 字母表
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDGenerator ULID 生成器
+//
+// 前48位是毫秒时间戳, 同一毫秒内单调递增
+type ULIDGenerator struct {
+	lock    sync.Mutex
+	lastMs  uint64
+	lastHi  uint16 // 随机部分的高16位
+	lastLow uint64 // 随机部分的低64位
+}
+
+// NewULIDGenerator 创建 ULID 生成器
+func NewULIDGenerator() *ULIDGenerator {
+	return &ULIDGenerator{}
+}
+
+// NewId 生成新的ID
+func (gen *ULIDGenerator) NewId() string {
+	gen.lock.Lock()
+	ms := uint64(time.Now().UnixMilli())
+	if ms > gen.lastMs {
+		var entropy [10]byte
+		_, _ = rand.Read(entropy[:])
+		gen.lastMs = ms
+		gen.lastHi = binary.BigEndian.Uint16(entropy[0:2])
+		gen.lastLow = binary.BigEndian.Uint64(entropy[2:10])
+	} else {
+		// 同一毫秒 (或时钟回拨) 时递增随机部分, 保证单调
+		gen.lastLow++
+		if gen.lastLow == 0 {
+			gen.lastHi++
+		}
+	}
+
+	var ulid [16]byte
+	ulid[0] = byte(gen.lastMs >> 40)
+	ulid[1] = byte(gen.lastMs >> 32)
+	ulid[2] = byte(gen.lastMs >> 24)
+	ulid[3] = byte(gen.lastMs >> 16)
+	ulid[4] = byte(gen.lastMs >> 8)
+	ulid[5] = byte(gen.lastMs)
+	binary.BigEndian.PutUint16(ulid[6:8], gen.lastHi)
+	binary.BigEndian.PutUint64(ulid[8:16], gen.lastLow)
+	gen.lock.Unlock()
+
+	return encodeCrockford(ulid)
+}
+
+// encodeCrockford 把128位数据编码为26个字符的 Crockford Base32
+func encodeCrockford(data [16]byte) string {
+	hi := binary.BigEndian.Uint64(data[0:8])
+	lo := binary.BigEndian.Uint64(data[8:16])
+
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockfordAlphabet[lo&0x1f]
+		lo = (lo >> 5) | (hi << 59)
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+var (
+	defaultIdGenerator     IdGenerator = NewUUIDv7Generator() // 默认的事件ID生成器
+	defaultIdGeneratorLock             = sync.RWMutex{}       // 默认的事件ID生成器锁
+)
+
+// DefaultIdGenerator 获取默认的事件ID生成器
+func DefaultIdGenerator() IdGenerator {
+	defaultIdGeneratorLock.RLock()
+	defer defaultIdGeneratorLock.RUnlock()
+
+	return defaultIdGenerator
+}
+
+// SetDefaultIdGenerator 设置默认的事件ID生成器
+//
+// 默认使用 UUID v7, 传入 nil 不做任何修改
+func SetDefaultIdGenerator(gen IdGenerator) {
+	if gen == nil {
+		return
+	}
+
+	defaultIdGeneratorLock.Lock()
+	defer defaultIdGeneratorLock.Unlock()
+
+	defaultIdGenerator = gen
+}
+
+// NewEventId 使用默认的事件ID生成器生成事件ID
+func NewEventId() string {
+	return DefaultIdGenerator().NewId()
+}
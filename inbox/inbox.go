@@ -0,0 +1,101 @@
+// Package inbox 实现幂等消费者的收件箱
+//
+// 消息队列保证至少一次投递, 收件箱记录每个消费者已经处理过的事件ID,
+// 重复投递的事件直接确认而不再交给处理函数, 从而在至少一次投递之上实现有效的一次处理
+//
+// 使用方法:
+//
+//	store := inbox.NewMemoryStore(24 * time.Hour)
+//	handler := ebus.Chain(handler, inbox.Middleware(store, "billing"))
+package inbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/nf5lab/ebus"
+)
+
+// DedupStore 已处理事件的存储
+//
+// 事件ID按照消费者区分, 同一个事件可以被不同的消费者 (例如不同的订阅组) 分别处理
+type DedupStore interface {
+
+	// IsProcessed 消费者是否已经处理过该事件
+	IsProcessed(ctx context.Context, consumer string, eventId string) (bool, error)
+
+	// MarkProcessed 标记消费者已经处理过该事件
+	MarkProcessed(ctx context.Context, consumer string, eventId string) error
+}
+
+// Option 收件箱中间件选项
+type Option func(*options)
+
+type options struct {
+	logger *slog.Logger
+}
+
+// WithLogger 设置日志记录器, 默认 slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(opts *options) {
+		opts.logger = logger
+	}
+}
+
+// newOptions 应用选项
+func newOptions(opts ...Option) *options {
+	options := &options{logger: slog.Default()}
+	for _, apply := range opts {
+		if apply != nil {
+			apply(options)
+		}
+	}
+	return options
+}
+
+// Middleware 跳过已经处理过的事件的中间件
+//
+// 处理函数成功之后标记事件已处理; 标记失败时只记录日志, 事件仍然视为处理成功,
+// 因为副作用已经发生, 重新投递反而会导致重复处理
+// 检查和标记不是原子的, 需要严格的一次处理时使用 SQLStore.TransactionalHandler
+// - store    已处理事件的存储
+// - consumer 消费者标识, 一般为服务名称或者订阅组
+func Middleware(store DedupStore, consumer string, opts ...Option) ebus.Middleware {
+	options := newOptions(opts...)
+
+	return func(next ebus.EventHandler) ebus.EventHandler {
+		return func(ctx context.Context, topic string, event ebus.Event) error {
+			eventId := event.Metadata().EventId
+
+			processed, err := store.IsProcessed(ctx, consumer, eventId)
+			if err != nil {
+				return fmt.Errorf("inbox: 检查事件(%s)是否已处理失败: %w", eventId, err)
+			}
+
+			if processed {
+				options.logger.DebugContext(ctx, "inbox: 跳过已处理的事件",
+					slog.String("topic", topic),
+					slog.String("consumer", consumer),
+					slog.String("eventId", eventId),
+				)
+				return nil
+			}
+
+			if err := next(ctx, topic, event); err != nil {
+				return err
+			}
+
+			if err := store.MarkProcessed(ctx, consumer, eventId); err != nil {
+				options.logger.WarnContext(ctx, "inbox: 标记事件已处理失败",
+					slog.String("topic", topic),
+					slog.String("consumer", consumer),
+					slog.String("eventId", eventId),
+					slog.Any("error", err),
+				)
+			}
+
+			return nil
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package inbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nf5lab/ebus"
+)
+
+var (
+	// 确保实现了 DedupStore 接口
+	_ DedupStore = (*MemoryStore)(nil)
+)
+
+// MemoryStore 进程内的已处理事件存储
+//
+// 进程重启之后记录丢失, 适用于测试和单实例的消费者
+type MemoryStore struct {
+	ttl time.Duration
+
+	lock      sync.Mutex
+	processed map[memoryKey]time.Time // 处理时间
+	sweptAt   time.Time               // 上次清理过期记录的时间
+}
+
+type memoryKey struct {
+	consumer string
+	eventId  string
+}
+
+// NewMemoryStore 创建进程内的已处理事件存储
+// - ttl 记录的保留时间, 0 表示永久保留
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		ttl:       ttl,
+		processed: make(map[memoryKey]time.Time),
+	}
+}
+
+// IsProcessed 消费者是否已经处理过该事件
+func (store *MemoryStore) IsProcessed(ctx context.Context, consumer string, eventId string) (bool, error) {
+	key := memoryKey{consumer: consumer, eventId: eventId}
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	processedAt, exists := store.processed[key]
+	if !exists {
+		return false, nil
+	}
+
+	if store.ttl > 0 && ebus.DefaultClock().Now().Sub(processedAt) >= store.ttl {
+		delete(store.processed, key)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// MarkProcessed 标记消费者已经处理过该事件
+//
+// 每经过一个保留时间清理一次过期的记录
+func (store *MemoryStore) MarkProcessed(ctx context.Context, consumer string, eventId string) error {
+	now := ebus.DefaultClock().Now()
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	if store.ttl > 0 && now.Sub(store.sweptAt) >= store.ttl {
+		store.sweptAt = now
+		for key, processedAt := range store.processed {
+			if now.Sub(processedAt) >= store.ttl {
+				delete(store.processed, key)
+			}
+		}
+	}
+
+	store.processed[memoryKey{consumer: consumer, eventId: eventId}] = now
+	return nil
+}
+
+// Len 记录的数量, 包括还没有清理的过期记录
+func (store *MemoryStore) Len() int {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	return len(store.processed)
+}
@@ -0,0 +1,14 @@
+module github.com/nf5lab/ebus/inbox/redis
+
+go 1.24.0
+
+require (
+	github.com/nf5lab/ebus v0.0.0-20261016011243-5686471bbfc5
+	github.com/redis/go-redis/v9 v9.7.0
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/nf5lab/broker v0.4.0 // indirect
+)
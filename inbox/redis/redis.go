@@ -0,0 +1,75 @@
+// Package redis 提供基于 Redis 的已处理事件存储
+//
+// 多个消费者实例共享同一个 Redis 时, 任意实例处理过的事件都不会被其它实例再次处理
+//
+// 使用方法:
+//
+//	store := redis.NewStore(client, "ebus:inbox:", 7*24*time.Hour)
+//	handler := ebus.Chain(handler, inbox.Middleware(store, "billing"))
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nf5lab/ebus/inbox"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	// DefaultKeyPrefix 默认的键前缀
+	DefaultKeyPrefix = "ebus:inbox:"
+)
+
+var (
+	// 确保实现了 DedupStore 接口
+	_ inbox.DedupStore = (*Store)(nil)
+)
+
+// Store 基于 Redis 的已处理事件存储
+//
+// 每个已处理的事件是一个带过期时间的键: <前缀><消费者>:<事件ID>
+type Store struct {
+	client goredis.Cmdable
+	prefix string
+	ttl    time.Duration
+}
+
+// NewStore 创建基于 Redis 的已处理事件存储
+// - client Redis 客户端, 可以是单机, 集群或者哨兵客户端
+// - prefix 键前缀, 为空时使用 DefaultKeyPrefix
+// - ttl    记录的保留时间, 0 表示永久保留
+func NewStore(client goredis.Cmdable, prefix string, ttl time.Duration) *Store {
+	if len(prefix) == 0 {
+		prefix = DefaultKeyPrefix
+	}
+
+	return &Store{
+		client: client,
+		prefix: prefix,
+		ttl:    ttl,
+	}
+}
+
+// key 构建记录的键
+func (store *Store) key(consumer string, eventId string) string {
+	return store.prefix + consumer + ":" + eventId
+}
+
+// IsProcessed 消费者是否已经处理过该事件
+func (store *Store) IsProcessed(ctx context.Context, consumer string, eventId string) (bool, error) {
+	count, err := store.client.Exists(ctx, store.key(consumer, eventId)).Result()
+	if err != nil {
+		return false, fmt.Errorf("inbox: 查询 Redis 失败: %w", err)
+	}
+	return count > 0, nil
+}
+
+// MarkProcessed 标记消费者已经处理过该事件
+func (store *Store) MarkProcessed(ctx context.Context, consumer string, eventId string) error {
+	if err := store.client.Set(ctx, store.key(consumer, eventId), time.Now().Unix(), store.ttl).Err(); err != nil {
+		return fmt.Errorf("inbox: 写入 Redis 失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package inbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nf5lab/ebus"
+	"github.com/nf5lab/ebus/outbox"
+)
+
+var (
+	// 确保实现了 DedupStore 接口
+	_ DedupStore = (*SQLStore)(nil)
+)
+
+const (
+	// DefaultTable 默认的收件箱表名
+	DefaultTable = "ebus_inbox"
+)
+
+// SQLStore 数据库中的已处理事件存储
+//
+// 同一个消费者和事件ID只有一条记录, 重复标记不会报错
+type SQLStore struct {
+	db      *sql.DB
+	table   string
+	dialect outbox.Dialect
+}
+
+// NewSQLStore 创建数据库中的已处理事件存储
+// - db      数据库
+// - table   表名, 为空时使用 DefaultTable
+// - dialect 数据库方言, 只使用其中的占位符
+func NewSQLStore(db *sql.DB, table string, dialect outbox.Dialect) *SQLStore {
+	if len(strings.TrimSpace(table)) == 0 {
+		table = DefaultTable
+	}
+
+	return &SQLStore{
+		db:      db,
+		table:   table,
+		dialect: dialect,
+	}
+}
+
+// Schema 创建收件箱表的语句 (标准 SQL, 适用于所有的方言)
+func (store *SQLStore) Schema() string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	consumer     VARCHAR(255) NOT NULL,
+	event_id     VARCHAR(128) NOT NULL,
+	processed_at TIMESTAMP    NOT NULL,
+	PRIMARY KEY (consumer, event_id)
+)`, store.table)
+}
+
+// IsProcessed 消费者是否已经处理过该事件
+func (store *SQLStore) IsProcessed(ctx context.Context, consumer string, eventId string) (bool, error) {
+	return store.isProcessed(ctx, store.db, consumer, eventId)
+}
+
+// isProcessed 在数据库或者数据库事务中查询是否已经处理过该事件
+func (store *SQLStore) isProcessed(ctx context.Context, db queryer, consumer string, eventId string) (bool, error) {
+	query := fmt.Sprintf("SELECT 1 FROM %s WHERE consumer = %s AND event_id = %s",
+		store.table, store.dialect.Placeholder(1), store.dialect.Placeholder(2))
+
+	var found int
+	err := db.QueryRowContext(ctx, query, consumer, eventId).Scan(&found)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("inbox: 查询收件箱失败: %w", err)
+	default:
+		return true, nil
+	}
+}
+
+// MarkProcessed 标记消费者已经处理过该事件
+func (store *SQLStore) MarkProcessed(ctx context.Context, consumer string, eventId string) error {
+	err := store.insert(ctx, store.db, consumer, eventId)
+	if err == nil {
+		return nil
+	}
+
+	// 不同数据库 "插入或忽略" 的语法不同, 所以插入失败时再确认记录是否已经存在 (主键冲突)
+	if processed, checkErr := store.IsProcessed(ctx, consumer, eventId); checkErr == nil && processed {
+		return nil
+	}
+	return err
+}
+
+// execer 数据库或者数据库事务
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// queryer 数据库或者数据库事务
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// insert 插入已处理的记录, 记录已经存在时返回主键冲突的错误
+func (store *SQLStore) insert(ctx context.Context, db execer, consumer string, eventId string) error {
+	query := fmt.Sprintf("INSERT INTO %s (consumer, event_id, processed_at) VALUES (%s, %s, %s)",
+		store.table, store.dialect.Placeholder(1), store.dialect.Placeholder(2), store.dialect.Placeholder(3))
+
+	if _, err := db.ExecContext(ctx, query, consumer, eventId, ebus.DefaultClock().Now().UTC()); err != nil {
+		return fmt.Errorf("inbox: 写入收件箱失败: %w", err)
+	}
+	return nil
+}
+
+// Cleanup 删除指定时间之前处理的记录, 返回删除的数量
+// - before 处理时间早于该时间的记录被删除
+func (store *SQLStore) Cleanup(ctx context.Context, before time.Time) (int64, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE processed_at < %s", store.table, store.dialect.Placeholder(1))
+
+	result, err := store.db.ExecContext(ctx, query, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("inbox: 清理收件箱失败: %w", err)
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,88 @@
+package inbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/nf5lab/ebus"
+	"github.com/nf5lab/ebus/outbox"
+)
+
+// TxHandler 在数据库事务中执行的事件处理函数
+//
+// 处理函数的副作用必须写入同一个事务, 由收件箱提交或者回滚
+type TxHandler func(ctx context.Context, tx *sql.Tx, topic string, event ebus.Event) error
+
+// TransactionalHandler 在同一个数据库事务中执行处理函数和标记已处理, 实现严格的一次处理
+//
+//   - 事件已经处理过: 直接确认, 不调用处理函数
+//   - 处理函数失败: 回滚事务, 返回错误, 由消息队列重新投递
+//   - 标记或者提交失败: 回滚事务, 返回错误; 并发处理同一个事件时只有一个事务能够提交,
+//     另一个事务因为主键冲突回滚, 重新投递之后被识别为已处理
+//
+// 处理函数的副作用和已处理标记一起提交, 不会出现副作用已经生效但是没有标记的情况
+// - consumer 消费者标识, 一般为服务名称或者订阅组
+// - fn       在数据库事务中执行的事件处理函数
+// - opts     收件箱选项, 例如日志记录器
+func (store *SQLStore) TransactionalHandler(consumer string, fn TxHandler, opts ...Option) ebus.EventHandler {
+	options := newOptions(opts...)
+
+	return func(ctx context.Context, topic string, event ebus.Event) error {
+		eventId := event.Metadata().EventId
+
+		tx, err := store.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("inbox: 开始事务失败: %w", err)
+		}
+		defer func() {
+			_ = tx.Rollback()
+		}()
+
+		processed, err := store.isProcessed(ctx, tx, consumer, eventId)
+		if err != nil {
+			return fmt.Errorf("inbox: 检查事件(%s)是否已处理失败: %w", eventId, err)
+		}
+
+		if processed {
+			options.logger.DebugContext(ctx, "inbox: 跳过已处理的事件",
+				slog.String("topic", topic),
+				slog.String("consumer", consumer),
+				slog.String("eventId", eventId),
+			)
+			return nil
+		}
+
+		if err := fn(ctx, tx, topic, event); err != nil {
+			return err
+		}
+
+		if err := store.insert(ctx, tx, consumer, eventId); err != nil {
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("inbox: 提交事件(%s)的事务失败: %w", eventId, err)
+		}
+
+		return nil
+	}
+}
+
+// WithTransactionalHandler 使用默认的收件箱表在同一个数据库事务中执行处理函数和标记已处理
+// (参考 SQLStore.TransactionalHandler)
+//
+//	subscriber.Subscribe(ctx, "orders", "billing", inbox.WithTransactionalHandler(db, "billing",
+//		func(ctx context.Context, tx *sql.Tx, topic string, event ebus.Event) error {
+//			_, err := tx.ExecContext(ctx, "UPDATE accounts SET ...")
+//			return err
+//		}))
+//
+// - db       数据库, 收件箱表使用 DefaultTable 和 PostgreSQL 占位符; 其它数据库使用 NewSQLStore
+// - consumer 消费者标识
+// - fn       在数据库事务中执行的事件处理函数
+// - opts     收件箱选项
+func WithTransactionalHandler(db *sql.DB, consumer string, fn TxHandler, opts ...Option) ebus.EventHandler {
+	return NewSQLStore(db, DefaultTable, outbox.Postgres).TransactionalHandler(consumer, fn, opts...)
+}
@@ -0,0 +1,586 @@
+package ebus
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrSchemaViolation 负载不符合事件类型的 JSON Schema
+	ErrSchemaViolation = errors.New("ebus: 负载不符合 JSON Schema")
+)
+
+// jsonSchema 编译后的 JSON Schema
+//
+// 只实现负载契约常用的关键字, 其余的关键字 (例如 format) 忽略:
+//   - type, enum, const
+//   - properties, required, additionalProperties, minProperties, maxProperties
+//   - items, minItems, maxItems, uniqueItems
+//   - minLength, maxLength, pattern
+//   - minimum, maximum, exclusiveMinimum, exclusiveMaximum, multipleOf
+//   - allOf, anyOf, oneOf, not
+//   - $ref (只支持文档内部的引用, 例如 #/$defs/item)
+//
+// 所有的引用在编译时解析, 无效的引用和不消耗数据的循环引用 (例如 a 引用 a) 在编译时返回错误
+type jsonSchema struct {
+	root     any                       // 模式文档
+	patterns map[string]*regexp.Regexp // 预编译的正则表达式
+	refs     map[string]jsonSchemaRef  // 引用 -> 解析之后的模式节点
+	compiled map[string]bool           // 已经编译的模式节点的 JSON Pointer
+}
+
+// jsonSchemaRef 解析之后的引用
+type jsonSchemaRef struct {
+	node    any    // 引用的模式节点
+	pointer string // 模式节点规范化的 JSON Pointer, 用于检测循环引用
+}
+
+// compileJSONSchema 编译 JSON Schema
+func compileJSONSchema(data []byte) (*jsonSchema, error) {
+	var root any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("ebus: JSON Schema 解析失败: %w", err)
+	}
+
+	switch root.(type) {
+	case map[string]any, bool:
+	default:
+		return nil, fmt.Errorf("ebus: JSON Schema 必须是对象或者布尔值")
+	}
+
+	schema := &jsonSchema{
+		root:     root,
+		patterns: make(map[string]*regexp.Regexp),
+		refs:     make(map[string]jsonSchemaRef),
+		compiled: make(map[string]bool),
+	}
+
+	var subschemas []jsonSchemaRef
+	if err := schema.compileNode(root, "", &subschemas); err != nil {
+		return nil, err
+	}
+
+	// 所有的引用都已经解析, 才能检测循环
+	for _, sub := range subschemas {
+		if err := schema.checkRefCycle(sub.node, sub.pointer, make(map[string]bool)); err != nil {
+			return nil, err
+		}
+	}
+
+	return schema, nil
+}
+
+// compileNode 编译模式节点: 预编译正则表达式, 解析引用, 收集所有的子模式
+// - node       模式节点
+// - pointer    模式节点的 JSON Pointer
+// - subschemas 收集的子模式
+func (schema *jsonSchema) compileNode(node any, pointer string, subschemas *[]jsonSchemaRef) error {
+	object, ok := node.(map[string]any)
+	if !ok || schema.compiled[pointer] {
+		return nil
+	}
+	schema.compiled[pointer] = true
+
+	*subschemas = append(*subschemas, jsonSchemaRef{node: node, pointer: pointer})
+
+	if pattern, ok := object["pattern"].(string); ok {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("ebus: JSON Schema 正则表达式(%s)无效: %w", pattern, err)
+		}
+		schema.patterns[pattern] = compiled
+	}
+
+	if ref, ok := object["$ref"].(string); ok {
+		if _, exists := schema.refs[ref]; !exists {
+			target, targetPointer, err := schema.resolve(ref)
+			if err != nil {
+				return fmt.Errorf("ebus: JSON Schema 位置(%s): %w", displayPointer(pointer), err)
+			}
+			schema.refs[ref] = jsonSchemaRef{node: target, pointer: targetPointer}
+
+			// 引用的节点可能不在其它关键字之下, 例如自定义的容器
+			if err := schema.compileNode(target, targetPointer, subschemas); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 值是子模式的关键字
+	for _, key := range []string{"additionalProperties", "items", "not"} {
+		if sub, exists := object[key]; exists {
+			if err := schema.compileNode(sub, pointer+"/"+key, subschemas); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 值是子模式数组的关键字
+	for _, key := range []string{"allOf", "anyOf", "oneOf"} {
+		list, _ := object[key].([]any)
+		for i, sub := range list {
+			if err := schema.compileNode(sub, pointer+"/"+key+"/"+strconv.Itoa(i), subschemas); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 值是名称到子模式映射的关键字
+	for _, key := range []string{"properties", "$defs", "definitions"} {
+		subs, _ := object[key].(map[string]any)
+		for name, sub := range subs {
+			if err := schema.compileNode(sub, pointer+"/"+key+"/"+escapePointerToken(name), subschemas); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkRefCycle 检测不消耗数据的循环引用
+//
+// $ref, allOf, anyOf, oneOf 和 not 使用同一个数据校验子模式, 沿着这些关键字回到同一个模式节点时,
+// 校验永远不会结束; properties, additionalProperties 和 items 校验下一层数据, 不会无限递归
+// - node     模式节点
+// - pointer  模式节点规范化的 JSON Pointer
+// - visiting 正在检测的模式节点
+func (schema *jsonSchema) checkRefCycle(node any, pointer string, visiting map[string]bool) error {
+	object, ok := node.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	if visiting[pointer] {
+		return fmt.Errorf("ebus: JSON Schema 位置(%s)存在循环引用", displayPointer(pointer))
+	}
+	visiting[pointer] = true
+	defer delete(visiting, pointer)
+
+	if ref, ok := object["$ref"].(string); ok {
+		// 引用存在时忽略其它关键字 (参考 validateNode)
+		target := schema.refs[ref]
+		return schema.checkRefCycle(target.node, target.pointer, visiting)
+	}
+
+	for _, key := range []string{"allOf", "anyOf", "oneOf"} {
+		list, _ := object[key].([]any)
+		for i, sub := range list {
+			if err := schema.checkRefCycle(sub, pointer+"/"+key+"/"+strconv.Itoa(i), visiting); err != nil {
+				return err
+			}
+		}
+	}
+
+	if not, exists := object["not"]; exists {
+		return schema.checkRefCycle(not, pointer+"/not", visiting)
+	}
+
+	return nil
+}
+
+// validate 校验 JSON 数据
+func (schema *jsonSchema) validate(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var instance any
+	if err := decoder.Decode(&instance); err != nil {
+		return fmt.Errorf("%w: %v", ErrSchemaViolation, err)
+	}
+
+	if err := schema.validateNode(schema.root, instance, ""); err != nil {
+		return fmt.Errorf("%w: %v", ErrSchemaViolation, err)
+	}
+	return nil
+}
+
+// validateNode 按照模式节点校验数据
+// - node     模式节点
+// - instance 数据, 数字为 json.Number
+// - path     数据的 JSON Pointer, 用于错误信息
+func (schema *jsonSchema) validateNode(node any, instance any, path string) error {
+	switch node := node.(type) {
+	case bool:
+		if !node {
+			return schemaError(path, "不允许任何值")
+		}
+		return nil
+	case map[string]any:
+		if ref, ok := node["$ref"].(string); ok {
+			// 引用在编译时已经解析
+			return schema.validateNode(schema.refs[ref].node, instance, path)
+		}
+		return schema.validateObject(node, instance, path)
+	default:
+		return nil
+	}
+}
+
+// resolve 解析文档内部的引用
+//
+// 返回引用的模式节点和规范化的 JSON Pointer
+func (schema *jsonSchema) resolve(ref string) (any, string, error) {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, "", fmt.Errorf("不支持外部引用: %s", ref)
+	}
+
+	node := schema.root
+	canonical := ""
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if len(token) == 0 {
+			continue
+		}
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+
+		object, ok := node.(map[string]any)
+		if !ok {
+			return nil, "", fmt.Errorf("引用无效: %s", ref)
+		}
+		if node, ok = object[token]; !ok {
+			return nil, "", fmt.Errorf("引用无效: %s", ref)
+		}
+		canonical += "/" + escapePointerToken(token)
+	}
+
+	switch node.(type) {
+	case map[string]any, bool:
+	default:
+		return nil, "", fmt.Errorf("引用的不是模式: %s", ref)
+	}
+
+	return node, canonical, nil
+}
+
+// escapePointerToken 转义 JSON Pointer 中的一段
+func escapePointerToken(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// displayPointer 用于错误信息的 JSON Pointer, 根节点显示为 "/"
+func displayPointer(pointer string) string {
+	if len(pointer) == 0 {
+		return "/"
+	}
+	return pointer
+}
+
+// validateObject 按照模式对象的关键字校验数据
+func (schema *jsonSchema) validateObject(node map[string]any, instance any, path string) error {
+	if expected, exists := node["type"]; exists {
+		if err := validateType(expected, instance, path); err != nil {
+			return err
+		}
+	}
+
+	if values, ok := node["enum"].([]any); ok {
+		matched := false
+		for _, value := range values {
+			if jsonEqual(value, instance) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return schemaError(path, "不是允许的值")
+		}
+	}
+
+	if value, exists := node["const"]; exists && !jsonEqual(value, instance) {
+		return schemaError(path, "必须等于常量")
+	}
+
+	switch instance := instance.(type) {
+	case map[string]any:
+		if err := schema.validateProperties(node, instance, path); err != nil {
+			return err
+		}
+	case []any:
+		if err := schema.validateItems(node, instance, path); err != nil {
+			return err
+		}
+	case string:
+		if err := schema.validateString(node, instance, path); err != nil {
+			return err
+		}
+	case json.Number:
+		if err := validateNumber(node, instance, path); err != nil {
+			return err
+		}
+	}
+
+	return schema.validateComposition(node, instance, path)
+}
+
+// validateProperties 校验对象
+func (schema *jsonSchema) validateProperties(node map[string]any, instance map[string]any, path string) error {
+	if required, ok := node["required"].([]any); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, exists := instance[name]; !exists {
+					return schemaError(path, fmt.Sprintf("缺少必需的属性(%s)", name))
+				}
+			}
+		}
+	}
+
+	if err := validateCount(node, "minProperties", "maxProperties", len(instance), path, "属性"); err != nil {
+		return err
+	}
+
+	properties, _ := node["properties"].(map[string]any)
+	for name, value := range instance {
+		propertyPath := path + "/" + escapePointerToken(name)
+
+		if property, exists := properties[name]; exists {
+			if err := schema.validateNode(property, value, propertyPath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if additional, exists := node["additionalProperties"]; exists {
+			if allowed, ok := additional.(bool); ok && !allowed {
+				return schemaError(path, fmt.Sprintf("不允许额外的属性(%s)", name))
+			}
+			if err := schema.validateNode(additional, value, propertyPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateItems 校验数组
+func (schema *jsonSchema) validateItems(node map[string]any, instance []any, path string) error {
+	if err := validateCount(node, "minItems", "maxItems", len(instance), path, "元素"); err != nil {
+		return err
+	}
+
+	if unique, ok := node["uniqueItems"].(bool); ok && unique {
+		for i := range instance {
+			for j := i + 1; j < len(instance); j++ {
+				if jsonEqual(instance[i], instance[j]) {
+					return schemaError(path, "元素必须唯一")
+				}
+			}
+		}
+	}
+
+	if items, exists := node["items"]; exists {
+		for i, item := range instance {
+			if err := schema.validateNode(items, item, path+"/"+strconv.Itoa(i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateString 校验字符串
+func (schema *jsonSchema) validateString(node map[string]any, instance string, path string) error {
+	if err := validateCount(node, "minLength", "maxLength", len([]rune(instance)), path, "字符"); err != nil {
+		return err
+	}
+
+	if pattern, ok := node["pattern"].(string); ok {
+		if compiled := schema.patterns[pattern]; compiled != nil && !compiled.MatchString(instance) {
+			return schemaError(path, fmt.Sprintf("不匹配正则表达式(%s)", pattern))
+		}
+	}
+
+	return nil
+}
+
+// validateComposition 校验组合关键字
+func (schema *jsonSchema) validateComposition(node map[string]any, instance any, path string) error {
+	if allOf, ok := node["allOf"].([]any); ok {
+		for _, sub := range allOf {
+			if err := schema.validateNode(sub, instance, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	if anyOf, ok := node["anyOf"].([]any); ok {
+		matched := false
+		for _, sub := range anyOf {
+			if schema.validateNode(sub, instance, path) == nil {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return schemaError(path, "不符合 anyOf 中的任何模式")
+		}
+	}
+
+	if oneOf, ok := node["oneOf"].([]any); ok {
+		matched := 0
+		for _, sub := range oneOf {
+			if schema.validateNode(sub, instance, path) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return schemaError(path, fmt.Sprintf("必须恰好符合 oneOf 中的一个模式, 实际符合 %d 个", matched))
+		}
+	}
+
+	if not, exists := node["not"]; exists && schema.validateNode(not, instance, path) == nil {
+		return schemaError(path, "不能符合 not 中的模式")
+	}
+
+	return nil
+}
+
+// validateType 校验数据的类型
+func validateType(expected any, instance any, path string) error {
+	var types []string
+	switch expected := expected.(type) {
+	case string:
+		types = []string{expected}
+	case []any:
+		for _, value := range expected {
+			if value, ok := value.(string); ok {
+				types = append(types, value)
+			}
+		}
+	default:
+		return nil
+	}
+
+	actual := jsonTypeOf(instance)
+	for _, typ := range types {
+		if typ == actual || (typ == "number" && actual == "integer") {
+			return nil
+		}
+	}
+	return schemaError(path, fmt.Sprintf("类型必须是 %s, 实际是 %s", strings.Join(types, "|"), actual))
+}
+
+// jsonTypeOf 数据的 JSON Schema 类型
+func jsonTypeOf(instance any) string {
+	switch instance := instance.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case json.Number:
+		if value, err := instance.Float64(); err == nil && value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	default:
+		return "unknown"
+	}
+}
+
+// validateNumber 校验数字
+func validateNumber(node map[string]any, instance json.Number, path string) error {
+	value, err := instance.Float64()
+	if err != nil {
+		return schemaError(path, "数字无效")
+	}
+
+	if limit, ok := node["minimum"].(float64); ok && value < limit {
+		return schemaError(path, fmt.Sprintf("不能小于 %v", limit))
+	}
+	if limit, ok := node["maximum"].(float64); ok && value > limit {
+		return schemaError(path, fmt.Sprintf("不能大于 %v", limit))
+	}
+	if limit, ok := node["exclusiveMinimum"].(float64); ok && value <= limit {
+		return schemaError(path, fmt.Sprintf("必须大于 %v", limit))
+	}
+	if limit, ok := node["exclusiveMaximum"].(float64); ok && value >= limit {
+		return schemaError(path, fmt.Sprintf("必须小于 %v", limit))
+	}
+	if divisor, ok := node["multipleOf"].(float64); ok && divisor > 0 {
+		if quotient := value / divisor; math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			return schemaError(path, fmt.Sprintf("必须是 %v 的倍数", divisor))
+		}
+	}
+
+	return nil
+}
+
+// validateCount 校验数量的上下限
+func validateCount(node map[string]any, minKey string, maxKey string, count int, path string, unit string) error {
+	if limit, ok := node[minKey].(float64); ok && float64(count) < limit {
+		return schemaError(path, fmt.Sprintf("至少需要 %v 个%s", limit, unit))
+	}
+	if limit, ok := node[maxKey].(float64); ok && float64(count) > limit {
+		return schemaError(path, fmt.Sprintf("最多允许 %v 个%s", limit, unit))
+	}
+	return nil
+}
+
+// jsonEqual 比较模式中的值和数据是否相等, 数字按照数值比较
+func jsonEqual(expected any, instance any) bool {
+	return reflect.DeepEqual(normalizeJSONValue(expected), normalizeJSONValue(instance))
+}
+
+// normalizeJSONValue 把数字统一转换为 float64
+func normalizeJSONValue(value any) any {
+	switch value := value.(type) {
+	case json.Number:
+		number, _ := value.Float64()
+		return number
+	case []any:
+		normalized := make([]any, len(value))
+		for i, item := range value {
+			normalized[i] = normalizeJSONValue(item)
+		}
+		return normalized
+	case map[string]any:
+		normalized := make(map[string]any, len(value))
+		for key, item := range value {
+			normalized[key] = normalizeJSONValue(item)
+		}
+		return normalized
+	default:
+		return value
+	}
+}
+
+// schemaError 创建带有数据位置的错误
+func schemaError(path string, message string) error {
+	return fmt.Errorf("%s: %s", displayPointer(path), message)
+}
+
+// validatePayloadSchema 按照事件类型的 JSON Schema 校验负载
+//
+// 只校验精确匹配的事件工厂附加的模式, 负载不是 JSON 时不校验
+// - metadata 事件元数据
+// - codec    负载的编解码器
+// - payload  负载
+func (r *Registry) validatePayloadSchema(metadata *Metadata, codec Codec, payload []byte) error {
+	if !isJSONCodec(codec) {
+		if _, ok := codec.(CloudEventsCodec); !ok {
+			return nil
+		}
+	}
+
+	entry, exists := r.snapshot()[buildEventFactoryKey(metadata.SchemaVersion, metadata.EventSource, metadata.EventType)]
+	if !exists || entry.schema == nil {
+		return nil
+	}
+
+	return entry.schema.validate(payload)
+}
@@ -0,0 +1,171 @@
+package ebus
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestJSONSchemaKeywords(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   string
+		instance string
+		valid    bool
+	}{
+		// 布尔模式
+		{"true schema", `true`, `{"a":1}`, true},
+		{"false schema", `false`, `1`, false},
+
+		// type
+		{"type string", `{"type":"string"}`, `"a"`, true},
+		{"type string mismatch", `{"type":"string"}`, `1`, false},
+		{"type integer", `{"type":"integer"}`, `3`, true},
+		{"type integer with fraction", `{"type":"integer"}`, `3.5`, false},
+		{"type number accepts integer", `{"type":"number"}`, `3`, true},
+		{"type boolean", `{"type":"boolean"}`, `false`, true},
+		{"type null", `{"type":"null"}`, `null`, true},
+		{"type array", `{"type":"array"}`, `{}`, false},
+		{"type object", `{"type":"object"}`, `{}`, true},
+		{"type list", `{"type":["string","null"]}`, `null`, true},
+		{"type list mismatch", `{"type":["string","null"]}`, `true`, false},
+
+		// enum, const
+		{"enum match", `{"enum":["a","b"]}`, `"b"`, true},
+		{"enum mismatch", `{"enum":["a","b"]}`, `"c"`, false},
+		{"enum numeric", `{"enum":[1,2]}`, `2.0`, true},
+		{"const match", `{"const":{"a":[1]}}`, `{"a":[1]}`, true},
+		{"const mismatch", `{"const":"x"}`, `"y"`, false},
+
+		// properties, required, additionalProperties
+		{"properties", `{"properties":{"a":{"type":"string"}}}`, `{"a":"x","b":1}`, true},
+		{"properties mismatch", `{"properties":{"a":{"type":"string"}}}`, `{"a":1}`, false},
+		{"required", `{"required":["a"]}`, `{"a":null}`, true},
+		{"required missing", `{"required":["a"]}`, `{"b":1}`, false},
+		{"additionalProperties false", `{"properties":{"a":{}},"additionalProperties":false}`, `{"a":1,"b":2}`, false},
+		{"additionalProperties schema", `{"additionalProperties":{"type":"integer"}}`, `{"a":1,"b":2}`, true},
+		{"additionalProperties schema mismatch", `{"additionalProperties":{"type":"integer"}}`, `{"a":"x"}`, false},
+		{"minProperties", `{"minProperties":2}`, `{"a":1}`, false},
+		{"maxProperties", `{"maxProperties":1}`, `{"a":1,"b":2}`, false},
+
+		// items, minItems, maxItems, uniqueItems
+		{"items", `{"items":{"type":"integer"}}`, `[1,2,3]`, true},
+		{"items mismatch", `{"items":{"type":"integer"}}`, `[1,"2"]`, false},
+		{"minItems", `{"minItems":2}`, `[1]`, false},
+		{"maxItems", `{"maxItems":1}`, `[1,2]`, false},
+		{"uniqueItems", `{"uniqueItems":true}`, `[1,2,3]`, true},
+		{"uniqueItems duplicate", `{"uniqueItems":true}`, `[{"a":1},{"a":1.0}]`, false},
+
+		// minLength, maxLength, pattern
+		{"minLength counts runes", `{"minLength":2}`, `"中文"`, true},
+		{"minLength", `{"minLength":3}`, `"ab"`, false},
+		{"maxLength counts runes", `{"maxLength":2}`, `"中文"`, true},
+		{"maxLength", `{"maxLength":1}`, `"ab"`, false},
+		{"pattern", `{"pattern":"^[a-z]+-[0-9]+$"}`, `"order-1"`, true},
+		{"pattern mismatch", `{"pattern":"^[a-z]+-[0-9]+$"}`, `"Order1"`, false},
+
+		// 数字
+		{"minimum", `{"minimum":1}`, `1`, true},
+		{"minimum violated", `{"minimum":1}`, `0.5`, false},
+		{"maximum", `{"maximum":10}`, `11`, false},
+		{"exclusiveMinimum", `{"exclusiveMinimum":1}`, `1`, false},
+		{"exclusiveMaximum", `{"exclusiveMaximum":1}`, `0.9`, true},
+		{"multipleOf", `{"multipleOf":0.1}`, `0.3`, true},
+		{"multipleOf violated", `{"multipleOf":2}`, `3`, false},
+
+		// 组合
+		{"allOf", `{"allOf":[{"type":"integer"},{"minimum":1}]}`, `2`, true},
+		{"allOf violated", `{"allOf":[{"type":"integer"},{"minimum":1}]}`, `0`, false},
+		{"anyOf", `{"anyOf":[{"type":"string"},{"type":"integer"}]}`, `1`, true},
+		{"anyOf violated", `{"anyOf":[{"type":"string"},{"type":"integer"}]}`, `true`, false},
+		{"oneOf", `{"oneOf":[{"type":"integer"},{"type":"string"}]}`, `1`, true},
+		{"oneOf matches two", `{"oneOf":[{"type":"integer"},{"type":"number"}]}`, `1`, false},
+		{"not", `{"not":{"type":"string"}}`, `1`, true},
+		{"not violated", `{"not":{"type":"string"}}`, `"a"`, false},
+
+		// $ref
+		{"ref", `{"$defs":{"id":{"type":"string"}},"properties":{"id":{"$ref":"#/$defs/id"}}}`, `{"id":"a"}`, true},
+		{"ref violated", `{"$defs":{"id":{"type":"string"}},"properties":{"id":{"$ref":"#/$defs/id"}}}`, `{"id":1}`, false},
+		{"ref escaped token", `{"$defs":{"a/b":{"type":"string"}},"$ref":"#/$defs/a~1b"}`, `1`, false},
+		{"ref custom container", `{"x":{"y":{"pattern":"^a"}},"$ref":"#/x/y"}`, `"b"`, false},
+		{"recursive ref consumes input", treeSchema, `{"children":[{"children":[]},{"children":[{}]}]}`, true},
+		{"recursive ref violated", treeSchema, `{"children":[{"children":[1]}]}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := compileJSONSchema([]byte(tt.schema))
+			if err != nil {
+				t.Fatalf("编译失败: %v", err)
+			}
+
+			err = schema.validate([]byte(tt.instance))
+			if tt.valid && err != nil {
+				t.Errorf("期望有效, 实际错误: %v", err)
+			}
+			if !tt.valid {
+				if err == nil {
+					t.Error("期望无效, 实际有效")
+				} else if !errors.Is(err, ErrSchemaViolation) {
+					t.Errorf("错误应该包装 ErrSchemaViolation: %v", err)
+				}
+			}
+		})
+	}
+}
+
+const treeSchema = `{
+	"$defs": {
+		"node": {
+			"type": "object",
+			"properties": {"children": {"type": "array", "items": {"$ref": "#/$defs/node"}}}
+		}
+	},
+	"$ref": "#/$defs/node"
+}`
+
+func TestCompileJSONSchemaErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		{"invalid json", `{`, "解析失败"},
+		{"not a schema", `[1]`, "必须是对象或者布尔值"},
+		{"invalid pattern", `{"properties":{"a":{"pattern":"("}}}`, "正则表达式"},
+		{"external ref", `{"$ref":"other.json#/a"}`, "外部引用"},
+		{"unresolvable ref", `{"properties":{"a":{"$ref":"#/$defs/missing"}}}`, "引用无效"},
+		{"ref to non schema", `{"$defs":{"a":1},"$ref":"#/$defs/a"}`, "不是模式"},
+		{"self ref", `{"$defs":{"a":{"$ref":"#/$defs/a"}},"$ref":"#/$defs/a"}`, "循环引用"},
+		{"root self ref", `{"$ref":"#"}`, "循环引用"},
+		{"mutual ref", `{"$defs":{"a":{"$ref":"#/$defs/b"},"b":{"$ref":"#/$defs/a"}},"$ref":"#/$defs/a"}`, "循环引用"},
+		{"cycle through allOf", `{"$defs":{"a":{"allOf":[{"type":"object"},{"$ref":"#/$defs/a"}]}},"$ref":"#/$defs/a"}`, "循环引用"},
+		{"cycle through not", `{"$defs":{"a":{"not":{"$ref":"#/$defs/a"}}},"properties":{"x":{"$ref":"#/$defs/a"}}}`, "循环引用"},
+		{"cycle in unreferenced defs", `{"$defs":{"a":{"anyOf":[{"$ref":"#/$defs/a"}]}}}`, "循环引用"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := compileJSONSchema([]byte(tt.schema))
+			if err == nil {
+				t.Fatal("期望编译失败")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("错误 = %v, 期望包含 %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterWithSchemaRejectsRefCycle(t *testing.T) {
+	registry := NewRegistry()
+	schema := []byte(`{"$defs":{"a":{"$ref":"#/$defs/a"}},"$ref":"#/$defs/a"}`)
+
+	factory := func() (Event, error) { return &testEvent{}, nil }
+	if err := registry.RegisterWithSchema(testVersion, testSource, testType, factory, schema); err == nil {
+		t.Fatal("循环引用的模式应该注册失败")
+	}
+	if registry.Exists(testVersion, testSource, testType) {
+		t.Error("注册失败时不应该注册事件工厂")
+	}
+}
@@ -0,0 +1,74 @@
+package ebus
+
+import (
+	"context"
+	"fmt"
+)
+
+var (
+	// 确保实现了 KeyProvider 接口
+	_ KeyProvider = StaticKeyProvider{}
+	_ KeyProvider = (*Keyring)(nil)
+)
+
+// KeyProvider 对称密钥的提供者, 用于 HMAC 签名 (参考 WithHMACSigner) 和 AES-GCM 加密 (参考 WithEncryption)
+//
+// 发布者使用当前的密钥, 订阅者按照消息头中的密钥ID查找密钥
+// 可以由本地的密钥环 (参考 Keyring) 或者 KMS 实现
+// 轮换密钥时, 订阅者先同时接受新旧密钥, 再让发布者切换到新密钥
+type KeyProvider interface {
+
+	// CurrentKey 发布者使用的当前密钥
+	CurrentKey(ctx context.Context) (keyId string, key []byte, err error)
+
+	// LookupKey 按照密钥ID查找密钥, 不存在时返回错误
+	LookupKey(ctx context.Context, keyId string) ([]byte, error)
+}
+
+// StaticKeyProvider 固定的密钥, 不支持轮换
+type StaticKeyProvider struct {
+	KeyId string // 密钥ID
+	Key   []byte // 密钥
+}
+
+// CurrentKey 发布者使用的当前密钥
+func (provider StaticKeyProvider) CurrentKey(_ context.Context) (string, []byte, error) {
+	if len(provider.Key) == 0 {
+		return "", nil, fmt.Errorf("ebus: 密钥不能为空")
+	}
+	return provider.KeyId, provider.Key, nil
+}
+
+// LookupKey 按照密钥ID查找密钥
+func (provider StaticKeyProvider) LookupKey(_ context.Context, keyId string) ([]byte, error) {
+	if keyId != provider.KeyId || len(provider.Key) == 0 {
+		return nil, fmt.Errorf("ebus: 密钥(%s)不存在", keyId)
+	}
+	return provider.Key, nil
+}
+
+// Keyring 本地的密钥环, 支持密钥轮换
+//
+// 轮换密钥时先在所有订阅者的密钥环中加入新密钥, 再把发布者的 Current 切换到新密钥
+type Keyring struct {
+	Current string            // 发布者使用的密钥ID
+	Keys    map[string][]byte // 密钥ID对应的密钥, 包括尚未淘汰的旧密钥
+}
+
+// CurrentKey 发布者使用的当前密钥
+func (keyring *Keyring) CurrentKey(ctx context.Context) (string, []byte, error) {
+	key, err := keyring.LookupKey(ctx, keyring.Current)
+	if err != nil {
+		return "", nil, err
+	}
+	return keyring.Current, key, nil
+}
+
+// LookupKey 按照密钥ID查找密钥
+func (keyring *Keyring) LookupKey(_ context.Context, keyId string) ([]byte, error) {
+	key, exists := keyring.Keys[keyId]
+	if !exists || len(key) == 0 {
+		return nil, fmt.Errorf("ebus: 密钥(%s)不存在", keyId)
+	}
+	return key, nil
+}
@@ -0,0 +1,95 @@
+package ebus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nf5lab/broker"
+)
+
+var (
+	// ErrMaxHopsExceeded 事件跳数超过了最大跳数
+	ErrMaxHopsExceeded = errors.New("ebus: 事件跳数超过最大跳数")
+)
+
+// lineagePathSeparator 事件路径的分隔符
+const lineagePathSeparator = ","
+
+// Lineage 事件血缘
+//
+// 记录事件从最初的发布开始, 经过了多少次 "消费后再发布", 以及经过的服务
+type Lineage struct {
+	Hops int      // 跳数, 直接发布的事件为1
+	Path []string // 经过的服务名称, 按顺序排列
+}
+
+// lineageContextKey 事件血缘在上下文中的键
+type lineageContextKey struct{}
+
+// ContextWithLineage 在上下文中设置事件血缘
+//
+// 订阅者在调用处理函数之前, 会把正在处理的事件的血缘放入上下文
+func ContextWithLineage(ctx context.Context, lineage Lineage) context.Context {
+	return context.WithValue(ctx, lineageContextKey{}, lineage)
+}
+
+// LineageFromContext 从上下文中获取事件血缘
+func LineageFromContext(ctx context.Context) (Lineage, bool) {
+	lineage, ok := ctx.Value(lineageContextKey{}).(Lineage)
+	return lineage, ok
+}
+
+// lineageFromMessage 从消息头解析事件血缘
+//
+// 缺失或者无效的消息头按照直接发布的事件处理
+func lineageFromMessage(message *broker.Message) Lineage {
+	lineage := Lineage{Hops: 1}
+
+	if value, ok := message.GetHeaderString(HeaderHopCount); ok {
+		if hops, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && hops > 0 {
+			lineage.Hops = hops
+		}
+	}
+
+	if value, ok := message.GetHeaderString(HeaderPath); ok {
+		for _, service := range strings.Split(value, lineagePathSeparator) {
+			if service = strings.TrimSpace(service); len(service) > 0 {
+				lineage.Path = append(lineage.Path, service)
+			}
+		}
+	}
+
+	return lineage
+}
+
+// nextLineage 计算待发布事件的血缘
+func nextLineage(ctx context.Context, opts *Options) (Lineage, error) {
+	next := Lineage{Hops: 1}
+	if parent, ok := LineageFromContext(ctx); ok {
+		next.Hops = parent.Hops + 1
+		next.Path = slices.Clone(parent.Path)
+	}
+
+	if len(opts.ServiceName) > 0 {
+		next.Path = append(next.Path, opts.ServiceName)
+	}
+
+	if opts.MaxHops > 0 && next.Hops > opts.MaxHops {
+		return Lineage{}, fmt.Errorf("%w: %d > %d, 路径: %s",
+			ErrMaxHopsExceeded, next.Hops, opts.MaxHops, strings.Join(next.Path, lineagePathSeparator))
+	}
+
+	return next, nil
+}
+
+// addLineageHeaders 把事件血缘写入消息头
+func addLineageHeaders(message *broker.Message, lineage Lineage) {
+	message.AddHeader(HeaderHopCount, strconv.Itoa(lineage.Hops))
+	if len(lineage.Path) > 0 {
+		message.AddHeader(HeaderPath, strings.Join(lineage.Path, lineagePathSeparator))
+	}
+}
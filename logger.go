@@ -0,0 +1,22 @@
+package ebus
+
+import (
+	"log/slog"
+)
+
+// discardLogger 丢弃所有日志的记录器
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// metadataLogAttrs 生成事件元数据的日志属性
+func metadataLogAttrs(meta *Metadata) []any {
+	if meta == nil {
+		return nil
+	}
+
+	return []any{
+		slog.String("eventId", meta.EventId),
+		slog.String("eventSource", meta.EventSource.String()),
+		slog.String("eventType", meta.EventType.String()),
+		slog.String("schemaVersion", meta.SchemaVersion.String()),
+	}
+}
@@ -0,0 +1,349 @@
+// Package memory 提供进程内的消息队列和事件总线, 用于单元测试
+//
+// 消息在进程内投递, 不需要真实的消息队列, 事件仍然经过 ebus 完整的编码和解码流程
+//
+// 使用方法:
+//
+//	bus := memory.New()
+//	_, _ = bus.Subscribe(ctx, "orders", "billing", handler)
+//	_ = bus.Publish(ctx, "orders", event) // 同步模式下返回时处理函数已经执行完毕
+//
+// 异步模式下使用 Wait 等待所有投递处理完毕:
+//
+//	bus := memory.NewAsync()
+//	_ = bus.Publish(ctx, "orders", event)
+//	bus.Wait()
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nf5lab/broker"
+)
+
+var (
+	// 确保实现了 Broker 接口
+	_ broker.Broker = (*Broker)(nil)
+)
+
+// Mode 投递模式
+type Mode int
+
+const (
+	// ModeSync 同步投递, Publish 在所有订阅组处理完毕 (包括重试) 之后才返回
+	ModeSync Mode = iota
+
+	// ModeAsync 异步投递, 每个订阅有独立的先进先出队列和工作协程
+	ModeAsync
+)
+
+func (mode Mode) String() string {
+	switch mode {
+	case ModeSync:
+		return "sync"
+	case ModeAsync:
+		return "async"
+	default:
+		return fmt.Sprintf("Mode(%d)", int(mode))
+	}
+}
+
+// DeadLetter 超过最大尝试次数或者不可重试的投递
+type DeadLetter struct {
+	Delivery *broker.Delivery // 最后一次投递
+	Group    string           // 订阅组
+	Err      error            // 最后一次处理的错误
+}
+
+// Option 进程内消息队列的选项
+type Option func(*Broker)
+
+// WithMode 设置投递模式, 默认 ModeSync
+func WithMode(mode Mode) Option {
+	return func(b *Broker) {
+		b.mode = mode
+	}
+}
+
+// WithRetryBackoff 设置重试退避函数
+//
+// 默认立即重试, 避免测试等待 broker.DefaultRetryBackoff 的长时间退避
+func WithRetryBackoff(backoff broker.RetryBackoff) Option {
+	return func(b *Broker) {
+		b.backoff = backoff
+	}
+}
+
+// Broker 进程内的消息队列
+//
+// - 消息投递给每一个订阅组, 组内的订阅轮流接收
+// - 处理失败时按照订阅的最大尝试次数重试, 超过之后记录为死信
+// - 支持延迟发布
+type Broker struct {
+	mode    Mode
+	backoff broker.RetryBackoff
+
+	lock          sync.Mutex
+	closed        bool
+	nextId        atomic.Uint64
+	subscriptions map[string]*subscription          // 订阅ID -> 订阅
+	groups        map[string]map[string]*groupState // 主题 -> 订阅组 -> 订阅组状态
+	deadLetters   []DeadLetter
+	deadLettered  chan struct{} // 新增死信时关闭并替换, 用于 WaitDeadLetters
+
+	pending sync.WaitGroup // 尚未处理完毕的投递 (包括延迟发布)
+}
+
+// groupState 订阅组状态
+type groupState struct {
+	subscriptions []*subscription
+	next          int // 下一个接收消息的订阅
+}
+
+// NewBroker 创建进程内的消息队列
+func NewBroker(opts ...Option) *Broker {
+	b := &Broker{
+		subscriptions: make(map[string]*subscription),
+		groups:        make(map[string]map[string]*groupState),
+		deadLettered:  make(chan struct{}),
+	}
+
+	for _, apply := range opts {
+		if apply != nil {
+			apply(b)
+		}
+	}
+
+	return b
+}
+
+// Mode 获取投递模式
+func (b *Broker) Mode() Mode {
+	return b.mode
+}
+
+// Publish 发布消息
+func (b *Broker) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	topic = strings.TrimSpace(topic)
+	if len(topic) == 0 {
+		return broker.ErrEmptyTopic
+	}
+
+	if err := msg.Validate(); err != nil {
+		return err
+	}
+
+	options := broker.NewPublishOptions(opts...)
+
+	// 发布者之后修改消息不影响投递
+	msg = msg.Clone()
+
+	if options.Delay > 0 {
+		b.pending.Add(1)
+		time.AfterFunc(options.Delay, func() {
+			defer b.pending.Done()
+			b.route(topic, msg)
+		})
+		return nil
+	}
+
+	b.route(topic, msg)
+	return nil
+}
+
+// route 把消息投递给每一个订阅组
+func (b *Broker) route(topic string, msg *broker.Message) {
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		return
+	}
+
+	var targets []*subscription
+	for _, state := range b.groups[topic] {
+		if len(state.subscriptions) == 0 {
+			continue
+		}
+		targets = append(targets, state.subscriptions[state.next%len(state.subscriptions)])
+		state.next++
+	}
+	b.lock.Unlock()
+
+	for _, subs := range targets {
+		if b.mode == ModeAsync {
+			b.pending.Add(1)
+			subs.enqueue(msg)
+			continue
+		}
+		b.deliver(subs, msg)
+	}
+}
+
+// deliver 投递消息, 失败时重试, 直到成功, 不可重试或者超过最大尝试次数
+func (b *Broker) deliver(subs *subscription, msg *broker.Message) {
+	for attempts := 1; ; attempts++ {
+		delivery := &broker.Delivery{
+			Message:     *msg.Clone(),
+			Topic:       subs.topic,
+			Attempts:    attempts,
+			ReceiveTime: time.Now(),
+		}
+
+		err := subs.handler(context.Background(), delivery)
+		if err == nil {
+			return
+		}
+
+		if broker.IsNonRetryableError(err) || !delivery.ShouldRetry(subs.options.MaxAttempts) || subs.stopped() {
+			b.lock.Lock()
+			b.deadLetters = append(b.deadLetters, DeadLetter{Delivery: delivery, Group: subs.options.Group, Err: err})
+			close(b.deadLettered)
+			b.deadLettered = make(chan struct{})
+			b.lock.Unlock()
+			return
+		}
+
+		if b.backoff != nil {
+			if delay := b.backoff(attempts); delay > 0 {
+				time.Sleep(delay)
+			}
+		}
+	}
+}
+
+// Subscribe 订阅消息
+func (b *Broker) Subscribe(ctx context.Context, topic string, handler broker.Handler, opts ...broker.SubscribeOption) (string, error) {
+	topic = strings.TrimSpace(topic)
+	if len(topic) == 0 {
+		return "", broker.ErrEmptyTopic
+	}
+
+	if handler == nil {
+		return "", broker.ErrEmptyHandler
+	}
+
+	options := broker.NewSubscribeOptions(opts...)
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.closed {
+		return "", fmt.Errorf("memory: 消息队列已关闭")
+	}
+
+	subs := &subscription{
+		id:      fmt.Sprintf("memory-%d", b.nextId.Add(1)),
+		topic:   topic,
+		handler: handler,
+		options: options,
+	}
+
+	if b.mode == ModeAsync {
+		subs.start(b)
+	}
+
+	groups, exists := b.groups[topic]
+	if !exists {
+		groups = make(map[string]*groupState)
+		b.groups[topic] = groups
+	}
+
+	state, exists := groups[options.Group]
+	if !exists {
+		state = &groupState{}
+		groups[options.Group] = state
+	}
+	state.subscriptions = append(state.subscriptions, subs)
+
+	b.subscriptions[subs.id] = subs
+	return subs.id, nil
+}
+
+// Unsubscribe 取消订阅
+//
+// 异步模式下队列中还没有处理的消息被丢弃
+func (b *Broker) Unsubscribe(ctx context.Context, subscriptionId string) error {
+	if len(strings.TrimSpace(subscriptionId)) == 0 {
+		return broker.ErrEmptySubscriptionId
+	}
+
+	b.lock.Lock()
+	subs, exists := b.subscriptions[subscriptionId]
+	if !exists {
+		b.lock.Unlock()
+		return broker.ErrSubscriptionNotFound
+	}
+	delete(b.subscriptions, subscriptionId)
+
+	if state := b.groups[subs.topic][subs.options.Group]; state != nil {
+		for i, s := range state.subscriptions {
+			if s == subs {
+				state.subscriptions = append(state.subscriptions[:i], state.subscriptions[i+1:]...)
+				break
+			}
+		}
+	}
+	b.lock.Unlock()
+
+	subs.stop()
+	return nil
+}
+
+// Close 关闭消息队列, 取消所有订阅
+func (b *Broker) Close() error {
+	b.lock.Lock()
+	b.closed = true
+	subscriptions := b.subscriptions
+	b.subscriptions = make(map[string]*subscription)
+	b.groups = make(map[string]map[string]*groupState)
+	b.lock.Unlock()
+
+	for _, subs := range subscriptions {
+		subs.stop()
+	}
+	return nil
+}
+
+// Wait 等待所有已经发布的消息处理完毕, 包括重试和延迟发布的消息
+//
+// 同步模式下只需要等待延迟发布的消息
+func (b *Broker) Wait() {
+	b.pending.Wait()
+}
+
+// DeadLetters 获取所有的死信
+func (b *Broker) DeadLetters() []DeadLetter {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	deadLetters := make([]DeadLetter, len(b.deadLetters))
+	copy(deadLetters, b.deadLetters)
+	return deadLetters
+}
+
+// WaitDeadLetters 等待死信的数量达到 count, 用于处理函数阻塞时确认投递已经成为死信
+//
+// ctx 被取消时返回上下文的错误
+func (b *Broker) WaitDeadLetters(ctx context.Context, count int) error {
+	for {
+		b.lock.Lock()
+		reached := len(b.deadLetters) >= count
+		deadLettered := b.deadLettered
+		b.lock.Unlock()
+
+		if reached {
+			return nil
+		}
+
+		select {
+		case <-deadLettered:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
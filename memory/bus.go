@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"github.com/nf5lab/ebus"
+)
+
+// Bus 进程内的事件总线
+//
+// 发布者和订阅者共享同一个进程内的消息队列, 事件经过 ebus 完整的编码和解码流程
+type Bus struct {
+	ebus.Publisher
+	ebus.Subscriber
+
+	broker *Broker
+}
+
+// New 创建同步投递的事件总线
+// - opts 发布者和订阅者共用的选项
+func New(opts ...ebus.Option) *Bus {
+	return NewBus(NewBroker(), opts...)
+}
+
+// NewAsync 创建异步投递的事件总线
+// - opts 发布者和订阅者共用的选项
+func NewAsync(opts ...ebus.Option) *Bus {
+	return NewBus(NewBroker(WithMode(ModeAsync)), opts...)
+}
+
+// NewBus 使用指定的进程内消息队列创建事件总线
+// - b    进程内的消息队列
+// - opts 发布者和订阅者共用的选项
+func NewBus(b *Broker, opts ...ebus.Option) *Bus {
+	return &Bus{
+		Publisher:  ebus.NewPublisher(b, opts...),
+		Subscriber: ebus.NewSubscriber(b, opts...),
+		broker:     b,
+	}
+}
+
+// Broker 获取底层的进程内消息队列
+func (bus *Bus) Broker() *Broker {
+	return bus.broker
+}
+
+// Wait 等待所有已经发布的事件处理完毕
+func (bus *Bus) Wait() {
+	bus.broker.Wait()
+}
+
+// Close 关闭事件总线和底层的进程内消息队列
+func (bus *Bus) Close() error {
+	return bus.broker.Close()
+}
@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/nf5lab/broker"
+)
+
+// subscription 订阅
+type subscription struct {
+	id      string
+	topic   string
+	handler broker.Handler
+	options *broker.SubscribeOptions
+
+	// 异步模式的先进先出队列
+	lock    sync.Mutex
+	cond    *sync.Cond
+	queue   []*broker.Message
+	done    bool
+	pending *sync.WaitGroup // 消息队列的待处理计数, 出队或者丢弃时减少
+}
+
+// start 启动异步模式的工作协程, 数量等于订阅的并发处理数
+func (subs *subscription) start(b *Broker) {
+	subs.cond = sync.NewCond(&subs.lock)
+	subs.pending = &b.pending
+
+	for range subs.options.Concurrency {
+		go subs.work(b)
+	}
+}
+
+// enqueue 消息入队, 调用者已经增加了待处理计数
+func (subs *subscription) enqueue(msg *broker.Message) {
+	subs.lock.Lock()
+	defer subs.lock.Unlock()
+
+	if subs.done {
+		subs.pending.Done()
+		return
+	}
+
+	subs.queue = append(subs.queue, msg)
+	subs.cond.Signal()
+}
+
+// work 工作协程, 依次处理队列中的消息
+func (subs *subscription) work(b *Broker) {
+	for {
+		subs.lock.Lock()
+		for len(subs.queue) == 0 && !subs.done {
+			subs.cond.Wait()
+		}
+		if subs.done {
+			subs.lock.Unlock()
+			return
+		}
+		msg := subs.queue[0]
+		subs.queue[0] = nil
+		subs.queue = subs.queue[1:]
+		subs.lock.Unlock()
+
+		b.deliver(subs, msg)
+		subs.pending.Done()
+	}
+}
+
+// stopped 订阅是否已经取消
+func (subs *subscription) stopped() bool {
+	subs.lock.Lock()
+	defer subs.lock.Unlock()
+
+	return subs.done
+}
+
+// stop 取消订阅, 丢弃队列中还没有处理的消息
+func (subs *subscription) stop() {
+	subs.lock.Lock()
+	defer subs.lock.Unlock()
+
+	if subs.done {
+		return
+	}
+	subs.done = true
+
+	if subs.cond == nil {
+		return
+	}
+
+	for range subs.queue {
+		subs.pending.Done()
+	}
+	subs.queue = nil
+	subs.cond.Broadcast()
+}
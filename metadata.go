@@ -0,0 +1,110 @@
+package ebus
+
+import (
+	"strings"
+	"time"
+)
+
+// MetadataOption 元数据的配置函数
+type MetadataOption func(meta *Metadata)
+
+// NewMetadata 创建事件元数据
+//
+// 事件时间取默认时钟 DefaultClock() 的当前时间, 然后依次应用配置函数
+// 如果没有指定事件ID, 使用默认的事件ID生成器生成
+// - evtSource  事件来源
+// - evtType    事件类型
+// - scmVersion 模型版本
+func NewMetadata(evtSource EventSource, evtType EventType, scmVersion SchemaVersion, opts ...MetadataOption) *Metadata {
+	meta := &Metadata{
+		SchemaVersion: scmVersion,
+		EventId:       "",
+		EventSource:   evtSource,
+		EventType:     evtType,
+		EventTime:     DefaultClock().Now().Unix(),
+	}
+
+	for _, apply := range opts {
+		if apply != nil {
+			apply(meta)
+		}
+	}
+
+	if len(meta.EventId) == 0 {
+		meta.EventId = NewEventId()
+	}
+
+	meta.Normalize()
+	return meta
+}
+
+// WithMetadataEventId 设置事件ID
+func WithMetadataEventId(eventId string) MetadataOption {
+	return func(meta *Metadata) {
+		if eventId = strings.TrimSpace(eventId); len(eventId) > 0 {
+			meta.EventId = eventId
+		}
+	}
+}
+
+// WithMetadataEventTime 设置事件时间
+func WithMetadataEventTime(eventTime time.Time) MetadataOption {
+	return func(meta *Metadata) {
+		if !eventTime.IsZero() {
+			meta.EventTime = eventTime.Unix()
+		}
+	}
+}
+
+// WithMetadataClock 使用指定时钟的当前时间作为事件时间
+func WithMetadataClock(clock Clock) MetadataOption {
+	return func(meta *Metadata) {
+		if clock != nil {
+			meta.EventTime = clock.Now().Unix()
+		}
+	}
+}
+
+// WithMetadataIdGenerator 使用指定的生成器生成事件ID
+func WithMetadataIdGenerator(gen IdGenerator) MetadataOption {
+	return func(meta *Metadata) {
+		if gen != nil {
+			meta.EventId = gen.NewId()
+		}
+	}
+}
+
+// WithMetadataCorrelationId 设置关联ID
+func WithMetadataCorrelationId(correlationId string) MetadataOption {
+	return func(meta *Metadata) {
+		meta.CorrelationId = correlationId
+	}
+}
+
+// WithMetadataCausationId 设置因果ID
+func WithMetadataCausationId(causationId string) MetadataOption {
+	return func(meta *Metadata) {
+		meta.CausationId = causationId
+	}
+}
+
+// WithMetadataTenantId 设置租户ID
+func WithMetadataTenantId(tenantId string) MetadataOption {
+	return func(meta *Metadata) {
+		meta.TenantId = tenantId
+	}
+}
+
+// WithMetadataPartitionKey 设置分区键
+func WithMetadataPartitionKey(partitionKey string) MetadataOption {
+	return func(meta *Metadata) {
+		meta.PartitionKey = partitionKey
+	}
+}
+
+// WithMetadataExtension 设置扩展属性
+func WithMetadataExtension(key string, value string) MetadataOption {
+	return func(meta *Metadata) {
+		meta.SetExtension(key, value)
+	}
+}
@@ -0,0 +1,65 @@
+package ebus
+
+import (
+	"time"
+)
+
+var (
+	// 确保实现了 Metrics 接口
+	_ Metrics = NoopMetrics{}
+)
+
+// Metrics 指标收集器接口
+//
+// 实现必须是并发安全的
+// 自定义实现可以嵌入 NoopMetrics, 只覆盖关心的方法
+type Metrics interface {
+
+	// IncPublished 事件发布成功
+	IncPublished(topic string, meta *Metadata)
+
+	// IncPublishFailed 事件发布失败
+	IncPublishFailed(topic string, meta *Metadata)
+
+	// IncConsumed 事件消费成功 (解码并交给处理函数)
+	IncConsumed(topic string, meta *Metadata)
+
+	// IncFailed 事件处理失败 (处理函数返回错误或发生 panic)
+	IncFailed(topic string, meta *Metadata)
+
+	// IncDecodeFailed 事件解码失败
+	IncDecodeFailed(topic string)
+
+	// IncDuplicate 收到重复投递的事件 (已经成功处理过)
+	IncDuplicate(topic string, meta *Metadata)
+
+	// IncSchemaReceived 收到事件, 用于按模型版本统计 (解码成功之后, 交给处理函数之前)
+	IncSchemaReceived(topic string, meta *Metadata)
+
+	// ObserveHandlerDuration 记录事件处理耗时
+	ObserveHandlerDuration(topic string, meta *Metadata, duration time.Duration)
+
+	// ObservePayloadSize 记录事件消息体大小, 单位字节
+	ObservePayloadSize(topic string, meta *Metadata, size int)
+}
+
+// NoopMetrics 不执行任何操作的指标收集器
+type NoopMetrics struct{}
+
+func (NoopMetrics) IncPublished(string, *Metadata) {}
+
+func (NoopMetrics) IncPublishFailed(string, *Metadata) {}
+
+func (NoopMetrics) IncConsumed(string, *Metadata) {}
+
+func (NoopMetrics) IncFailed(string, *Metadata) {}
+
+func (NoopMetrics) IncDecodeFailed(string) {}
+
+func (NoopMetrics) IncDuplicate(string, *Metadata) {}
+
+func (NoopMetrics) IncSchemaReceived(string, *Metadata) {}
+
+func (NoopMetrics) ObserveHandlerDuration(string, *Metadata, time.Duration) {}
+
+func (NoopMetrics) ObservePayloadSize(string, *Metadata, int) {}
@@ -0,0 +1,101 @@
+package ebus
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Middleware 事件处理中间件
+//
+// 中间件包装下游的处理函数, 可以在事件到达处理函数之前或之后执行额外的逻辑
+type Middleware func(next EventHandler) EventHandler
+
+// Chain 组合中间件
+//
+// 第一个中间件位于最外层, 最先执行
+func Chain(handler EventHandler, middlewares ...Middleware) EventHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		if middlewares[i] != nil {
+			handler = middlewares[i](handler)
+		}
+	}
+	return handler
+}
+
+// TransformFunc 事件转换函数
+//
+// - 返回新的事件, 新事件将传递给下游
+// - 返回 nil 事件, 表示丢弃该事件 (视为处理成功)
+// - 返回错误, 表示处理失败
+type TransformFunc func(ctx context.Context, topic string, event Event) (Event, error)
+
+// Transform 事件转换中间件
+//
+// 在解码之后, 处理函数之前转换事件
+// 例如: 补充参考数据, 去除废弃字段, 转换事件类型
+func Transform(fn TransformFunc) Middleware {
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, topic string, event Event) error {
+			transformed, err := fn(ctx, topic, event)
+			if err != nil {
+				return fmt.Errorf("ebus: 事件转换失败: %w", err)
+			}
+
+			if transformed == nil {
+				return nil
+			}
+
+			return next(ctx, topic, transformed)
+		}
+	}
+}
+
+// HandlerTimeoutError 事件处理超时错误
+type HandlerTimeoutError struct {
+	Timeout time.Duration // 超时时间
+}
+
+func (err *HandlerTimeoutError) Error() string {
+	return fmt.Sprintf("ebus: 事件处理超时(%s)", err.Timeout)
+}
+
+// Unwrap 支持 errors.Is(err, context.DeadlineExceeded)
+func (err *HandlerTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// Timeout 事件处理超时中间件
+//
+// 处理函数在带有截止时间的上下文中执行, 超时后立即返回 *HandlerTimeoutError
+// 注意: 不响应上下文取消的处理函数会在后台继续运行, 直到自行返回
+func Timeout(timeout time.Duration) Middleware {
+	return func(next EventHandler) EventHandler {
+		if timeout <= 0 {
+			return next
+		}
+
+		return func(ctx context.Context, topic string, event Event) error {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			done := make(chan error, 1)
+			go func() {
+				done <- callHandler(ctx, next, topic, event)
+			}()
+
+			select {
+			case err := <-done:
+				if err != nil && ctx.Err() == context.DeadlineExceeded {
+					return &HandlerTimeoutError{Timeout: timeout}
+				}
+				return err
+			case <-ctx.Done():
+				if ctx.Err() == context.DeadlineExceeded {
+					return &HandlerTimeoutError{Timeout: timeout}
+				}
+				return ctx.Err()
+			}
+		}
+	}
+}
@@ -0,0 +1,1039 @@
+package ebus
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/nf5lab/broker"
+)
+
+// OrderingKeyFunc 排序键函数, 一般返回聚合ID
+type OrderingKeyFunc func(event Event) string
+
+// HeaderFilterFunc 消息头过滤函数, 返回 true 表示处理该消息
+type HeaderFilterFunc func(headers map[string]any) bool
+
+const (
+	// DefaultOrderingLanes 默认的串行通道数量
+	DefaultOrderingLanes = 16
+
+	// DefaultControlQueueSize 只区分控制事件并且没有设置 QueueSize 时, 分发队列的容量
+	DefaultControlQueueSize = 16
+)
+
+// Options 发布者与订阅者的选项
+type Options struct {
+
+	// Metrics 指标收集器
+	//
+	// - 设置为 nil, 表示使用 NoopMetrics (不收集指标)
+	Metrics Metrics
+
+	// Watchdog 订阅看门狗
+	//
+	// - 设置为 nil, 表示不监控订阅
+	Watchdog *Watchdog
+
+	// MetadataValidator 元数据校验器
+	//
+	// - 设置为 nil, 表示使用默认的元数据校验器 DefaultMetadataValidator()
+	MetadataValidator *MetadataValidator
+
+	// Middlewares 事件处理中间件
+	//
+	// 按添加顺序包装处理函数, 第一个中间件位于最外层
+	Middlewares []Middleware
+
+	// Logger 日志记录器
+	//
+	// - 设置为 nil, 表示不记录日志
+	Logger *slog.Logger
+
+	// RebalanceHandler 再均衡回调
+	//
+	// - 设置为 nil, 表示不关心再均衡
+	// - 如果消息队列不支持再均衡通知, 订阅时返回 ErrRebalanceNotSupported
+	RebalanceHandler RebalanceHandler
+
+	// Recorder 消费记录器, 仅用于开发和排查问题
+	//
+	// - 设置为 nil, 表示不记录
+	Recorder *Recorder
+
+	// Clock 时钟, 用于元数据校验
+	//
+	// - 设置为 nil, 表示使用默认时钟 DefaultClock()
+	Clock Clock
+
+	// MaxClockSkew 允许的最大时钟漂移
+	//
+	// 事件时间超过当前时间加上该值时, 元数据校验失败
+	// - 设置为 0, 表示使用默认值 DefaultMaxClockSkew
+	// - 设置为负数 (参考 NoClockSkewCheck), 表示不校验
+	MaxClockSkew time.Duration
+
+	// ControlEventTypes 控制事件类型
+	//
+	// 控制事件 (例如暂停, 刷新命令) 在进程内分发器中优先于数据事件处理
+	// 没有使用串行通道或者工作池时, 使用单个工作协程, 消息队列订阅的并发处理数为 1 + QueueSize,
+	// 数据事件在队列中等待时控制事件仍然可以进入分发器 (QueueSize 为 0 时使用 DefaultControlQueueSize)
+	// - 为空表示不区分控制事件, 不启用进程内分发器
+	ControlEventTypes []EventType
+
+	// DuplicateWindow 重复检测窗口大小
+	//
+	// 每个订阅记录最近成功处理的事件ID, 再次收到时计入重复投递指标并调用 OnDuplicate
+	// 只做统计, 不会跳过重复的事件
+	// - 设置为 0, 表示不检测重复投递
+	DuplicateWindow int
+
+	// OnDuplicate 重复投递回调
+	//
+	// - 设置为 nil, 表示只计入指标
+	OnDuplicate DuplicateHandler
+
+	// MaxEventAge 事件的最大年龄
+	//
+	// 事件时间早于当前时间减去该值的事件不会交给处理函数,
+	// 而是以不可重试的错误拒绝, 由消息队列丢弃或者转入死信队列
+	// 用于长时间停机恢复后, 避免处理已经过期的积压事件
+	// - 设置为 0, 表示不限制事件年龄
+	MaxEventAge time.Duration
+
+	// DefaultTopic 默认主题
+	//
+	// 发布或者订阅的主题为空时使用该主题
+	// - 设置为空字符串, 表示没有默认主题
+	DefaultTopic string
+
+	// AllowEmptyTopic 是否允许空主题
+	//
+	// 适用于本身支持默认主题的消息队列, 空主题原样交给消息队列处理
+	// 设置了 DefaultTopic 时, 优先使用 DefaultTopic
+	AllowEmptyTopic bool
+
+	// AllowEmptyGroup 是否允许空订阅组
+	//
+	// 适用于把空订阅组视为临时广播订阅的消息队列, 空订阅组原样交给消息队列处理
+	AllowEmptyGroup bool
+
+	// CausationChaining 是否自动填充因果ID
+	//
+	// 在处理函数中发布事件时, 如果事件没有因果ID, 使用正在处理的事件的ID作为因果ID
+	// 处理函数的上下文必须传递给 Publish
+	CausationChaining bool
+
+	// ServiceName 服务名称
+	//
+	// 发布事件时追加到事件路径消息头 (x-event-path) 中, 用于追踪事件经过的服务
+	// - 设置为空字符串, 表示不追加
+	ServiceName string
+
+	// MaxHops 最大跳数
+	//
+	// 在处理函数中发布事件时, 跳数等于正在处理的事件的跳数加1
+	// 超过最大跳数时发布失败, 返回 ErrMaxHopsExceeded, 用于打断服务之间意外形成的事件循环
+	// - 设置为 0, 表示不限制跳数
+	MaxHops int
+
+	// SchemaTelemetry 模型版本遥测
+	//
+	// - 设置为 nil, 表示不统计 (指标收集器仍然会收到 IncSchemaReceived)
+	SchemaTelemetry *SchemaTelemetry
+
+	// ExtensionHeaders 是否把扩展属性复制到消息头
+	//
+	// 开启后, 每个扩展属性都以 x-ext- 前缀写入消息头, 便于消息队列按消息头过滤和路由
+	// 扩展属性始终保存在事件信封中, 该选项不影响订阅者解码
+	ExtensionHeaders bool
+
+	// LegacyPayloadEncoding 是否把负载编码为 base64 字符串
+	//
+	// 旧版本的订阅者只能解码 base64 负载, 在所有订阅者升级之前开启
+	// 新版本的订阅者可以解码两种格式
+	LegacyPayloadEncoding bool
+
+	// StrictDecode 是否严格解码
+	//
+	// 开启后, 事件信封和负载中存在未知字段时解码失败, 以便尽早发现生产者和消费者的模型漂移
+	// 实现了 json.Unmarshaler 的事件 (例如 GenericEvent) 自行决定如何处理未知字段
+	StrictDecode bool
+
+	// SkipPayloadValidation 是否跳过事件校验
+	//
+	// 开启后, 事件的 Validate 失败时仍然交给处理函数, 校验错误可以通过 ValidationErrorFromContext 获取
+	// 适用于调试或者转发事件的消费者
+	SkipPayloadValidation bool
+
+	// Codec 编解码器
+	//
+	// 发布者使用该编解码器编码事件, 订阅者也使用该编解码器解码对应内容类型的消息
+	// - 设置为 nil, 表示使用 JSONCodec
+	Codec Codec
+
+	// Codecs 订阅者额外支持的编解码器
+	//
+	// 用于迁移期间同时接收多种格式的消息, JSON 总是支持
+	Codecs []Codec
+
+	// Compressor 消息体压缩器
+	//
+	// 发布者使用该压缩器压缩消息体, 订阅者按照 content-encoding 消息头解压, gzip 总是支持
+	// - 设置为 nil, 表示不压缩
+	Compressor Compressor
+
+	// CompressionThreshold 压缩阈值, 单位字节
+	//
+	// 消息体小于该大小时不压缩, 避免小事件承担压缩的开销
+	// - 设置为 0, 表示总是压缩
+	CompressionThreshold int
+
+	// PayloadChecksum 是否在消息头中附加消息体的 SHA-256 校验和
+	//
+	// 订阅者总是校验带有校验和的消息, 用于发现中间代理或者存储损坏的数据
+	// 校验和对应压缩之前的消息体
+	PayloadChecksum bool
+
+	// SigningKeys HMAC 签名的密钥提供者
+	//
+	// 发布者为消息签名, 订阅者拒绝没有签名或者签名无效的消息, 用于在共享的消息队列上拒绝伪造的事件
+	// - 设置为 nil, 表示不签名也不校验
+	SigningKeys KeyProvider
+
+	// Authorizer 发布和订阅的授权检查
+	//
+	// - 设置为 nil, 表示不检查
+	Authorizer Authorizer
+
+	// Quota 按照事件来源的发布配额
+	//
+	// 超过配额时发布失败, 返回 *QuotaExceededError, 避免失控的生产者淹没共享的主题
+	// - 设置为 nil, 表示不限制
+	Quota QuotaLimiter
+
+	// PublishRateLimit 发布速率限制
+	//
+	// 令牌桶按照消息计算, 全局共享或者按照主题分别限制
+	// - 设置为 nil, 表示不限制
+	PublishRateLimit *RateLimit
+
+	// AsyncPublish 异步发布的队列设置, 用于 PublishAsync
+	//
+	// - 设置为 nil, 表示使用默认设置
+	AsyncPublish *AsyncPublish
+
+	// Outbox 事务性发件箱, 用于 PublishTx
+	//
+	// - 设置为 nil, 表示不支持 PublishTx
+	Outbox TxOutbox
+
+	// EncryptionKeys AES-GCM 加密的密钥提供者
+	//
+	// 发布者加密消息体, 订阅者按照消息头中的加密算法和密钥ID透明地解密
+	// 事件的标识消息头不加密, 仍然可以用于路由和过滤
+	// - 设置为 nil, 表示不加密, 订阅者无法解密已加密的消息
+	EncryptionKeys KeyProvider
+
+	// Ed25519PrivateKey 发布者签名使用的 Ed25519 私钥, 参考 WithEd25519Signer
+	//
+	// - 设置为 nil, 表示不使用 Ed25519 签名
+	Ed25519PrivateKey ed25519.PrivateKey
+
+	// Ed25519KeyId 私钥对应的密钥ID
+	Ed25519KeyId string
+
+	// KeyResolver 订阅者校验 Ed25519 签名使用的公钥解析器
+	//
+	// 订阅者拒绝没有签名或者签名无效的消息
+	// - 设置为 nil, 表示不校验 Ed25519 签名
+	KeyResolver KeyResolver
+
+	// ResultPublisher 处理结果事件的发布者
+	//
+	// 每个事件处理完成后, 向 ResultTopic 发布一条 ResultEvent
+	// 处理结果事件自身不会再产生结果事件
+	// - 设置为 nil, 表示不发布处理结果事件
+	ResultPublisher Publisher
+
+	// ResultTopic 处理结果事件的主题
+	ResultTopic string
+
+	// TenantId 订阅者所属的租户
+	//
+	// 设置后, 租户ID不匹配 (包括没有租户ID) 的事件按照 TenantPolicy 处理
+	// - 设置为空字符串, 表示不按租户过滤
+	TenantId string
+
+	// TenantPolicy 租户不匹配时的处理策略
+	TenantPolicy TenantPolicy
+
+	// OrderingKey 排序键函数
+	//
+	// 设置后, 事件按排序键分配到 OrderingLanes 条串行通道中处理,
+	// 同一个排序键 (例如聚合ID) 的事件按照到达顺序串行处理, 不同的排序键并发处理
+	// 没有设置 SubscribeConcurrency 时, 消息队列订阅的并发处理数为 OrderingLanes
+	// - 设置为 nil, 表示不按键排序
+	OrderingKey OrderingKeyFunc
+
+	// OrderingLanes 串行通道的数量
+	//
+	// - 设置为 0, 表示使用默认值 DefaultOrderingLanes
+	OrderingLanes int
+
+	// Workers 工作池的工作协程数量
+	//
+	// 设置后, 投递先进入有界队列, 再由工作协程执行处理函数, 使消息队列的拉取与处理解耦
+	// 投递仍然等待处理完成之后才确认, 确认语义不变
+	// 设置了 OrderingKey 时, 使用串行通道, 忽略该值
+	// 没有设置 SubscribeConcurrency 时, 消息队列订阅的并发处理数为 Workers + QueueSize, 使队列可以填满
+	// - 设置为 0, 表示不使用工作池
+	Workers int
+
+	// QueueSize 分发队列的容量 (工作池, 串行通道, 控制事件分发共用)
+	//
+	// - 设置为 0, 表示不缓冲, 只有空闲的工作协程才能接收投递
+	QueueSize int
+
+	// OverflowPolicy 分发队列已满时的处理策略
+	//
+	// 只有消息队列的并发处理数超过工作协程数量与队列容量之和时, 队列才会溢出 (参考 SubscribeConcurrency)
+	OverflowPolicy OverflowPolicy
+
+	// SubscribeConcurrency 消息队列订阅的并发处理数 (参考 broker.WithSubscribeConcurrency)
+	//
+	// 处理函数执行完成之后才确认投递, 所以该值决定了同时进入进程内分发器的投递数量
+	// 取值范围由消息队列限制, 超过 broker.MaxConcurrencyLimit 时按照上限处理
+	// - 设置为 0, 表示按照分发方式计算: 串行通道数量, 工作池的 Workers + QueueSize, 控制事件分发的 1 + QueueSize, MaxInFlight, 其他情况使用消息队列的默认值
+	SubscribeConcurrency int
+
+	// MaxInFlight 每个订阅最大处理中的投递数量
+	//
+	// 达到该数量时自动暂停, 新的投递在进程内等待, 直到处理中的投递数量下降
+	// 消息队列实现了 PauseResumer 时同时暂停拉取, 多余的投递由消息队列保留, 数量下降之后恢复
+	// 只有消息队列的并发处理数超过该值时才会达到阈值 (参考 SubscribeConcurrency, OrderingLanes, Workers)
+	// 没有使用串行通道或者工作池时, 消息队列订阅的并发处理数默认为该值
+	// - 设置为 0, 表示不限制
+	MaxInFlight int
+
+	// ConsumeRateLimit 每个订阅每秒最多处理的投递数量
+	//
+	// 超过时新的投递在进程内等待, 用于消化积压时保护有严格速率限制的下游接口
+	// - 设置为 0, 表示不限制
+	ConsumeRateLimit float64
+
+	// HandlerTimeout 处理函数的超时时间
+	//
+	// 在所有中间件的最内层生效, 超时返回 *HandlerTimeoutError, 消息会重新投递
+	// - 设置为 0, 表示不限制
+	HandlerTimeout time.Duration
+
+	// PanicPolicy 处理函数发生 panic 时的处理策略
+	PanicPolicy PanicPolicy
+
+	// OnPanic 处理函数发生 panic 时的回调
+	//
+	// - 设置为 nil, 表示只记录日志
+	OnPanic PanicHandler
+
+	// DelayedRetryMode 处理函数返回 RetryAfter 时, 延迟重新投递的方式
+	DelayedRetryMode DelayedRetryMode
+
+	// RetryPublisher 延迟重新投递使用的消息队列发布者
+	//
+	// - 设置为 nil, 表示只能使用 DelayedRetryWait
+	RetryPublisher broker.Publisher
+
+	// AckMode 消息确认模式
+	AckMode AckMode
+
+	// HeaderFilter 消息头过滤函数
+	//
+	// 在解码事件信封之前调用, 返回 false 的消息被跳过 (视为处理成功)
+	// 容器消息的消息头只包含容器的信息, 整条消息一起过滤
+	// - 设置为 nil, 表示不过滤
+	HeaderFilter HeaderFilterFunc
+
+	// UnknownEventPolicy 未知事件类型的处理策略
+	UnknownEventPolicy UnknownEventPolicy
+
+	// UnknownEventHandler 未知事件类型的兜底处理函数
+	//
+	// 只有 UnknownEventPolicy 为 UnknownEventFallback 时使用
+	// - 设置为 nil, 表示按照 UnknownEventError 处理
+	UnknownEventHandler RawEventHandler
+
+	// DualVersions 双版本发布
+	//
+	// 发布模型版本为键的事件时, 使用已注册的降级函数同时发布值对应的模型版本
+	// 两条消息的事件ID相同, 旧版本的消息带有 HeaderLegacyCopyOf 消息头, 订阅者只处理其中一个版本:
+	// - 升级之后的订阅者使用 WithHeaderFilter(SkipLegacyCopies) 跳过旧版本的消息
+	// - 没有升级的订阅者没有注册新的模型版本, 使用 UnknownEventSkip 跳过新版本的消息
+	// 注意 DuplicateWindow 只检测和统计重复投递, 不会过滤
+	// PublishPacked 不会发布降级的事件
+	// - 设置为 nil, 表示不开启
+	DualVersions map[SchemaVersion]SchemaVersion
+
+	// Registry 事件工厂注册表
+	//
+	// - 设置为 nil, 表示使用全局注册表 (DefaultRegistry)
+	Registry *Registry
+
+	// SchemaRegistry 集中的模式注册中心
+	//
+	// 发布者检查事件的模型版本是否已经注册, 未注册时发布失败
+	// - 设置为 nil, 表示不检查
+	SchemaRegistry SchemaRegistry
+}
+
+// DefaultOptions 默认的选项
+func DefaultOptions() *Options {
+	return &Options{
+		Metrics:  NoopMetrics{},
+		Watchdog: nil,
+
+		MetadataValidator: defaultMetadataValidator,
+
+		Logger: discardLogger,
+	}
+}
+
+// Normalize 规范选项
+func (opts *Options) Normalize() {
+	if opts == nil {
+		return
+	}
+
+	if opts.Metrics == nil {
+		opts.Metrics = NoopMetrics{}
+	}
+
+	if opts.MetadataValidator == nil {
+		opts.MetadataValidator = defaultMetadataValidator
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = discardLogger
+	}
+
+	if opts.Registry == nil {
+		opts.Registry = defaultRegistry
+	}
+
+	if opts.Codec == nil {
+		opts.Codec = JSONCodec{}
+	}
+
+	if opts.CompressionThreshold < 0 {
+		opts.CompressionThreshold = 0
+	}
+
+	if opts.MaxClockSkew < 0 {
+		opts.MaxClockSkew = NoClockSkewCheck
+	}
+
+	if opts.DuplicateWindow < 0 {
+		opts.DuplicateWindow = 0
+	}
+
+	if opts.MaxEventAge < 0 {
+		opts.MaxEventAge = 0
+	}
+
+	opts.DefaultTopic = strings.TrimSpace(opts.DefaultTopic)
+	opts.ServiceName = strings.TrimSpace(opts.ServiceName)
+	opts.ResultTopic = strings.TrimSpace(opts.ResultTopic)
+	opts.TenantId = strings.TrimSpace(opts.TenantId)
+
+	if opts.OrderingLanes <= 0 {
+		opts.OrderingLanes = DefaultOrderingLanes
+	}
+
+	if opts.Workers < 0 {
+		opts.Workers = 0
+	}
+
+	if opts.QueueSize < 0 {
+		opts.QueueSize = 0
+	}
+
+	if opts.SubscribeConcurrency < 0 {
+		opts.SubscribeConcurrency = 0
+	}
+
+	if opts.MaxInFlight < 0 {
+		opts.MaxInFlight = 0
+	}
+
+	if opts.ConsumeRateLimit < 0 {
+		opts.ConsumeRateLimit = 0
+	}
+
+	if opts.HandlerTimeout < 0 {
+		opts.HandlerTimeout = 0
+	}
+
+	if opts.MaxHops < 0 {
+		opts.MaxHops = 0
+	}
+
+	if len(opts.DualVersions) > 0 {
+		dualVersions := make(map[SchemaVersion]SchemaVersion, len(opts.DualVersions))
+		for version, legacyVersion := range opts.DualVersions {
+			version, legacyVersion = version.Normalize(), legacyVersion.Normalize()
+			if !version.IsEmpty() && !legacyVersion.IsEmpty() && version != legacyVersion {
+				dualVersions[version] = legacyVersion
+			}
+		}
+		opts.DualVersions = dualVersions
+	}
+}
+
+// clock 获取时钟
+func (opts *Options) clock() Clock {
+	if opts.Clock != nil {
+		return opts.Clock
+	}
+	return DefaultClock()
+}
+
+// validateMetadata 使用选项中的校验器和时钟校验元数据
+func (opts *Options) validateMetadata(meta *Metadata) error {
+	return opts.MetadataValidator.ValidateWith(meta, &ValidateEnv{
+		Now:          opts.clock().Now(),
+		MaxClockSkew: opts.MaxClockSkew,
+	})
+}
+
+// buildHandler 使用选项中的超时和中间件包装处理函数
+func (opts *Options) buildHandler(handler EventHandler) EventHandler {
+	handler = Timeout(opts.HandlerTimeout)(handler)
+	return Chain(handler, opts.Middlewares...)
+}
+
+// resolveTopic 按照空主题策略确定主题
+func (opts *Options) resolveTopic(topic string) (string, error) {
+	topic = strings.TrimSpace(topic)
+	if len(topic) > 0 {
+		return topic, nil
+	}
+
+	if len(opts.DefaultTopic) > 0 {
+		return opts.DefaultTopic, nil
+	}
+
+	if opts.AllowEmptyTopic {
+		return "", nil
+	}
+
+	return "", fmt.Errorf("ebus: 主题不能为空")
+}
+
+// resolveGroup 按照空订阅组策略确定订阅组
+func (opts *Options) resolveGroup(group string) (string, error) {
+	group = strings.TrimSpace(group)
+	if len(group) == 0 && !opts.AllowEmptyGroup {
+		return "", fmt.Errorf("ebus: 订阅组不能为空")
+	}
+	return group, nil
+}
+
+// Option 选项的配置函数
+type Option func(*Options)
+
+// NewOptions 新建选项
+func NewOptions(opts ...Option) *Options {
+	options := DefaultOptions()
+	for _, apply := range opts {
+		if apply != nil {
+			apply(options)
+		}
+	}
+	options.Normalize()
+	return options
+}
+
+// WithMetrics 设置指标收集器
+func WithMetrics(metrics Metrics) Option {
+	return func(opts *Options) {
+		opts.Metrics = metrics
+	}
+}
+
+// WithWatchdog 设置订阅看门狗
+func WithWatchdog(watchdog *Watchdog) Option {
+	return func(opts *Options) {
+		opts.Watchdog = watchdog
+	}
+}
+
+// WithMetadataValidator 设置元数据校验器
+func WithMetadataValidator(validator *MetadataValidator) Option {
+	return func(opts *Options) {
+		opts.MetadataValidator = validator
+	}
+}
+
+// WithMiddleware 添加事件处理中间件
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(opts *Options) {
+		opts.Middlewares = append(opts.Middlewares, middlewares...)
+	}
+}
+
+// WithLogger 设置日志记录器
+func WithLogger(logger *slog.Logger) Option {
+	return func(opts *Options) {
+		opts.Logger = logger
+	}
+}
+
+// WithRebalanceHandler 设置再均衡回调
+func WithRebalanceHandler(handler RebalanceHandler) Option {
+	return func(opts *Options) {
+		opts.RebalanceHandler = handler
+	}
+}
+
+// WithRecorder 设置消费记录器
+func WithRecorder(recorder *Recorder) Option {
+	return func(opts *Options) {
+		opts.Recorder = recorder
+	}
+}
+
+// WithClock 设置时钟
+func WithClock(clock Clock) Option {
+	return func(opts *Options) {
+		opts.Clock = clock
+	}
+}
+
+// WithControlEvents 设置控制事件类型
+func WithControlEvents(evtTypes ...EventType) Option {
+	return func(opts *Options) {
+		opts.ControlEventTypes = append(opts.ControlEventTypes, evtTypes...)
+	}
+}
+
+// WithMaxClockSkew 设置允许的最大时钟漂移
+//
+// 漂移按照完整的时长比较, 不截断到秒; 事件时间的精度是秒, 所以 time.Nanosecond 表示不允许事件时间晚于当前时间
+// 使用 NoClockSkewCheck 关闭该校验
+//
+// 注意: 事件自身的 Validate 方法如果调用了 Metadata.Validate, 仍然使用默认值
+// 建议事件的 Validate 只校验负载字段, 元数据由发布者和订阅者统一校验
+func WithMaxClockSkew(skew time.Duration) Option {
+	return func(opts *Options) {
+		opts.MaxClockSkew = skew
+	}
+}
+
+// WithDuplicateDetection 开启重复投递检测
+// - window      检测窗口大小, 小于等于0时使用 DefaultDuplicateWindow
+// - onDuplicate 重复投递回调, 可以为 nil
+func WithDuplicateDetection(window int, onDuplicate DuplicateHandler) Option {
+	return func(opts *Options) {
+		if window <= 0 {
+			window = DefaultDuplicateWindow
+		}
+		opts.DuplicateWindow = window
+		opts.OnDuplicate = onDuplicate
+	}
+}
+
+// WithMaxEventAge 设置事件的最大年龄, 拒绝过期的事件
+func WithMaxEventAge(age time.Duration) Option {
+	return func(opts *Options) {
+		opts.MaxEventAge = age
+	}
+}
+
+// WithDefaultTopic 设置默认主题, 主题为空时使用
+func WithDefaultTopic(topic string) Option {
+	return func(opts *Options) {
+		opts.DefaultTopic = topic
+	}
+}
+
+// WithEmptyTopicAllowed 允许空主题, 空主题原样交给消息队列处理
+func WithEmptyTopicAllowed() Option {
+	return func(opts *Options) {
+		opts.AllowEmptyTopic = true
+	}
+}
+
+// WithEmptyGroupAllowed 允许空订阅组, 空订阅组原样交给消息队列处理
+func WithEmptyGroupAllowed() Option {
+	return func(opts *Options) {
+		opts.AllowEmptyGroup = true
+	}
+}
+
+// WithCausationChaining 在处理函数中发布事件时自动填充因果ID
+func WithCausationChaining() Option {
+	return func(opts *Options) {
+		opts.CausationChaining = true
+	}
+}
+
+// WithServiceName 设置服务名称
+func WithServiceName(name string) Option {
+	return func(opts *Options) {
+		opts.ServiceName = name
+	}
+}
+
+// WithMaxHops 设置最大跳数
+func WithMaxHops(hops int) Option {
+	return func(opts *Options) {
+		opts.MaxHops = hops
+	}
+}
+
+// WithSchemaTelemetry 设置模型版本遥测
+func WithSchemaTelemetry(telemetry *SchemaTelemetry) Option {
+	return func(opts *Options) {
+		opts.SchemaTelemetry = telemetry
+	}
+}
+
+// WithExtensionHeaders 把扩展属性复制到消息头
+func WithExtensionHeaders() Option {
+	return func(opts *Options) {
+		opts.ExtensionHeaders = true
+	}
+}
+
+// WithLegacyPayloadEncoding 把负载编码为 base64 字符串, 兼容旧版本的订阅者
+func WithLegacyPayloadEncoding() Option {
+	return func(opts *Options) {
+		opts.LegacyPayloadEncoding = true
+	}
+}
+
+// WithStrictDecode 开启严格解码, 事件信封和负载中存在未知字段时解码失败
+func WithStrictDecode() Option {
+	return func(opts *Options) {
+		opts.StrictDecode = true
+	}
+}
+
+// WithSkipPayloadValidation 跳过事件校验, 校验错误放入处理函数的上下文
+func WithSkipPayloadValidation() Option {
+	return func(opts *Options) {
+		opts.SkipPayloadValidation = true
+	}
+}
+
+// WithCodec 设置编解码器
+// - codec  发布和订阅使用的编解码器
+// - codecs 订阅者额外支持的编解码器
+func WithCodec(codec Codec, codecs ...Codec) Option {
+	return func(opts *Options) {
+		opts.Codec = codec
+		opts.Codecs = append(opts.Codecs, codecs...)
+	}
+}
+
+// WithCloudEvents 发布 CloudEvents 1.0 结构化模式的事件, 参考 CloudEventsCodec
+func WithCloudEvents() Option {
+	return WithCodec(CloudEventsCodec{})
+}
+
+// WithCloudEventsBinary 发布 CloudEvents 1.0 二进制模式的事件, 参考 CloudEventsBinaryCodec
+func WithCloudEventsBinary() Option {
+	return WithCodec(CloudEventsBinaryCodec{})
+}
+
+// WithGzip 使用 gzip 压缩消息体, 适用于承载大型文档类负载的主题
+//
+// 解压之后的大小不能超过 DefaultMaxDecompressedSize, 其他上限使用 WithCompression(GzipCompressor{MaxSize: n}, 0)
+func WithGzip() Option {
+	return func(opts *Options) {
+		opts.Compressor = GzipCompressor{}
+	}
+}
+
+// WithCompression 设置消息体压缩器
+//
+// 例如只压缩超过 64KB 的消息体:
+//
+//	ebus.WithCompression(compressor, 64<<10)
+//
+// - compressor 压缩器, 订阅者也需要设置相同的压缩器才能解压 (gzip 除外)
+// - minBytes   压缩阈值, 消息体小于该大小时不压缩
+func WithCompression(compressor Compressor, minBytes int) Option {
+	return func(opts *Options) {
+		opts.Compressor = compressor
+		opts.CompressionThreshold = minBytes
+	}
+}
+
+// WithPayloadChecksum 在消息头中附加消息体的 SHA-256 校验和, 订阅者解码之前校验
+func WithPayloadChecksum() Option {
+	return func(opts *Options) {
+		opts.PayloadChecksum = true
+	}
+}
+
+// WithHMACSigner 使用 HMAC-SHA256 为消息签名, 订阅者强制校验签名
+//
+// 签名覆盖内容类型, 事件的标识消息头和消息体, 写入 x-event-signature 消息头
+// - keyProvider 密钥提供者, 发布者和订阅者需要使用相同的密钥
+func WithHMACSigner(keyProvider KeyProvider) Option {
+	return func(opts *Options) {
+		opts.SigningKeys = keyProvider
+	}
+}
+
+// WithAuthorizer 在调用消息队列之前检查发布和订阅的权限
+func WithAuthorizer(authorizer Authorizer) Option {
+	return func(opts *Options) {
+		opts.Authorizer = authorizer
+	}
+}
+
+// WithSourceQuota 限制每个事件来源的发布速率
+//
+// 例如每个事件来源每秒最多发布 100 个事件, 允许突发 200 个:
+//
+//	ebus.WithSourceQuota(ebus.NewSourceQuota(100, 200))
+//
+// - limiter 发布配额的限制器
+func WithSourceQuota(limiter QuotaLimiter) Option {
+	return func(opts *Options) {
+		opts.Quota = limiter
+	}
+}
+
+// WithPublishRateLimit 限制发布者的发布速率, 保护消息队列不受突发流量的冲击
+//
+// 例如每个主题每秒最多发布 500 条消息, 超过时返回 ErrRateLimited:
+//
+//	ebus.WithPublishRateLimit(ebus.RateLimit{Rate: 500, Burst: 500, PerTopic: true, Mode: ebus.RateLimitError})
+//
+// - limit 发布速率限制, Rate 小于等于 0 表示不限制
+func WithPublishRateLimit(limit RateLimit) Option {
+	return func(opts *Options) {
+		opts.PublishRateLimit = &limit
+	}
+}
+
+// WithAsyncPublish 设置异步发布 (参考 PublishAsync) 的队列容量, 发送协程数量和发送超时
+//
+//	ebus.WithAsyncPublish(ebus.AsyncPublish{QueueSize: 4096, Workers: 8, Timeout: 5 * time.Second})
+//
+// - async 异步发布的队列设置, 小于等于 0 的字段使用默认值
+func WithAsyncPublish(async AsyncPublish) Option {
+	return func(opts *Options) {
+		opts.AsyncPublish = &async
+	}
+}
+
+// WithOutbox 设置事务性发件箱, 启用 PublishTx
+//
+//	box := outbox.New(outbox.WithDialect(outbox.MySQL))
+//	publisher := ebus.NewPublisher(brokerPublisher, ebus.WithOutbox(box))
+//	err := publisher.PublishTx(ctx, tx, "orders", event)
+//
+// - outbox 事务性发件箱
+func WithOutbox(outbox TxOutbox) Option {
+	return func(opts *Options) {
+		opts.Outbox = outbox
+	}
+}
+
+// WithEncryption 使用 AES-GCM 加密消息体, 用于通过共享的消息队列传递包含敏感个人信息的事件
+//
+// 密钥提供者可以是本地的密钥环, 也可以由 KMS 实现; 消息体先压缩再加密
+// - keyProvider 密钥提供者, 密钥长度为 16, 24 或者 32 字节
+func WithEncryption(keyProvider KeyProvider) Option {
+	return func(opts *Options) {
+		opts.EncryptionKeys = keyProvider
+	}
+}
+
+// WithEd25519Signer 发布者使用 Ed25519 私钥为消息签名
+//
+// 签名的范围与 WithHMACSigner 相同, 消费者使用公钥校验 (参考 WithEd25519Verifier), 不需要共享密钥
+// - keyId      密钥ID, 写入 x-event-key-id 消息头, 订阅者据此查找公钥
+// - privateKey 私钥
+func WithEd25519Signer(keyId string, privateKey ed25519.PrivateKey) Option {
+	return func(opts *Options) {
+		opts.Ed25519KeyId = keyId
+		opts.Ed25519PrivateKey = privateKey
+	}
+}
+
+// WithEd25519Verifier 订阅者强制校验 Ed25519 签名
+// - resolver 公钥解析器
+func WithEd25519Verifier(resolver KeyResolver) Option {
+	return func(opts *Options) {
+		opts.KeyResolver = resolver
+	}
+}
+
+// WithResultEvents 开启处理结果事件
+// - publisher 处理结果事件的发布者
+// - topic     处理结果事件的主题
+func WithResultEvents(publisher Publisher, topic string) Option {
+	return func(opts *Options) {
+		opts.ResultPublisher = publisher
+		opts.ResultTopic = topic
+	}
+}
+
+// WithTenant 只处理指定租户的事件
+// - tenantId 租户ID
+// - policy   租户不匹配时的处理策略
+func WithTenant(tenantId string, policy TenantPolicy) Option {
+	return func(opts *Options) {
+		opts.TenantId = tenantId
+		opts.TenantPolicy = policy
+	}
+}
+
+// WithOrderingKey 按排序键串行处理事件
+func WithOrderingKey(keyFunc OrderingKeyFunc) Option {
+	return func(opts *Options) {
+		opts.OrderingKey = keyFunc
+	}
+}
+
+// WithOrderingLanes 设置串行通道的数量
+func WithOrderingLanes(lanes int) Option {
+	return func(opts *Options) {
+		opts.OrderingLanes = lanes
+	}
+}
+
+// WithWorkerPool 使用工作池处理投递
+// - workers   工作协程数量
+// - queueSize 有界队列的容量
+// - overflow  队列已满时的处理策略
+func WithWorkerPool(workers int, queueSize int, overflow OverflowPolicy) Option {
+	return func(opts *Options) {
+		opts.Workers = workers
+		opts.QueueSize = queueSize
+		opts.OverflowPolicy = overflow
+	}
+}
+
+// WithSubscribeConcurrency 设置消息队列订阅的并发处理数
+//
+// 默认值由串行通道或者工作池计算, 设置为超过 Workers + QueueSize 时, OverflowNack 才会生效
+func WithSubscribeConcurrency(concurrency int) Option {
+	return func(opts *Options) {
+		opts.SubscribeConcurrency = concurrency
+	}
+}
+
+// WithMaxInFlight 设置每个订阅最大处理中的投递数量
+func WithMaxInFlight(maxInFlight int) Option {
+	return func(opts *Options) {
+		opts.MaxInFlight = maxInFlight
+	}
+}
+
+// WithConsumeRateLimit 限制每个订阅每秒处理的投递数量
+//
+// 投递按照固定的间隔均匀地处理, 不允许突发; 一条容器消息只计算一次
+// - rps 每秒最多处理的投递数量, 0 表示不限制
+func WithConsumeRateLimit(rps float64) Option {
+	return func(opts *Options) {
+		opts.ConsumeRateLimit = rps
+	}
+}
+
+// WithHandlerTimeout 设置处理函数的超时时间
+func WithHandlerTimeout(timeout time.Duration) Option {
+	return func(opts *Options) {
+		opts.HandlerTimeout = timeout
+	}
+}
+
+// WithPanicPolicy 设置处理函数发生 panic 时的处理策略
+// - policy  处理策略
+// - onPanic 回调, 可以为 nil
+func WithPanicPolicy(policy PanicPolicy, onPanic PanicHandler) Option {
+	return func(opts *Options) {
+		opts.PanicPolicy = policy
+		opts.OnPanic = onPanic
+	}
+}
+
+// WithDelayedRetry 设置延迟重新投递的方式
+// - mode      延迟重新投递的方式
+// - publisher 重新发布使用的消息队列发布者, DelayedRetryWait 时可以为 nil
+func WithDelayedRetry(mode DelayedRetryMode, publisher broker.Publisher) Option {
+	return func(opts *Options) {
+		opts.DelayedRetryMode = mode
+		opts.RetryPublisher = publisher
+	}
+}
+
+// WithAckMode 设置消息确认模式
+func WithAckMode(mode AckMode) Option {
+	return func(opts *Options) {
+		opts.AckMode = mode
+	}
+}
+
+// WithHeaderFilter 设置消息头过滤函数
+//
+// 例如只处理部分事件类型:
+//
+//	ebus.WithHeaderFilter(func(headers map[string]any) bool {
+//		return headers[ebus.HeaderEventType] == "order.created"
+//	})
+func WithHeaderFilter(filter HeaderFilterFunc) Option {
+	return func(opts *Options) {
+		opts.HeaderFilter = filter
+	}
+}
+
+// WithUnknownEventPolicy 设置未知事件类型的处理策略
+// - policy  处理策略
+// - handler 兜底处理函数, 只有 UnknownEventFallback 时需要
+func WithUnknownEventPolicy(policy UnknownEventPolicy, handler RawEventHandler) Option {
+	return func(opts *Options) {
+		opts.UnknownEventPolicy = policy
+		opts.UnknownEventHandler = handler
+	}
+}
+
+// WithDualVersion 开启双版本发布, 用于滚动升级的迁移窗口
+//
+// 需要使用 RegisterDowncaster 注册对应的降级函数
+// 旧版本的消息带有 HeaderLegacyCopyOf 消息头, 升级之后的订阅者使用 SkipLegacyCopies 跳过
+// - version       新的模型版本
+// - legacyVersion 同时发布的旧模型版本
+func WithDualVersion(version SchemaVersion, legacyVersion SchemaVersion) Option {
+	return func(opts *Options) {
+		if opts.DualVersions == nil {
+			opts.DualVersions = make(map[SchemaVersion]SchemaVersion)
+		}
+		opts.DualVersions[version] = legacyVersion
+	}
+}
+
+// WithRegistry 设置事件工厂注册表
+//
+// 用于隔离同一个程序中多个事件总线的事件工厂
+func WithRegistry(registry *Registry) Option {
+	return func(opts *Options) {
+		opts.Registry = registry
+	}
+}
+
+// WithSchemaRegistry 发布时检查事件的模型版本是否已经在模式注册中心注册
+//
+// 检查通过的模型版本会被缓存, 每个发布者对每个模型版本只请求一次注册中心
+func WithSchemaRegistry(registry SchemaRegistry) Option {
+	return func(opts *Options) {
+		opts.SchemaRegistry = registry
+	}
+}
@@ -0,0 +1,94 @@
+package outbox
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect 数据库方言
+//
+// 不同数据库的占位符和自增主键的写法不同, 其余语句使用标准 SQL
+type Dialect struct {
+	Name        string           // 方言名称
+	Placeholder func(int) string // 第 n 个参数的占位符, n 从 1 开始
+	CreateTable string           // 创建发件箱表的语句, 表名使用 {table} 占位
+	LockClause  string           // 中继查询待发布消息时的行锁子句, 为空表示不加锁 (数据库本身串行化写事务)
+}
+
+// placeholders 生成 n 个参数的占位符列表
+func (d Dialect) placeholders(n int) string {
+	list := make([]string, n)
+	for i := range list {
+		list[i] = d.Placeholder(i + 1)
+	}
+	return strings.Join(list, ", ")
+}
+
+// Schema 创建发件箱表的语句
+// - table 表名
+func (d Dialect) Schema(table string) string {
+	return strings.ReplaceAll(d.CreateTable, "{table}", table)
+}
+
+func questionPlaceholder(int) string {
+	return "?"
+}
+
+func dollarPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+var (
+	// Postgres PostgreSQL 方言
+	Postgres = Dialect{
+		Name:        "postgres",
+		Placeholder: dollarPlaceholder,
+		LockClause:  "FOR UPDATE",
+		CreateTable: `CREATE TABLE IF NOT EXISTS {table} (
+	seq           BIGSERIAL PRIMARY KEY,
+	message_id    VARCHAR(128) NOT NULL,
+	topic         VARCHAR(255) NOT NULL,
+	partition_key VARCHAR(255) NOT NULL DEFAULT '',
+	content_type  VARCHAR(128) NOT NULL,
+	headers       TEXT         NOT NULL,
+	body          BYTEA        NOT NULL,
+	created_at    TIMESTAMPTZ  NOT NULL,
+	sent_at       TIMESTAMPTZ  NULL
+)`,
+	}
+
+	// MySQL MySQL 方言
+	MySQL = Dialect{
+		Name:        "mysql",
+		Placeholder: questionPlaceholder,
+		LockClause:  "FOR UPDATE",
+		CreateTable: `CREATE TABLE IF NOT EXISTS {table} (
+	seq           BIGINT       NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	message_id    VARCHAR(128) NOT NULL,
+	topic         VARCHAR(255) NOT NULL,
+	partition_key VARCHAR(255) NOT NULL DEFAULT '',
+	content_type  VARCHAR(128) NOT NULL,
+	headers       TEXT         NOT NULL,
+	body          LONGBLOB     NOT NULL,
+	created_at    DATETIME(6)  NOT NULL,
+	sent_at       DATETIME(6)  NULL
+)`,
+	}
+
+	// SQLite SQLite 方言
+	SQLite = Dialect{
+		Name:        "sqlite",
+		Placeholder: questionPlaceholder,
+		CreateTable: `CREATE TABLE IF NOT EXISTS {table} (
+	seq           INTEGER PRIMARY KEY AUTOINCREMENT,
+	message_id    TEXT     NOT NULL,
+	topic         TEXT     NOT NULL,
+	partition_key TEXT     NOT NULL DEFAULT '',
+	content_type  TEXT     NOT NULL,
+	headers       TEXT     NOT NULL,
+	body          BLOB     NOT NULL,
+	created_at    DATETIME NOT NULL,
+	sent_at       DATETIME NULL
+)`,
+	}
+)
@@ -0,0 +1,205 @@
+// Package outbox 实现事务性发件箱
+//
+// 事件在调用者的数据库事务中写入发件箱表, 与业务状态的变更一起提交或者回滚,
+// 之后由中继把发件箱中的消息发布到消息队列, 从而保证事件发布与状态变更的原子性
+//
+// 使用方法:
+//
+//	tx, _ := db.BeginTx(ctx, nil)
+//	// ... 修改业务状态 ...
+//	if err := outbox.Save(ctx, tx, "orders", event); err != nil {
+//		_ = tx.Rollback()
+//		return err
+//	}
+//	return tx.Commit()
+//
+// 静态加密: 使用 WithEncryption 之后, 消息体在写入发件箱之前已经使用 AES-GCM 加密,
+// 发件箱表中只保存密文; 消息头 (事件标识, 加密算法和密钥ID) 仍然是明文,
+// 中继原样发布, 消息队列需要按照消息头路由, 订阅者需要按照消息头查找解密密钥
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/nf5lab/broker"
+	"github.com/nf5lab/ebus"
+)
+
+const (
+	// DefaultTable 默认的发件箱表名
+	DefaultTable = "ebus_outbox"
+)
+
+var (
+	// ErrNilTx 数据库事务为空
+	ErrNilTx = errors.New("outbox: 数据库事务不能为空")
+
+	// ErrNotEncrypted 发件箱要求加密, 但是消息体没有加密
+	ErrNotEncrypted = errors.New("outbox: 消息体没有加密")
+)
+
+// Option 发件箱选项
+type Option func(*Outbox)
+
+// WithTable 设置发件箱表名, 默认 DefaultTable
+func WithTable(table string) Option {
+	return func(o *Outbox) {
+		o.table = table
+	}
+}
+
+// WithDialect 设置数据库方言, 默认 Postgres
+func WithDialect(dialect Dialect) Option {
+	return func(o *Outbox) {
+		o.dialect = dialect
+	}
+}
+
+// WithEventOptions 设置编码事件使用的发布者选项, 例如编解码器, 主题前缀, 签名和加密
+//
+// 消息在写入发件箱时已经完成编码, 中继只负责原样发布
+func WithEventOptions(opts ...ebus.Option) Option {
+	return func(o *Outbox) {
+		o.eventOptions = append(o.eventOptions, opts...)
+	}
+}
+
+// WithEncryption 使用 AES-GCM 加密写入发件箱的消息体 (参考 ebus.WithEncryption)
+//
+// 等价于 WithEventOptions(ebus.WithEncryption(keyProvider)), 同时 Stage 拒绝没有加密的消息,
+// 避免其它发布者通过 PublishTx 把明文写入发件箱; 消息头不加密
+// - keyProvider 密钥提供者, 订阅者需要使用能够查找到相同密钥的提供者
+func WithEncryption(keyProvider ebus.KeyProvider) Option {
+	return func(o *Outbox) {
+		o.encrypted = keyProvider != nil
+		o.eventOptions = append(o.eventOptions, ebus.WithEncryption(keyProvider))
+	}
+}
+
+// Outbox 事务性发件箱
+type Outbox struct {
+	table        string
+	dialect      Dialect
+	eventOptions []ebus.Option
+	encrypted    bool // 是否要求消息体已经加密
+
+	encoder ebus.Publisher // 把事件编码为消息, 不会发送到消息队列
+}
+
+var (
+	// 确保实现了 TxOutbox 接口
+	_ ebus.TxOutbox = (*Outbox)(nil)
+)
+
+// New 创建事务性发件箱
+func New(opts ...Option) *Outbox {
+	o := &Outbox{
+		table:   DefaultTable,
+		dialect: Postgres,
+	}
+
+	for _, apply := range opts {
+		if apply != nil {
+			apply(o)
+		}
+	}
+
+	// 编码器只通过 PublishTx 把消息暂存到发件箱, 不会使用消息队列
+	o.encoder = ebus.NewPublisher(nil, append(o.eventOptions, ebus.WithOutbox(o))...)
+	return o
+}
+
+// Table 发件箱表名
+func (o *Outbox) Table() string {
+	return o.table
+}
+
+// Dialect 数据库方言
+func (o *Outbox) Dialect() Dialect {
+	return o.dialect
+}
+
+// Schema 创建发件箱表的语句
+func (o *Outbox) Schema() string {
+	return o.dialect.Schema(o.table)
+}
+
+// Save 在调用者的数据库事务中把事件写入发件箱
+//
+// 事件经过发布者完整的编码流程 (校验, 编码, 签名, 压缩, 加密), 编码之后的消息写入发件箱表;
+// 开启了双版本发布时, 每个版本写入一条消息
+// - tx    调用者的数据库事务, 由调用者提交或者回滚
+// - topic 主题
+// - event 事件
+func (o *Outbox) Save(ctx context.Context, tx *sql.Tx, topic string, event ebus.Event) error {
+	if tx == nil {
+		return ErrNilTx
+	}
+
+	return o.encoder.PublishTx(ctx, tx, topic, event)
+}
+
+// Stage 在数据库事务中把编码之后的消息写入发件箱 (实现 ebus.TxOutbox)
+func (o *Outbox) Stage(ctx context.Context, tx *sql.Tx, topic string, message *broker.Message) error {
+	if tx == nil {
+		return ErrNilTx
+	}
+
+	if err := message.Validate(); err != nil {
+		return err
+	}
+
+	if o.encrypted {
+		if algorithm, _ := message.GetHeaderString(ebus.HeaderEncryption); len(algorithm) == 0 {
+			return fmt.Errorf("%w: 消息(%s)", ErrNotEncrypted, message.Id)
+		}
+	}
+
+	headers, err := json.Marshal(message.Headers)
+	if err != nil {
+		return fmt.Errorf("outbox: 消息(%s)的消息头编码失败: %w", message.Id, err)
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (message_id, topic, partition_key, content_type, headers, body, created_at) VALUES (%s)",
+		o.table, o.dialect.placeholders(7),
+	)
+
+	_, err = tx.ExecContext(ctx, query,
+		message.Id,
+		topic,
+		message.PartitionKey,
+		message.ContentType,
+		string(headers),
+		message.Body,
+		ebus.DefaultClock().Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("outbox: 消息(%s)写入发件箱失败: %w", message.Id, err)
+	}
+
+	return nil
+}
+
+var (
+	defaultOutbox     *Outbox
+	defaultOutboxOnce sync.Once
+)
+
+// Default 默认的发件箱 (Postgres 方言, 默认表名, 默认的发布者选项)
+func Default() *Outbox {
+	defaultOutboxOnce.Do(func() {
+		defaultOutbox = New()
+	})
+	return defaultOutbox
+}
+
+// Save 在调用者的数据库事务中把事件写入默认的发件箱 (参考 Outbox.Save)
+func Save(ctx context.Context, tx *sql.Tx, topic string, event ebus.Event) error {
+	return Default().Save(ctx, tx, topic, event)
+}
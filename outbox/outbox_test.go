@@ -0,0 +1,230 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nf5lab/broker"
+	"github.com/nf5lab/ebus"
+	"github.com/nf5lab/ebus/ebustest"
+)
+
+// recordingDriver 记录写入语句参数的数据库驱动, 测试不需要真实的数据库
+type recordingDriver struct {
+	lock sync.Mutex
+	rows [][]driver.Value
+}
+
+func (d *recordingDriver) Open(name string) (driver.Conn, error) {
+	return &recordingConn{driver: d}, nil
+}
+
+func (d *recordingDriver) staged() [][]driver.Value {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return append([][]driver.Value(nil), d.rows...)
+}
+
+type recordingConn struct {
+	driver *recordingDriver
+}
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordingStmt{driver: c.driver}, nil
+}
+
+func (c *recordingConn) Close() error {
+	return nil
+}
+
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	return c, nil
+}
+
+func (c *recordingConn) Commit() error {
+	return nil
+}
+
+func (c *recordingConn) Rollback() error {
+	return nil
+}
+
+type recordingStmt struct {
+	driver *recordingDriver
+}
+
+func (s *recordingStmt) Close() error {
+	return nil
+}
+
+func (s *recordingStmt) NumInput() int {
+	return -1
+}
+
+func (s *recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.driver.lock.Lock()
+	defer s.driver.lock.Unlock()
+
+	s.driver.rows = append(s.driver.rows, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *recordingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("recordingStmt: 不支持查询")
+}
+
+var recorder = &recordingDriver{}
+
+func init() {
+	sql.Register("outbox-recording", recorder)
+}
+
+// customerUpdated 包含个人信息的测试事件
+type customerUpdated struct {
+	Meta  *ebus.Metadata `json:"metadata"`
+	Phone string         `json:"phone"`
+}
+
+func (evt *customerUpdated) Metadata() *ebus.Metadata {
+	return evt.Meta
+}
+
+func (evt *customerUpdated) Validate() error {
+	return nil
+}
+
+const secretPhone = "+86-138-0000-0000"
+
+func newCustomerRegistry(t *testing.T) *ebus.Registry {
+	t.Helper()
+
+	registry := ebus.NewRegistry()
+	if err := ebus.RegisterEventIn[customerUpdated](registry, "v1", "ebus.test", "customer.updated"); err != nil {
+		t.Fatalf("注册测试事件失败: %v", err)
+	}
+	return registry
+}
+
+// stage 在数据库事务中保存事件, 返回写入发件箱的消息
+func stage(t *testing.T, box *Outbox, event ebus.Event) (*broker.Message, error) {
+	t.Helper()
+
+	db, err := sql.Open("outbox-recording", "")
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("开启事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	before := len(recorder.staged())
+	if err := box.Save(context.Background(), tx, "customers", event); err != nil {
+		return nil, err
+	}
+
+	rows := recorder.staged()
+	if len(rows) != before+1 {
+		t.Fatalf("写入 %d 条消息, 期望 1 条", len(rows)-before)
+	}
+
+	// 参数顺序与 Stage 的 INSERT 语句一致
+	row := rows[len(rows)-1]
+	message := &broker.Message{
+		Id:           row[0].(string),
+		PartitionKey: row[2].(string),
+		ContentType:  row[3].(string),
+		Body:         row[5].([]byte),
+	}
+	if err := json.Unmarshal([]byte(row[4].(string)), &message.Headers); err != nil {
+		t.Fatalf("解码消息头失败: %v", err)
+	}
+	return message, nil
+}
+
+func TestStageEncrypted(t *testing.T) {
+	keys := ebus.StaticKeyProvider{KeyId: "k1", Key: bytes.Repeat([]byte{7}, 32)}
+	registry := newCustomerRegistry(t)
+
+	box := New(
+		WithEncryption(keys),
+		WithEventOptions(ebus.WithRegistry(registry)),
+	)
+
+	event := &customerUpdated{
+		Meta:  ebus.NewMetadata("ebus.test", "customer.updated", "v1"),
+		Phone: secretPhone,
+	}
+
+	message, err := stage(t, box, event)
+	if err != nil {
+		t.Fatalf("保存事件失败: %v", err)
+	}
+
+	// 发件箱表中的消息体是密文
+	if bytes.Contains(message.Body, []byte(secretPhone)) {
+		t.Fatalf("发件箱中的消息体是明文: %s", message.Body)
+	}
+
+	// 消息头是明文, 中继和订阅者依赖消息头路由和查找密钥
+	if keyId, _ := message.GetHeaderString(ebus.HeaderEncryptionKeyId); keyId != "k1" {
+		t.Errorf("密钥ID = %q, 期望 k1", keyId)
+	}
+	if eventType, _ := message.GetHeaderString(ebus.HeaderEventType); eventType != "customer.updated" {
+		t.Errorf("事件类型 = %q, 期望明文的 customer.updated", eventType)
+	}
+
+	// 中继原样发布之后, 订阅者使用相同的密钥解密
+	var received *customerUpdated
+	handler := func(ctx context.Context, topic string, event ebus.Event) error {
+		received = event.(*customerUpdated)
+		return nil
+	}
+
+	delivery := &broker.Delivery{Message: *message, Topic: "customers", Attempts: 1, ReceiveTime: time.Now()}
+	if err := ebustest.DeliverMessage(t, handler, delivery, ebus.WithRegistry(registry), ebus.WithEncryption(keys)); err != nil {
+		t.Fatalf("处理发件箱中的消息失败: %v", err)
+	}
+	if received == nil || received.Phone != secretPhone {
+		t.Fatalf("解密之后的事件 = %+v, 期望恢复原始内容", received)
+	}
+}
+
+func TestStageRejectsPlaintext(t *testing.T) {
+	registry := newCustomerRegistry(t)
+	box := New(WithEncryption(ebus.StaticKeyProvider{KeyId: "k1", Key: bytes.Repeat([]byte{7}, 32)}))
+
+	// 没有配置加密的发布者通过 PublishTx 写入要求加密的发件箱
+	publisher := ebus.NewPublisher(nil, ebus.WithRegistry(registry), ebus.WithOutbox(box))
+
+	db, err := sql.Open("outbox-recording", "")
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("开启事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	event := &customerUpdated{
+		Meta:  ebus.NewMetadata("ebus.test", "customer.updated", "v1"),
+		Phone: secretPhone,
+	}
+	if err := publisher.PublishTx(context.Background(), tx, "customers", event); !errors.Is(err, ErrNotEncrypted) {
+		t.Fatalf("写入明文消息的错误 = %v, 期望 ErrNotEncrypted", err)
+	}
+}
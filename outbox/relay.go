@@ -0,0 +1,250 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nf5lab/broker"
+	"github.com/nf5lab/ebus"
+)
+
+const (
+	// DefaultPollInterval 默认的轮询间隔
+	DefaultPollInterval = time.Second
+
+	// DefaultBatchSize 默认每批发布的消息数量
+	DefaultBatchSize = 100
+)
+
+// RelayOption 中继选项
+type RelayOption func(*Relay)
+
+// WithPollInterval 设置轮询间隔, 默认 DefaultPollInterval
+func WithPollInterval(interval time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.interval = interval
+	}
+}
+
+// WithBatchSize 设置每批发布的消息数量, 默认 DefaultBatchSize
+func WithBatchSize(size int) RelayOption {
+	return func(r *Relay) {
+		r.batchSize = size
+	}
+}
+
+// WithNotify 设置唤醒通道, 收到通知时立即发布, 不必等待下一次轮询
+//
+// 例如把 PostgreSQL 的 LISTEN/NOTIFY (由插入发件箱的触发器发出) 转换为通知,
+// 轮询仍然作为兜底, 通知丢失时不会丢失消息
+func WithNotify(notify <-chan struct{}) RelayOption {
+	return func(r *Relay) {
+		r.notify = notify
+	}
+}
+
+// WithRelayLogger 设置日志记录器, 默认 slog.Default()
+func WithRelayLogger(logger *slog.Logger) RelayOption {
+	return func(r *Relay) {
+		r.logger = logger
+	}
+}
+
+// Relay 发件箱中继
+//
+// 按照写入的顺序读取发件箱中待发布的消息, 原样发布到消息队列, 然后标记为已发送
+//   - 崩溃恢复: 消息只有在发布成功之后才标记为已发送, 中继重启之后继续发布没有标记的消息;
+//     发布成功但是标记之前崩溃的消息会再次发布 (至少一次), 消费者按照事件ID去重
+//   - 顺序: 同一个分区键 (聚合) 的消息发布失败时, 同一批次中该分区键之后的消息不再发布, 留到下一批次
+//   - 多实例: 查询使用方言的行锁子句, 同一时间只有一个中继在发布, 其余实例等待
+type Relay struct {
+	db        *sql.DB
+	outbox    *Outbox
+	publisher broker.Publisher
+
+	interval  time.Duration
+	batchSize int
+	notify    <-chan struct{}
+	logger    *slog.Logger
+}
+
+// NewRelay 创建发件箱中继
+// - db        发件箱所在的数据库
+// - publisher 消息队列的发布者, 消息已经完成编码, 不再经过 ebus.Publisher
+func (o *Outbox) NewRelay(db *sql.DB, publisher broker.Publisher, opts ...RelayOption) *Relay {
+	r := &Relay{
+		db:        db,
+		outbox:    o,
+		publisher: publisher,
+		interval:  DefaultPollInterval,
+		batchSize: DefaultBatchSize,
+		logger:    slog.Default(),
+	}
+
+	for _, apply := range opts {
+		if apply != nil {
+			apply(r)
+		}
+	}
+
+	if r.interval <= 0 {
+		r.interval = DefaultPollInterval
+	}
+
+	if r.batchSize <= 0 {
+		r.batchSize = DefaultBatchSize
+	}
+
+	if r.logger == nil {
+		r.logger = slog.Default()
+	}
+
+	return r
+}
+
+// NewRelay 创建默认发件箱的中继 (参考 Outbox.NewRelay)
+func NewRelay(db *sql.DB, publisher broker.Publisher, opts ...RelayOption) *Relay {
+	return Default().NewRelay(db, publisher, opts...)
+}
+
+// Run 持续发布发件箱中的消息, 直到上下文被取消
+//
+// 一批消息发布完毕之后, 如果批次已满则立即发布下一批, 否则等待轮询间隔或者唤醒通知
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		count, err := r.RelayOnce(ctx)
+		if err != nil {
+			r.logger.WarnContext(ctx, "outbox: 发布发件箱消息失败", slog.Any("error", err))
+		}
+
+		if err == nil && count >= r.batchSize {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-r.notify:
+		}
+	}
+}
+
+// pendingMessage 待发布的消息
+type pendingMessage struct {
+	seq     int64
+	topic   string
+	message *broker.Message
+}
+
+// RelayOnce 发布一批待发布的消息, 返回处理的消息数量 (包括发布失败的消息)
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("outbox: 开始事务失败: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	pending, err := r.fetch(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	table := r.outbox.table
+	dialect := r.outbox.dialect
+	update := fmt.Sprintf("UPDATE %s SET sent_at = %s WHERE seq = %s", table, dialect.Placeholder(1), dialect.Placeholder(2))
+
+	var (
+		errs   []error
+		failed = make(map[string]struct{}) // 发布失败的分区键
+	)
+	for _, item := range pending {
+		key := item.message.PartitionKey
+		if _, exists := failed[key]; exists && len(key) > 0 {
+			continue
+		}
+
+		if err := r.publisher.Publish(ctx, item.topic, item.message); err != nil {
+			failed[key] = struct{}{}
+			errs = append(errs, fmt.Errorf("outbox: 消息(%s)发布失败: %w", item.message.Id, err))
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, update, ebus.DefaultClock().Now().UTC(), item.seq); err != nil {
+			// 已经发布的消息会在下一批次再次发布
+			errs = append(errs, fmt.Errorf("outbox: 消息(%s)标记为已发送失败: %w", item.message.Id, err))
+			break
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		errs = append(errs, fmt.Errorf("outbox: 提交事务失败: %w", err))
+	}
+
+	return len(pending), errors.Join(errs...)
+}
+
+// fetch 按照写入的顺序读取一批待发布的消息
+func (r *Relay) fetch(ctx context.Context, tx *sql.Tx) ([]pendingMessage, error) {
+	query := fmt.Sprintf(
+		"SELECT seq, message_id, topic, partition_key, content_type, headers, body FROM %s WHERE sent_at IS NULL ORDER BY seq LIMIT %d %s",
+		r.outbox.table, r.batchSize, r.outbox.dialect.LockClause,
+	)
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("outbox: 查询发件箱失败: %w", err)
+	}
+	defer rows.Close()
+
+	var pending []pendingMessage
+	for rows.Next() {
+		var (
+			item    pendingMessage
+			message broker.Message
+			headers string
+		)
+		if err := rows.Scan(&item.seq, &message.Id, &item.topic, &message.PartitionKey, &message.ContentType, &headers, &message.Body); err != nil {
+			return nil, fmt.Errorf("outbox: 读取发件箱失败: %w", err)
+		}
+
+		if err := json.Unmarshal([]byte(headers), &message.Headers); err != nil {
+			return nil, fmt.Errorf("outbox: 消息(%s)的消息头解码失败: %w", message.Id, err)
+		}
+
+		item.message = &message
+		pending = append(pending, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("outbox: 读取发件箱失败: %w", err)
+	}
+
+	return pending, nil
+}
+
+// Cleanup 删除指定时间之前已经发送的消息, 返回删除的数量
+// - before 发送时间早于该时间的消息被删除
+func (r *Relay) Cleanup(ctx context.Context, before time.Time) (int64, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE sent_at IS NOT NULL AND sent_at < %s", r.outbox.table, r.outbox.dialect.Placeholder(1))
+
+	result, err := r.db.ExecContext(ctx, query, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("outbox: 清理发件箱失败: %w", err)
+	}
+
+	return result.RowsAffected()
+}
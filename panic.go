@@ -0,0 +1,65 @@
+package ebus
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nf5lab/broker"
+)
+
+// PanicPolicy 处理函数发生 panic 时的处理策略
+type PanicPolicy int
+
+const (
+	// PanicPolicyError 把 panic 转换为错误返回, 消息会重新投递 (默认)
+	PanicPolicyError PanicPolicy = iota
+
+	// PanicPolicyDeadLetter 把 panic 转换为不可重试的错误, 由消息队列丢弃或者转入死信队列
+	PanicPolicyDeadLetter
+
+	// PanicPolicyCrash 重新抛出 panic, 进程快速失败
+	PanicPolicyCrash
+)
+
+func (policy PanicPolicy) String() string {
+	switch policy {
+	case PanicPolicyError:
+		return "error"
+	case PanicPolicyDeadLetter:
+		return "dead-letter"
+	case PanicPolicyCrash:
+		return "crash"
+	default:
+		return fmt.Sprintf("PanicPolicy(%d)", int(policy))
+	}
+}
+
+// PanicHandler 处理函数发生 panic 时的回调
+//
+// 在执行处理策略之前调用, 可用于上报告警
+// - info  panic 的值
+// - stack 发生 panic 时的调用栈
+type PanicHandler func(ctx context.Context, topic string, event Event, info any, stack []byte)
+
+// handlerCrash 按照 PanicPolicyCrash 重新抛出的 panic
+//
+// 投递处理流程中的 recover 遇到该类型时继续抛出, 不会转换为错误
+type handlerCrash struct {
+	*handlerPanicError
+}
+
+// applyPanicPolicy 执行 panic 处理策略, 返回投递的结果
+func (sub *subscriber) applyPanicPolicy(ctx context.Context, topic string, event Event, panicErr *handlerPanicError, err error) error {
+	if onPanic := sub.options.OnPanic; onPanic != nil {
+		onPanic(ctx, topic, event, panicErr.info, panicErr.stack)
+	}
+
+	switch sub.options.PanicPolicy {
+	case PanicPolicyDeadLetter:
+		return broker.NewNonRetryableError(err)
+	case PanicPolicyCrash:
+		panic(handlerCrash{panicErr})
+	default:
+		return err
+	}
+}
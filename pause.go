@@ -0,0 +1,214 @@
+package ebus
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrSubscriptionNotFound 订阅不存在
+	ErrSubscriptionNotFound = errors.New("ebus: 订阅不存在")
+)
+
+// PauseResumer 支持暂停和恢复订阅的消息队列 (可选接口)
+//
+// 如果消息队列的订阅者实现了该接口, 暂停时停止从消息队列拉取消息
+// 否则暂停期间收到的投递在进程内等待, 直到恢复或者上下文取消
+type PauseResumer interface {
+
+	// Pause 暂停订阅
+	Pause(subscriptionId string) error
+
+	// Resume 恢复订阅
+	Resume(subscriptionId string) error
+}
+
+// flowGate 订阅的流量闸门
+//
+// 负责手动暂停, 以及处理中的投递数量超过阈值时的自动暂停
+// 绑定了 PauseResumer 之后, 暂停时同时暂停消息队列的拉取, 投递由消息队列保留而不是在进程内堆积
+type flowGate struct {
+	lock        sync.Mutex
+	paused      bool
+	autoPaused  bool // 处理中的投递数量达到阈值时自动暂停
+	inFlight    int
+	maxInFlight int           // 最大处理中的投递数量, 0 表示不限制
+	changed     chan struct{} // 状态变化时关闭并重建, 唤醒等待者
+
+	pauser         PauseResumer // 消息队列的暂停接口, 可以为 nil
+	subscriptionId string
+
+	syncLock     sync.Mutex // 保证暂停和恢复按照状态变化的顺序调用消息队列
+	brokerPaused bool       // 消息队列是否已经暂停
+}
+
+func newFlowGate(maxInFlight int) *flowGate {
+	return &flowGate{
+		maxInFlight: maxInFlight,
+		changed:     make(chan struct{}),
+	}
+}
+
+// notify 唤醒等待者, 调用者必须持有锁
+func (gate *flowGate) notify() {
+	close(gate.changed)
+	gate.changed = make(chan struct{})
+}
+
+// setPaused 设置暂停状态
+func (gate *flowGate) setPaused(paused bool) {
+	gate.lock.Lock()
+	defer gate.lock.Unlock()
+
+	if gate.paused != paused {
+		gate.paused = paused
+		gate.notify()
+	}
+}
+
+// bind 绑定消息队列的暂停接口, 订阅成功之后调用
+func (gate *flowGate) bind(pauser PauseResumer, subscriptionId string) {
+	gate.lock.Lock()
+	defer gate.lock.Unlock()
+
+	gate.pauser = pauser
+	gate.subscriptionId = subscriptionId
+}
+
+// syncBroker 使消息队列的暂停状态与闸门一致
+//
+// 手动暂停或者自动暂停时, 消息队列都处于暂停状态
+func (gate *flowGate) syncBroker() error {
+	gate.syncLock.Lock()
+	defer gate.syncLock.Unlock()
+
+	gate.lock.Lock()
+	paused := gate.paused || gate.autoPaused
+	pauser, subscriptionId := gate.pauser, gate.subscriptionId
+	gate.lock.Unlock()
+
+	if pauser == nil || paused == gate.brokerPaused {
+		return nil
+	}
+
+	var err error
+	if paused {
+		err = pauser.Pause(subscriptionId)
+	} else {
+		err = pauser.Resume(subscriptionId)
+	}
+	if err != nil {
+		return err
+	}
+
+	gate.brokerPaused = paused
+	return nil
+}
+
+// isPaused 是否已暂停
+func (gate *flowGate) isPaused() bool {
+	gate.lock.Lock()
+	defer gate.lock.Unlock()
+
+	return gate.paused
+}
+
+// enter 等待闸门打开并占用一个处理名额
+//
+// 成功时返回释放名额的函数
+func (gate *flowGate) enter(ctx context.Context) (func(), error) {
+	for {
+		gate.lock.Lock()
+		full := gate.maxInFlight > 0 && gate.inFlight >= gate.maxInFlight
+		if !gate.paused && !full {
+			gate.inFlight++
+			autoPause := gate.maxInFlight > 0 && gate.inFlight >= gate.maxInFlight && !gate.autoPaused
+			if autoPause {
+				gate.autoPaused = true
+			}
+			gate.lock.Unlock()
+
+			// 暂停失败时, 多余的投递仍然在闸门等待
+			if autoPause {
+				_ = gate.syncBroker()
+			}
+			return gate.leave, nil
+		}
+		changed := gate.changed
+		gate.lock.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// leave 释放处理名额, 处理中的投递数量低于阈值时恢复自动暂停的消息队列
+func (gate *flowGate) leave() {
+	gate.lock.Lock()
+	gate.inFlight--
+	autoResume := gate.autoPaused && gate.inFlight < gate.maxInFlight
+	if autoResume {
+		gate.autoPaused = false
+	}
+	gate.notify()
+	gate.lock.Unlock()
+
+	if autoResume {
+		_ = gate.syncBroker()
+	}
+}
+
+// findSubscription 查找订阅
+func (sub *subscriber) findSubscription(subscriptionId string) (*subscription, error) {
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+
+	subs, exists := sub.subscriptions[subscriptionId]
+	if !exists {
+		return nil, ErrSubscriptionNotFound
+	}
+	return subs, nil
+}
+
+// Pause 暂停订阅
+func (sub *subscriber) Pause(subscriptionId string) error {
+	subs, err := sub.findSubscription(subscriptionId)
+	if err != nil {
+		return err
+	}
+
+	wasPaused := subs.gate.isPaused()
+	subs.gate.setPaused(true)
+	if err := subs.gate.syncBroker(); err != nil {
+		subs.gate.setPaused(wasPaused)
+		return err
+	}
+
+	return nil
+}
+
+// Resume 恢复订阅
+func (sub *subscriber) Resume(subscriptionId string) error {
+	subs, err := sub.findSubscription(subscriptionId)
+	if err != nil {
+		return err
+	}
+
+	subs.gate.setPaused(false)
+
+	// 处理中的投递数量仍然达到阈值时, 消息队列保持暂停, 直到投递数量下降
+	return subs.gate.syncBroker()
+}
+
+// IsPaused 订阅是否已暂停
+func (sub *subscriber) IsPaused(subscriptionId string) (bool, error) {
+	subs, err := sub.findSubscription(subscriptionId)
+	if err != nil {
+		return false, err
+	}
+	return subs.gate.isPaused(), nil
+}
@@ -0,0 +1,105 @@
+package ebus
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/nf5lab/broker"
+)
+
+// pausingCapture 记录暂停和恢复调用的 broker.Subscriber
+type pausingCapture struct {
+	handlerCapture
+
+	lock  sync.Mutex
+	calls []string
+}
+
+func (c *pausingCapture) Pause(subscriptionId string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.calls = append(c.calls, "pause")
+	return nil
+}
+
+func (c *pausingCapture) Resume(subscriptionId string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.calls = append(c.calls, "resume")
+	return nil
+}
+
+func (c *pausingCapture) history() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return append([]string(nil), c.calls...)
+}
+
+func TestMaxInFlightPausesBroker(t *testing.T) {
+	const maxInFlight = 2
+
+	registry := newTestRegistry(t)
+
+	capturePub := &capturePublisher{}
+	publisher := NewPublisher(capturePub, WithRegistry(registry))
+	for i := range maxInFlight {
+		if err := publisher.Publish(context.Background(), "orders", newTestEvent("order", i)); err != nil {
+			t.Fatalf("编码事件失败: %v", err)
+		}
+	}
+	messages := capturePub.published()
+
+	entered := make(chan struct{}, maxInFlight)
+	release := make(chan struct{})
+	handler := func(ctx context.Context, topic string, event Event) error {
+		entered <- struct{}{}
+		<-release
+		return nil
+	}
+
+	capture := &pausingCapture{}
+	subscriber := NewSubscriber(capture, WithRegistry(registry), WithWorkerPool(4, 4, OverflowBlock), WithMaxInFlight(maxInFlight))
+	subscriptionId, err := subscriber.Subscribe(context.Background(), "orders", "billing", handler)
+	if err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+
+	var wait sync.WaitGroup
+	for _, message := range messages {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			_ = capture.handler(context.Background(), &broker.Delivery{Message: *message, Topic: "orders", Attempts: 1})
+		}()
+	}
+	for range maxInFlight {
+		<-entered
+	}
+
+	// 达到阈值时暂停消息队列的拉取
+	if calls := capture.history(); len(calls) != 1 || calls[0] != "pause" {
+		t.Fatalf("达到阈值时的调用 = %v, 期望 [pause]", calls)
+	}
+
+	// 手动暂停期间投递数量下降, 消息队列保持暂停
+	if err := subscriber.Pause(subscriptionId); err != nil {
+		t.Fatalf("暂停订阅失败: %v", err)
+	}
+	close(release)
+	wait.Wait()
+
+	if calls := capture.history(); len(calls) != 1 {
+		t.Fatalf("手动暂停期间的调用 = %v, 期望消息队列保持暂停", calls)
+	}
+
+	if err := subscriber.Resume(subscriptionId); err != nil {
+		t.Fatalf("恢复订阅失败: %v", err)
+	}
+	if calls := capture.history(); len(calls) != 2 || calls[1] != "resume" {
+		t.Fatalf("恢复之后的调用 = %v, 期望 [pause resume]", calls)
+	}
+}
@@ -0,0 +1,77 @@
+package ebus
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PeekMetadata 只解码事件信封中的元数据
+//
+// 不查找事件工厂, 也不解码负载, 路由和过滤可以据此低成本地做出决策
+// 返回的元数据已经规范化, 但是没有校验
+func PeekMetadata(data []byte) (*Metadata, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyBody
+	}
+
+	var envelope struct {
+		Metadata *Metadata `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEnvelopeDecode, err)
+	}
+
+	if envelope.Metadata == nil {
+		return nil, fmt.Errorf("%w: 事件信封元数据为空", ErrMetadataInvalid)
+	}
+
+	envelope.Metadata.Normalize()
+	return envelope.Metadata, nil
+}
+
+// PeekMetadataFromHeaders 从消息头读取元数据
+//
+// 只能读取发布者写入消息头的字段, 扩展属性只有开启了 ExtensionHeaders 才能读取
+// 消息头中没有事件ID时返回错误
+func PeekMetadataFromHeaders(headers map[string]any) (*Metadata, error) {
+	header := func(key string) string {
+		value, _ := headers[key].(string)
+		return value
+	}
+
+	meta := &Metadata{
+		SchemaVersion: SchemaVersion(header(HeaderSchemaVersion)),
+		EventId:       header(HeaderEventId),
+		EventSource:   EventSource(header(HeaderEventSource)),
+		EventType:     EventType(header(HeaderEventType)),
+		CorrelationId: header(HeaderCorrelationId),
+		CausationId:   header(HeaderCausationId),
+		TenantId:      header(HeaderTenantId),
+	}
+
+	if value := header(HeaderEventTime); len(value) > 0 {
+		eventTime, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("ebus: 消息头[%s]无效: %w", HeaderEventTime, err)
+		}
+		meta.EventTime = eventTime
+	}
+
+	for key, value := range headers {
+		if name, ok := strings.CutPrefix(key, HeaderExtensionPrefix); ok {
+			if str, ok := value.(string); ok {
+				meta.SetExtension(name, str)
+			}
+		}
+	}
+
+	meta.Normalize()
+
+	if len(meta.EventId) == 0 {
+		return nil, fmt.Errorf("ebus: 消息头中没有事件元数据")
+	}
+
+	return meta, nil
+}
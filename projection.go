@@ -0,0 +1,438 @@
+package ebus
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultProjectionDedupWindow 检查点默认记录的最近处理的事件ID数量
+	DefaultProjectionDedupWindow = 256
+)
+
+// Checkpoint 投影检查点
+type Checkpoint struct {
+	EventId   string    `json:"eventId"`   // 最后处理的事件ID
+	EventTime int64     `json:"eventTime"` // 最后处理的事件时间, Unix时间戳, 单位秒
+	Position  int64     `json:"position"`  // 已处理的事件数量
+	UpdatedAt time.Time `json:"updatedAt"` // 检查点更新时间
+
+	// AppliedEventIds 最近处理的事件ID, 按照处理顺序, 最多保留去重窗口大小个
+	//
+	// 重新投递或者回放与实时事件重叠时, 已经处理的事件被跳过
+	AppliedEventIds []string `json:"appliedEventIds,omitempty"`
+}
+
+// CheckpointStore 检查点存储接口
+type CheckpointStore interface {
+
+	// LoadCheckpoint 加载检查点, 不存在时返回 nil
+	LoadCheckpoint(ctx context.Context, projection string) (*Checkpoint, error)
+
+	// SaveCheckpoint 保存检查点
+	SaveCheckpoint(ctx context.Context, projection string, checkpoint *Checkpoint) error
+}
+
+// MemoryCheckpointStore 内存检查点存储
+//
+// 进程重启后检查点丢失, 适用于测试和可以完整重建的投影
+type MemoryCheckpointStore struct {
+	lock        sync.Mutex
+	checkpoints map[string]Checkpoint
+}
+
+// NewMemoryCheckpointStore 创建内存检查点存储
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{
+		checkpoints: make(map[string]Checkpoint),
+	}
+}
+
+// LoadCheckpoint 加载检查点
+func (store *MemoryCheckpointStore) LoadCheckpoint(_ context.Context, projection string) (*Checkpoint, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	if checkpoint, exists := store.checkpoints[projection]; exists {
+		return &checkpoint, nil
+	}
+	return nil, nil
+}
+
+// SaveCheckpoint 保存检查点
+func (store *MemoryCheckpointStore) SaveCheckpoint(_ context.Context, projection string, checkpoint *Checkpoint) error {
+	if checkpoint == nil {
+		return fmt.Errorf("ebus: 检查点不能为空")
+	}
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.checkpoints[projection] = *checkpoint
+	return nil
+}
+
+// ProjectionHandler 投影处理函数
+type ProjectionHandler func(ctx context.Context, event Event) error
+
+// ProjectionKeyFunc 投影分区键函数
+//
+// 同一个分区键的事件串行处理, 一般返回聚合ID
+type ProjectionKeyFunc func(event Event) string
+
+// ReplayFunc 事件回放函数
+//
+// 按顺序把历史事件传给 yield, yield 返回错误时应停止回放并返回该错误
+// - from 开始回放的检查点, 为 nil 时从头回放;
+// from.Position 是已经处理的事件数量, 按照相同顺序回放时可以作为偏移量,
+// 从 from.EventId 之前的位置开始回放也是安全的, 已经处理的事件会被跳过
+type ReplayFunc func(ctx context.Context, from *Checkpoint, yield func(event Event) error) error
+
+// Projection 读模型投影
+//
+// 按事件类型声明处理函数, 由投影负责订阅, 分区键串行处理, 检查点持久化和回放重建
+type Projection struct {
+	name    string
+	store   CheckpointStore
+	keyFunc ProjectionKeyFunc
+
+	handlersLock sync.RWMutex
+	handlers     map[EventType]ProjectionHandler
+
+	keyLocks keyedMutex
+
+	checkpointLock sync.Mutex
+	checkpoint     *Checkpoint         // 缓存的检查点, nil 表示尚未加载
+	applied        map[string]struct{} // 检查点中最近处理的事件ID
+	dedupWindow    int                 // 检查点记录的最近处理的事件ID数量
+}
+
+// NewProjection 创建读模型投影
+// - name  投影名称, 用作检查点的键
+// - store 检查点存储
+func NewProjection(name string, store CheckpointStore) *Projection {
+	return &Projection{
+		name:        strings.TrimSpace(name),
+		store:       store,
+		handlers:    make(map[EventType]ProjectionHandler),
+		dedupWindow: DefaultProjectionDedupWindow,
+	}
+}
+
+// Name 投影名称
+func (p *Projection) Name() string {
+	return p.name
+}
+
+// SetKeyFunc 设置分区键函数
+//
+// 未设置时所有事件串行处理
+func (p *Projection) SetKeyFunc(fn ProjectionKeyFunc) *Projection {
+	p.keyFunc = fn
+	return p
+}
+
+// SetDedupWindow 设置检查点记录的最近处理的事件ID数量
+//
+// 窗口越大, 能够识别的重复投递越早, 检查点也越大
+// - size 为 0 时使用 DefaultProjectionDedupWindow
+func (p *Projection) SetDedupWindow(size int) *Projection {
+	if size <= 0 {
+		size = DefaultProjectionDedupWindow
+	}
+
+	p.checkpointLock.Lock()
+	defer p.checkpointLock.Unlock()
+
+	p.dedupWindow = size
+	return p
+}
+
+// On 声明事件类型的处理函数
+//
+// 没有声明处理函数的事件类型会被忽略 (检查点照常推进)
+func (p *Projection) On(evtType EventType, handler ProjectionHandler) *Projection {
+	p.handlersLock.Lock()
+	defer p.handlersLock.Unlock()
+
+	p.handlers[evtType.Normalize()] = handler
+	return p
+}
+
+// Subscribe 订阅主题, 把事件投影到读模型
+func (p *Projection) Subscribe(ctx context.Context, sub Subscriber, topic string, group string) (string, error) {
+	if err := p.validate(); err != nil {
+		return "", err
+	}
+
+	if sub == nil {
+		return "", fmt.Errorf("ebus: 订阅者不能为空")
+	}
+
+	return sub.Subscribe(ctx, topic, group, p.Handle)
+}
+
+// Handle 处理事件, 可以直接作为 EventHandler 使用
+//
+// 检查点中记录为已经处理的事件 (例如重新投递) 直接跳过
+func (p *Projection) Handle(ctx context.Context, _ string, event Event) error {
+	return p.apply(ctx, event)
+}
+
+// Checkpoint 获取当前检查点, 不存在时返回 nil
+func (p *Projection) Checkpoint(ctx context.Context) (*Checkpoint, error) {
+	p.checkpointLock.Lock()
+	defer p.checkpointLock.Unlock()
+
+	if err := p.loadCheckpoint(ctx); err != nil {
+		return nil, err
+	}
+
+	if p.checkpoint == nil || p.checkpoint.Position == 0 {
+		return nil, nil
+	}
+
+	checkpoint := *p.checkpoint
+	checkpoint.AppliedEventIds = slices.Clone(checkpoint.AppliedEventIds)
+	return &checkpoint, nil
+}
+
+// CatchUp 从检查点继续回放, 追上历史事件
+//
+// 例如服务启动时, 先追上停机期间的事件, 再订阅实时事件, 读模型不需要重建
+// 回放与实时事件重叠的部分按照检查点中的事件ID跳过
+// - replay 事件回放函数, 收到已经加载的检查点, 没有检查点时收到 nil
+func (p *Projection) CatchUp(ctx context.Context, replay ReplayFunc) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+
+	if replay == nil {
+		return fmt.Errorf("ebus: 投影(%s)回放函数不能为空", p.name)
+	}
+
+	from, err := p.Checkpoint(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := replay(ctx, from, func(event Event) error { return p.apply(ctx, event) }); err != nil {
+		return fmt.Errorf("ebus: 投影(%s)回放失败: %w", p.name, err)
+	}
+
+	return nil
+}
+
+// Rebuild 清空读模型和检查点, 从头回放重建读模型
+//
+// 读模型没有损坏时使用 CatchUp 从检查点继续, 不需要重建
+// 重建前应当停止该投影的订阅, 否则实时事件会与回放事件交错
+// - reset  清空读模型, 可以为 nil
+// - replay 事件回放函数, 收到的检查点为 nil
+func (p *Projection) Rebuild(ctx context.Context, reset func(ctx context.Context) error, replay ReplayFunc) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+
+	if replay == nil {
+		return fmt.Errorf("ebus: 投影(%s)回放函数不能为空", p.name)
+	}
+
+	if reset != nil {
+		if err := reset(ctx); err != nil {
+			return fmt.Errorf("ebus: 投影(%s)重置读模型失败: %w", p.name, err)
+		}
+	}
+
+	if err := p.resetCheckpoint(ctx); err != nil {
+		return err
+	}
+
+	if err := replay(ctx, nil, func(event Event) error { return p.apply(ctx, event) }); err != nil {
+		return fmt.Errorf("ebus: 投影(%s)回放失败: %w", p.name, err)
+	}
+
+	return nil
+}
+
+func (p *Projection) validate() error {
+	if len(p.name) == 0 {
+		return fmt.Errorf("ebus: 投影名称不能为空")
+	}
+
+	if p.store == nil {
+		return fmt.Errorf("ebus: 投影(%s)检查点存储不能为空", p.name)
+	}
+
+	return nil
+}
+
+// apply 应用事件并推进检查点
+func (p *Projection) apply(ctx context.Context, event Event) error {
+	if event == nil {
+		return fmt.Errorf("ebus: 事件不能为空")
+	}
+
+	metadata := event.Metadata()
+	if metadata == nil {
+		return fmt.Errorf("ebus: 事件元数据不能为空")
+	}
+
+	key := ""
+	if p.keyFunc != nil {
+		key = p.keyFunc(event)
+	}
+
+	unlock := p.keyLocks.lock(key)
+	defer unlock()
+
+	// 同一个事件的分区键相同, 所以检查和处理之间不会有重复的事件插入
+	if applied, err := p.isApplied(ctx, metadata.EventId); applied || err != nil {
+		return err
+	}
+
+	p.handlersLock.RLock()
+	handler := p.handlers[metadata.EventType.Normalize()]
+	p.handlersLock.RUnlock()
+
+	if handler != nil {
+		if err := handler(ctx, event); err != nil {
+			return fmt.Errorf("ebus: 投影(%s)处理事件(%s)失败: %w", p.name, metadata.EventId, err)
+		}
+	}
+
+	return p.advanceCheckpoint(ctx, metadata)
+}
+
+// loadCheckpoint 加载检查点到缓存, 调用者必须持有 checkpointLock
+func (p *Projection) loadCheckpoint(ctx context.Context) error {
+	if p.checkpoint != nil {
+		return nil
+	}
+
+	checkpoint, err := p.store.LoadCheckpoint(ctx, p.name)
+	if err != nil {
+		return fmt.Errorf("ebus: 投影(%s)加载检查点失败: %w", p.name, err)
+	}
+
+	if checkpoint == nil {
+		checkpoint = &Checkpoint{}
+	}
+
+	p.setCheckpoint(checkpoint)
+	return nil
+}
+
+// setCheckpoint 更新缓存的检查点, 调用者必须持有 checkpointLock
+func (p *Projection) setCheckpoint(checkpoint *Checkpoint) {
+	p.checkpoint = checkpoint
+	p.applied = make(map[string]struct{}, len(checkpoint.AppliedEventIds))
+	for _, eventId := range checkpoint.AppliedEventIds {
+		p.applied[eventId] = struct{}{}
+	}
+}
+
+// isApplied 事件是否已经处理
+func (p *Projection) isApplied(ctx context.Context, eventId string) (bool, error) {
+	p.checkpointLock.Lock()
+	defer p.checkpointLock.Unlock()
+
+	if err := p.loadCheckpoint(ctx); err != nil {
+		return false, err
+	}
+
+	_, applied := p.applied[eventId]
+	return applied, nil
+}
+
+func (p *Projection) advanceCheckpoint(ctx context.Context, metadata *Metadata) error {
+	p.checkpointLock.Lock()
+	defer p.checkpointLock.Unlock()
+
+	if err := p.loadCheckpoint(ctx); err != nil {
+		return err
+	}
+
+	// 保存的检查点不能与缓存共享底层数组, 所以总是复制
+	applied := p.checkpoint.AppliedEventIds
+	evicted := applied[:max(0, len(applied)-p.dedupWindow+1)]
+	applied = applied[len(evicted):]
+
+	checkpoint := &Checkpoint{
+		EventId:         metadata.EventId,
+		EventTime:       metadata.EventTime,
+		Position:        p.checkpoint.Position + 1,
+		UpdatedAt:       time.Now(),
+		AppliedEventIds: append(slices.Clone(applied), metadata.EventId),
+	}
+
+	if err := p.store.SaveCheckpoint(ctx, p.name, checkpoint); err != nil {
+		return fmt.Errorf("ebus: 投影(%s)保存检查点失败: %w", p.name, err)
+	}
+
+	p.checkpoint = checkpoint
+	for _, eventId := range evicted {
+		delete(p.applied, eventId)
+	}
+	p.applied[metadata.EventId] = struct{}{}
+	return nil
+}
+
+func (p *Projection) resetCheckpoint(ctx context.Context) error {
+	p.checkpointLock.Lock()
+	defer p.checkpointLock.Unlock()
+
+	checkpoint := &Checkpoint{
+		UpdatedAt: time.Now(),
+	}
+
+	if err := p.store.SaveCheckpoint(ctx, p.name, checkpoint); err != nil {
+		return fmt.Errorf("ebus: 投影(%s)重置检查点失败: %w", p.name, err)
+	}
+
+	p.setCheckpoint(checkpoint)
+	return nil
+}
+
+// keyedMutex 按键加锁的互斥锁
+type keyedMutex struct {
+	mutex sync.Mutex
+	locks map[string]*keyedMutexEntry
+}
+
+type keyedMutexEntry struct {
+	mutex sync.Mutex
+	refs  int
+}
+
+// lock 锁定指定的键, 返回解锁函数
+func (km *keyedMutex) lock(key string) func() {
+	km.mutex.Lock()
+	if km.locks == nil {
+		km.locks = make(map[string]*keyedMutexEntry)
+	}
+	entry, exists := km.locks[key]
+	if !exists {
+		entry = &keyedMutexEntry{}
+		km.locks[key] = entry
+	}
+	entry.refs++
+	km.mutex.Unlock()
+
+	entry.mutex.Lock()
+
+	return func() {
+		entry.mutex.Unlock()
+
+		km.mutex.Lock()
+		entry.refs--
+		if entry.refs == 0 {
+			delete(km.locks, key)
+		}
+		km.mutex.Unlock()
+	}
+}
@@ -0,0 +1,162 @@
+package ebus
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// orderHistory 按照顺序保存的历史事件, 用于回放
+func orderHistory(count int) []Event {
+	events := make([]Event, count)
+	for i := range events {
+		events[i] = newTestEvent(fmt.Sprintf("order-%d", i), i)
+	}
+	return events
+}
+
+// replayFrom 按照检查点的位置回放历史事件
+//
+// overlap 表示从检查点之前的位置开始回放, 模拟与已经处理的事件重叠
+func replayFrom(history []Event, overlap int, received **Checkpoint) ReplayFunc {
+	return func(ctx context.Context, from *Checkpoint, yield func(event Event) error) error {
+		*received = from
+
+		start := 0
+		if from != nil {
+			start = max(0, int(from.Position)-overlap)
+		}
+
+		for _, event := range history[start:] {
+			if err := yield(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// newOrderProjection 创建统计事件处理次数的投影
+func newOrderProjection(store CheckpointStore, applied map[string]int) *Projection {
+	return NewProjection("orders", store).On(testType, func(ctx context.Context, event Event) error {
+		applied[event.(*testEvent).OrderId]++
+		return nil
+	})
+}
+
+func TestProjectionCatchUp(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryCheckpointStore()
+	history := orderHistory(5)
+
+	applied := make(map[string]int)
+	projection := newOrderProjection(store, applied)
+
+	// 停机之前处理了前三个事件
+	for _, event := range history[:3] {
+		if err := projection.Handle(ctx, "orders", event); err != nil {
+			t.Fatalf("处理事件失败: %v", err)
+		}
+	}
+
+	// 重启之后从保存的检查点继续, 回放与已经处理的事件重叠两个
+	restarted := newOrderProjection(store, applied)
+
+	var from *Checkpoint
+	if err := restarted.CatchUp(ctx, replayFrom(history, 2, &from)); err != nil {
+		t.Fatalf("追赶失败: %v", err)
+	}
+
+	if from == nil || from.Position != 3 || from.EventId != history[2].Metadata().EventId {
+		t.Fatalf("回放收到的检查点 = %+v, 期望位置 3", from)
+	}
+
+	for _, event := range history {
+		orderId := event.(*testEvent).OrderId
+		if applied[orderId] != 1 {
+			t.Errorf("事件(%s)处理 %d 次, 期望 1 次", orderId, applied[orderId])
+		}
+	}
+
+	checkpoint, err := restarted.Checkpoint(ctx)
+	if err != nil {
+		t.Fatalf("获取检查点失败: %v", err)
+	}
+	if checkpoint.Position != int64(len(history)) {
+		t.Errorf("检查点位置 = %d, 期望 %d", checkpoint.Position, len(history))
+	}
+}
+
+func TestProjectionSkipsAppliedEvents(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryCheckpointStore()
+
+	applied := make(map[string]int)
+	projection := newOrderProjection(store, applied).SetDedupWindow(2)
+
+	history := orderHistory(3)
+	for _, event := range history {
+		if err := projection.Handle(ctx, "orders", event); err != nil {
+			t.Fatalf("处理事件失败: %v", err)
+		}
+	}
+
+	// 重新投递窗口内的事件被跳过, 检查点不推进
+	if err := projection.Handle(ctx, "orders", history[2]); err != nil {
+		t.Fatalf("处理重复事件失败: %v", err)
+	}
+	if applied["order-2"] != 1 {
+		t.Errorf("重复投递的事件处理 %d 次, 期望 1 次", applied["order-2"])
+	}
+
+	// 移出窗口的事件不再被识别
+	if err := projection.Handle(ctx, "orders", history[0]); err != nil {
+		t.Fatalf("处理事件失败: %v", err)
+	}
+	if applied["order-0"] != 2 {
+		t.Errorf("窗口之外的事件处理 %d 次, 期望 2 次", applied["order-0"])
+	}
+
+	checkpoint, err := store.LoadCheckpoint(ctx, "orders")
+	if err != nil {
+		t.Fatalf("加载检查点失败: %v", err)
+	}
+	if checkpoint.Position != 4 || len(checkpoint.AppliedEventIds) != 2 {
+		t.Errorf("检查点 = %+v, 期望位置 4 并且保留 2 个事件ID", checkpoint)
+	}
+}
+
+func TestProjectionRebuild(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryCheckpointStore()
+	history := orderHistory(3)
+
+	applied := make(map[string]int)
+	projection := newOrderProjection(store, applied)
+	for _, event := range history {
+		if err := projection.Handle(ctx, "orders", event); err != nil {
+			t.Fatalf("处理事件失败: %v", err)
+		}
+	}
+
+	reset := func(ctx context.Context) error {
+		clear(applied)
+		return nil
+	}
+
+	// 重建从头回放, 检查点中已经处理的事件也重新处理
+	from := &Checkpoint{}
+	if err := projection.Rebuild(ctx, reset, replayFrom(history, 0, &from)); err != nil {
+		t.Fatalf("重建失败: %v", err)
+	}
+
+	if from != nil {
+		t.Errorf("重建收到的检查点 = %+v, 期望 nil", from)
+	}
+	for _, event := range history {
+		orderId := event.(*testEvent).OrderId
+		if applied[orderId] != 1 {
+			t.Errorf("事件(%s)处理 %d 次, 期望 1 次", orderId, applied[orderId])
+		}
+	}
+}
@@ -0,0 +1,8 @@
+module github.com/nf5lab/ebus/prometheus
+
+go 1.24.0
+
+require (
+	github.com/nf5lab/ebus v0.0.0-20261016011243-5686471bbfc5
+	github.com/prometheus/client_golang v1.22.0
+)
@@ -0,0 +1,190 @@
+// Package prometheus 使用 prometheus/client_golang 导出 ebus 指标
+//
+// Metrics 同时实现了 ebus.Metrics 和 prometheus.Collector 接口,
+// 注册到应用已有的 prometheus.Registerer 之后, 和应用的其它指标在同一个抓取地址中输出
+//
+// 独立的子模块, 只有使用 Prometheus 的应用才会引入 client_golang 的依赖
+//
+// 使用方法:
+//
+//	metrics := prometheus.New("")
+//	client.MustRegister(metrics) // client 为 github.com/prometheus/client_golang/prometheus
+//	bus := memory.New(ebus.WithMetrics(metrics))
+//	mux.Handle("/metrics", promhttp.Handler())
+package prometheus
+
+import (
+	"strings"
+	"time"
+
+	"github.com/nf5lab/ebus"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// DefaultNamespace 默认的指标命名空间
+	DefaultNamespace = "ebus"
+)
+
+var (
+	// DefaultDurationBuckets 默认的处理耗时分桶, 单位秒
+	DefaultDurationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+	// DefaultSizeBuckets 默认的消息体大小分桶, 单位字节
+	DefaultSizeBuckets = []float64{256, 1024, 4096, 16384, 65536, 262144, 1048576}
+)
+
+var (
+	// 确保实现了 ebus.Metrics 接口
+	_ ebus.Metrics = (*Metrics)(nil)
+
+	// 确保实现了 prometheus.Collector 接口
+	_ prometheus.Collector = (*Metrics)(nil)
+)
+
+var (
+	// eventLabels 所有指标都带有的标签
+	eventLabels = []string{"topic", "source", "type"}
+
+	// schemaLabels 模型版本指标的标签
+	schemaLabels = []string{"topic", "source", "type", "version"}
+)
+
+// Metrics Prometheus 指标收集器
+//
+// 所有指标都带有 topic / source / type 标签, 模型版本指标额外带有 version 标签
+type Metrics struct {
+	published       *prometheus.CounterVec
+	publishFailed   *prometheus.CounterVec
+	consumed        *prometheus.CounterVec
+	failed          *prometheus.CounterVec
+	decodeFailed    *prometheus.CounterVec
+	duplicate       *prometheus.CounterVec
+	schemaReceived  *prometheus.CounterVec
+	handlerDuration *prometheus.HistogramVec
+	payloadSize     *prometheus.HistogramVec
+	collectors      []prometheus.Collector
+}
+
+// New 创建指标收集器, 需要注册到 prometheus.Registerer 之后才会被抓取
+//
+// - namespace 指标命名空间, 为空时使用 DefaultNamespace
+func New(namespace string) *Metrics {
+	namespace = strings.TrimSpace(namespace)
+	if len(namespace) == 0 {
+		namespace = DefaultNamespace
+	}
+
+	counter := func(name string, help string, labels []string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      name,
+			Help:      help,
+		}, labels)
+	}
+
+	histogram := func(name string, help string, buckets []float64) *prometheus.HistogramVec {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      name,
+			Help:      help,
+			Buckets:   buckets,
+		}, eventLabels)
+	}
+
+	m := &Metrics{
+		published:       counter("events_published_total", "发布成功的事件总数", eventLabels),
+		publishFailed:   counter("events_publish_failed_total", "发布失败的事件总数", eventLabels),
+		consumed:        counter("events_consumed_total", "消费成功的事件总数", eventLabels),
+		failed:          counter("events_failed_total", "处理失败的事件总数", eventLabels),
+		decodeFailed:    counter("events_decode_failed_total", "解码失败的事件总数", eventLabels),
+		duplicate:       counter("events_duplicate_total", "重复投递的事件总数", eventLabels),
+		schemaReceived:  counter("events_schema_received_total", "按模型版本统计的收到的事件总数", schemaLabels),
+		handlerDuration: histogram("handler_duration_seconds", "事件处理耗时, 单位秒", DefaultDurationBuckets),
+		payloadSize:     histogram("payload_size_bytes", "事件消息体大小, 单位字节", DefaultSizeBuckets),
+	}
+
+	m.collectors = []prometheus.Collector{
+		m.published,
+		m.publishFailed,
+		m.consumed,
+		m.failed,
+		m.decodeFailed,
+		m.duplicate,
+		m.schemaReceived,
+		m.handlerDuration,
+		m.payloadSize,
+	}
+	return m
+}
+
+// Describe 实现 prometheus.Collector 接口
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range m.collectors {
+		collector.Describe(ch)
+	}
+}
+
+// Collect 实现 prometheus.Collector 接口
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range m.collectors {
+		collector.Collect(ch)
+	}
+}
+
+// IncPublished 事件发布成功
+func (m *Metrics) IncPublished(topic string, meta *ebus.Metadata) {
+	m.published.WithLabelValues(labelValues(topic, meta)...).Inc()
+}
+
+// IncPublishFailed 事件发布失败
+func (m *Metrics) IncPublishFailed(topic string, meta *ebus.Metadata) {
+	m.publishFailed.WithLabelValues(labelValues(topic, meta)...).Inc()
+}
+
+// IncConsumed 事件消费成功
+func (m *Metrics) IncConsumed(topic string, meta *ebus.Metadata) {
+	m.consumed.WithLabelValues(labelValues(topic, meta)...).Inc()
+}
+
+// IncFailed 事件处理失败
+func (m *Metrics) IncFailed(topic string, meta *ebus.Metadata) {
+	m.failed.WithLabelValues(labelValues(topic, meta)...).Inc()
+}
+
+// IncDecodeFailed 事件解码失败
+func (m *Metrics) IncDecodeFailed(topic string) {
+	m.decodeFailed.WithLabelValues(labelValues(topic, nil)...).Inc()
+}
+
+// IncDuplicate 收到重复投递的事件
+func (m *Metrics) IncDuplicate(topic string, meta *ebus.Metadata) {
+	m.duplicate.WithLabelValues(labelValues(topic, meta)...).Inc()
+}
+
+// IncSchemaReceived 收到事件, 带有 version 标签
+func (m *Metrics) IncSchemaReceived(topic string, meta *ebus.Metadata) {
+	version := ""
+	if meta != nil {
+		version = meta.SchemaVersion.String()
+	}
+	m.schemaReceived.WithLabelValues(append(labelValues(topic, meta), version)...).Inc()
+}
+
+// ObserveHandlerDuration 记录事件处理耗时
+func (m *Metrics) ObserveHandlerDuration(topic string, meta *ebus.Metadata, duration time.Duration) {
+	m.handlerDuration.WithLabelValues(labelValues(topic, meta)...).Observe(duration.Seconds())
+}
+
+// ObservePayloadSize 记录事件消息体大小
+func (m *Metrics) ObservePayloadSize(topic string, meta *ebus.Metadata, size int) {
+	m.payloadSize.WithLabelValues(labelValues(topic, meta)...).Observe(float64(size))
+}
+
+// labelValues 按照 eventLabels 的顺序返回标签值
+func labelValues(topic string, meta *ebus.Metadata) []string {
+	if meta == nil {
+		return []string{topic, "", ""}
+	}
+	return []string{topic, meta.EventSource.String(), meta.EventType.String()}
+}
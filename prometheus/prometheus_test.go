@@ -0,0 +1,45 @@
+package prometheus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nf5lab/ebus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	metrics := New("")
+	meta := ebus.NewMetadata("ebus.test", "order.created", "v1")
+
+	metrics.IncPublished("orders", meta)
+	metrics.IncPublished("orders", meta)
+	metrics.IncDecodeFailed("orders")
+	metrics.IncSchemaReceived("orders", meta)
+	metrics.ObserveHandlerDuration("orders", meta, 20*time.Millisecond)
+
+	// 严格模式的注册表会校验 Describe 和 Collect 输出的指标是否一致
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(metrics); err != nil {
+		t.Fatalf("注册指标收集器失败: %v", err)
+	}
+
+	if got := testutil.ToFloat64(metrics.published.WithLabelValues("orders", "ebus.test", "order.created")); got != 2 {
+		t.Errorf("发布成功的事件总数 = %v, 期望 2", got)
+	}
+
+	expected := `
+# HELP ebus_events_schema_received_total 按模型版本统计的收到的事件总数
+# TYPE ebus_events_schema_received_total counter
+ebus_events_schema_received_total{source="ebus.test",topic="orders",type="order.created",version="v1"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "ebus_events_schema_received_total"); err != nil {
+		t.Errorf("模型版本指标不一致: %v", err)
+	}
+
+	if count := testutil.CollectAndCount(metrics, "ebus_handler_duration_seconds"); count != 1 {
+		t.Errorf("处理耗时的序列数量 = %d, 期望 1", count)
+	}
+}
@@ -2,9 +2,12 @@ package ebus
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
-	"strings"
+	"log/slog"
+	"strconv"
+	"sync"
 
 	"github.com/nf5lab/broker"
 )
@@ -15,6 +18,45 @@ type Publisher interface {
 	// Publish 发布事件
 	Publish(ctx context.Context, topic string, event Event) error
 
+	// PublishPacked 把多个事件打包成一条容器消息发布
+	//
+	// 订阅者会拆包并逐个分发事件
+	// 如果其中一个事件处理失败, 整条消息会被重新投递 (已处理的事件也会再次处理)
+	PublishPacked(ctx context.Context, topic string, events []Event) error
+
+	// PublishBatch 批量发布事件到同一个主题
+	//
+	// 每个事件编码为一条独立的消息, 消息队列支持批量发布时 (参考 BatchPublisher) 一次发送所有消息,
+	// 用于导入和回填等大量发布事件的场景; 任何一个事件编码失败时不会发送任何消息
+	// 所有消息在发送之前都保存在内存中, 大量事件应该由调用者分成合适大小的批次
+	PublishBatch(ctx context.Context, topic string, events []Event) error
+
+	// PublishBatchTopics 批量发布事件到多个主题
+	//
+	// 事件按照主题分组, 每个主题调用一次批量发布, 同一个主题的事件保持原来的顺序
+	// 某个主题发布失败时停止, 之前主题的事件已经发布
+	PublishBatchTopics(ctx context.Context, events []TopicEvent) error
+
+	// PublishAsync 异步发布事件, 不等待消息队列的确认
+	//
+	// 事件在调用者的协程中编码之后放入有界队列, 由后台协程发送 (参考 WithAsyncPublish)
+	// 返回的通道只接收一个发布结果然后关闭, 调用者可以忽略; 队列已满时结果为 ErrPublishQueueFull
+	// 发送不会因为 ctx 的取消而中止, 上下文中的值 (追踪, 租户等) 仍然有效
+	// 发布配额和速率限制在发送协程中生效, RateLimitBlock 模式不会阻塞调用者, 等待时间计入发送超时
+	PublishAsync(ctx context.Context, topic string, event Event) <-chan error
+
+	// Flush 等待异步发布队列中的事件全部发送完成
+	//
+	// 用于停机之前排空队列, 避免已经放入队列的事件丢失; 调用者应该先停止调用 PublishAsync
+	// ctx 被取消时返回上下文的错误, 队列中剩余的事件仍然会继续发送
+	Flush(ctx context.Context) error
+
+	// PublishTx 在调用者的数据库事务中暂存事件, 与业务状态的变更一起提交或者回滚
+	//
+	// 事件经过完整的编码流程, 编码之后的消息写入发件箱 (参考 WithOutbox), 事务提交之后由中继发布
+	// 没有配置发件箱时返回 ErrOutboxNotConfigured
+	PublishTx(ctx context.Context, tx *sql.Tx, topic string, event Event) error
+
 	// Close 关闭发布者 (不会执行任何操作)
 	//
 	// Deprecated: 此方法已废弃, 将在未来版本中移除
@@ -23,72 +65,383 @@ type Publisher interface {
 }
 
 type publisher struct {
-	inner broker.Publisher
+	inner             broker.Publisher
+	options           *Options
+	limiter           *rateLimiter // 发布速率限制器 (参考 WithPublishRateLimit)
+	async             *asyncQueue  // 异步发布队列 (参考 PublishAsync)
+	registeredSchemas sync.Map     // 已经在模式注册中心注册的模型版本 (参考 WithSchemaRegistry)
 }
 
 // NewPublisher 创建发布者
-func NewPublisher(brokerPublisher broker.Publisher) Publisher {
-	return &publisher{
-		inner: brokerPublisher,
+func NewPublisher(brokerPublisher broker.Publisher, opts ...Option) Publisher {
+	options := NewOptions(opts...)
+	pub := &publisher{
+		inner:   brokerPublisher,
+		options: options,
+		limiter: newRateLimiter(options.PublishRateLimit),
 	}
+	pub.async = newAsyncQueue(pub, options.AsyncPublish)
+	return pub
 }
 
-// Publish 发布事件
-func (pub *publisher) Publish(ctx context.Context, topic string, event Event) error {
-	topic = strings.TrimSpace(topic)
-	if len(topic) == 0 {
-		return fmt.Errorf("ebus: 主题不能为空")
-	}
-
+// encodeEnvelope 校验事件并构建事件信封
+//
+// 元数据中缺少的关联信息从上下文中补充
+// 负载编码到 buffer 中, 事件信封只在 buffer 释放之前有效
+func (pub *publisher) encodeEnvelope(ctx context.Context, event Event, buffer *encodeBuffer) (*Envelope, error) {
 	if event == nil {
-		return fmt.Errorf("ebus: 事件不能为空")
+		return nil, fmt.Errorf("ebus: 事件不能为空")
 	}
 
 	if err := event.Validate(); err != nil {
-		return fmt.Errorf("ebus: 事件无效: %w", err)
+		return nil, fmt.Errorf("ebus: 事件无效: %w", err)
 	}
 
 	metadata := event.Metadata()
 	if metadata == nil {
-		return fmt.Errorf("ebus: 事件元数据不能为空")
+		return nil, fmt.Errorf("ebus: 事件元数据不能为空")
+	}
+
+	applyContextMetadata(ctx, metadata, pub.options)
+
+	if err := pub.options.validateMetadata(metadata); err != nil {
+		return nil, fmt.Errorf("ebus: 事件(%s)元数据无效: %w", metadata.EventId, err)
 	}
 
-	if err := metadata.Validate(); err != nil {
-		return fmt.Errorf("ebus: 事件(%s)元数据无效: %w", metadata.EventId, err)
+	if err := pub.checkSchemaRegistered(ctx, metadata); err != nil {
+		return nil, err
 	}
 
-	payload, err := json.Marshal(event)
+	var (
+		payload []byte
+		err     error
+	)
+	// 默认的 JSON 编解码器使用池化的缓冲区
+	if _, ok := pub.options.Codec.(JSONCodec); ok {
+		payload, err = buffer.encode(event)
+	} else {
+		payload, err = pub.options.Codec.Marshal(event)
+	}
 	if err != nil {
-		return fmt.Errorf("ebus: 事件(%s)编码失败: %w", metadata.EventId, err)
+		return nil, fmt.Errorf("ebus: 事件(%s)编码失败: %w", metadata.EventId, err)
+	}
+
+	if err := pub.options.Registry.validatePayloadSchema(metadata, pub.options.Codec, payload); err != nil {
+		return nil, fmt.Errorf("ebus: 事件(%s)无效: %w", metadata.EventId, err)
 	}
 
-	// 构建信封
-	envelope := &Envelope{
+	// 消息体只包含事件负载时没有事件信封, 不需要兼容旧版本的订阅者
+	version := EnvelopeVersion
+	if pub.options.LegacyPayloadEncoding && isJSONCodec(pub.options.Codec) && !isPayloadOnlyCodec(pub.options.Codec) {
+		// 旧版本的订阅者只能解码 base64 负载
+		if payload, err = json.Marshal(payload); err != nil {
+			return nil, fmt.Errorf("ebus: 事件(%s)编码失败: %w", metadata.EventId, err)
+		}
+		version = EnvelopeVersion1
+	}
+
+	return &Envelope{
+		Version:  version,
 		Metadata: metadata,
 		Payload:  payload,
+	}, nil
+}
+
+// Publish 发布事件
+func (pub *publisher) Publish(ctx context.Context, topic string, event Event) error {
+	topic, err := pub.options.resolveTopic(topic)
+	if err != nil {
+		return err
+	}
+
+	lineage, err := nextLineage(ctx, pub.options)
+	if err != nil {
+		return err
+	}
+
+	if err := pub.publishEvent(ctx, topic, lineage, event, ""); err != nil {
+		return err
+	}
+
+	legacyEvent, exists, err := pub.options.downcastForDualVersion(event)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return pub.publishEvent(ctx, topic, lineage, legacyEvent, event.Metadata().SchemaVersion)
+	}
+
+	return nil
+}
+
+// acquire 按照发布速率限制获取令牌
+//
+// 返回 false 时, 如果错误为 nil 表示消息被丢弃
+func (pub *publisher) acquire(ctx context.Context, topic string, metadata *Metadata) (bool, error) {
+	// 暂存到发件箱的消息不经过消息队列
+	if _, ok := stagingTx(ctx); ok {
+		return true, nil
+	}
+
+	allowed, err := pub.limiter.acquire(ctx, topic)
+	if !allowed && err == nil {
+		pub.options.Logger.WarnContext(ctx, "ebus: 超过发布速率限制, 事件被丢弃",
+			slog.String("topic", topic),
+			slog.String("eventId", metadata.EventId),
+		)
+	}
+	return allowed, err
+}
+
+// admit 在发送之前检查发布配额并获取速率限制的令牌
+//
+// 返回 false 时, 如果错误为 nil 表示消息被丢弃
+func (pub *publisher) admit(ctx context.Context, topic string, metadata *Metadata) (bool, error) {
+	if err := pub.options.checkQuota(metadata); err != nil {
+		return false, err
+	}
+
+	return pub.acquire(ctx, topic, metadata)
+}
+
+// outgoingMessage 编码之后等待发送的消息
+type outgoingMessage struct {
+	message     *broker.Message
+	metadata    *Metadata
+	payloadSize int // 压缩和加密之前的消息体大小
+}
+
+// publishEvent 编码并发布单个事件
+// - legacyOf 双版本发布的旧版本事件对应的新模型版本, 其他事件为空
+func (pub *publisher) publishEvent(ctx context.Context, topic string, lineage Lineage, event Event, legacyOf SchemaVersion) error {
+	buffer := acquireEncodeBuffer()
+	defer buffer.release()
+
+	outgoing, err := pub.encodeMessage(ctx, topic, lineage, event, legacyOf, buffer)
+	if err != nil {
+		return err
+	}
+
+	if allowed, err := pub.admit(ctx, topic, outgoing.metadata); !allowed {
+		return err
 	}
 
-	data, err := json.Marshal(envelope)
+	return pub.sendOutgoing(ctx, topic, outgoing)
+}
+
+// encodeMessage 编码单个事件为消息
+//
+// 编码不消耗发布配额和速率限制的令牌, 由调用者在发送之前调用 admit
+// 消息体可能引用 buffer, 消息只在 buffer 释放之前有效
+// - legacyOf 双版本发布的旧版本事件对应的新模型版本, 写入 HeaderLegacyCopyOf 消息头, 其他事件为空
+func (pub *publisher) encodeMessage(ctx context.Context, topic string, lineage Lineage, event Event, legacyOf SchemaVersion, buffer *encodeBuffer) (*outgoingMessage, error) {
+	envelope, err := pub.encodeEnvelope(ctx, event, buffer)
 	if err != nil {
-		return fmt.Errorf("ebus: 事件信封(%s)编码失败: %w", metadata.EventId, err)
+		return nil, err
+	}
+
+	metadata := envelope.Metadata
+
+	if err := pub.options.authorizePublish(ctx, topic, metadata); err != nil {
+		return nil, err
+	}
+
+	codec := pub.options.Codec
+	payloadOnly := isPayloadOnlyCodec(codec)
+
+	var data []byte
+	if payloadOnly {
+		// 只有 JSONCodec 使用池化的缓冲区, 负载可以直接作为消息体
+		data = envelope.Payload
+	} else if data, err = codec.Marshal(envelope); err != nil {
+		return nil, fmt.Errorf("ebus: 事件信封(%s)编码失败: %w", metadata.EventId, err)
 	}
 
 	// 创建消息
 	message := &broker.Message{
-		Id:          metadata.EventId,
-		Headers:     make(map[string]any),
-		Body:        data,
-		ContentType: ContentTypeJson,
+		Id:           metadata.EventId,
+		Headers:      make(map[string]any),
+		Body:         data,
+		ContentType:  codec.ContentType(),
+		PartitionKey: metadata.PartitionKey,
 	}
 
 	// 设置消息头
 	for key, value := range metadataToHeaders(metadata) {
 		message.AddHeader(key, value)
 	}
+	// 消息体只包含事件负载时, 扩展属性只能保存在消息头中
+	if codec, ok := codec.(headerMetadataCodec); ok {
+		headers, err := codec.metadataToHeaders(metadata)
+		if err != nil {
+			return nil, fmt.Errorf("ebus: 事件(%s)元数据编码失败: %w", metadata.EventId, err)
+		}
+		for key, value := range headers {
+			message.AddHeader(key, value)
+		}
+	} else if pub.options.ExtensionHeaders || payloadOnly {
+		for key, value := range extensionsToHeaders(metadata) {
+			message.AddHeader(key, value)
+		}
+	}
+	if !payloadOnly {
+		message.AddHeader(HeaderEnvelopeVersion, strconv.Itoa(envelope.Version))
+	}
+	if !legacyOf.IsEmpty() {
+		message.AddHeader(HeaderLegacyCopyOf, legacyOf.Normalize().String())
+	}
+	addLineageHeaders(message, lineage)
+	addTraceParentHeader(ctx, message)
+	message.AddHeader(HeaderPublishTime, strconv.FormatInt(pub.options.clock().Now().UnixMilli(), 10))
+
+	pub.options.addChecksum(message)
+	if err := pub.options.signMessage(ctx, message); err != nil {
+		return nil, err
+	}
+	if err := pub.options.compressMessage(message); err != nil {
+		return nil, err
+	}
+	if err := pub.options.encryptMessage(ctx, message); err != nil {
+		return nil, err
+	}
+
+	return &outgoingMessage{
+		message:     message,
+		metadata:    metadata,
+		payloadSize: len(data),
+	}, nil
+}
+
+// sendOutgoing 发送编码之后的消息, 并且记录指标和日志
+func (pub *publisher) sendOutgoing(ctx context.Context, topic string, outgoing *outgoingMessage) error {
+	if err := pub.send(ctx, topic, outgoing.message); err != nil {
+		pub.publishFailed(ctx, topic, outgoing.metadata, err)
+		return fmt.Errorf("ebus: 事件(%s)发布失败: %w", outgoing.metadata.EventId, err)
+	}
+
+	pub.published(topic, outgoing)
+	return nil
+}
+
+// published 记录发布成功的指标
+func (pub *publisher) published(topic string, outgoing *outgoingMessage) {
+	metrics := pub.options.Metrics
+	metrics.IncPublished(topic, outgoing.metadata)
+	metrics.ObservePayloadSize(topic, outgoing.metadata, outgoing.payloadSize)
+}
+
+// publishFailed 记录发布失败的指标和日志
+func (pub *publisher) publishFailed(ctx context.Context, topic string, metadata *Metadata, err error) {
+	pub.options.Metrics.IncPublishFailed(topic, metadata)
+	pub.options.Logger.ErrorContext(ctx, "ebus: 事件发布失败",
+		append(metadataLogAttrs(metadata), slog.String("topic", topic), slog.Any("error", err))...,
+	)
+}
+
+// PublishPacked 把多个事件打包成一条容器消息发布
+func (pub *publisher) PublishPacked(ctx context.Context, topic string, events []Event) error {
+	topic, err := pub.options.resolveTopic(topic)
+	if err != nil {
+		return err
+	}
+
+	lineage, err := nextLineage(ctx, pub.options)
+	if err != nil {
+		return err
+	}
+
+	if len(events) == 0 {
+		return fmt.Errorf("ebus: 事件列表不能为空")
+	}
+
+	if !isJSONCodec(pub.options.Codec) {
+		return fmt.Errorf("ebus: 打包发布只支持 JSON 编解码器")
+	}
+
+	container := &Container{
+		Envelopes: make([]*Envelope, 0, len(events)),
+	}
+
+	for _, event := range events {
+		// 负载在编码容器之前必须有效, 所以缓冲区在函数返回时才释放
+		buffer := acquireEncodeBuffer()
+		defer buffer.release()
+
+		envelope, err := pub.encodeEnvelope(ctx, event, buffer)
+		if err != nil {
+			return err
+		}
+
+		if err := pub.options.authorizePublish(ctx, topic, envelope.Metadata); err != nil {
+			return err
+		}
+
+		container.Envelopes = append(container.Envelopes, envelope)
+	}
+
+	// 所有事件编码成功之后才消耗发布配额
+	for _, envelope := range container.Envelopes {
+		if err := pub.options.checkQuota(envelope.Metadata); err != nil {
+			return err
+		}
+	}
+
+	// 容器消息使用第一个事件的ID作为消息ID, 第一个事件的分区键作为消息分区键
+	firstMetadata := container.Envelopes[0].Metadata
+
+	// 速率限制按照消息计算, 一条容器消息只需要一个令牌
+	if allowed, err := pub.acquire(ctx, topic, firstMetadata); !allowed {
+		return err
+	}
+
+	data, err := json.Marshal(container)
+	if err != nil {
+		return fmt.Errorf("ebus: 事件容器(%s)编码失败: %w", firstMetadata.EventId, err)
+	}
+
+	message := &broker.Message{
+		Id:           firstMetadata.EventId,
+		Headers:      make(map[string]any),
+		Body:         data,
+		ContentType:  ContentTypeContainerJson,
+		PartitionKey: firstMetadata.PartitionKey,
+	}
+	message.AddHeader(HeaderEventCount, strconv.Itoa(len(container.Envelopes)))
+	message.AddHeader(HeaderEnvelopeVersion, strconv.Itoa(container.Envelopes[0].Version))
+	addLineageHeaders(message, lineage)
+	addTraceParentHeader(ctx, message)
+	message.AddHeader(HeaderPublishTime, strconv.FormatInt(pub.options.clock().Now().UnixMilli(), 10))
+
+	pub.options.addChecksum(message)
+	if err := pub.options.signMessage(ctx, message); err != nil {
+		return err
+	}
+	if err := pub.options.compressMessage(message); err != nil {
+		return err
+	}
+	if err := pub.options.encryptMessage(ctx, message); err != nil {
+		return err
+	}
+
+	metrics := pub.options.Metrics
+
+	if err := pub.send(ctx, topic, message); err != nil {
+		for _, envelope := range container.Envelopes {
+			metrics.IncPublishFailed(topic, envelope.Metadata)
+		}
+		pub.options.Logger.ErrorContext(ctx, "ebus: 事件容器发布失败",
+			slog.String("topic", topic),
+			slog.String("messageId", message.Id),
+			slog.Int("eventCount", len(container.Envelopes)),
+			slog.Any("error", err),
+		)
+		return fmt.Errorf("ebus: 事件容器(%s)发布失败: %w", firstMetadata.EventId, err)
+	}
 
-	// 发布消息
-	if err := pub.inner.Publish(ctx, topic, message); err != nil {
-		return fmt.Errorf("ebus: 事件(%s)发布失败: %w", metadata.EventId, err)
+	for _, envelope := range container.Envelopes {
+		metrics.IncPublished(topic, envelope.Metadata)
+		metrics.ObservePayloadSize(topic, envelope.Metadata, len(envelope.Payload))
 	}
 
 	return nil
@@ -0,0 +1,85 @@
+package ebus
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// 确保实现了 QuotaLimiter 接口
+	_ QuotaLimiter = (*SourceQuota)(nil)
+)
+
+var (
+	// ErrQuotaExceeded 事件来源超过了发布配额
+	ErrQuotaExceeded = errors.New("ebus: 超过发布配额")
+)
+
+// QuotaExceededError 事件来源超过了发布配额的错误
+//
+// 支持 errors.Is(err, ErrQuotaExceeded)
+type QuotaExceededError struct {
+	EventSource EventSource // 超过配额的事件来源
+	EventId     string      // 被拒绝的事件ID
+}
+
+func (err *QuotaExceededError) Error() string {
+	return fmt.Sprintf("ebus: 事件来源(%s)超过发布配额, 事件(%s)被拒绝", err.EventSource, err.EventId)
+}
+
+// Unwrap 支持 errors.Is(err, ErrQuotaExceeded)
+func (err *QuotaExceededError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// QuotaLimiter 发布配额的限制器
+//
+// 可以替换为分布式的实现 (例如基于 Redis), 在多个实例之间共享配额
+type QuotaLimiter interface {
+
+	// Allow 是否允许事件来源再发布一个事件
+	Allow(evtSource EventSource) bool
+}
+
+// SourceQuota 按照事件来源限制每秒发布的事件数量, 每个事件来源一个令牌桶
+type SourceQuota struct {
+	rate    float64
+	burst   int
+	buckets sync.Map // EventSource -> *tokenBucket
+}
+
+// NewSourceQuota 创建按照事件来源的发布配额
+// - perSecond 每个事件来源每秒允许发布的事件数量
+// - burst     允许的突发数量, 小于 1 时为 1
+func NewSourceQuota(perSecond float64, burst int) *SourceQuota {
+	return &SourceQuota{
+		rate:  perSecond,
+		burst: burst,
+	}
+}
+
+// Allow 是否允许事件来源再发布一个事件
+//
+// 令牌按照真实经过的时间补充, 所以与发布速率限制一样使用系统时间, 而不是可以替换的默认时钟
+func (quota *SourceQuota) Allow(evtSource EventSource) bool {
+	now := time.Now()
+
+	bucket, exists := quota.buckets.Load(evtSource)
+	if !exists {
+		bucket, _ = quota.buckets.LoadOrStore(evtSource, newTokenBucket(quota.rate, quota.burst, now))
+	}
+
+	return bucket.(*tokenBucket).allow(now)
+}
+
+// checkQuota 按照选项检查事件来源的发布配额
+func (opts *Options) checkQuota(metadata *Metadata) error {
+	if opts.Quota == nil || opts.Quota.Allow(metadata.EventSource) {
+		return nil
+	}
+
+	opts.Logger.Warn("ebus: 事件来源超过发布配额", metadataLogAttrs(metadata)...)
+	return &QuotaExceededError{EventSource: metadata.EventSource, EventId: metadata.EventId}
+}
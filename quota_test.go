@@ -0,0 +1,24 @@
+package ebus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSourceQuotaIgnoresDefaultClock(t *testing.T) {
+	// 固定的默认时钟不能让令牌桶停止补充
+	SetDefaultClock(FixedClock(time.Unix(1000, 0)))
+	defer SetDefaultClock(nil)
+
+	quota := NewSourceQuota(1000, 1)
+	if !quota.Allow(testSource) {
+		t.Fatal("第一个事件被拒绝, 期望使用突发配额")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for !quota.Allow(testSource) {
+		if time.Now().After(deadline) {
+			t.Fatal("令牌没有补充, 事件来源被永久拒绝")
+		}
+	}
+}
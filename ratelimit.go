@@ -0,0 +1,208 @@
+package ebus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// tokenBucket 令牌桶
+//
+// 令牌以固定的速率补充, 最多累积 burst 个, 用于发布配额和限流
+type tokenBucket struct {
+	rate   float64   // 每秒补充的令牌数量
+	burst  float64   // 令牌桶的容量
+	tokens float64   // 当前的令牌数量, 预留之后可能为负数
+	last   time.Time // 上次补充令牌的时间
+	lock   sync.Mutex
+}
+
+// newTokenBucket 创建令牌桶, 初始时令牌桶是满的
+// - rate  每秒补充的令牌数量
+// - burst 令牌桶的容量, 小于 1 时为 1
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+// refill 按照经过的时间补充令牌, 调用者必须持有锁
+func (bucket *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(bucket.last); elapsed > 0 {
+		bucket.tokens = min(bucket.burst, bucket.tokens+elapsed.Seconds()*bucket.rate)
+		bucket.last = now
+	}
+}
+
+// allow 有可用的令牌时取走一个令牌, 返回是否成功
+func (bucket *tokenBucket) allow(now time.Time) bool {
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	bucket.refill(now)
+	if bucket.tokens < 1 {
+		return false
+	}
+
+	bucket.tokens--
+	return true
+}
+
+// reserve 预留一个令牌, 返回令牌可用之前需要等待的时间
+//
+// 预留之后令牌数量可能为负数, 后续的调用者排在后面等待
+func (bucket *tokenBucket) reserve(now time.Time) time.Duration {
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	bucket.refill(now)
+	bucket.tokens--
+	if bucket.tokens >= 0 || bucket.rate <= 0 {
+		return 0
+	}
+
+	return time.Duration(-bucket.tokens / bucket.rate * float64(time.Second))
+}
+
+// wait 等待一个令牌可用, 直到上下文被取消
+func (bucket *tokenBucket) wait(ctx context.Context, now time.Time) error {
+	delay := bucket.reserve(now)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		bucket.cancel()
+		return ctx.Err()
+	}
+}
+
+// cancel 归还预留的令牌, 用于等待被取消的情况
+func (bucket *tokenBucket) cancel() {
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	bucket.tokens = min(bucket.burst, bucket.tokens+1)
+}
+
+var (
+	// ErrRateLimited 超过了发布速率限制 (参考 RateLimitError)
+	ErrRateLimited = errors.New("ebus: 超过发布速率限制")
+)
+
+// RateLimitMode 超过速率限制时的处理方式
+type RateLimitMode int
+
+const (
+	// RateLimitBlock 等待令牌可用, 直到上下文被取消 (默认)
+	RateLimitBlock RateLimitMode = iota
+
+	// RateLimitDrop 丢弃事件, 发布返回 nil
+	RateLimitDrop
+
+	// RateLimitError 发布失败, 返回 ErrRateLimited
+	RateLimitError
+)
+
+// String 返回处理方式的名称
+func (mode RateLimitMode) String() string {
+	switch mode {
+	case RateLimitBlock:
+		return "block"
+	case RateLimitDrop:
+		return "drop"
+	case RateLimitError:
+		return "error"
+	default:
+		return fmt.Sprintf("RateLimitMode(%d)", int(mode))
+	}
+}
+
+// RateLimit 发布速率限制
+type RateLimit struct {
+	Rate     float64       // 每秒允许发布的消息数量
+	Burst    int           // 允许的突发数量, 小于 1 时为 1
+	PerTopic bool          // 是否按照主题分别限制, 否则所有主题共享一个令牌桶
+	Mode     RateLimitMode // 超过速率限制时的处理方式
+}
+
+// rateLimiter 发布速率限制器
+type rateLimiter struct {
+	limit   RateLimit
+	global  *tokenBucket
+	buckets sync.Map // 主题 -> *tokenBucket
+}
+
+// newRateLimiter 创建发布速率限制器, 没有限制时返回 nil
+//
+// 限制器会真实地等待, 所以总是使用系统时间而不是选项中的时钟
+func newRateLimiter(limit *RateLimit) *rateLimiter {
+	if limit == nil || limit.Rate <= 0 {
+		return nil
+	}
+
+	limiter := &rateLimiter{
+		limit: *limit,
+	}
+	if !limit.PerTopic {
+		limiter.global = newTokenBucket(limit.Rate, limit.Burst, time.Now())
+	}
+	return limiter
+}
+
+// bucket 获取主题对应的令牌桶
+func (limiter *rateLimiter) bucket(topic string, now time.Time) *tokenBucket {
+	if limiter.global != nil {
+		return limiter.global
+	}
+
+	bucket, exists := limiter.buckets.Load(topic)
+	if !exists {
+		bucket, _ = limiter.buckets.LoadOrStore(topic, newTokenBucket(limiter.limit.Rate, limiter.limit.Burst, now))
+	}
+	return bucket.(*tokenBucket)
+}
+
+// acquire 获取发布一条消息的令牌
+//
+// 返回 false 表示消息应该被丢弃
+func (limiter *rateLimiter) acquire(ctx context.Context, topic string) (bool, error) {
+	if limiter == nil {
+		return true, nil
+	}
+
+	now := time.Now()
+	bucket := limiter.bucket(topic, now)
+
+	switch limiter.limit.Mode {
+	case RateLimitDrop:
+		return bucket.allow(now), nil
+
+	case RateLimitError:
+		if !bucket.allow(now) {
+			return false, fmt.Errorf("%w: 主题(%s)", ErrRateLimited, topic)
+		}
+		return true, nil
+
+	default:
+		if err := bucket.wait(ctx, now); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+}
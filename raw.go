@@ -0,0 +1,64 @@
+package ebus
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/nf5lab/broker"
+)
+
+// RawEventHandler 原始模式的事件处理函数
+//
+// - env 事件信封, 负载没有解码
+// - raw 事件信封的原始数据
+type RawEventHandler func(ctx context.Context, topic string, env *Envelope, raw []byte) error
+
+// decodeRawEnvelope 解码事件信封, 不查找事件工厂, 也不解码负载
+// - codec   消息内容类型对应的编解码器
+// - headers 消息头, 编解码器的消息体只包含事件负载时从中读取元数据
+func decodeRawEnvelope(codec Codec, headers map[string]any, data []byte) (*Envelope, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyBody
+	}
+
+	envelope, err := decodeEnvelopeWith(codec, headers, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if envelope.Metadata == nil {
+		return nil, fmt.Errorf("%w: 事件信封元数据为空", ErrMetadataInvalid)
+	}
+
+	envelope.Metadata.Normalize()
+	return envelope, nil
+}
+
+// routeRaw 把事件信封交给原始模式的处理函数, 不解码负载
+func (sub *subscriber) routeRaw(ctx context.Context, topic string, delivery *broker.Delivery, codec Codec, handler RawEventHandler, raw []byte) error {
+	envelope, err := decodeRawEnvelope(codec, delivery.Message.Headers, raw)
+	if err != nil {
+		sub.onDecodeFailed(ctx, topic, delivery, err)
+		return err
+	}
+
+	metadata := envelope.Metadata
+	ctx = contextWithConsumedEvent(ctx, delivery, raw, metadata)
+
+	if err := callRawHandler(ctx, handler, topic, envelope, raw); err != nil {
+		sub.options.Logger.WarnContext(ctx, "ebus: 事件处理失败",
+			append(metadataLogAttrs(metadata), slog.String("topic", topic), slog.Any("error", err))...,
+		)
+		return fmt.Errorf("ebus: 事件(%s)处理失败: %w", metadata.EventId, err)
+	}
+
+	return nil
+}
+
+// callRawHandler 调用原始模式的处理函数, 并将 panic 转换为错误
+func callRawHandler(ctx context.Context, handler RawEventHandler, topic string, envelope *Envelope, raw []byte) error {
+	return callHandler(ctx, func(ctx context.Context, topic string, _ Event) error {
+		return handler(ctx, topic, envelope, raw)
+	}, topic, nil)
+}
@@ -0,0 +1,40 @@
+package ebus
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrRebalanceNotSupported = errors.New("ebus: 消息队列不支持再均衡通知")
+)
+
+// RebalanceEvent 再均衡通知
+//
+// 当订阅组内的分区分配发生变化时产生
+type RebalanceEvent struct {
+	SubscriptionId string  // 订阅ID
+	Topic          string  // 订阅主题
+	Group          string  // 订阅组
+	Assigned       []int32 // 新分配给当前订阅者的分区
+	Revoked        []int32 // 从当前订阅者收回的分区
+}
+
+// RebalanceHandler 再均衡回调
+//
+// 维护分区级状态 (缓存, 去重窗口等) 的处理函数可以在这里重置状态
+type RebalanceHandler func(ctx context.Context, event RebalanceEvent)
+
+// RebalanceNotifier 再均衡通知接口
+//
+// 支持分区分配通知的 broker.Subscriber 可以实现此接口
+// 例如 Kafka 的消费组再均衡
+type RebalanceNotifier interface {
+
+	// NotifyRebalance 注册再均衡回调
+	//
+	// 参数:
+	// - subscriptionId: 订阅ID
+	// - fn:             分区分配变化时的回调
+	NotifyRebalance(subscriptionId string, fn func(ctx context.Context, assigned []int32, revoked []int32)) error
+}
@@ -0,0 +1,185 @@
+package ebus
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nf5lab/broker"
+)
+
+// Record 消费记录
+//
+// 保存一次投递的原始消息和处理结果, 用于在本地重现处理过程
+type Record struct {
+	Topic       string         `json:"topic"`           // 消息主题
+	MessageId   string         `json:"messageId"`       // 消息ID
+	ContentType string         `json:"contentType"`     // 消息内容类型
+	Headers     map[string]any `json:"headers"`         // 消息头
+	Body        []byte         `json:"body"`            // 原始消息体 (事件信封)
+	Attempts    int            `json:"attempts"`        // 投递的尝试次数
+	ReceiveTime time.Time      `json:"receiveTime"`     // 接收时间
+	Duration    time.Duration  `json:"duration"`        // 处理耗时
+	Error       string         `json:"error,omitempty"` // 处理错误, 为空表示处理成功
+}
+
+// Recorder 消费记录器
+//
+// 仅用于开发和排查问题, 记录器会把原始消息体写入本地文件, 不要在处理敏感数据的生产环境中长期开启
+// 记录以 JSON Lines 格式追加到文件中
+type Recorder struct {
+	lock    sync.Mutex
+	file    *os.File
+	writer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+// NewRecorder 创建消费记录器
+// - path 记录文件路径, 文件不存在时自动创建, 存在时追加
+func NewRecorder(path string) (*Recorder, error) {
+	path = strings.TrimSpace(path)
+	if len(path) == 0 {
+		return nil, fmt.Errorf("ebus: 记录文件路径不能为空")
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("ebus: 打开记录文件失败: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	return &Recorder{
+		file:    file,
+		writer:  writer,
+		encoder: json.NewEncoder(writer),
+	}, nil
+}
+
+// Record 写入一条消费记录
+func (rec *Recorder) Record(record *Record) error {
+	if record == nil {
+		return nil
+	}
+
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+
+	if rec.file == nil {
+		return fmt.Errorf("ebus: 记录器已关闭")
+	}
+
+	if err := rec.encoder.Encode(record); err != nil {
+		return fmt.Errorf("ebus: 写入消费记录失败: %w", err)
+	}
+
+	if err := rec.writer.Flush(); err != nil {
+		return fmt.Errorf("ebus: 写入消费记录失败: %w", err)
+	}
+
+	return nil
+}
+
+// Close 关闭记录器
+func (rec *Recorder) Close() error {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+
+	if rec.file == nil {
+		return nil
+	}
+
+	flushErr := rec.writer.Flush()
+	closeErr := rec.file.Close()
+	rec.file = nil
+
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
+
+// record 记录一次投递
+func (rec *Recorder) record(delivery *broker.Delivery, duration time.Duration, err error) error {
+	record := &Record{
+		Topic:       delivery.Topic,
+		MessageId:   delivery.Message.Id,
+		ContentType: delivery.Message.ContentType,
+		Headers:     maps.Clone(delivery.Message.Headers),
+		Body:        slices.Clone(delivery.Message.Body),
+		Attempts:    delivery.Attempts,
+		ReceiveTime: delivery.ReceiveTime,
+		Duration:    duration,
+	}
+
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	return rec.Record(record)
+}
+
+// LoadRecords 从文件加载消费记录
+func LoadRecords(path string) ([]*Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("ebus: 打开记录文件失败: %w", err)
+	}
+	defer file.Close()
+
+	var records []*Record
+
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		var record Record
+		if err := decoder.Decode(&record); err != nil {
+			return nil, fmt.Errorf("ebus: 解析第%d条消费记录失败: %w", len(records)+1, err)
+		}
+		records = append(records, &record)
+	}
+
+	return records, nil
+}
+
+// ReplayRecord 使用记录的原始消息重新调用处理函数
+//
+// 消息经过与订阅时相同的解码和分发流程, 便于在调试器中重现线上的处理问题
+// - record  消费记录
+// - handler 事件处理函数
+// - opts    选项, 应与订阅时一致 (例如中间件)
+func ReplayRecord(ctx context.Context, record *Record, handler EventHandler, opts ...Option) error {
+	if record == nil {
+		return fmt.Errorf("ebus: 消费记录不能为空")
+	}
+
+	if handler == nil {
+		return fmt.Errorf("ebus: 事件处理函数不能为空")
+	}
+
+	// 回放不需要再次记录
+	options := NewOptions(opts...)
+	options.Recorder = nil
+
+	sub := &subscriber{options: options}
+	subs := newSubscription(options, record.Topic, options.buildHandler(handler), nil)
+
+	delivery := &broker.Delivery{
+		Message: broker.Message{
+			Id:          record.MessageId,
+			Headers:     maps.Clone(record.Headers),
+			Body:        slices.Clone(record.Body),
+			ContentType: record.ContentType,
+		},
+		Topic:       record.Topic,
+		Attempts:    record.Attempts,
+		ReceiveTime: record.ReceiveTime,
+	}
+
+	return sub.handleDelivery(ctx, subs, delivery)
+}
@@ -0,0 +1,101 @@
+package ebus
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nf5lab/broker"
+)
+
+func TestReplayRecord(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	capture := &capturePublisher{}
+	publisher := NewPublisher(capture, WithRegistry(registry))
+
+	published := newTestEvent("order-1", 42)
+	if err := publisher.Publish(context.Background(), "orders", published); err != nil {
+		t.Fatalf("发布事件失败: %v", err)
+	}
+
+	message := capture.published()[0]
+
+	// 经过记录文件往返, 与线上排查问题的流程一致
+	path := filepath.Join(t.TempDir(), "records.jsonl")
+	recorder, err := NewRecorder(path)
+	if err != nil {
+		t.Fatalf("创建记录器失败: %v", err)
+	}
+
+	delivery := &broker.Delivery{
+		Message:     *message,
+		Topic:       "orders",
+		Attempts:    1,
+		ReceiveTime: time.Now(),
+	}
+	if err := recorder.record(delivery, time.Millisecond, nil); err != nil {
+		t.Fatalf("记录投递失败: %v", err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("关闭记录器失败: %v", err)
+	}
+
+	records, err := LoadRecords(path)
+	if err != nil {
+		t.Fatalf("加载消费记录失败: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("消费记录数量 = %d, 期望 1", len(records))
+	}
+
+	var replayed *testEvent
+	handler := func(ctx context.Context, topic string, event Event) error {
+		replayed, _ = event.(*testEvent)
+		return nil
+	}
+
+	if err := ReplayRecord(context.Background(), records[0], handler, WithRegistry(registry)); err != nil {
+		t.Fatalf("回放失败: %v", err)
+	}
+
+	if replayed == nil {
+		t.Fatal("处理函数没有收到事件")
+	}
+	if replayed.OrderId != published.OrderId || replayed.Amount != published.Amount {
+		t.Errorf("回放的事件 = %+v, 期望 %+v", replayed, published)
+	}
+	if replayed.Metadata().EventId != published.Metadata().EventId {
+		t.Errorf("事件ID = %s, 期望 %s", replayed.Metadata().EventId, published.Metadata().EventId)
+	}
+}
+
+func TestReplayRecordHandlerError(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	capture := &capturePublisher{}
+	publisher := NewPublisher(capture, WithRegistry(registry))
+	if err := publisher.Publish(context.Background(), "orders", newTestEvent("order-2", 1)); err != nil {
+		t.Fatalf("发布事件失败: %v", err)
+	}
+
+	message := capture.published()[0]
+	record := &Record{
+		Topic:       "orders",
+		MessageId:   message.Id,
+		ContentType: message.ContentType,
+		Headers:     message.Headers,
+		Body:        message.Body,
+		Attempts:    1,
+	}
+
+	handlerErr := context.DeadlineExceeded
+	handler := func(ctx context.Context, topic string, event Event) error {
+		return handlerErr
+	}
+
+	if err := ReplayRecord(context.Background(), record, handler, WithRegistry(registry)); err == nil {
+		t.Fatal("处理函数返回错误时回放应该失败")
+	}
+}
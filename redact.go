@@ -0,0 +1,257 @@
+package ebus
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+var (
+	// 确保实现了 slog.LogValuer 接口
+	_ slog.LogValuer = redactedValue{}
+)
+
+const (
+	// RedactedMask 脱敏字段的替代值
+	RedactedMask = "[REDACTED]"
+
+	// HeaderRedactedPayload 脱敏之后的负载 (参考 RedactJSON)
+	//
+	// 订阅者以不可重试的错误拒绝投递时附加到消息上, 消息转入死信队列之后可以查看诊断信息
+	// 未知的事件类型没有字段标签, 所有字段的值都替换为 RedactedMask, 只保留结构
+	HeaderRedactedPayload = "x-event-redacted-payload"
+)
+
+// Redact 把事件转换为脱敏之后的 JSON 结构 (map, slice 和基础类型)
+//
+// 带有 `ebus:"redact"` 标签的字段替换为 RedactedMask, 字段名称与 encoding/json 的规则一致
+// 例如:
+//
+//	type UserRegistered struct {
+//		Meta  *ebus.Metadata `json:"metadata"`
+//		Email string         `json:"email" ebus:"redact"`
+//	}
+func Redact(value any) any {
+	return redactValue(reflect.ValueOf(value), 0)
+}
+
+// RedactJSON 把事件编码为脱敏之后的 JSON, 可以写入日志或者死信队列的消息头 (HeaderRedactedPayload)
+func RedactJSON(value any) ([]byte, error) {
+	return json.Marshal(Redact(value))
+}
+
+// Redacted 返回事件脱敏之后的日志值, 用于 slog 记录事件
+//
+//	logger.Info("收到事件", slog.Any("event", ebus.Redacted(event)))
+func Redacted(value any) slog.LogValuer {
+	return redactedValue{value: value}
+}
+
+// redactedValue 延迟脱敏的日志值, 日志级别没有启用时不需要反射
+type redactedValue struct {
+	value any
+}
+
+// LogValue 返回脱敏之后的日志值
+func (v redactedValue) LogValue() slog.Value {
+	return slog.AnyValue(Redact(v.value))
+}
+
+// RedactedLogging 记录事件处理失败的中间件, 日志中的事件负载经过脱敏
+//
+// 失败诊断需要事件内容, 但是不能把个人信息泄露到日志中
+// - logger 日志记录器, 为 nil 时使用 slog.Default()
+func RedactedLogging(logger *slog.Logger) Middleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, topic string, event Event) error {
+			err := next(ctx, topic, event)
+			if err != nil {
+				logger.WarnContext(ctx, "ebus: 事件处理失败",
+					append(metadataLogAttrs(event.Metadata()),
+						slog.String("topic", topic),
+						slog.Any("event", Redacted(event)),
+						slog.Any("error", err),
+					)...,
+				)
+			}
+			return err
+		}
+	}
+}
+
+// maskPayloadJSON 解码事件信封, 把负载中所有的值替换为 RedactedMask
+//
+// 用于没有注册事件类型, 无法按照字段标签脱敏的事件
+func maskPayloadJSON(codec Codec, headers map[string]any, data []byte) ([]byte, error) {
+	envelope, err := decodeEnvelopeWith(codec, headers, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload any
+	if err := json.Unmarshal(envelope.Payload, &payload); err != nil {
+		return nil, err
+	}
+	return json.Marshal(maskAll(payload))
+}
+
+// maskAll 把解码之后的 JSON 中所有的值替换为 RedactedMask, 只保留对象的键和数组的结构
+func maskAll(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, item := range value {
+			value[key] = maskAll(item)
+		}
+		return value
+	case []any:
+		for i, item := range value {
+			value[i] = maskAll(item)
+		}
+		return value
+	case nil:
+		return nil
+	default:
+		return RedactedMask
+	}
+}
+
+// maxRedactDepth 脱敏的最大嵌套深度, 防止循环引用
+const maxRedactDepth = 32
+
+// redactValue 递归地转换并脱敏
+func redactValue(value reflect.Value, depth int) any {
+	if !value.IsValid() || depth > maxRedactDepth {
+		return nil
+	}
+
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	typ := value.Type()
+
+	// 自定义了 JSON 编码的类型 (例如 time.Time, GenericEvent) 按照其 JSON 形式输出, 无法按字段脱敏
+	if typ.Implements(jsonMarshalerType) || reflect.PointerTo(typ).Implements(jsonMarshalerType) {
+		if value.CanAddr() {
+			value = value.Addr()
+		}
+		data, err := json.Marshal(value.Interface())
+		if err != nil {
+			return nil
+		}
+		var decoded any
+		_ = json.Unmarshal(data, &decoded)
+		return decoded
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		result := make(map[string]any)
+		redactFields(value, result, depth)
+		return result
+
+	case reflect.Map:
+		if value.IsNil() {
+			return nil
+		}
+		result := make(map[string]any, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			result[formatMapKey(iter.Key())] = redactValue(iter.Value(), depth+1)
+		}
+		return result
+
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return nil
+		}
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return value.Interface()
+		}
+		result := make([]any, value.Len())
+		for i := range result {
+			result[i] = redactValue(value.Index(i), depth+1)
+		}
+		return result
+
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return nil
+
+	default:
+		return value.Interface()
+	}
+}
+
+// redactFields 收集结构体的字段, 匿名嵌入的结构体字段提升到外层
+func redactFields(value reflect.Value, result map[string]any, depth int) {
+	typ := value.Type()
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		fieldValue := value.Field(i)
+		if field.Anonymous && len(name) == 0 {
+			embedded := fieldValue
+			for embedded.Kind() == reflect.Pointer {
+				if embedded.IsNil() {
+					break
+				}
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				redactFields(embedded, result, depth+1)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+
+		if isRedactedField(field) {
+			result[name] = RedactedMask
+			continue
+		}
+		result[name] = redactValue(fieldValue, depth+1)
+	}
+}
+
+// isRedactedField 字段是否带有 `ebus:"redact"` 标签
+func isRedactedField(field reflect.StructField) bool {
+	for option := range strings.SplitSeq(field.Tag.Get("ebus"), ",") {
+		if strings.TrimSpace(option) == "redact" {
+			return true
+		}
+	}
+	return false
+}
+
+// formatMapKey 把映射的键转换为字符串
+func formatMapKey(key reflect.Value) string {
+	if key.Kind() == reflect.String {
+		return key.String()
+	}
+	data, err := json.Marshal(key.Interface())
+	if err != nil {
+		return ""
+	}
+	return strings.Trim(string(data), `"`)
+}
@@ -0,0 +1,189 @@
+package ebus_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/nf5lab/broker"
+	"github.com/nf5lab/ebus"
+	"github.com/nf5lab/ebus/memory"
+)
+
+// userRegistered 带有脱敏字段的测试事件
+type userRegistered struct {
+	Meta    *ebus.Metadata `json:"metadata"`
+	UserId  string         `json:"userId"`
+	Email   string         `json:"email" ebus:"redact"`
+	Address struct {
+		City   string `json:"city"`
+		Street string `json:"street" ebus:"redact"`
+	} `json:"address"`
+}
+
+func (evt *userRegistered) Metadata() *ebus.Metadata {
+	return evt.Meta
+}
+
+func (evt *userRegistered) Validate() error {
+	return nil
+}
+
+const secretEmail = "alice@example.com"
+
+func newUserRegistered() *userRegistered {
+	event := &userRegistered{
+		Meta:   ebus.NewMetadata("ebus.test", "user.registered", "v1"),
+		UserId: "user-1",
+		Email:  secretEmail,
+	}
+	event.Address.City = "Hangzhou"
+	event.Address.Street = "1 Secret Road"
+	return event
+}
+
+func newUserRegistry(t testing.TB) *ebus.Registry {
+	t.Helper()
+
+	registry := ebus.NewRegistry()
+	if err := ebus.RegisterEventIn[userRegistered](registry, "v1", "ebus.test", "user.registered"); err != nil {
+		t.Fatalf("注册测试事件失败: %v", err)
+	}
+	return registry
+}
+
+func TestRedact(t *testing.T) {
+	data, err := ebus.RedactJSON(newUserRegistered())
+	if err != nil {
+		t.Fatalf("脱敏失败: %v", err)
+	}
+
+	var redacted struct {
+		UserId  string `json:"userId"`
+		Email   string `json:"email"`
+		Address struct {
+			City   string `json:"city"`
+			Street string `json:"street"`
+		} `json:"address"`
+	}
+	if err := json.Unmarshal(data, &redacted); err != nil {
+		t.Fatalf("解码脱敏结果失败: %v", err)
+	}
+
+	if redacted.UserId != "user-1" || redacted.Address.City != "Hangzhou" {
+		t.Errorf("没有标签的字段被修改: %s", data)
+	}
+	if redacted.Email != ebus.RedactedMask || redacted.Address.Street != ebus.RedactedMask {
+		t.Errorf("带有标签的字段没有脱敏: %s", data)
+	}
+}
+
+// deadLetterPayload 发布事件, 返回死信消息上附加的脱敏负载
+func deadLetterPayload(t *testing.T, handler ebus.EventHandler, subscribeOpts ...ebus.Option) string {
+	t.Helper()
+
+	b := memory.NewBroker()
+	defer b.Close()
+
+	subscriber := ebus.NewSubscriber(b, subscribeOpts...)
+	if _, err := subscriber.Subscribe(context.Background(), "users", "crm", handler); err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+
+	publisher := ebus.NewPublisher(b, ebus.WithRegistry(newUserRegistry(t)))
+	if err := publisher.Publish(context.Background(), "users", newUserRegistered()); err != nil {
+		t.Fatalf("发布事件失败: %v", err)
+	}
+
+	deadLetters := b.DeadLetters()
+	if len(deadLetters) != 1 {
+		t.Fatalf("死信数量 = %d, 期望 1", len(deadLetters))
+	}
+
+	payload, exists := deadLetters[0].Delivery.Message.GetHeaderString(ebus.HeaderRedactedPayload)
+	if !exists {
+		t.Fatalf("死信消息没有附加 %s", ebus.HeaderRedactedPayload)
+	}
+	if strings.Contains(payload, secretEmail) {
+		t.Fatalf("脱敏负载泄露了个人信息: %s", payload)
+	}
+	return payload
+}
+
+func TestDeadLetterRedactedPayload(t *testing.T) {
+	t.Run("non-retryable error", func(t *testing.T) {
+		handler := func(ctx context.Context, topic string, event ebus.Event) error {
+			return broker.NewNonRetryableError(errors.New("拒绝"))
+		}
+
+		payload := deadLetterPayload(t, handler,
+			ebus.WithRegistry(newUserRegistry(t)),
+			ebus.WithLogger(slog.New(slog.DiscardHandler)),
+		)
+		if !strings.Contains(payload, `"userId":"user-1"`) || !strings.Contains(payload, ebus.RedactedMask) {
+			t.Errorf("脱敏负载 = %s, 期望只替换带有标签的字段", payload)
+		}
+	})
+
+	t.Run("panic dead letter", func(t *testing.T) {
+		handler := func(ctx context.Context, topic string, event ebus.Event) error {
+			panic("处理失败")
+		}
+
+		payload := deadLetterPayload(t, handler,
+			ebus.WithRegistry(newUserRegistry(t)),
+			ebus.WithPanicPolicy(ebus.PanicPolicyDeadLetter, nil),
+			ebus.WithLogger(slog.New(slog.DiscardHandler)),
+		)
+		if !strings.Contains(payload, `"userId":"user-1"`) {
+			t.Errorf("脱敏负载 = %s, 期望包含没有标签的字段", payload)
+		}
+	})
+
+	t.Run("unknown event", func(t *testing.T) {
+		handler := func(ctx context.Context, topic string, event ebus.Event) error {
+			t.Error("未知的事件类型不应该交给处理函数")
+			return nil
+		}
+
+		// 订阅者没有注册事件类型, 所有字段的值都被替换
+		payload := deadLetterPayload(t, handler,
+			ebus.WithRegistry(ebus.NewRegistry()),
+			ebus.WithUnknownEventPolicy(ebus.UnknownEventDeadLetter, nil),
+			ebus.WithLogger(slog.New(slog.DiscardHandler)),
+		)
+		if strings.Contains(payload, "user-1") || !strings.Contains(payload, `"email"`) {
+			t.Errorf("脱敏负载 = %s, 期望保留结构并替换所有的值", payload)
+		}
+	})
+}
+
+func TestHandlerFailureLogRedacted(t *testing.T) {
+	var logs bytes.Buffer
+	bus := memory.New(
+		ebus.WithRegistry(newUserRegistry(t)),
+		ebus.WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))),
+	)
+	defer bus.Close()
+
+	handler := func(ctx context.Context, topic string, event ebus.Event) error {
+		return broker.NewNonRetryableError(errors.New("拒绝"))
+	}
+	if _, err := bus.Subscribe(context.Background(), "users", "crm", handler); err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+	if err := bus.Publish(context.Background(), "users", newUserRegistered()); err != nil {
+		t.Fatalf("发布事件失败: %v", err)
+	}
+
+	if !strings.Contains(logs.String(), "Hangzhou") {
+		t.Fatalf("失败日志没有记录事件: %s", logs.String())
+	}
+	if strings.Contains(logs.String(), secretEmail) {
+		t.Fatalf("失败日志泄露了个人信息: %s", logs.String())
+	}
+}
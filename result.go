@@ -0,0 +1,108 @@
+package ebus
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/nf5lab/broker"
+)
+
+// 处理结果事件的标识
+const (
+	ResultEventSchemaVersion SchemaVersion = "v1"
+	ResultEventSource        EventSource   = "ebus"
+	ResultEventType          EventType     = "processing-result"
+)
+
+func init() {
+	MustRegisterEvent[ResultEvent](ResultEventSchemaVersion, ResultEventSource, ResultEventType)
+}
+
+// ResultEvent 处理结果事件
+//
+// 订阅者开启了处理结果事件后, 每个事件处理完成 (成功或者失败) 时发布一条处理结果事件
+// 结果事件的因果ID为原事件ID, 关联ID继承自原事件, 便于工作流引擎跟踪分布式步骤的完成情况
+type ResultEvent struct {
+	Meta *Metadata `json:"metadata"` // 结果事件的元数据
+
+	Topic         string        `json:"topic"`           // 原事件的主题
+	EventId       string        `json:"eventId"`         // 原事件ID
+	EventSource   EventSource   `json:"eventSource"`     // 原事件来源
+	EventType     EventType     `json:"eventType"`       // 原事件类型
+	SchemaVersion SchemaVersion `json:"schemaVersion"`   // 原事件的模型版本
+	Consumer      string        `json:"consumer"`        // 消费者标识 (服务名称)
+	Group         string        `json:"group"`           // 订阅组
+	Attempts      int           `json:"attempts"`        // 投递的尝试次数
+	Success       bool          `json:"success"`         // 是否处理成功
+	Error         string        `json:"error,omitempty"` // 处理失败的错误信息
+	Duration      int64         `json:"duration"`        // 处理耗时, 单位毫秒
+}
+
+// Metadata 获取事件元数据
+func (evt *ResultEvent) Metadata() *Metadata {
+	return evt.Meta
+}
+
+// Validate 验证事件是否有效
+func (evt *ResultEvent) Validate() error {
+	if evt.Meta == nil {
+		return fmt.Errorf("ebus: 事件元数据不能为空")
+	}
+
+	if len(strings.TrimSpace(evt.EventId)) == 0 {
+		return fmt.Errorf("ebus: 原事件ID不能为空")
+	}
+
+	return nil
+}
+
+// isResultEvent 是否是处理结果事件
+func isResultEvent(metadata *Metadata) bool {
+	return metadata.EventSource == ResultEventSource && metadata.EventType == ResultEventType
+}
+
+// publishResult 发布处理结果事件
+//
+// 发布失败只记录日志, 不影响原事件的确认
+func (sub *subscriber) publishResult(ctx context.Context, subs *subscription, topic string, delivery *broker.Delivery, metadata *Metadata, duration time.Duration, err error) {
+	publisher := sub.options.ResultPublisher
+	if publisher == nil || isResultEvent(metadata) {
+		return
+	}
+
+	correlationId := metadata.CorrelationId
+	if len(correlationId) == 0 {
+		correlationId = metadata.EventId
+	}
+
+	result := &ResultEvent{
+		Meta: NewMetadata(ResultEventSource, ResultEventType, ResultEventSchemaVersion,
+			WithMetadataClock(sub.options.clock()),
+			WithMetadataCorrelationId(correlationId),
+			WithMetadataCausationId(metadata.EventId),
+		),
+		Topic:         topic,
+		EventId:       metadata.EventId,
+		EventSource:   metadata.EventSource,
+		EventType:     metadata.EventType,
+		SchemaVersion: metadata.SchemaVersion,
+		Consumer:      sub.options.ServiceName,
+		Group:         subs.group,
+		Attempts:      delivery.Attempts,
+		Success:       err == nil,
+		Duration:      duration.Milliseconds(),
+	}
+
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	if err := publisher.Publish(ctx, sub.options.ResultTopic, result); err != nil {
+		sub.options.Logger.WarnContext(ctx, "ebus: 处理结果事件发布失败",
+			append(metadataLogAttrs(metadata), slog.String("topic", topic), slog.Any("error", err))...,
+		)
+	}
+}
@@ -0,0 +1,133 @@
+package ebus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/nf5lab/broker"
+)
+
+// RetryAfterError 要求延迟重新投递的错误
+type RetryAfterError struct {
+	Err   error         // 原始错误
+	Delay time.Duration // 重新投递之前的延迟
+}
+
+func (err *RetryAfterError) Error() string {
+	return fmt.Sprintf("ebus: %s后重试: %v", err.Delay, err.Err)
+}
+
+func (err *RetryAfterError) Unwrap() error {
+	return err.Err
+}
+
+// RetryAfter 要求在指定的延迟之后重新投递事件
+//
+// 处理函数返回该错误时, 订阅者按照 DelayedRetryMode 延迟重新投递, 用于对限流的下游进行礼貌退避
+// - err   原始错误
+// - delay 延迟, 小于等于0时按照普通错误处理
+func RetryAfter(err error, delay time.Duration) error {
+	return &RetryAfterError{Err: err, Delay: delay}
+}
+
+// DelayedRetryMode 延迟重新投递的方式
+type DelayedRetryMode int
+
+const (
+	// DelayedRetryWait 在进程内等待延迟之后返回错误, 由消息队列立即重新投递 (默认)
+	//
+	// 不需要额外的发布者, 但是等待期间占用订阅的处理名额
+	DelayedRetryWait DelayedRetryMode = iota
+
+	// DelayedRetryNative 使用消息队列原生的延迟发布, 把消息重新发布到原主题, 然后确认原消息
+	DelayedRetryNative
+
+	// DelayedRetryTimer 确认原消息, 在进程内定时到期后重新发布到原主题
+	//
+	// 适用于不支持延迟发布的消息队列, 注意: 到期之前进程退出会丢失消息
+	DelayedRetryTimer
+)
+
+func (mode DelayedRetryMode) String() string {
+	switch mode {
+	case DelayedRetryWait:
+		return "wait"
+	case DelayedRetryNative:
+		return "native"
+	case DelayedRetryTimer:
+		return "timer"
+	default:
+		return fmt.Sprintf("DelayedRetryMode(%d)", int(mode))
+	}
+}
+
+// retryCountFromMessage 从消息头读取延迟重新发布的次数
+func retryCountFromMessage(message *broker.Message) int {
+	value, ok := message.GetHeaderString(HeaderRetryCount)
+	if !ok {
+		return 0
+	}
+
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		return 0
+	}
+	return count
+}
+
+// handleRetryAfter 处理要求延迟重新投递的错误
+//
+// 返回值作为投递的最终结果
+func (sub *subscriber) handleRetryAfter(ctx context.Context, delivery *broker.Delivery, err error) error {
+	var retryErr *RetryAfterError
+	if delivery == nil || !errors.As(err, &retryErr) || retryErr.Delay <= 0 {
+		return err
+	}
+
+	delay := retryErr.Delay
+	mode := sub.options.DelayedRetryMode
+	publisher := sub.options.RetryPublisher
+
+	if mode == DelayedRetryWait || publisher == nil {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		return err
+	}
+
+	message := delivery.Message.Clone()
+	message.AddHeader(HeaderRetryCount, strconv.Itoa(retryCountFromMessage(message)+1))
+
+	logAttrs := []any{
+		slog.String("topic", delivery.Topic),
+		slog.String("messageId", message.Id),
+		slog.Duration("delay", delay),
+	}
+
+	if mode == DelayedRetryNative {
+		if pubErr := publisher.Publish(ctx, delivery.Topic, message, broker.WithPublishDelay(delay)); pubErr != nil {
+			sub.options.Logger.WarnContext(ctx, "ebus: 延迟重新发布失败", append(logAttrs, slog.Any("error", pubErr))...)
+			return errors.Join(err, pubErr)
+		}
+		return nil
+	}
+
+	topic := delivery.Topic
+	logger := sub.options.Logger
+	time.AfterFunc(delay, func() {
+		// 原投递的上下文已经结束, 使用独立的上下文
+		if pubErr := publisher.Publish(context.Background(), topic, message); pubErr != nil {
+			logger.Error("ebus: 定时重新发布失败, 消息丢失", append(logAttrs, slog.Any("error", pubErr))...)
+		}
+	})
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package ebus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// 确保实现了 SchemaRegistry 接口
+	_ SchemaRegistry = (*SchemaRegistryClient)(nil)
+)
+
+var (
+	// ErrSchemaNotRegistered 模式注册中心中没有对应的模式
+	ErrSchemaNotRegistered = errors.New("ebus: 模式未在注册中心注册")
+)
+
+// SchemaRegistry 集中的模式注册中心
+//
+// 每个事件来源和事件类型对应一个主题 (参考 SchemaSubject), 模型版本对应主题下的版本
+type SchemaRegistry interface {
+
+	// GetSchema 获取主题下某个模型版本的模式, 不存在时返回 ErrSchemaNotRegistered
+	GetSchema(ctx context.Context, subject string, version SchemaVersion) (string, error)
+
+	// CheckCompatibility 检查模式是否与主题的最新版本兼容
+	CheckCompatibility(ctx context.Context, subject string, schema string) (bool, error)
+
+	// RegisterSchema 在主题下注册模式, 返回注册中心分配的模型版本
+	//
+	// 模式已经注册时返回已有的模型版本
+	RegisterSchema(ctx context.Context, subject string, schema string) (SchemaVersion, error)
+}
+
+// SchemaSubject 事件类型在模式注册中心的主题, 格式为 "事件来源.事件类型"
+func SchemaSubject(evtSource EventSource, evtType EventType) string {
+	return fmt.Sprintf("%s.%s", evtSource.Normalize(), evtType.Normalize())
+}
+
+const (
+	// schemaRegistryContentType 模式注册中心 REST API 的内容类型
+	schemaRegistryContentType = "application/vnd.schemaregistry.v1+json"
+
+	// schemaRegistrySchemaType 注册的模式类型, 事件负载使用 JSON Schema (参考 RegisterEventFactoryWithSchema)
+	schemaRegistrySchemaType = "JSON"
+
+	// defaultSchemaRegistryTimeout 默认的请求超时时间
+	defaultSchemaRegistryTimeout = 10 * time.Second
+)
+
+// SchemaRegistryClient 兼容 Confluent 模式注册中心 REST API 的客户端
+//
+// Apicurio 通过兼容接口访问, 例如 http://localhost:8080/apis/ccompat/v7
+// 注册中心的版本号是整数, 模型版本 v3 对应版本号 3
+type SchemaRegistryClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewSchemaRegistryClient 创建模式注册中心客户端
+// - baseURL    模式注册中心地址, 例如 http://localhost:8081
+// - httpClient HTTP 客户端, 为 nil 时使用默认超时的客户端
+func NewSchemaRegistryClient(baseURL string, httpClient *http.Client) *SchemaRegistryClient {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultSchemaRegistryTimeout}
+	}
+
+	return &SchemaRegistryClient{
+		baseURL:    strings.TrimRight(strings.TrimSpace(baseURL), "/"),
+		httpClient: httpClient,
+	}
+}
+
+// GetSchema 获取主题下某个模型版本的模式
+func (client *SchemaRegistryClient) GetSchema(ctx context.Context, subject string, version SchemaVersion) (string, error) {
+	registryVersion, err := registryVersionOf(version)
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		Schema string `json:"schema"`
+	}
+
+	path := "/subjects/" + url.PathEscape(subject) + "/versions/" + strconv.Itoa(registryVersion)
+	if err := client.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return "", fmt.Errorf("ebus: 获取模式(%s@%s)失败: %w", subject, version, err)
+	}
+
+	return resp.Schema, nil
+}
+
+// CheckCompatibility 检查模式是否与主题的最新版本兼容, 主题不存在时视为兼容
+func (client *SchemaRegistryClient) CheckCompatibility(ctx context.Context, subject string, schema string) (bool, error) {
+	var resp struct {
+		IsCompatible bool `json:"is_compatible"`
+	}
+
+	path := "/compatibility/subjects/" + url.PathEscape(subject) + "/versions/latest"
+	err := client.do(ctx, http.MethodPost, path, newSchemaRequest(schema), &resp)
+	if errors.Is(err, ErrSchemaNotRegistered) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("ebus: 检查模式(%s)兼容性失败: %w", subject, err)
+	}
+
+	return resp.IsCompatible, nil
+}
+
+// RegisterSchema 在主题下注册模式, 返回注册中心分配的模型版本
+func (client *SchemaRegistryClient) RegisterSchema(ctx context.Context, subject string, schema string) (SchemaVersion, error) {
+	path := "/subjects/" + url.PathEscape(subject)
+
+	if err := client.do(ctx, http.MethodPost, path+"/versions", newSchemaRequest(schema), nil); err != nil {
+		return "", fmt.Errorf("ebus: 注册模式(%s)失败: %w", subject, err)
+	}
+
+	// 注册接口只返回模式ID, 需要再查询模式在主题下的版本号
+	var resp struct {
+		Version int `json:"version"`
+	}
+
+	if err := client.do(ctx, http.MethodPost, path, newSchemaRequest(schema), &resp); err != nil {
+		return "", fmt.Errorf("ebus: 查询模式(%s)版本失败: %w", subject, err)
+	}
+
+	return SchemaVersion("v" + strconv.Itoa(resp.Version)), nil
+}
+
+// schemaRequest 模式注册中心的请求
+type schemaRequest struct {
+	Schema     string `json:"schema"`
+	SchemaType string `json:"schemaType"`
+}
+
+func newSchemaRequest(schema string) *schemaRequest {
+	return &schemaRequest{
+		Schema:     schema,
+		SchemaType: schemaRegistrySchemaType,
+	}
+}
+
+// registryVersionOf 把模型版本转换为注册中心的版本号
+func registryVersionOf(version SchemaVersion) (int, error) {
+	registryVersion, err := strconv.Atoi(strings.TrimPrefix(version.Normalize().String(), "v"))
+	if err != nil || registryVersion <= 0 {
+		return 0, fmt.Errorf("ebus: 模型版本(%s)无法对应注册中心的版本号", version)
+	}
+	return registryVersion, nil
+}
+
+// do 发送请求并解码响应, 注册中心返回 404 时返回 ErrSchemaNotRegistered
+func (client *SchemaRegistryClient) do(ctx context.Context, method string, path string, body any, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, client.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", schemaRegistryContentType)
+	if body != nil {
+		req.Header.Set("Content-Type", schemaRegistryContentType)
+	}
+
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrSchemaNotRegistered
+	}
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("模式注册中心返回 %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// checkSchemaRegistered 检查事件的模型版本是否已经在模式注册中心注册
+//
+// 只缓存检查通过的结果, 未注册的模型版本注册之后不需要重启发布者
+func (pub *publisher) checkSchemaRegistered(ctx context.Context, metadata *Metadata) error {
+	registry := pub.options.SchemaRegistry
+	if registry == nil {
+		return nil
+	}
+
+	subject := SchemaSubject(metadata.EventSource, metadata.EventType)
+	cacheKey := subject + "@" + metadata.SchemaVersion.String()
+
+	if _, exists := pub.registeredSchemas.Load(cacheKey); exists {
+		return nil
+	}
+
+	if _, err := registry.GetSchema(ctx, subject, metadata.SchemaVersion); err != nil {
+		return fmt.Errorf("ebus: 事件(%s)的模型版本(%s)检查失败: %w", metadata.EventId, cacheKey, err)
+	}
+
+	pub.registeredSchemas.Store(cacheKey, struct{}{})
+	return nil
+}
@@ -0,0 +1,182 @@
+package ebus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrIncompatibleShape 事件结构与基线不兼容
+	ErrIncompatibleShape = errors.New("ebus: 事件结构与基线不兼容")
+)
+
+// ShapeStore 事件结构基线的存储
+//
+// 基线是事件结构体反射生成的 JSON Schema (参考 ExportAsyncAPI), 一般随代码提交到版本库
+type ShapeStore interface {
+
+	// Load 加载基线, 不存在时返回 fs.ErrNotExist
+	Load(info FactoryInfo) ([]byte, error)
+
+	// Save 保存基线
+	Save(info FactoryInfo, shape []byte) error
+}
+
+// DirShapeStore 使用目录保存事件结构基线, 每个事件工厂一个文件, 文件名为 "事件来源.事件类型.模型版本.json"
+type DirShapeStore string
+
+// Load 加载基线
+func (dir DirShapeStore) Load(info FactoryInfo) ([]byte, error) {
+	return os.ReadFile(dir.path(info))
+}
+
+// Save 保存基线
+func (dir DirShapeStore) Save(info FactoryInfo, shape []byte) error {
+	if err := os.MkdirAll(string(dir), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(dir.path(info), shape, 0o644)
+}
+
+func (dir DirShapeStore) path(info FactoryInfo) string {
+	return filepath.Join(string(dir), fmt.Sprintf("%s.%s.%s.json", info.EventSource, info.EventType, info.SchemaVersion))
+}
+
+// CheckEventShapes 检查全局注册表中事件的结构, 参考 Registry.CheckShapes
+// - store 事件结构基线的存储
+func CheckEventShapes(store ShapeStore) error {
+	return defaultRegistry.CheckShapes(store)
+}
+
+// CheckShapes 检查已注册事件的结构是否与基线兼容, 一般在启动阶段调用, 尽早发现不兼容的修改
+//
+// 同一模型版本的事件只允许向后兼容的修改 (例如增加字段), 以下修改视为不兼容:
+//   - 删除字段
+//   - 修改字段的类型
+//   - 可选字段变为必需字段
+//
+// 没有基线的事件保存当前结构作为基线; 不兼容的修改应该使用新的模型版本, 确实需要时删除基线文件重新生成
+// - store 事件结构基线的存储
+func (r *Registry) CheckShapes(store ShapeStore) error {
+	factories := r.snapshot()
+
+	var errs []error
+	for _, info := range r.Factories() {
+		event, err := factories[info.key()].factory()
+		if err != nil || event == nil {
+			errs = append(errs, fmt.Errorf("ebus: 事件工厂(%s)创建事件失败: %w", r.describeKey(info.key()), err))
+			continue
+		}
+
+		current := newSchemaBuilder().schemaOf(reflect.TypeOf(event))
+
+		golden, err := store.Load(info)
+		if errors.Is(err, fs.ErrNotExist) {
+			shape, err := json.MarshalIndent(current, "", "  ")
+			if err == nil {
+				err = store.Save(info, shape)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("ebus: 事件(%s)保存结构基线失败: %w", r.describeKey(info.key()), err))
+			}
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("ebus: 事件(%s)加载结构基线失败: %w", r.describeKey(info.key()), err))
+			continue
+		}
+
+		var baseline map[string]any
+		if err := json.Unmarshal(golden, &baseline); err != nil {
+			errs = append(errs, fmt.Errorf("ebus: 事件(%s)结构基线无效: %w", r.describeKey(info.key()), err))
+			continue
+		}
+
+		// 反射生成的结构经过一次 JSON 编解码, 与基线的表示方式一致
+		data, _ := json.Marshal(current)
+		var shape map[string]any
+		_ = json.Unmarshal(data, &shape)
+
+		if problems := compareShapes(baseline, shape, ""); len(problems) > 0 {
+			errs = append(errs, fmt.Errorf("%w: %s: %s", ErrIncompatibleShape, r.describeKey(info.key()), strings.Join(problems, "; ")))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// MustCheckShapes 检查已注册事件的结构是否与基线兼容, 如果不兼容则 panic
+// - store 事件结构基线的存储
+func (r *Registry) MustCheckShapes(store ShapeStore) {
+	if err := r.CheckShapes(store); err != nil {
+		panic(err)
+	}
+}
+
+// compareShapes 比较基线和当前的结构, 返回不兼容的修改
+// - path 字段路径, 用于错误信息
+func compareShapes(baseline map[string]any, current map[string]any, path string) []string {
+	location := path
+	if len(location) == 0 {
+		location = "/"
+	}
+
+	baseType, _ := baseline["type"].(string)
+	currentType, _ := current["type"].(string)
+	if baseType != currentType {
+		return []string{fmt.Sprintf("%s 的类型从 %q 修改为 %q", location, baseType, currentType)}
+	}
+
+	var problems []string
+
+	baseProperties, _ := baseline["properties"].(map[string]any)
+	currentProperties, _ := current["properties"].(map[string]any)
+	for _, name := range slices.Sorted(maps.Keys(baseProperties)) {
+		currentProperty, exists := currentProperties[name].(map[string]any)
+		if !exists {
+			problems = append(problems, fmt.Sprintf("%s/%s 被删除", path, name))
+			continue
+		}
+		if baseProperty, ok := baseProperties[name].(map[string]any); ok {
+			problems = append(problems, compareShapes(baseProperty, currentProperty, path+"/"+name)...)
+		}
+	}
+
+	baseRequired := shapeRequired(baseline)
+	for _, name := range shapeRequired(current) {
+		if _, existed := baseProperties[name]; existed && !slices.Contains(baseRequired, name) {
+			problems = append(problems, fmt.Sprintf("%s/%s 从可选变为必需", path, name))
+		}
+	}
+
+	for _, key := range []string{"items", "additionalProperties"} {
+		baseChild, ok1 := baseline[key].(map[string]any)
+		currentChild, ok2 := current[key].(map[string]any)
+		if ok1 && ok2 {
+			problems = append(problems, compareShapes(baseChild, currentChild, path+"/"+key)...)
+		}
+	}
+
+	return problems
+}
+
+// shapeRequired 结构中的必需字段
+func shapeRequired(shape map[string]any) []string {
+	values, _ := shape["required"].([]any)
+
+	required := make([]string, 0, len(values))
+	for _, value := range values {
+		if name, ok := value.(string); ok {
+			required = append(required, name)
+		}
+	}
+	return required
+}
@@ -0,0 +1,150 @@
+package ebus
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ShardKeyFunc 分片键函数
+//
+// 同一个分片键的事件总是发布到同一个物理主题, 一般返回聚合ID
+type ShardKeyFunc func(event Event) string
+
+// ShardedTopic 分片主题
+//
+// 把一个逻辑主题分散到 N 个物理主题 ({name}.{shard}) 上,
+// 用于没有原生分区能力, 单个队列又无法承载流量的消息队列
+// 发布者按分片键选择物理主题, 订阅者订阅全部或者部分物理主题
+type ShardedTopic struct {
+	name    string
+	shards  int
+	keyFunc ShardKeyFunc
+}
+
+// NewShardedTopic 创建分片主题
+// - name    逻辑主题
+// - shards  分片数量, 小于1时按1处理
+// - keyFunc 分片键函数, 为 nil 时使用元数据的分区键, 没有分区键时使用事件ID
+func NewShardedTopic(name string, shards int, keyFunc ShardKeyFunc) *ShardedTopic {
+	return &ShardedTopic{
+		name:    strings.TrimSpace(name),
+		shards:  max(shards, 1),
+		keyFunc: keyFunc,
+	}
+}
+
+// Name 逻辑主题
+func (st *ShardedTopic) Name() string {
+	return st.name
+}
+
+// Shards 分片数量
+func (st *ShardedTopic) Shards() int {
+	return st.shards
+}
+
+// ShardOf 计算分片键所在的分片
+//
+// 分片算法为 FNV-1a 取模, 修改分片数量会改变键与分片的对应关系
+func (st *ShardedTopic) ShardOf(key string) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+	return int(hash.Sum32() % uint32(st.shards))
+}
+
+// Topic 获取分片的物理主题
+func (st *ShardedTopic) Topic(shard int) string {
+	return st.name + "." + strconv.Itoa(shard)
+}
+
+// Topics 获取全部物理主题
+func (st *ShardedTopic) Topics() []string {
+	topics := make([]string, 0, st.shards)
+	for shard := range st.shards {
+		topics = append(topics, st.Topic(shard))
+	}
+	return topics
+}
+
+// TopicFor 获取事件的物理主题
+func (st *ShardedTopic) TopicFor(event Event) (string, error) {
+	if event == nil {
+		return "", fmt.Errorf("ebus: 事件不能为空")
+	}
+
+	var key string
+	if st.keyFunc != nil {
+		key = st.keyFunc(event)
+	} else if metadata := event.Metadata(); metadata != nil {
+		key = metadata.PartitionKey
+		if len(key) == 0 {
+			key = metadata.EventId
+		}
+	}
+
+	return st.Topic(st.ShardOf(key)), nil
+}
+
+// Publish 把事件发布到分片键对应的物理主题
+func (st *ShardedTopic) Publish(ctx context.Context, pub Publisher, event Event) error {
+	if len(st.name) == 0 {
+		return fmt.Errorf("ebus: 分片主题名称不能为空")
+	}
+
+	if pub == nil {
+		return fmt.Errorf("ebus: 发布者不能为空")
+	}
+
+	topic, err := st.TopicFor(event)
+	if err != nil {
+		return err
+	}
+
+	return pub.Publish(ctx, topic, event)
+}
+
+// Subscribe 订阅分片的物理主题
+//
+// 任何一个分片订阅失败时, 取消已经成功的订阅并返回错误
+// - shards 要订阅的分片, 为空时订阅全部分片
+func (st *ShardedTopic) Subscribe(ctx context.Context, sub Subscriber, group string, handler EventHandler, shards ...int) ([]string, error) {
+	if len(st.name) == 0 {
+		return nil, fmt.Errorf("ebus: 分片主题名称不能为空")
+	}
+
+	if sub == nil {
+		return nil, fmt.Errorf("ebus: 订阅者不能为空")
+	}
+
+	if len(shards) == 0 {
+		for shard := range st.shards {
+			shards = append(shards, shard)
+		}
+	}
+
+	for _, shard := range shards {
+		if shard < 0 || shard >= st.shards {
+			return nil, fmt.Errorf("ebus: 分片(%d)超出范围[0, %d)", shard, st.shards)
+		}
+	}
+
+	shards = slices.Compact(slices.Sorted(slices.Values(shards)))
+
+	subscriptionIds := make([]string, 0, len(shards))
+	for _, shard := range shards {
+		subscriptionId, err := sub.Subscribe(ctx, st.Topic(shard), group, handler)
+		if err != nil {
+			for _, id := range subscriptionIds {
+				_ = sub.Unsubscribe(ctx, id)
+			}
+			return nil, fmt.Errorf("ebus: 订阅分片主题(%s)失败: %w", st.Topic(shard), err)
+		}
+		subscriptionIds = append(subscriptionIds, subscriptionId)
+	}
+
+	return subscriptionIds, nil
+}
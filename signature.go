@@ -0,0 +1,193 @@
+package ebus
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nf5lab/broker"
+)
+
+var (
+	// 确保实现了 KeyResolver 接口
+	_ KeyResolver = StaticKeyResolver{}
+)
+
+var (
+	// ErrSignatureMissing 订阅者要求签名, 但是消息没有签名
+	ErrSignatureMissing = errors.New("ebus: 消息没有签名")
+
+	// ErrSignatureInvalid 消息的签名无效, 消息可能被伪造或者篡改
+	ErrSignatureInvalid = errors.New("ebus: 消息签名无效")
+)
+
+const (
+	// HeaderSignature 消息的签名, base64 编码
+	HeaderSignature = "x-event-signature"
+
+	// HeaderSignatureAlgorithm 签名算法, 参考 SignatureAlgorithmHMAC 和 SignatureAlgorithmEd25519
+	HeaderSignatureAlgorithm = "x-event-signature-alg"
+
+	// HeaderKeyId 签名使用的密钥ID, 用于密钥轮换
+	HeaderKeyId = "x-event-key-id"
+)
+
+// 签名算法
+const (
+	SignatureAlgorithmHMAC    = "hmac-sha256" // 对称签名, 参考 WithHMACSigner
+	SignatureAlgorithmEd25519 = "ed25519"     // 非对称签名, 参考 WithEd25519Signer
+)
+
+// signedHeaders 签名覆盖的消息头, 订阅者按照这些消息头路由和过滤事件, 必须与消息体一起防篡改
+var signedHeaders = []string{
+	HeaderEventId,
+	HeaderSchemaVersion,
+	HeaderEventSource,
+	HeaderEventType,
+	HeaderEventTime,
+	HeaderTenantId,
+	HeaderEventCount,
+}
+
+// KeyResolver 公钥解析器
+//
+// 订阅者按照消息头中的密钥ID查找生产者发布的 Ed25519 公钥
+// 轮换密钥时, 解析器先同时返回新旧公钥, 再让生产者切换到新的私钥
+type KeyResolver interface {
+
+	// ResolvePublicKey 按照密钥ID查找公钥, 不存在时返回错误
+	ResolvePublicKey(ctx context.Context, keyId string) (ed25519.PublicKey, error)
+}
+
+// StaticKeyResolver 固定的公钥集合, 键为密钥ID
+type StaticKeyResolver map[string]ed25519.PublicKey
+
+// ResolvePublicKey 按照密钥ID查找公钥
+func (resolver StaticKeyResolver) ResolvePublicKey(_ context.Context, keyId string) (ed25519.PublicKey, error) {
+	publicKey, exists := resolver[keyId]
+	if !exists {
+		return nil, fmt.Errorf("ebus: 公钥(%s)不存在", keyId)
+	}
+	return publicKey, nil
+}
+
+// signatureInput 签名的规范形式
+//
+// 依次为内容类型, 签名覆盖的消息头 (name:value, 每行一个), 以及未压缩的消息体
+func signatureInput(contentType string, headers map[string]any, body []byte) []byte {
+	var canonical bytes.Buffer
+	canonical.Grow(len(body) + 256)
+
+	canonical.WriteString(strings.ToLower(strings.TrimSpace(contentType)))
+	canonical.WriteByte('\n')
+
+	for _, name := range signedHeaders {
+		value, _ := headers[name].(string)
+		canonical.WriteString(name)
+		canonical.WriteByte(':')
+		canonical.WriteString(value)
+		canonical.WriteByte('\n')
+	}
+
+	canonical.Write(body)
+	return canonical.Bytes()
+}
+
+// computeHMAC 计算 HMAC-SHA256 签名
+func computeHMAC(key []byte, input []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(input)
+	return mac.Sum(nil)
+}
+
+// signMessage 按照选项为消息签名, 在压缩之前调用, 签名对应未压缩的消息体
+//
+// 同时设置了两种签名时使用 Ed25519
+func (opts *Options) signMessage(ctx context.Context, message *broker.Message) error {
+	var (
+		algorithm string
+		keyId     string
+		signature []byte
+	)
+
+	switch {
+	case len(opts.Ed25519PrivateKey) > 0:
+		if len(opts.Ed25519PrivateKey) != ed25519.PrivateKeySize {
+			return fmt.Errorf("ebus: Ed25519 私钥无效")
+		}
+		input := signatureInput(message.ContentType, message.Headers, message.Body)
+		algorithm, keyId = SignatureAlgorithmEd25519, opts.Ed25519KeyId
+		signature = ed25519.Sign(opts.Ed25519PrivateKey, input)
+
+	case opts.SigningKeys != nil:
+		currentKeyId, key, err := opts.SigningKeys.CurrentKey(ctx)
+		if err != nil {
+			return fmt.Errorf("ebus: 获取签名密钥失败: %w", err)
+		}
+		input := signatureInput(message.ContentType, message.Headers, message.Body)
+		algorithm, keyId = SignatureAlgorithmHMAC, currentKeyId
+		signature = computeHMAC(key, input)
+
+	default:
+		return nil
+	}
+
+	message.AddHeader(HeaderSignature, base64.StdEncoding.EncodeToString(signature))
+	message.AddHeader(HeaderSignatureAlgorithm, algorithm)
+	message.AddHeader(HeaderKeyId, keyId)
+	return nil
+}
+
+// verifySignature 按照选项校验解压之后的消息体的签名
+//
+// 设置了 SigningKeys 或者 KeyResolver 时强制校验, 没有签名的消息也会被拒绝
+// 两者都设置时接受任意一种算法的签名
+func (opts *Options) verifySignature(ctx context.Context, message *broker.Message, body []byte) error {
+	if opts.SigningKeys == nil && opts.KeyResolver == nil {
+		return nil
+	}
+
+	encoded, _ := message.Headers[HeaderSignature].(string)
+	if len(encoded) == 0 {
+		return ErrSignatureMissing
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+	}
+
+	keyId, _ := message.Headers[HeaderKeyId].(string)
+	input := signatureInput(message.ContentType, message.Headers, body)
+
+	switch algorithm, _ := message.Headers[HeaderSignatureAlgorithm].(string); {
+	case algorithm == SignatureAlgorithmEd25519 && opts.KeyResolver != nil:
+		publicKey, err := opts.KeyResolver.ResolvePublicKey(ctx, keyId)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+		}
+		if len(publicKey) != ed25519.PublicKeySize || !ed25519.Verify(publicKey, input, signature) {
+			return ErrSignatureInvalid
+		}
+		return nil
+
+	case algorithm == SignatureAlgorithmHMAC && opts.SigningKeys != nil:
+		key, err := opts.SigningKeys.LookupKey(ctx, keyId)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+		}
+		if !hmac.Equal(signature, computeHMAC(key, input)) {
+			return ErrSignatureInvalid
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("%w: 不接受的签名算法(%s)", ErrSignatureInvalid, algorithm)
+	}
+}
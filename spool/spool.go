@@ -0,0 +1,339 @@
+// Package spool 实现本地磁盘上的存储转发缓冲区
+//
+// 消息队列不可用时, 消息先写入本地目录, 恢复之后按照写入的顺序转发并删除
+// 缓冲的消息可能包含个人信息, 并且可能落在节点的临时磁盘上, 所以可以使用 WithEncryption
+// 把整条记录 (主题, 消息头和消息体) 使用 AES-GCM 加密之后再写入磁盘
+//
+// 使用方法:
+//
+//	sp, _ := spool.New("/var/spool/ebus", spool.WithEncryption(keyring))
+//	publisher := ebus.NewPublisher(sp.Wrap(brokerPublisher))
+//	// ... 消息队列恢复之后 ...
+//	_, err := sp.Forward(ctx, brokerPublisher)
+package spool
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nf5lab/broker"
+	"github.com/nf5lab/ebus"
+)
+
+const (
+	// fileSuffix 缓冲文件的后缀, 写入过程中的临时文件没有该后缀
+	fileSuffix = ".spool"
+)
+
+var (
+	// ErrEncryptionKeyMissing 缓冲文件已加密, 但是没有设置密钥提供者
+	ErrEncryptionKeyMissing = errors.New("spool: 缓冲文件已加密, 没有解密的密钥")
+)
+
+// Option 缓冲区选项
+type Option func(*Spool)
+
+// WithEncryption 使用 AES-GCM 加密写入磁盘的记录 (参考 ebus.KeyProvider)
+//
+// 加密整条记录, 包括主题和消息头; 转发时按照文件中的密钥ID查找密钥, 支持密钥轮换
+// - keyProvider 密钥提供者, 密钥长度为 16, 24 或者 32 字节
+func WithEncryption(keyProvider ebus.KeyProvider) Option {
+	return func(sp *Spool) {
+		sp.keys = keyProvider
+	}
+}
+
+// WithLogger 设置日志记录器, 默认 slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(sp *Spool) {
+		sp.logger = logger
+	}
+}
+
+// Spool 本地磁盘上的存储转发缓冲区
+type Spool struct {
+	dir    string
+	keys   ebus.KeyProvider
+	logger *slog.Logger
+
+	lock sync.Mutex // 保证转发按照顺序进行, 同一个文件不会被转发两次
+	seq  uint64     // 同一纳秒内写入的文件按照序号排序
+}
+
+// New 创建存储转发缓冲区, 目录不存在时创建
+// - dir 缓冲文件所在的目录, 只有当前用户可以访问
+func New(dir string, opts ...Option) (*Spool, error) {
+	sp := &Spool{
+		dir:    dir,
+		logger: slog.Default(),
+	}
+
+	for _, apply := range opts {
+		if apply != nil {
+			apply(sp)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("spool: 创建目录(%s)失败: %w", dir, err)
+	}
+
+	return sp, nil
+}
+
+// record 缓冲的消息
+type record struct {
+	Topic   string          `json:"topic"`
+	Message *broker.Message `json:"message"`
+}
+
+// spoolFile 缓冲文件的内容, 加密时只有密钥ID和密文是明文
+type spoolFile struct {
+	KeyId      string          `json:"keyId,omitempty"`
+	Ciphertext []byte          `json:"ciphertext,omitempty"`
+	Record     json.RawMessage `json:"record,omitempty"`
+}
+
+// Store 把消息写入缓冲区
+//
+// 先写入临时文件再重命名, 进程崩溃时不会留下不完整的缓冲文件
+// - topic   主题
+// - message 消息
+func (sp *Spool) Store(ctx context.Context, topic string, message *broker.Message) error {
+	data, err := json.Marshal(record{Topic: topic, Message: message})
+	if err != nil {
+		return fmt.Errorf("spool: 消息(%s)编码失败: %w", message.Id, err)
+	}
+
+	file := spoolFile{Record: data}
+	if sp.keys != nil {
+		if file, err = sp.encrypt(ctx, data); err != nil {
+			return err
+		}
+	}
+
+	content, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("spool: 消息(%s)编码失败: %w", message.Id, err)
+	}
+
+	sp.lock.Lock()
+	sp.seq++
+	name := fmt.Sprintf("%020d-%010d", time.Now().UnixNano(), sp.seq)
+	sp.lock.Unlock()
+
+	temp := filepath.Join(sp.dir, name+".tmp")
+	if err := os.WriteFile(temp, content, 0o600); err != nil {
+		return fmt.Errorf("spool: 消息(%s)写入失败: %w", message.Id, err)
+	}
+
+	if err := os.Rename(temp, filepath.Join(sp.dir, name+fileSuffix)); err != nil {
+		_ = os.Remove(temp)
+		return fmt.Errorf("spool: 消息(%s)写入失败: %w", message.Id, err)
+	}
+
+	return nil
+}
+
+// Pending 缓冲区中等待转发的消息数量
+func (sp *Spool) Pending() (int, error) {
+	names, err := sp.list()
+	return len(names), err
+}
+
+// Forward 按照写入的顺序把缓冲的消息发布到消息队列, 发布成功之后删除
+//
+// 遇到第一个发布失败时停止, 返回已经转发的消息数量, 剩余的消息留在缓冲区中
+// - publisher 消息队列的发布者
+func (sp *Spool) Forward(ctx context.Context, publisher broker.Publisher) (int, error) {
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+
+	names, err := sp.list()
+	if err != nil {
+		return 0, err
+	}
+
+	forwarded := 0
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return forwarded, err
+		}
+
+		path := filepath.Join(sp.dir, name)
+		rec, err := sp.load(ctx, path)
+		if err != nil {
+			return forwarded, err
+		}
+
+		if err := publisher.Publish(ctx, rec.Topic, rec.Message); err != nil {
+			return forwarded, fmt.Errorf("spool: 消息(%s)转发失败: %w", rec.Message.Id, err)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return forwarded, fmt.Errorf("spool: 删除已转发的文件(%s)失败: %w", name, err)
+		}
+		forwarded++
+	}
+
+	return forwarded, nil
+}
+
+var (
+	// 确保实现了 broker.Publisher 接口
+	_ broker.Publisher = (*spoolPublisher)(nil)
+)
+
+// Wrap 包装消息队列的发布者, 发布失败时把消息写入缓冲区
+//
+// 写入缓冲区成功时发布返回 nil, 消息由 Forward 稍后转发; 写入失败时返回发布的错误
+// 注意: 缓冲区不保存发布选项, 转发时使用默认的发布选项
+// - publisher 消息队列的发布者
+func (sp *Spool) Wrap(publisher broker.Publisher) broker.Publisher {
+	return &spoolPublisher{spool: sp, inner: publisher}
+}
+
+// spoolPublisher 发布失败时写入缓冲区的发布者
+type spoolPublisher struct {
+	spool *Spool
+	inner broker.Publisher
+}
+
+// Publish 发布消息, 失败时写入缓冲区
+func (pub *spoolPublisher) Publish(ctx context.Context, topic string, message *broker.Message, opts ...broker.PublishOption) error {
+	err := pub.inner.Publish(ctx, topic, message, opts...)
+	if err == nil {
+		return nil
+	}
+
+	if spoolErr := pub.spool.Store(ctx, topic, message); spoolErr != nil {
+		return errors.Join(err, spoolErr)
+	}
+
+	pub.spool.logger.WarnContext(ctx, "spool: 发布失败, 消息已写入缓冲区",
+		slog.String("topic", topic),
+		slog.String("messageId", message.Id),
+		slog.Any("error", err),
+	)
+	return nil
+}
+
+// Close 关闭被包装的发布者
+func (pub *spoolPublisher) Close() error {
+	return pub.inner.Close()
+}
+
+// list 按照写入的顺序列出缓冲文件
+func (sp *Spool) list() ([]string, error) {
+	entries, err := os.ReadDir(sp.dir)
+	if err != nil {
+		return nil, fmt.Errorf("spool: 读取目录(%s)失败: %w", sp.dir, err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), fileSuffix) {
+			names = append(names, entry.Name())
+		}
+	}
+
+	slices.Sort(names)
+	return names, nil
+}
+
+// load 读取并解密缓冲文件
+func (sp *Spool) load(ctx context.Context, path string) (*record, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("spool: 读取文件(%s)失败: %w", filepath.Base(path), err)
+	}
+
+	var file spoolFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("spool: 文件(%s)解码失败: %w", filepath.Base(path), err)
+	}
+
+	data := []byte(file.Record)
+	if len(file.Ciphertext) > 0 {
+		if data, err = sp.decrypt(ctx, file); err != nil {
+			return nil, fmt.Errorf("spool: 文件(%s)解密失败: %w", filepath.Base(path), err)
+		}
+	}
+
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("spool: 文件(%s)中的消息无效: %w", filepath.Base(path), err)
+	}
+	if rec.Message == nil {
+		return nil, fmt.Errorf("spool: 文件(%s)中没有消息", filepath.Base(path))
+	}
+	return &rec, nil
+}
+
+// encrypt 使用当前密钥加密记录, 随机数保存在密文之前
+func (sp *Spool) encrypt(ctx context.Context, data []byte) (spoolFile, error) {
+	keyId, key, err := sp.keys.CurrentKey(ctx)
+	if err != nil {
+		return spoolFile{}, fmt.Errorf("spool: 获取加密密钥失败: %w", err)
+	}
+
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return spoolFile{}, fmt.Errorf("spool: 加密密钥(%s)无效: %w", keyId, err)
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return spoolFile{}, fmt.Errorf("spool: 生成随机数失败: %w", err)
+	}
+
+	return spoolFile{
+		KeyId:      keyId,
+		Ciphertext: aead.Seal(nonce, nonce, data, []byte(keyId)),
+	}, nil
+}
+
+// decrypt 按照文件中的密钥ID解密记录
+func (sp *Spool) decrypt(ctx context.Context, file spoolFile) ([]byte, error) {
+	if sp.keys == nil {
+		return nil, ErrEncryptionKeyMissing
+	}
+
+	key, err := sp.keys.LookupKey(ctx, file.KeyId)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(file.Ciphertext) < aead.NonceSize() {
+		return nil, errors.New("密文太短")
+	}
+
+	nonce, ciphertext := file.Ciphertext[:aead.NonceSize()], file.Ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(file.KeyId))
+}
+
+// newAESGCM 创建 AES-GCM 加密器
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,146 @@
+package spool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/nf5lab/broker"
+	"github.com/nf5lab/ebus"
+)
+
+// recordingPublisher 记录已发布消息的发布者, 设置 err 时发布失败
+type recordingPublisher struct {
+	lock     sync.Mutex
+	err      error
+	topics   []string
+	messages []*broker.Message
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, topic string, message *broker.Message, opts ...broker.PublishOption) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.err != nil {
+		return p.err
+	}
+	p.topics = append(p.topics, topic)
+	p.messages = append(p.messages, message)
+	return nil
+}
+
+func (p *recordingPublisher) Close() error {
+	return nil
+}
+
+func newTestMessage(id string) *broker.Message {
+	return &broker.Message{
+		Id:          id,
+		Headers:     map[string]any{"x-user-email": "alice@example.com"},
+		Body:        []byte(`{"cardNumber":"4111111111111111"}`),
+		ContentType: "application/json",
+	}
+}
+
+func TestStoreEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	keys := ebus.StaticKeyProvider{KeyId: "k1", Key: bytes.Repeat([]byte{7}, 32)}
+
+	sp, err := New(dir, WithEncryption(keys))
+	if err != nil {
+		t.Fatalf("创建缓冲区失败: %v", err)
+	}
+	if err := sp.Store(context.Background(), "payments", newTestMessage("m1")); err != nil {
+		t.Fatalf("写入缓冲区失败: %v", err)
+	}
+
+	names, err := filepath.Glob(filepath.Join(dir, "*"+fileSuffix))
+	if err != nil || len(names) != 1 {
+		t.Fatalf("缓冲文件 = %v, %v, 期望 1 个", names, err)
+	}
+
+	content, err := os.ReadFile(names[0])
+	if err != nil {
+		t.Fatalf("读取缓冲文件失败: %v", err)
+	}
+	for _, plaintext := range []string{"4111111111111111", "alice@example.com", "payments"} {
+		if bytes.Contains(content, []byte(plaintext)) {
+			t.Errorf("缓冲文件包含明文 %q", plaintext)
+		}
+	}
+
+	// 没有密钥时不能转发已加密的文件
+	plain, _ := New(dir)
+	if _, err := plain.Forward(context.Background(), &recordingPublisher{}); !errors.Is(err, ErrEncryptionKeyMissing) {
+		t.Errorf("没有密钥时转发的错误 = %v, 期望 %v", err, ErrEncryptionKeyMissing)
+	}
+}
+
+func TestForwardInOrder(t *testing.T) {
+	keys := ebus.StaticKeyProvider{KeyId: "k1", Key: bytes.Repeat([]byte{7}, 32)}
+	sp, err := New(t.TempDir(), WithEncryption(keys))
+	if err != nil {
+		t.Fatalf("创建缓冲区失败: %v", err)
+	}
+
+	for _, id := range []string{"m1", "m2", "m3"} {
+		if err := sp.Store(context.Background(), "payments", newTestMessage(id)); err != nil {
+			t.Fatalf("写入缓冲区失败: %v", err)
+		}
+	}
+
+	// 消息队列仍然不可用时消息留在缓冲区中
+	unavailable := &recordingPublisher{err: errors.New("broker unavailable")}
+	if forwarded, err := sp.Forward(context.Background(), unavailable); err == nil || forwarded != 0 {
+		t.Fatalf("转发 = %d, %v, 期望失败", forwarded, err)
+	}
+
+	pub := &recordingPublisher{}
+	forwarded, err := sp.Forward(context.Background(), pub)
+	if err != nil || forwarded != 3 {
+		t.Fatalf("转发 = %d, %v, 期望 3", forwarded, err)
+	}
+
+	for i, id := range []string{"m1", "m2", "m3"} {
+		message := pub.messages[i]
+		if message.Id != id || pub.topics[i] != "payments" {
+			t.Errorf("第 %d 条消息 = %s/%s, 期望 payments/%s", i, pub.topics[i], message.Id, id)
+		}
+		if !bytes.Equal(message.Body, newTestMessage(id).Body) || message.Headers["x-user-email"] != "alice@example.com" {
+			t.Errorf("第 %d 条消息的内容不一致: %+v", i, message)
+		}
+	}
+
+	if pending, err := sp.Pending(); err != nil || pending != 0 {
+		t.Errorf("转发之后剩余 %d 条消息, %v", pending, err)
+	}
+}
+
+func TestWrapSpoolsOnFailure(t *testing.T) {
+	sp, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建缓冲区失败: %v", err)
+	}
+
+	inner := &recordingPublisher{err: errors.New("broker unavailable")}
+	publisher := sp.Wrap(inner)
+	if err := publisher.Publish(context.Background(), "payments", newTestMessage("m1")); err != nil {
+		t.Fatalf("写入缓冲区之后发布不应该失败: %v", err)
+	}
+
+	if pending, err := sp.Pending(); err != nil || pending != 1 {
+		t.Fatalf("缓冲区中有 %d 条消息, %v, 期望 1", pending, err)
+	}
+
+	inner.err = nil
+	if err := publisher.Publish(context.Background(), "payments", newTestMessage("m2")); err != nil {
+		t.Fatalf("发布失败: %v", err)
+	}
+	if pending, _ := sp.Pending(); pending != 1 {
+		t.Errorf("发布成功时不应该写入缓冲区, 缓冲区中有 %d 条消息", pending)
+	}
+}
@@ -3,13 +3,23 @@ package ebus
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"log/slog"
 	"runtime/debug"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/nf5lab/broker"
 )
 
+var (
+	// ErrEventTooOld 事件超过了允许的最大年龄
+	ErrEventTooOld = errors.New("ebus: 事件已过期")
+)
+
 // EventHandler 事件处理函数
 //
 // - 处理成功返回 nil
@@ -22,9 +32,24 @@ type Subscriber interface {
 	// Subscribe 订阅事件
 	Subscribe(ctx context.Context, topic string, group string, handler EventHandler) (string, error)
 
+	// SubscribeRaw 以原始模式订阅事件
+	//
+	// 不查找事件工厂, 也不解码负载, 用于归档, 转发, 调试等通用工具消费本地没有注册事件类型的主题
+	// 中间件, 进程内分发器和重复检测等只作用于 Subscribe 的功能不会生效
+	SubscribeRaw(ctx context.Context, topic string, group string, handler RawEventHandler) (string, error)
+
 	// Unsubscribe 取消订阅
 	Unsubscribe(ctx context.Context, subscriptionId string) error
 
+	// Pause 暂停订阅, 用于下游故障时主动卸载负载
+	Pause(subscriptionId string) error
+
+	// Resume 恢复订阅
+	Resume(subscriptionId string) error
+
+	// IsPaused 订阅是否已暂停
+	IsPaused(subscriptionId string) (bool, error)
+
 	// Close 关闭订阅者 (不会执行任何操作)
 	//
 	// Deprecated: 此方法已废弃, 将在未来版本中移除
@@ -33,145 +58,699 @@ type Subscriber interface {
 }
 
 type subscriber struct {
-	inner broker.Subscriber
+	inner   broker.Subscriber
+	options *Options
+
+	lock          sync.Mutex
+	subscriptions map[string]*subscription // 订阅ID -> 订阅信息
 }
 
 // NewSubscriber 创建订阅者
-func NewSubscriber(brokerSubscriber broker.Subscriber) Subscriber {
+func NewSubscriber(brokerSubscriber broker.Subscriber, opts ...Option) Subscriber {
 	return &subscriber{
-		inner: brokerSubscriber,
+		inner:         brokerSubscriber,
+		options:       NewOptions(opts...),
+		subscriptions: make(map[string]*subscription),
 	}
 }
 
 // decodeEvent 解码事件
-func (sub *subscriber) decodeEvent(data []byte) (Event, error) {
+// - factories 订阅的事件工厂缓存
+// - codec     消息内容类型对应的编解码器
+// - headers   消息头, 编解码器的消息体只包含事件负载时从中读取元数据
+//
+// 开启 SkipPayloadValidation 时, 事件校验失败不会中断解码, 校验错误通过 validationErr 返回
+func (sub *subscriber) decodeEvent(factories *factoryCache, codec Codec, headers map[string]any, data []byte) (event Event, validationErr error, err error) {
+	if len(data) == 0 {
+		return nil, nil, ErrEmptyBody
+	}
+
+	envelope, err := decodeEnvelopeWith(codec, headers, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sub.decodeEnvelope(factories, codec, envelope)
+}
+
+// decodeContainer 解码事件容器, 返回每个事件信封的原始数据
+func (sub *subscriber) decodeContainer(data []byte) ([]json.RawMessage, error) {
 	if len(data) == 0 {
-		return nil, fmt.Errorf("ebus: 事件数据为空")
+		return nil, ErrEmptyBody
+	}
+
+	var container struct {
+		Envelopes []json.RawMessage `json:"envelopes"`
+	}
+	if err := json.Unmarshal(data, &container); err != nil {
+		return nil, fmt.Errorf("%w: 事件容器: %w", ErrEnvelopeDecode, err)
 	}
 
-	var envelope Envelope
-	if err := json.Unmarshal(data, &envelope); err != nil {
-		return nil, fmt.Errorf("ebus: 事件信封解码失败: %w", err)
+	if len(container.Envelopes) == 0 {
+		return nil, fmt.Errorf("%w: 事件容器为空", ErrEnvelopeDecode)
+	}
+
+	return container.Envelopes, nil
+}
+
+// decodeEnvelope 从事件信封解码事件
+// - factories 订阅的事件工厂缓存
+// - codec     消息内容类型对应的编解码器
+func (sub *subscriber) decodeEnvelope(factories *factoryCache, codec Codec, envelope *Envelope) (event Event, validationErr error, err error) {
+	if envelope == nil {
+		return nil, nil, fmt.Errorf("%w: 事件信封为空", ErrEnvelopeDecode)
 	}
 
 	metadata := envelope.Metadata
 	if metadata == nil {
-		return nil, fmt.Errorf("ebus: 事件信封元数据为空")
+		return nil, nil, fmt.Errorf("%w: 事件信封元数据为空", ErrMetadataInvalid)
 	}
 
-	if err := metadata.Validate(); err != nil {
-		return nil, fmt.Errorf("ebus: 事件信封元数据无效: %w", err)
+	if err := sub.options.validateMetadata(metadata); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrMetadataInvalid, err)
 	}
 
 	if len(envelope.Payload) == 0 {
-		return nil, fmt.Errorf("ebus: 事件信封负载为空")
+		return nil, nil, fmt.Errorf("%w: 事件信封负载为空", ErrPayloadDecode)
 	}
 
-	factory, err := GetEventFactory(metadata.SchemaVersion, metadata.EventSource, metadata.EventType)
+	factory, err := factories.get(metadata.SchemaVersion, metadata.EventSource, metadata.EventType)
 	if err != nil {
-		return nil, fmt.Errorf("ebus: 获取事件工厂失败: %w", err)
+		return nil, nil, fmt.Errorf("ebus: 获取事件工厂失败: %w", err)
+	}
+
+	if err := factories.registry.validatePayloadSchema(metadata, codec, envelope.Payload); err != nil {
+		validationErr = fmt.Errorf("ebus: 事件(%s)无效: %w", metadata.EventId, err)
+		if !sub.options.SkipPayloadValidation {
+			return nil, nil, validationErr
+		}
 	}
 
-	event, err := factory()
+	event, err = factory()
 	if err != nil {
-		return nil, fmt.Errorf("ebus: 创建事件实例失败: %w", err)
+		return nil, nil, fmt.Errorf("ebus: 创建事件实例失败: %w", err)
 	}
 
-	if err := json.Unmarshal(envelope.Payload, event); err != nil {
-		return nil, fmt.Errorf("ebus: 事件(%s)解码失败: %w", metadata.EventId, err)
+	if err := codec.Unmarshal(envelope.Payload, event); err != nil {
+		return nil, nil, fmt.Errorf("%w: 事件(%s): %w", ErrPayloadDecode, metadata.EventId, err)
 	}
 
-	if err := event.Validate(); err != nil {
-		return nil, fmt.Errorf("ebus: 事件(%s)无效: %w", metadata.EventId, err)
+	if setter, ok := event.(EnvelopeMetadataSetter); ok {
+		setter.SetEnvelopeMetadata(metadata)
+	}
+
+	if err := event.Validate(); err != nil && validationErr == nil {
+		validationErr = fmt.Errorf("ebus: 事件(%s)无效: %w", metadata.EventId, err)
+		if !sub.options.SkipPayloadValidation {
+			return nil, nil, validationErr
+		}
 	}
 
 	eventMetadata := event.Metadata()
 	if eventMetadata == nil {
-		return nil, fmt.Errorf("ebus: 事件(%s)元数据为空", metadata.EventId)
+		return nil, nil, fmt.Errorf("%w: 事件(%s)元数据为空", ErrMetadataMismatch, metadata.EventId)
 	}
 
 	if eventMetadata.SchemaVersion != metadata.SchemaVersion {
-		return nil, fmt.Errorf("ebus: 事件(%s)元数据[模型版本]不匹配", metadata.EventId)
+		return nil, nil, fmt.Errorf("%w: 事件(%s)[模型版本]", ErrMetadataMismatch, metadata.EventId)
 	}
 
 	if eventMetadata.EventId != metadata.EventId {
-		return nil, fmt.Errorf("ebus: 事件(%s)元数据[事件ID]不匹配", metadata.EventId)
+		return nil, nil, fmt.Errorf("%w: 事件(%s)[事件ID]", ErrMetadataMismatch, metadata.EventId)
 	}
 
 	if eventMetadata.EventSource != metadata.EventSource {
-		return nil, fmt.Errorf("ebus: 事件(%s)元数据[事件来源]不匹配", metadata.EventId)
+		return nil, nil, fmt.Errorf("%w: 事件(%s)[事件来源]", ErrMetadataMismatch, metadata.EventId)
 	}
 
 	if eventMetadata.EventType != metadata.EventType {
-		return nil, fmt.Errorf("ebus: 事件(%s)元数据[事件类型]不匹配", metadata.EventId)
+		return nil, nil, fmt.Errorf("%w: 事件(%s)[事件类型]", ErrMetadataMismatch, metadata.EventId)
 	}
 
 	// 忽略事件时间的检查
 
-	return event, nil
+	event, err = sub.options.Registry.Upcast(event)
+	return event, validationErr, err
 }
 
-// Subscribe 订阅事件
-func (sub *subscriber) Subscribe(ctx context.Context, topic string, group string, handler EventHandler) (string, error) {
-	topic = strings.TrimSpace(topic)
-	if len(topic) == 0 {
-		return "", fmt.Errorf("ebus: 订阅主题不能为空")
+// handlerPanicError 事件处理函数发生 panic 的错误
+type handlerPanicError struct {
+	info  any
+	stack []byte
+}
+
+func (err *handlerPanicError) Error() string {
+	return fmt.Sprintf("ebus: 事件处理函数发生 panic: %v\n\n%s", err.info, err.stack)
+}
+
+// callHandler 调用事件处理函数, 并将 panic 转换为错误
+func callHandler(ctx context.Context, handler EventHandler, topic string, event Event) (finalErr error) {
+	defer func() {
+		if panicInfo := recover(); panicInfo != nil {
+			finalErr = &handlerPanicError{info: panicInfo, stack: debug.Stack()}
+		}
+	}()
+
+	return handler(ctx, topic, event)
+}
+
+// onDecodeFailed 记录解码失败
+func (sub *subscriber) onDecodeFailed(ctx context.Context, topic string, delivery *broker.Delivery, err error) {
+	sub.options.Metrics.IncDecodeFailed(topic)
+	sub.options.Logger.ErrorContext(ctx, "ebus: 事件解码失败",
+		slog.String("topic", topic),
+		slog.String("messageId", delivery.Message.Id),
+		slog.Any("error", err),
+	)
+}
+
+// dispatch 把解码后的事件交给处理函数
+// - envelope 事件信封的原始数据, 其长度作为消息体大小
+func (sub *subscriber) dispatch(ctx context.Context, topic string, delivery *broker.Delivery, event Event, envelope []byte, handler EventHandler) error {
+	metrics := sub.options.Metrics
+	logger := sub.options.Logger
+
+	metadata := event.Metadata()
+	metrics.IncConsumed(topic, metadata)
+	metrics.ObservePayloadSize(topic, metadata, len(envelope))
+
+	logAttrs := append(metadataLogAttrs(metadata), slog.String("topic", topic), slog.Int("attempts", delivery.Attempts))
+	if delivery.IsRetry() {
+		logger.InfoContext(ctx, "ebus: 事件重试", logAttrs...)
 	}
 
-	group = strings.TrimSpace(group)
-	if len(group) == 0 {
-		return "", fmt.Errorf("ebus: 订阅组不能为空")
+	ctx = contextWithConsumedEvent(ctx, delivery, envelope, metadata)
+
+	var eventCtx *EventContext
+	if sub.options.AckMode == AckModeManual {
+		eventCtx = newEventContext()
+		ctx = context.WithValue(ctx, eventContextKey{}, eventCtx)
 	}
 
-	if handler == nil {
-		return "", fmt.Errorf("ebus: 事件处理函数不能为空")
+	startTime := time.Now()
+	err := callHandler(ctx, handler, topic, event)
+	if err == nil && eventCtx != nil {
+		err = eventCtx.wait(ctx)
+	}
+	metrics.ObserveHandlerDuration(topic, metadata, time.Since(startTime))
+
+	if err != nil {
+		metrics.IncFailed(topic, metadata)
+
+		wrappedErr := fmt.Errorf("ebus: 事件(%s)处理失败: %w", metadata.EventId, err)
+
+		var panicErr *handlerPanicError
+		if errors.As(err, &panicErr) {
+			logger.ErrorContext(ctx, "ebus: 事件处理函数发生 panic",
+				append(logAttrs,
+					slog.Any("event", Redacted(event)),
+					slog.Any("panic", panicErr.info),
+					slog.String("stack", string(panicErr.stack)),
+				)...,
+			)
+			return sub.applyPanicPolicy(ctx, topic, event, panicErr, wrappedErr)
+		}
+
+		logger.WarnContext(ctx, "ebus: 事件处理失败", append(logAttrs, slog.Any("event", Redacted(event)), slog.Any("error", err))...)
+		return wrappedErr
 	}
 
-	wrapHandler := func(ctx context.Context, delivery *broker.Delivery) (finalErr error) {
+	return nil
+}
+
+// subscription 订阅信息
+type subscription struct {
+	id         string
+	topic      string
+	group      string
+	handler    EventHandler    // 已经组合了中间件的处理函数
+	rawHandler RawEventHandler // 原始模式的处理函数, 设置后不再解码事件, 忽略 handler
+	watch      *watchEntry     // 看门狗记录, 可以为 nil
+
+	dispatchers  []*dispatcher          // 进程内分发器, 每个分发器是一条串行的通道, 为空表示直接处理
+	controlTypes map[EventType]struct{} // 控制事件类型
+	orderingKey  OrderingKeyFunc        // 排序键函数, 可以为 nil
+
+	gate     *flowGate    // 流量闸门, 可以为 nil
+	throttle *tokenBucket // 消费速率限制, 可以为 nil
+
+	processed *lruSet // 最近成功处理的事件ID, 用于检测重复投递, 可以为 nil
+
+	factories *factoryCache // 事件工厂缓存
+}
+
+// close 释放订阅持有的资源
+func (subs *subscription) close() {
+	for _, d := range subs.dispatchers {
+		d.close()
+	}
+}
+
+// dispatcherFor 选择处理事件的分发器
+//
+// 同一个排序键的事件总是进入同一个分发器, 从而串行处理
+func (subs *subscription) dispatcherFor(event Event) *dispatcher {
+	if len(subs.dispatchers) == 1 || subs.orderingKey == nil {
+		return subs.dispatchers[0]
+	}
+
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(subs.orderingKey(event)))
+	return subs.dispatchers[hash.Sum32()%uint32(len(subs.dispatchers))]
+}
+
+// checkEventAge 检查事件年龄
+//
+// 过期的事件返回不可重试的错误, 由消息队列丢弃或者转入死信队列
+func (sub *subscriber) checkEventAge(ctx context.Context, topic string, metadata *Metadata) error {
+	maxAge := sub.options.MaxEventAge
+	if maxAge <= 0 {
+		return nil
+	}
+
+	age := sub.options.clock().Now().Sub(time.Unix(metadata.EventTime, 0))
+	if age <= maxAge {
+		return nil
+	}
+
+	sub.options.Logger.WarnContext(ctx, "ebus: 事件已过期",
+		append(metadataLogAttrs(metadata), slog.String("topic", topic), slog.Duration("age", age))...,
+	)
+
+	return broker.NewNonRetryableError(fmt.Errorf("%w: 事件(%s)年龄(%s)超过(%s)", ErrEventTooOld, metadata.EventId, age, maxAge))
+}
+
+// route 把事件交给进程内分发器, 没有分发器时直接处理
+// - envelope 事件信封的原始数据
+func (sub *subscriber) route(ctx context.Context, subs *subscription, topic string, delivery *broker.Delivery, event Event, envelope []byte) error {
+	metadata := event.Metadata()
+
+	sub.options.Metrics.IncSchemaReceived(topic, metadata)
+	if telemetry := sub.options.SchemaTelemetry; telemetry != nil {
+		telemetry.record(topic, metadata, sub.options.clock().Now())
+	}
+
+	if err := sub.checkEventAge(ctx, topic, metadata); err != nil {
+		return err
+	}
+
+	if matched, err := sub.checkTenant(ctx, topic, metadata); !matched {
+		return err
+	}
+
+	if subs.processed != nil && subs.processed.contains(metadata.EventId) {
+		sub.options.Metrics.IncDuplicate(topic, metadata)
+		if onDuplicate := sub.options.OnDuplicate; onDuplicate != nil {
+			onDuplicate(ctx, topic, event)
+		}
+	}
+
+	startTime := time.Now()
+
+	var err error
+	if len(subs.dispatchers) == 0 {
+		err = sub.dispatch(ctx, topic, delivery, event, envelope, subs.handler)
+	} else {
+		_, control := subs.controlTypes[metadata.EventType]
+		err = subs.dispatcherFor(event).submit(ctx, control, func(ctx context.Context) error {
+			return sub.dispatch(ctx, topic, delivery, event, envelope, subs.handler)
+		})
+	}
+
+	sub.publishResult(ctx, subs, topic, delivery, metadata, time.Since(startTime), err)
+
+	// 只记录成功处理的事件, 失败后的重试不算重复投递
+	if err == nil && subs.processed != nil {
+		subs.processed.add(metadata.EventId)
+	}
+
+	return err
+}
+
+// process 解码并分发一个事件信封
+func (sub *subscriber) process(ctx context.Context, subs *subscription, topic string, delivery *broker.Delivery, codec Codec, envelope []byte) error {
+	if subs.rawHandler != nil {
+		return sub.routeRaw(ctx, topic, delivery, codec, subs.rawHandler, envelope)
+	}
+
+	event, validationErr, err := sub.decodeEvent(subs.factories, codec, delivery.Message.Headers, envelope)
+	if err != nil {
+		if errors.Is(err, ErrEventFactoryNotFound) {
+			return sub.handleUnknownEvent(ctx, subs, topic, delivery, codec, envelope, err)
+		}
+
+		sub.onDecodeFailed(ctx, topic, delivery, err)
+		return err
+	}
+
+	if validationErr != nil {
+		sub.options.Logger.WarnContext(ctx, "ebus: 跳过事件校验",
+			append(metadataLogAttrs(event.Metadata()), slog.String("topic", topic), slog.Any("error", validationErr))...,
+		)
+		ctx = context.WithValue(ctx, validationErrorContextKey{}, validationErr)
+	}
+
+	err = sub.route(ctx, subs, topic, delivery, event, envelope)
+
+	// 消息队列把投递的消息转入死信队列, 附加脱敏之后的负载作为诊断信息
+	if broker.IsNonRetryableError(err) {
+		if data, redactErr := RedactJSON(event); redactErr == nil {
+			delivery.Message.AddHeader(HeaderRedactedPayload, string(data))
+		}
+	}
+	return err
+}
+
+// handleDelivery 处理一次投递: 解码并分发事件
+func (sub *subscriber) handleDelivery(ctx context.Context, subs *subscription, delivery *broker.Delivery) (finalErr error) {
+	if recorder := sub.options.Recorder; recorder != nil && delivery != nil {
+		startTime := time.Now()
 		defer func() {
-			if panicInfo := recover(); panicInfo != nil {
-				finalErr = fmt.Errorf("ebus: 事件处理函数发生 panic: %v\n\n%s", panicInfo, debug.Stack())
+			if err := recorder.record(delivery, time.Since(startTime), finalErr); err != nil {
+				sub.options.Logger.WarnContext(ctx, "ebus: 写入消费记录失败", slog.Any("error", err))
 			}
 		}()
+	}
+
+	defer func() {
+		if panicInfo := recover(); panicInfo != nil {
+			// 按照 PanicPolicyCrash 抛出的 panic 不做转换
+			if crash, ok := panicInfo.(handlerCrash); ok {
+				panic(crash)
+			}
+			finalErr = fmt.Errorf("ebus: 事件处理函数发生 panic: %v\n\n%s", panicInfo, debug.Stack())
+		}
+	}()
+
+	if delivery == nil {
+		return fmt.Errorf("ebus: 接收到空的投递")
+	}
 
-		if delivery == nil {
-			return fmt.Errorf("ebus: 接收到空的投递")
+	if subs.gate != nil {
+		leave, err := subs.gate.enter(ctx)
+		if err != nil {
+			return err
+		}
+		defer leave()
+	}
+
+	if subs.throttle != nil {
+		if err := subs.throttle.wait(ctx, time.Now()); err != nil {
+			return err
 		}
+	}
+
+	if subs.watch != nil {
+		subs.watch.touchReceived()
+	}
+
+	msgTopic := strings.TrimSpace(delivery.Topic)
+	if len(msgTopic) == 0 {
+		msgTopic = subs.topic
+	}
+
+	if len(msgTopic) == 0 && !sub.options.AllowEmptyTopic {
+		return fmt.Errorf("ebus: 接收到空的主题")
+	}
+
+	if len(delivery.Message.Body) == 0 {
+		return ErrEmptyBody
+	}
 
-		msgTopic := strings.TrimSpace(delivery.Topic)
-		if len(msgTopic) == 0 {
-			return fmt.Errorf("ebus: 接收到空的主题")
+	// 在解码之前按消息头过滤, 跳过的消息视为处理成功
+	if filter := sub.options.HeaderFilter; filter != nil && !filter(delivery.Message.Headers) {
+		if subs.watch != nil {
+			subs.watch.touchProcessed()
 		}
+		return nil
+	}
+
+	body, err := sub.options.decryptBody(ctx, &delivery.Message)
+	if err != nil {
+		sub.onDecodeFailed(ctx, msgTopic, delivery, err)
+		return err
+	}
+
+	body, err = sub.options.decompressBody(&delivery.Message, body)
+	if err != nil {
+		sub.onDecodeFailed(ctx, msgTopic, delivery, err)
+		return err
+	}
 
-		if len(delivery.Message.Body) == 0 {
-			return fmt.Errorf("ebus: 接收到空的消息体")
+	if err := verifyChecksum(delivery.Message.Headers, body); err != nil {
+		sub.onDecodeFailed(ctx, msgTopic, delivery, err)
+		return err
+	}
+
+	if err := sub.options.verifySignature(ctx, &delivery.Message, body); err != nil {
+		sub.onDecodeFailed(ctx, msgTopic, delivery, err)
+		return err
+	}
+
+	contentType := delivery.Message.ContentType
+	contentType = strings.TrimSpace(contentType)
+	contentType = strings.ToLower(contentType)
+
+	switch {
+	case strings.HasPrefix(contentType, ContentTypeContainerJson):
+		envelopes, err := sub.decodeContainer(body)
+		if err != nil {
+			sub.onDecodeFailed(ctx, msgTopic, delivery, err)
+			return err
 		}
 
-		contentType := delivery.Message.ContentType
-		contentType = strings.TrimSpace(contentType)
-		contentType = strings.ToLower(contentType)
-		if !strings.HasPrefix(contentType, ContentTypeJson) {
+		// 事件容器中的事件信封总是 JSON
+		codec, _ := sub.options.codecFor(ContentTypeJson)
+
+		// 逐个分发事件, 任何一个失败都会导致整条消息重新投递
+		for _, envelope := range envelopes {
+			if err := sub.process(ctx, subs, msgTopic, delivery, codec, envelope); err != nil {
+				return err
+			}
+		}
+
+	default:
+		codec, exists := sub.options.codecFor(contentType)
+		if isCloudEventsBinary(delivery.Message.Headers) {
+			codec, exists = CloudEventsBinaryCodec{}, true
+		}
+		if !exists {
 			return fmt.Errorf("ebus: 不支持的内容类型: %s", contentType)
 		}
 
-		event, err := sub.decodeEvent(delivery.Message.Body)
-		if err != nil {
+		if err := sub.process(ctx, subs, msgTopic, delivery, codec, body); err != nil {
 			return err
 		}
+	}
+
+	if subs.watch != nil {
+		subs.watch.touchProcessed()
+	}
+
+	return nil
+}
+
+// Subscribe 订阅事件
+func (sub *subscriber) Subscribe(ctx context.Context, topic string, group string, handler EventHandler) (string, error) {
+	if handler == nil {
+		return "", fmt.Errorf("ebus: 事件处理函数不能为空")
+	}
+
+	return sub.subscribe(ctx, topic, group, sub.options.buildHandler(handler), nil)
+}
+
+// SubscribeRaw 以原始模式订阅事件
+func (sub *subscriber) SubscribeRaw(ctx context.Context, topic string, group string, handler RawEventHandler) (string, error) {
+	if handler == nil {
+		return "", fmt.Errorf("ebus: 事件处理函数不能为空")
+	}
+
+	return sub.subscribe(ctx, topic, group, nil, handler)
+}
+
+// newSubscription 创建订阅信息, 设置与消息队列无关的订阅级别状态
+//
+// 订阅和回放 (参考 ReplayRecord) 共用, 保证两者的处理流程一致
+// - topic      解析之后的主题
+// - handler    已经组合了中间件的处理函数
+// - rawHandler 原始模式的处理函数, 设置后忽略 handler
+func newSubscription(options *Options, topic string, handler EventHandler, rawHandler RawEventHandler) *subscription {
+	subs := &subscription{
+		topic:      topic,
+		handler:    handler,
+		rawHandler: rawHandler,
+		gate:       newFlowGate(options.MaxInFlight),
+		factories:  newFactoryCache(options.Registry),
+	}
 
-		if err := handler(ctx, msgTopic, event); err != nil {
-			return fmt.Errorf("ebus: 事件(%s)处理失败: %w", event.Metadata().EventId, err)
+	if rate := options.ConsumeRateLimit; rate > 0 {
+		// 限流会真实地等待, 所以使用系统时间而不是选项中的时钟
+		subs.throttle = newTokenBucket(rate, 1, time.Now())
+	}
+
+	if options.DuplicateWindow > 0 {
+		subs.processed = newLRUSet(options.DuplicateWindow)
+	}
+
+	if len(options.ControlEventTypes) > 0 {
+		subs.controlTypes = make(map[EventType]struct{}, len(options.ControlEventTypes))
+		for _, evtType := range options.ControlEventTypes {
+			subs.controlTypes[evtType.Normalize()] = struct{}{}
 		}
+	}
 
-		return nil
+	return subs
+}
+
+// subscribe 订阅主题
+// - handler    已经组合了中间件的处理函数
+// - rawHandler 原始模式的处理函数, 设置后忽略 handler
+func (sub *subscriber) subscribe(ctx context.Context, topic string, group string, handler EventHandler, rawHandler RawEventHandler) (string, error) {
+	topic, err := sub.options.resolveTopic(topic)
+	if err != nil {
+		return "", err
+	}
+
+	group, err = sub.options.resolveGroup(group)
+	if err != nil {
+		return "", err
+	}
+
+	if err := sub.options.authorizeSubscribe(ctx, topic, group); err != nil {
+		return "", err
+	}
+
+	rebalanceHandler := sub.options.RebalanceHandler
+
+	var notifier RebalanceNotifier
+	if rebalanceHandler != nil {
+		var ok bool
+		if notifier, ok = sub.inner.(RebalanceNotifier); !ok {
+			return "", ErrRebalanceNotSupported
+		}
+	}
+
+	watchdog := sub.options.Watchdog
+
+	var watch *watchEntry
+	if watchdog != nil {
+		watch = watchdog.newEntry(topic, group)
+	}
+
+	subs := newSubscription(sub.options, topic, handler, rawHandler)
+	subs.group = group
+	subs.watch = watch
+
+	queueSize := sub.options.QueueSize
+	overflow := sub.options.OverflowPolicy
+
+	// 分发器等待处理完成之后才返回, 所以消息队列的并发处理数决定了同时进入分发器的投递数量
+	// - 0 表示使用消息队列的默认值
+	concurrency := 0
+
+	switch {
+	case sub.options.OrderingKey != nil:
+		// 每个串行通道使用单个工作协程, 保证通道内串行处理
+		// 并发处理数至少等于通道数量, 否则不同排序键的事件无法并发处理
+		subs.orderingKey = sub.options.OrderingKey
+		for range sub.options.OrderingLanes {
+			subs.dispatchers = append(subs.dispatchers, newDispatcher(1, queueSize, overflow))
+		}
+		concurrency = sub.options.OrderingLanes
+	case sub.options.Workers > 0:
+		// 并发处理数等于工作协程数量与队列容量之和, 工作协程忙碌时投递才能在队列中等待
+		subs.dispatchers = append(subs.dispatchers, newDispatcher(sub.options.Workers, queueSize, overflow))
+		concurrency = sub.options.Workers + queueSize
+	case len(subs.controlTypes) > 0:
+		// 使用单个工作协程, 保证控制事件严格优先
+		// 数据事件必须能够在队列中等待, 否则控制事件没有机会越过它们
+		if queueSize <= 0 {
+			queueSize = DefaultControlQueueSize
+		}
+		subs.dispatchers = append(subs.dispatchers, newDispatcher(1, queueSize, overflow))
+		concurrency = 1 + queueSize
+	}
+
+	wrapHandler := func(ctx context.Context, delivery *broker.Delivery) error {
+		err := sub.handleDelivery(ctx, subs, delivery)
+		return sub.handleRetryAfter(ctx, delivery, err)
+	}
+
+	// 直接处理时, 消息队列的并发处理数就是处理中的投递数量的上限
+	if concurrency == 0 {
+		concurrency = sub.options.MaxInFlight
+	}
+
+	if sub.options.SubscribeConcurrency > 0 {
+		concurrency = sub.options.SubscribeConcurrency
+	}
+
+	subscribeOpts := []broker.SubscribeOption{broker.WithSubscribeGroup(group)}
+	if concurrency > 0 {
+		subscribeOpts = append(subscribeOpts, broker.WithSubscribeConcurrency(concurrency))
+	}
+
+	subscriptionId, err := sub.inner.Subscribe(ctx, topic, wrapHandler, subscribeOpts...)
+	if err != nil {
+		subs.close()
+		return "", err
+	}
+
+	if notifier != nil {
+		err := notifier.NotifyRebalance(subscriptionId, func(ctx context.Context, assigned []int32, revoked []int32) {
+			rebalanceHandler(ctx, RebalanceEvent{
+				SubscriptionId: subscriptionId,
+				Topic:          topic,
+				Group:          group,
+				Assigned:       assigned,
+				Revoked:        revoked,
+			})
+		})
+
+		if err != nil {
+			_ = sub.inner.Unsubscribe(ctx, subscriptionId)
+			subs.close()
+			return "", fmt.Errorf("ebus: 注册再均衡回调失败: %w", err)
+		}
 	}
 
-	return sub.inner.Subscribe(ctx, topic, wrapHandler, broker.WithSubscribeGroup(group))
+	if watchdog != nil {
+		watchdog.add(subscriptionId, watch)
+	}
+
+	if pauser, ok := sub.inner.(PauseResumer); ok {
+		subs.gate.bind(pauser, subscriptionId)
+	}
+
+	subs.id = subscriptionId
+
+	sub.lock.Lock()
+	sub.subscriptions[subscriptionId] = subs
+	sub.lock.Unlock()
+
+	return subscriptionId, nil
 }
 
 // Unsubscribe 取消订阅
 func (sub *subscriber) Unsubscribe(ctx context.Context, subscriptionId string) error {
-	return sub.inner.Unsubscribe(ctx, subscriptionId)
+	if err := sub.inner.Unsubscribe(ctx, subscriptionId); err != nil {
+		return err
+	}
+
+	if watchdog := sub.options.Watchdog; watchdog != nil {
+		watchdog.remove(subscriptionId)
+	}
+
+	sub.lock.Lock()
+	subs, exists := sub.subscriptions[subscriptionId]
+	delete(sub.subscriptions, subscriptionId)
+	sub.lock.Unlock()
+
+	if exists {
+		subs.close()
+	}
+
+	return nil
 }
 
 // Close 关闭订阅者 (不会执行任何操作)
@@ -0,0 +1,354 @@
+package ebus_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nf5lab/ebus"
+	"github.com/nf5lab/ebus/memory"
+)
+
+// accountEvent 订阅测试使用的事件
+type accountEvent struct {
+	Meta      *ebus.Metadata `json:"metadata"`
+	AccountId string         `json:"accountId"`
+	Sequence  int            `json:"sequence"`
+}
+
+func (evt *accountEvent) Metadata() *ebus.Metadata {
+	return evt.Meta
+}
+
+func (evt *accountEvent) Validate() error {
+	return nil
+}
+
+func newAccountEvent(accountId string, sequence int) *accountEvent {
+	return &accountEvent{
+		Meta:      ebus.NewMetadata("ebus.test", "account.changed", "v1"),
+		AccountId: accountId,
+		Sequence:  sequence,
+	}
+}
+
+// newAccountRegistry 创建注册了测试事件的注册表
+func newAccountRegistry(t testing.TB) *ebus.Registry {
+	t.Helper()
+
+	registry := ebus.NewRegistry()
+	if err := ebus.RegisterEventIn[accountEvent](registry, "v1", "ebus.test", "account.changed"); err != nil {
+		t.Fatalf("注册测试事件失败: %v", err)
+	}
+	return registry
+}
+
+// concurrencyProbe 记录处理函数的并发度
+type concurrencyProbe struct {
+	lock      sync.Mutex
+	active    int
+	maxActive int
+}
+
+// enter 进入处理函数, 返回离开时调用的函数
+func (probe *concurrencyProbe) enter() func() {
+	probe.lock.Lock()
+	probe.active++
+	probe.maxActive = max(probe.maxActive, probe.active)
+	probe.lock.Unlock()
+
+	return func() {
+		probe.lock.Lock()
+		probe.active--
+		probe.lock.Unlock()
+	}
+}
+
+func (probe *concurrencyProbe) max() int {
+	probe.lock.Lock()
+	defer probe.lock.Unlock()
+
+	return probe.maxActive
+}
+
+// receiveN 从通道接收 count 个值, 超时说明处理函数没有按照期望被调用
+func receiveN[T any](t *testing.T, ch <-chan T, count int) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for i := range count {
+		select {
+		case <-ch:
+		case <-timeout:
+			t.Fatalf("等待第 %d 个值超时, 期望 %d 个", i+1, count)
+		}
+	}
+}
+
+func TestOrderingKeyConcurrency(t *testing.T) {
+	const (
+		keys   = 8
+		rounds = 4
+	)
+
+	bus := memory.NewAsync(
+		ebus.WithRegistry(newAccountRegistry(t)),
+		ebus.WithOrderingKey(func(event ebus.Event) string {
+			return event.(*accountEvent).AccountId
+		}),
+	)
+	defer bus.Close()
+
+	var (
+		probe    concurrencyProbe
+		lock     sync.Mutex
+		active   = make(map[string]bool)
+		received = make(map[string][]int)
+		entered  = make(chan struct{}, keys*rounds)
+		release  = make(chan struct{})
+	)
+
+	handler := func(ctx context.Context, topic string, event ebus.Event) error {
+		evt := event.(*accountEvent)
+
+		lock.Lock()
+		if active[evt.AccountId] {
+			t.Errorf("账户(%s)的事件被并发处理", evt.AccountId)
+		}
+		active[evt.AccountId] = true
+		lock.Unlock()
+
+		leave := probe.enter()
+		entered <- struct{}{}
+		// 第一轮的事件等待放行, 不同账户的事件同时处于处理中
+		if evt.Sequence == 0 {
+			<-release
+		}
+		leave()
+
+		lock.Lock()
+		active[evt.AccountId] = false
+		received[evt.AccountId] = append(received[evt.AccountId], evt.Sequence)
+		lock.Unlock()
+		return nil
+	}
+
+	if _, err := bus.Subscribe(context.Background(), "accounts", "ledger", handler); err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+
+	// 上一轮的事件全部开始处理之后才发布下一轮, 保证同一个账户的事件按照发布顺序到达
+	for round := range rounds {
+		for key := range keys {
+			event := newAccountEvent(fmt.Sprintf("account-%d", key), round)
+			if err := bus.Publish(context.Background(), "accounts", event); err != nil {
+				t.Fatalf("发布事件失败: %v", err)
+			}
+		}
+
+		if round == 0 {
+			// 两个不同账户的事件同时处于处理中之后放行
+			receiveN(t, entered, 2)
+			close(release)
+			receiveN(t, entered, keys-2)
+		} else {
+			receiveN(t, entered, keys)
+		}
+	}
+	bus.Wait()
+
+	if got := probe.max(); got < 2 {
+		t.Errorf("最大并发度 = %d, 期望不同账户的事件并发处理", got)
+	}
+
+	for key := range keys {
+		accountId := fmt.Sprintf("account-%d", key)
+		sequences := received[accountId]
+		if len(sequences) != rounds {
+			t.Fatalf("账户(%s)收到 %d 个事件, 期望 %d", accountId, len(sequences), rounds)
+		}
+		for i, sequence := range sequences {
+			if sequence != i {
+				t.Fatalf("账户(%s)的处理顺序 = %v, 期望按照发布顺序", accountId, sequences)
+			}
+		}
+	}
+}
+
+// blockingHandler 进入时通知 entered, 然后等待 release 关闭
+func blockingHandler(probe *concurrencyProbe, entered chan<- struct{}, release <-chan struct{}) ebus.EventHandler {
+	return func(ctx context.Context, topic string, event ebus.Event) error {
+		leave := probe.enter()
+		defer leave()
+
+		entered <- struct{}{}
+		<-release
+		return nil
+	}
+}
+
+func TestWorkerPoolConcurrency(t *testing.T) {
+	const (
+		workers = 4
+		events  = 8
+	)
+
+	bus := memory.NewAsync(
+		ebus.WithRegistry(newAccountRegistry(t)),
+		ebus.WithWorkerPool(workers, 4, ebus.OverflowBlock),
+	)
+	defer bus.Close()
+
+	var (
+		probe   concurrencyProbe
+		entered = make(chan struct{}, events)
+		release = make(chan struct{})
+	)
+
+	if _, err := bus.Subscribe(context.Background(), "accounts", "ledger", blockingHandler(&probe, entered, release)); err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+
+	for i := range events {
+		if err := bus.Publish(context.Background(), "accounts", newAccountEvent("account", i)); err != nil {
+			t.Fatalf("发布事件失败: %v", err)
+		}
+	}
+
+	// 所有工作协程都在处理中, 其余的投递在队列中等待
+	receiveN(t, entered, workers)
+	close(release)
+	bus.Wait()
+
+	if got := probe.max(); got != workers {
+		t.Errorf("最大并发度 = %d, 期望等于工作协程数量 %d", got, workers)
+	}
+
+	if deadLetters := bus.Broker().DeadLetters(); len(deadLetters) != 0 {
+		t.Errorf("死信数量 = %d, 期望 0 (OverflowBlock 等待队列空位)", len(deadLetters))
+	}
+}
+
+func TestWorkerPoolOverflowNack(t *testing.T) {
+	const events = 4
+
+	bus := memory.NewAsync(
+		ebus.WithRegistry(newAccountRegistry(t)),
+		ebus.WithWorkerPool(1, 1, ebus.OverflowNack),
+		ebus.WithSubscribeConcurrency(events),
+	)
+	defer bus.Close()
+
+	var (
+		probe   concurrencyProbe
+		entered = make(chan struct{}, events)
+		release = make(chan struct{})
+	)
+
+	if _, err := bus.Subscribe(context.Background(), "accounts", "ledger", blockingHandler(&probe, entered, release)); err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+
+	for i := range events {
+		if err := bus.Publish(context.Background(), "accounts", newAccountEvent("account", i)); err != nil {
+			t.Fatalf("发布事件失败: %v", err)
+		}
+	}
+
+	// 工作协程和队列最多占用两个投递, 其余的投递立即重试, 超过最大尝试次数之后成为死信
+	receiveN(t, entered, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := bus.Broker().WaitDeadLetters(ctx, events-2); err != nil {
+		t.Fatalf("等待死信失败, 队列已满时没有拒绝投递: %v", err)
+	}
+
+	close(release)
+	bus.Wait()
+
+	deadLetters := bus.Broker().DeadLetters()
+	for _, deadLetter := range deadLetters {
+		if !errors.Is(deadLetter.Err, ebus.ErrQueueFull) {
+			t.Errorf("死信的错误 = %v, 期望 ErrQueueFull", deadLetter.Err)
+		}
+	}
+
+	if handled := len(entered) + 1; handled+len(deadLetters) != events {
+		t.Errorf("处理 %d 个, 死信 %d 个, 期望合计 %d", handled, len(deadLetters), events)
+	}
+}
+
+func TestMaxInFlight(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []ebus.Option
+		want int
+	}{
+		{
+			// 没有其他分发方式时, 消息队列的并发处理数等于 MaxInFlight
+			name: "direct",
+			opts: []ebus.Option{ebus.WithMaxInFlight(3)},
+			want: 3,
+		},
+		{
+			// 消息队列的并发处理数超过阈值, 多余的投递在闸门等待
+			name: "above threshold",
+			opts: []ebus.Option{ebus.WithMaxInFlight(2), ebus.WithSubscribeConcurrency(8)},
+			want: 2,
+		},
+		{
+			name: "ordering lanes",
+			opts: []ebus.Option{
+				ebus.WithMaxInFlight(2),
+				ebus.WithOrderingKey(func(event ebus.Event) string {
+					return event.(*accountEvent).AccountId
+				}),
+			},
+			want: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const events = 8
+
+			bus := memory.NewAsync(append([]ebus.Option{ebus.WithRegistry(newAccountRegistry(t))}, tt.opts...)...)
+			defer bus.Close()
+
+			var (
+				probe   concurrencyProbe
+				entered = make(chan struct{}, events)
+				release = make(chan struct{})
+			)
+
+			if _, err := bus.Subscribe(context.Background(), "accounts", "ledger", blockingHandler(&probe, entered, release)); err != nil {
+				t.Fatalf("订阅失败: %v", err)
+			}
+
+			for i := range events {
+				event := newAccountEvent(fmt.Sprintf("account-%d", i), i)
+				if err := bus.Publish(context.Background(), "accounts", event); err != nil {
+					t.Fatalf("发布事件失败: %v", err)
+				}
+			}
+
+			// 达到上限之后放行, 其余的投递在此之前不能进入处理函数
+			receiveN(t, entered, tt.want)
+			close(release)
+			bus.Wait()
+
+			if got := probe.max(); got != tt.want {
+				t.Errorf("最大并发度 = %d, 期望 %d", got, tt.want)
+			}
+
+			if handled := len(entered) + tt.want; handled != events {
+				t.Errorf("处理 %d 个事件, 期望 %d", handled, events)
+			}
+		})
+	}
+}
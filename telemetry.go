@@ -0,0 +1,103 @@
+package ebus
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// SchemaStat 模型版本的接收统计
+type SchemaStat struct {
+	Topic         string        `json:"topic"`         // 主题
+	EventSource   EventSource   `json:"eventSource"`   // 事件来源
+	EventType     EventType     `json:"eventType"`     // 事件类型
+	SchemaVersion SchemaVersion `json:"schemaVersion"` // 模型版本
+	Count         int64         `json:"count"`         // 接收次数
+	FirstSeen     time.Time     `json:"firstSeen"`     // 首次接收时间
+	LastSeen      time.Time     `json:"lastSeen"`      // 最后接收时间
+}
+
+// schemaStatKey 模型版本统计的键
+type schemaStatKey struct {
+	topic         string
+	eventSource   EventSource
+	eventType     EventType
+	schemaVersion SchemaVersion
+}
+
+// SchemaTelemetry 订阅端的模型版本遥测
+//
+// 按主题统计实际收到的每个事件类型的模型版本
+// 某个旧版本长时间没有再出现时, 就可以安全地删除该版本的事件定义和升级转换
+// 同一个遥测可以在多个订阅者之间共享
+type SchemaTelemetry struct {
+	lock  sync.RWMutex
+	stats map[schemaStatKey]*SchemaStat
+}
+
+// NewSchemaTelemetry 创建模型版本遥测
+func NewSchemaTelemetry() *SchemaTelemetry {
+	return &SchemaTelemetry{
+		stats: make(map[schemaStatKey]*SchemaStat),
+	}
+}
+
+// record 记录收到的事件
+func (t *SchemaTelemetry) record(topic string, meta *Metadata, now time.Time) {
+	key := schemaStatKey{
+		topic:         topic,
+		eventSource:   meta.EventSource,
+		eventType:     meta.EventType,
+		schemaVersion: meta.SchemaVersion,
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	stat, exists := t.stats[key]
+	if !exists {
+		stat = &SchemaStat{
+			Topic:         topic,
+			EventSource:   meta.EventSource,
+			EventType:     meta.EventType,
+			SchemaVersion: meta.SchemaVersion,
+			FirstSeen:     now,
+		}
+		t.stats[key] = stat
+	}
+
+	stat.Count++
+	stat.LastSeen = now
+}
+
+// Stats 获取统计快照
+//
+// 按主题, 事件来源, 事件类型, 模型版本排序
+func (t *SchemaTelemetry) Stats() []SchemaStat {
+	t.lock.RLock()
+	stats := make([]SchemaStat, 0, len(t.stats))
+	for _, stat := range t.stats {
+		stats = append(stats, *stat)
+	}
+	t.lock.RUnlock()
+
+	slices.SortFunc(stats, func(a, b SchemaStat) int {
+		return cmp.Or(
+			cmp.Compare(a.Topic, b.Topic),
+			cmp.Compare(a.EventSource, b.EventSource),
+			cmp.Compare(a.EventType, b.EventType),
+			cmp.Compare(a.SchemaVersion, b.SchemaVersion),
+		)
+	})
+
+	return stats
+}
+
+// Reset 清空统计
+func (t *SchemaTelemetry) Reset() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	clear(t.stats)
+}
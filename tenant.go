@@ -0,0 +1,62 @@
+package ebus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/nf5lab/broker"
+)
+
+var (
+	// ErrTenantMismatch 事件的租户与订阅者的租户不匹配
+	ErrTenantMismatch = errors.New("ebus: 事件租户不匹配")
+)
+
+// TenantPolicy 租户不匹配时的处理策略
+type TenantPolicy int
+
+const (
+	// TenantPolicyFilter 跳过事件, 视为处理成功 (消息被确认)
+	TenantPolicyFilter TenantPolicy = iota
+
+	// TenantPolicyReject 以不可重试的错误拒绝事件, 由消息队列丢弃或者转入死信队列
+	TenantPolicyReject
+)
+
+func (policy TenantPolicy) String() string {
+	switch policy {
+	case TenantPolicyFilter:
+		return "filter"
+	case TenantPolicyReject:
+		return "reject"
+	default:
+		return fmt.Sprintf("TenantPolicy(%d)", int(policy))
+	}
+}
+
+// checkTenant 检查事件租户
+//
+// 返回事件是否应该继续处理, 不继续处理时返回的错误作为投递的结果
+func (sub *subscriber) checkTenant(ctx context.Context, topic string, metadata *Metadata) (bool, error) {
+	tenantId := sub.options.TenantId
+	if len(tenantId) == 0 || metadata.TenantId == tenantId {
+		return true, nil
+	}
+
+	logAttrs := append(metadataLogAttrs(metadata),
+		slog.String("topic", topic),
+		slog.String("tenantId", metadata.TenantId),
+		slog.String("expectedTenantId", tenantId),
+	)
+
+	if sub.options.TenantPolicy == TenantPolicyReject {
+		sub.options.Logger.WarnContext(ctx, "ebus: 拒绝租户不匹配的事件", logAttrs...)
+		return false, broker.NewNonRetryableError(fmt.Errorf("%w: 事件(%s)租户(%s), 期望(%s)",
+			ErrTenantMismatch, metadata.EventId, metadata.TenantId, tenantId))
+	}
+
+	sub.options.Logger.DebugContext(ctx, "ebus: 跳过租户不匹配的事件", logAttrs...)
+	return false, nil
+}
@@ -0,0 +1,136 @@
+package ebus
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/nf5lab/broker"
+)
+
+// TraceParent W3C 追踪上下文 (traceparent)
+//
+// 格式: {version}-{trace-id}-{parent-id}-{trace-flags}, 例如:
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+type TraceParent struct {
+	TraceId [16]byte // 追踪ID
+	SpanId  [8]byte  // 父跨度ID
+	Flags   byte     // 追踪标志, 最低位表示是否采样
+}
+
+// traceParentVersion 支持的 traceparent 版本
+const traceParentVersion = "00"
+
+// ParseTraceParent 解析 traceparent 字符串
+func ParseTraceParent(value string) (TraceParent, error) {
+	var tp TraceParent
+
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return tp, fmt.Errorf("ebus: 无效的追踪上下文: %s", value)
+	}
+
+	// 未来的版本可能追加字段, 只有版本 00 要求恰好4个字段
+	if parts[0] == traceParentVersion && len(parts) != 4 {
+		return tp, fmt.Errorf("ebus: 无效的追踪上下文: %s", value)
+	}
+
+	if len(parts[0]) != 2 || parts[0] == "ff" {
+		return tp, fmt.Errorf("ebus: 无效的追踪上下文版本: %s", parts[0])
+	}
+
+	if err := decodeTraceField(tp.TraceId[:], parts[1]); err != nil {
+		return tp, fmt.Errorf("ebus: 无效的追踪ID: %w", err)
+	}
+
+	if err := decodeTraceField(tp.SpanId[:], parts[2]); err != nil {
+		return tp, fmt.Errorf("ebus: 无效的跨度ID: %w", err)
+	}
+
+	var flags [1]byte
+	if err := decodeTraceField(flags[:], parts[3]); err != nil {
+		return tp, fmt.Errorf("ebus: 无效的追踪标志: %w", err)
+	}
+	tp.Flags = flags[0]
+
+	if !tp.IsValid() {
+		return tp, fmt.Errorf("ebus: 无效的追踪上下文: %s", value)
+	}
+
+	return tp, nil
+}
+
+// decodeTraceField 解码固定长度的小写十六进制字段
+func decodeTraceField(dst []byte, field string) error {
+	if len(field) != hex.EncodedLen(len(dst)) {
+		return fmt.Errorf("长度必须是%d", hex.EncodedLen(len(dst)))
+	}
+
+	if strings.ToLower(field) != field {
+		return fmt.Errorf("必须是小写十六进制")
+	}
+
+	_, err := hex.Decode(dst, []byte(field))
+	return err
+}
+
+// IsValid 追踪ID和跨度ID都不能全为0
+func (tp TraceParent) IsValid() bool {
+	return tp.TraceId != [16]byte{} && tp.SpanId != [8]byte{}
+}
+
+// IsSampled 是否采样
+func (tp TraceParent) IsSampled() bool {
+	return tp.Flags&0x01 == 0x01
+}
+
+// String 格式化为 traceparent 字符串
+func (tp TraceParent) String() string {
+	return traceParentVersion + "-" +
+		hex.EncodeToString(tp.TraceId[:]) + "-" +
+		hex.EncodeToString(tp.SpanId[:]) + "-" +
+		hex.EncodeToString([]byte{tp.Flags})
+}
+
+// traceParentContextKey 追踪上下文在上下文中的键
+type traceParentContextKey struct{}
+
+// ContextWithTraceParent 在上下文中设置追踪上下文
+//
+// 发布者会把上下文中的追踪上下文写入消息头 (x-trace-parent)
+// 追踪系统应当在发布之前, 把当前跨度写入上下文
+func ContextWithTraceParent(ctx context.Context, tp TraceParent) context.Context {
+	return context.WithValue(ctx, traceParentContextKey{}, tp)
+}
+
+// TraceParentFromContext 从上下文中获取追踪上下文
+//
+// 订阅者在调用处理函数之前, 会把消息头中的追踪上下文放入上下文
+// 追踪系统可以在中间件中读取它, 作为消费跨度的父跨度
+func TraceParentFromContext(ctx context.Context) (TraceParent, bool) {
+	tp, ok := ctx.Value(traceParentContextKey{}).(TraceParent)
+	return tp, ok && tp.IsValid()
+}
+
+// traceParentFromMessage 从消息头解析追踪上下文
+func traceParentFromMessage(message *broker.Message) (TraceParent, bool) {
+	value, ok := message.GetHeaderString(HeaderTraceParent)
+	if !ok {
+		return TraceParent{}, false
+	}
+
+	tp, err := ParseTraceParent(value)
+	if err != nil {
+		return TraceParent{}, false
+	}
+
+	return tp, true
+}
+
+// addTraceParentHeader 把上下文中的追踪上下文写入消息头
+func addTraceParentHeader(ctx context.Context, message *broker.Message) {
+	if tp, ok := TraceParentFromContext(ctx); ok {
+		message.AddHeader(HeaderTraceParent, tp.String())
+	}
+}
@@ -0,0 +1,58 @@
+package ebus
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/nf5lab/broker"
+)
+
+var (
+	// ErrOutboxNotConfigured 通过 PublishTx 发布, 但是没有配置发件箱 (参考 WithOutbox)
+	ErrOutboxNotConfigured = errors.New("ebus: 没有配置发件箱")
+)
+
+// TxOutbox 事务性发件箱
+//
+// 在调用者的数据库事务中暂存编码之后的消息, 事务提交之后由中继发布到消息队列 (参考 ebus/outbox)
+type TxOutbox interface {
+
+	// Stage 在数据库事务中暂存消息
+	// - tx      调用者的数据库事务
+	// - topic   解析之后的主题
+	// - message 编码之后的消息
+	Stage(ctx context.Context, tx *sql.Tx, topic string, message *broker.Message) error
+}
+
+// txContextKey 上下文中通过 PublishTx 发布的数据库事务的键
+type txContextKey struct{}
+
+// stagingTx 获取通过 PublishTx 发布的数据库事务
+func stagingTx(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(*sql.Tx)
+	return tx, ok
+}
+
+// send 发送编码之后的消息
+//
+// 通过 PublishTx 发布时暂存到调用者事务中的发件箱, 否则直接发布到消息队列
+func (pub *publisher) send(ctx context.Context, topic string, message *broker.Message) error {
+	if tx, ok := stagingTx(ctx); ok {
+		return pub.options.Outbox.Stage(ctx, tx, topic, message)
+	}
+	return pub.inner.Publish(ctx, topic, message)
+}
+
+// PublishTx 在调用者的数据库事务中暂存事件
+func (pub *publisher) PublishTx(ctx context.Context, tx *sql.Tx, topic string, event Event) error {
+	if tx == nil {
+		return errors.New("ebus: 数据库事务不能为空")
+	}
+
+	if pub.options.Outbox == nil {
+		return ErrOutboxNotConfigured
+	}
+
+	return pub.Publish(context.WithValue(ctx, txContextKey{}, tx), topic, event)
+}
@@ -0,0 +1,37 @@
+package ebus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrEventTypeMismatch = errors.New("ebus: 事件类型不匹配")
+)
+
+// TypedEventHandler 具体类型的事件处理函数
+type TypedEventHandler[T Event] func(ctx context.Context, topic string, event T) error
+
+// SubscribeTyped 订阅具体类型的事件
+//
+// 事件仍然通过已注册的事件工厂解码, 然后断言为类型 T
+// 如果解码出的事件不是类型 T, 处理失败并返回 ErrEventTypeMismatch
+func SubscribeTyped[T Event](ctx context.Context, sub Subscriber, topic string, group string, handler TypedEventHandler[T]) (string, error) {
+	if sub == nil {
+		return "", fmt.Errorf("ebus: 订阅者不能为空")
+	}
+
+	if handler == nil {
+		return "", fmt.Errorf("ebus: 事件处理函数不能为空")
+	}
+
+	return sub.Subscribe(ctx, topic, group, func(ctx context.Context, topic string, event Event) error {
+		typed, ok := event.(T)
+		if !ok {
+			var zero T
+			return fmt.Errorf("%w: 期望 %T, 实际 %T", ErrEventTypeMismatch, zero, event)
+		}
+		return handler(ctx, topic, typed)
+	})
+}
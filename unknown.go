@@ -0,0 +1,79 @@
+package ebus
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/nf5lab/broker"
+)
+
+// UnknownEventPolicy 未知事件类型的处理策略
+//
+// 未知事件类型指的是没有注册事件工厂的 (模型版本, 事件来源, 事件类型)
+type UnknownEventPolicy int
+
+const (
+	// UnknownEventError 返回错误, 消息会重新投递 (默认)
+	UnknownEventError UnknownEventPolicy = iota
+
+	// UnknownEventSkip 跳过事件, 视为处理成功
+	UnknownEventSkip
+
+	// UnknownEventDeadLetter 以不可重试的错误拒绝事件, 由消息队列丢弃或者转入死信队列
+	UnknownEventDeadLetter
+
+	// UnknownEventFallback 交给原始模式的兜底处理函数 UnknownEventHandler
+	UnknownEventFallback
+)
+
+func (policy UnknownEventPolicy) String() string {
+	switch policy {
+	case UnknownEventError:
+		return "error"
+	case UnknownEventSkip:
+		return "skip"
+	case UnknownEventDeadLetter:
+		return "dead-letter"
+	case UnknownEventFallback:
+		return "fallback"
+	default:
+		return fmt.Sprintf("UnknownEventPolicy(%d)", int(policy))
+	}
+}
+
+// handleUnknownEvent 按照策略处理未知事件类型
+// - envelope 事件信封的原始数据
+// - err      查找事件工厂的错误
+func (sub *subscriber) handleUnknownEvent(ctx context.Context, subs *subscription, topic string, delivery *broker.Delivery, codec Codec, envelope []byte, err error) error {
+	logAttrs := []any{
+		slog.String("topic", topic),
+		slog.String("messageId", delivery.Message.Id),
+		slog.String("policy", sub.options.UnknownEventPolicy.String()),
+		slog.Any("error", err),
+	}
+
+	switch sub.options.UnknownEventPolicy {
+	case UnknownEventSkip:
+		sub.options.Logger.InfoContext(ctx, "ebus: 跳过未知的事件类型", logAttrs...)
+		return nil
+
+	case UnknownEventDeadLetter:
+		sub.options.Metrics.IncDecodeFailed(topic)
+		sub.options.Logger.WarnContext(ctx, "ebus: 拒绝未知的事件类型", logAttrs...)
+
+		// 没有事件类型, 无法按照字段标签脱敏, 只保留负载的结构
+		if data, maskErr := maskPayloadJSON(codec, delivery.Message.Headers, envelope); maskErr == nil {
+			delivery.Message.AddHeader(HeaderRedactedPayload, string(data))
+		}
+		return broker.NewNonRetryableError(err)
+
+	case UnknownEventFallback:
+		if handler := sub.options.UnknownEventHandler; handler != nil {
+			return sub.routeRaw(ctx, topic, delivery, codec, handler, envelope)
+		}
+	}
+
+	sub.onDecodeFailed(ctx, topic, delivery, err)
+	return err
+}
@@ -0,0 +1,165 @@
+package ebus
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUpcasterExists = errors.New("ebus: 升级函数已存在")
+)
+
+// Upcaster 事件升级函数
+//
+// 把旧模型版本的事件转换为新模型版本的事件
+// 返回的事件元数据的模型版本会被设置为目标版本
+type Upcaster func(old Event) (Event, error)
+
+// upcasterEntry 已注册的升级函数
+type upcasterEntry struct {
+	toVersion SchemaVersion // 目标模型版本
+	upcaster  Upcaster      // 升级函数
+}
+
+// RegisterUpcaster 在全局注册表中注册事件升级函数 (参考 Registry.RegisterUpcaster)
+func RegisterUpcaster(fromVersion SchemaVersion, toVersion SchemaVersion, evtSource EventSource, evtType EventType, upcaster Upcaster) error {
+	return defaultRegistry.RegisterUpcaster(fromVersion, toVersion, evtSource, evtType, upcaster)
+}
+
+// MustRegisterUpcaster 在全局注册表中注册事件升级函数, 如果注册失败则 panic
+func MustRegisterUpcaster(fromVersion SchemaVersion, toVersion SchemaVersion, evtSource EventSource, evtType EventType, upcaster Upcaster) {
+	defaultRegistry.MustRegisterUpcaster(fromVersion, toVersion, evtSource, evtType, upcaster)
+}
+
+// Upcast 使用全局注册表的升级函数, 把事件升级到最新的模型版本 (参考 Registry.Upcast)
+func Upcast(event Event) (Event, error) {
+	return defaultRegistry.Upcast(event)
+}
+
+// RegisterUpcaster 注册事件升级函数
+//
+// 订阅者解码事件之后会自动串联升级函数, 处理函数总是收到最新模型版本的事件
+// 每个源模型版本只能注册一个升级函数
+// - fromVersion 源模型版本
+// - toVersion   目标模型版本
+// - evtSource   事件来源
+// - evtType     事件类型
+// - upcaster    升级函数
+func (r *Registry) RegisterUpcaster(fromVersion SchemaVersion, toVersion SchemaVersion, evtSource EventSource, evtType EventType, upcaster Upcaster) error {
+	fromVersion = fromVersion.Normalize()
+	if fromVersion.IsEmpty() {
+		return fmt.Errorf("ebus: 源模型版本不能为空")
+	}
+
+	toVersion = toVersion.Normalize()
+	if toVersion.IsEmpty() {
+		return fmt.Errorf("ebus: 目标模型版本不能为空")
+	}
+
+	if fromVersion == toVersion {
+		return fmt.Errorf("ebus: 源模型版本和目标模型版本不能相同")
+	}
+
+	evtSource = evtSource.Normalize()
+	if evtSource.IsEmpty() {
+		return fmt.Errorf("ebus: 事件来源不能为空")
+	}
+
+	evtType = evtType.Normalize()
+	if evtType.IsEmpty() {
+		return fmt.Errorf("ebus: 事件类型不能为空")
+	}
+
+	if upcaster == nil {
+		return fmt.Errorf("ebus: 升级函数不能为空")
+	}
+
+	// 在锁外面构建key, 减少锁的持有时间
+	upcasterKey := buildEventFactoryKey(fromVersion, evtSource, evtType)
+
+	r.convertLock.Lock()
+	defer r.convertLock.Unlock()
+
+	if _, exists := r.upcasters[upcasterKey]; exists {
+		return fmt.Errorf("%w: %s", ErrUpcasterExists, r.describeKey(upcasterKey))
+	}
+
+	if r.upcasters == nil {
+		r.upcasters = make(map[string]upcasterEntry)
+	}
+	r.upcasters[upcasterKey] = upcasterEntry{
+		toVersion: toVersion,
+		upcaster:  upcaster,
+	}
+
+	return nil
+}
+
+// MustRegisterUpcaster 注册事件升级函数, 如果注册失败则 panic
+// - fromVersion 源模型版本
+// - toVersion   目标模型版本
+// - evtSource   事件来源
+// - evtType     事件类型
+// - upcaster    升级函数
+func (r *Registry) MustRegisterUpcaster(fromVersion SchemaVersion, toVersion SchemaVersion, evtSource EventSource, evtType EventType, upcaster Upcaster) {
+	if err := r.RegisterUpcaster(fromVersion, toVersion, evtSource, evtType, upcaster); err != nil {
+		panic(err)
+	}
+}
+
+// getUpcaster 获取升级函数, 参数已经规范化
+func (r *Registry) getUpcaster(scmVersion SchemaVersion, evtSource EventSource, evtType EventType) (upcasterEntry, bool) {
+	upcasterKey := buildEventFactoryKey(scmVersion, evtSource, evtType)
+
+	r.convertLock.RLock()
+	defer r.convertLock.RUnlock()
+
+	entry, exists := r.upcasters[upcasterKey]
+	return entry, exists
+}
+
+// Upcast 串联升级函数, 把事件升级到最新的模型版本
+//
+// 没有注册升级函数时原样返回事件
+func (r *Registry) Upcast(event Event) (Event, error) {
+	if event == nil {
+		return nil, fmt.Errorf("ebus: 事件不能为空")
+	}
+
+	metadata := event.Metadata()
+	if metadata == nil {
+		return nil, fmt.Errorf("ebus: 事件元数据为空")
+	}
+
+	// 记录经过的模型版本, 防止升级函数形成环
+	visited := map[SchemaVersion]bool{}
+
+	for {
+		scmVersion := metadata.SchemaVersion.Normalize()
+		evtSource := metadata.EventSource.Normalize()
+		evtType := metadata.EventType.Normalize()
+
+		entry, exists := r.getUpcaster(scmVersion, evtSource, evtType)
+		if !exists {
+			return event, nil
+		}
+
+		if visited[scmVersion] {
+			return nil, fmt.Errorf("ebus: 事件(%s)的升级函数形成环: %s", metadata.EventId, scmVersion)
+		}
+		visited[scmVersion] = true
+
+		upcasted, err := entry.upcaster(event)
+		if err != nil {
+			return nil, fmt.Errorf("ebus: 事件(%s)从版本[%s]升级到[%s]失败: %w", metadata.EventId, scmVersion, entry.toVersion, err)
+		}
+
+		if upcasted == nil || upcasted.Metadata() == nil {
+			return nil, fmt.Errorf("ebus: 事件(%s)从版本[%s]升级到[%s]之后元数据为空", metadata.EventId, scmVersion, entry.toVersion)
+		}
+
+		event = upcasted
+		metadata = upcasted.Metadata()
+		metadata.SchemaVersion = entry.toVersion
+	}
+}
@@ -0,0 +1,287 @@
+package ebus
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 默认规则名称
+const (
+	RuleSchemaVersionRequired = "schema-version-required" // 模型版本不能为空
+	RuleEventIdRequired       = "event-id-required"       // 事件ID不能为空
+	RuleEventSourceRequired   = "event-source-required"   // 事件来源不能为空
+	RuleEventTypeRequired     = "event-type-required"     // 事件类型不能为空
+	RuleEventTimePositive     = "event-time-positive"     // 事件时间必须大于0
+	RuleEventTimeNotFuture    = "event-time-not-future"   // 事件时间不能超出允许的时钟漂移
+)
+
+const (
+	// DefaultMaxClockSkew 默认允许的最大时钟漂移
+	DefaultMaxClockSkew = 300 * time.Second
+
+	// NoClockSkewCheck 不校验事件时间是否超出允许的时钟漂移 (参考 WithMaxClockSkew)
+	NoClockSkewCheck time.Duration = -1
+)
+
+// ValidateEnv 元数据校验环境
+type ValidateEnv struct {
+	Now          time.Time     // 校验时的当前时间
+	MaxClockSkew time.Duration // 允许的最大时钟漂移, 等于0时使用 DefaultMaxClockSkew, 小于0时不校验
+}
+
+// maxClockSkew 获取允许的最大时钟漂移
+//
+// 返回 false 表示不校验时钟漂移
+func (env *ValidateEnv) maxClockSkew() (time.Duration, bool) {
+	switch {
+	case env.MaxClockSkew < 0:
+		return 0, false
+	case env.MaxClockSkew == 0:
+		return DefaultMaxClockSkew, true
+	default:
+		return env.MaxClockSkew, true
+	}
+}
+
+// MetadataRule 元数据校验规则
+type MetadataRule struct {
+	Name  string                                       // 规则名称, 在校验器内唯一
+	Check func(meta *Metadata, env *ValidateEnv) error // 校验函数, 元数据已经规范化
+}
+
+// DefaultMetadataRules 默认的元数据校验规则
+func DefaultMetadataRules() []MetadataRule {
+	return []MetadataRule{
+		{
+			Name: RuleSchemaVersionRequired,
+			Check: func(meta *Metadata, _ *ValidateEnv) error {
+				if meta.SchemaVersion.IsEmpty() {
+					return fmt.Errorf("ebus: 模型版本不能为空")
+				}
+				return nil
+			},
+		},
+		{
+			Name: RuleEventIdRequired,
+			Check: func(meta *Metadata, _ *ValidateEnv) error {
+				if len(meta.EventId) == 0 {
+					return fmt.Errorf("ebus: 事件ID不能为空")
+				}
+				return nil
+			},
+		},
+		{
+			Name: RuleEventSourceRequired,
+			Check: func(meta *Metadata, _ *ValidateEnv) error {
+				if meta.EventSource.IsEmpty() {
+					return fmt.Errorf("ebus: 事件来源不能为空")
+				}
+				return nil
+			},
+		},
+		{
+			Name: RuleEventTypeRequired,
+			Check: func(meta *Metadata, _ *ValidateEnv) error {
+				if meta.EventType.IsEmpty() {
+					return fmt.Errorf("ebus: 事件类型不能为空")
+				}
+				return nil
+			},
+		},
+		{
+			Name: RuleEventTimePositive,
+			Check: func(meta *Metadata, _ *ValidateEnv) error {
+				if meta.EventTime <= 0 {
+					return fmt.Errorf("ebus: 事件时间必须大于0")
+				}
+				return nil
+			},
+		},
+		{
+			Name: RuleEventTimeNotFuture,
+			Check: func(meta *Metadata, env *ValidateEnv) error {
+				// 如果这条消息来自未来的时间
+				// 说明发送者的时钟比接收者快
+				// 允许一定范围的时钟漂移 (默认300秒), 按照完整的时长比较, 不截断到秒
+				skew, enabled := env.maxClockSkew()
+				if !enabled {
+					return nil
+				}
+
+				if ahead := time.Unix(meta.EventTime, 0).Sub(env.Now); ahead > skew {
+					return fmt.Errorf("ebus: 事件时间超出允许范围(领先 %s, 允许 %s)", ahead, skew)
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// EventSourceAllowListRule 事件来源白名单规则
+func EventSourceAllowListRule(name string, sources ...EventSource) MetadataRule {
+	allowed := make(map[EventSource]struct{}, len(sources))
+	for _, source := range sources {
+		allowed[source.Normalize()] = struct{}{}
+	}
+
+	return MetadataRule{
+		Name: name,
+		Check: func(meta *Metadata, _ *ValidateEnv) error {
+			if _, exists := allowed[meta.EventSource]; !exists {
+				return fmt.Errorf("ebus: 事件来源(%s)不在白名单中", meta.EventSource)
+			}
+			return nil
+		},
+	}
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// UUIDEventIdRule 事件ID必须是UUID的规则
+func UUIDEventIdRule(name string) MetadataRule {
+	return MetadataRule{
+		Name: name,
+		Check: func(meta *Metadata, _ *ValidateEnv) error {
+			if !uuidPattern.MatchString(strings.ToLower(meta.EventId)) {
+				return fmt.Errorf("ebus: 事件ID(%s)不是有效的UUID", meta.EventId)
+			}
+			return nil
+		},
+	}
+}
+
+// MetadataValidator 元数据校验器
+//
+// 按添加顺序执行校验规则, 返回第一个失败的规则的错误
+type MetadataValidator struct {
+	lock  sync.RWMutex
+	rules []MetadataRule
+}
+
+// NewMetadataValidator 创建元数据校验器
+//
+// 如果需要在默认规则上增加规则, 可以传入 DefaultMetadataRules()
+func NewMetadataValidator(rules ...MetadataRule) *MetadataValidator {
+	validator := &MetadataValidator{}
+	for _, rule := range rules {
+		validator.AddRule(rule)
+	}
+	return validator
+}
+
+// AddRule 添加校验规则
+//
+// 如果同名规则已经存在, 则替换该规则
+func (v *MetadataValidator) AddRule(rule MetadataRule) {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if len(rule.Name) == 0 || rule.Check == nil {
+		return
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	index := slices.IndexFunc(v.rules, func(r MetadataRule) bool {
+		return r.Name == rule.Name
+	})
+
+	// 复制后修改, 正在执行的校验不受影响
+	rules := slices.Clone(v.rules)
+	if index >= 0 {
+		rules[index] = rule
+	} else {
+		rules = append(rules, rule)
+	}
+	v.rules = rules
+}
+
+// RemoveRule 移除校验规则
+//
+// 返回规则是否存在
+func (v *MetadataValidator) RemoveRule(name string) bool {
+	name = strings.TrimSpace(name)
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	rules := slices.DeleteFunc(slices.Clone(v.rules), func(r MetadataRule) bool {
+		return r.Name == name
+	})
+
+	removed := len(rules) != len(v.rules)
+	v.rules = rules
+	return removed
+}
+
+// RuleNames 列出校验规则的名称 (按执行顺序)
+func (v *MetadataValidator) RuleNames() []string {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	names := make([]string, 0, len(v.rules))
+	for _, rule := range v.rules {
+		names = append(names, rule.Name)
+	}
+	return names
+}
+
+// Validate 规范并校验元数据
+//
+// 当前时间取自默认时钟 DefaultClock()
+func (v *MetadataValidator) Validate(meta *Metadata) error {
+	return v.ValidateWith(meta, nil)
+}
+
+// ValidateWith 使用指定的校验环境规范并校验元数据
+//
+// - env 为 nil 时, 当前时间取自默认时钟 DefaultClock()
+func (v *MetadataValidator) ValidateWith(meta *Metadata, env *ValidateEnv) error {
+	if meta == nil {
+		return fmt.Errorf("ebus: 事件元数据不能为空")
+	} else {
+		meta.Normalize()
+	}
+
+	v.lock.RLock()
+	rules := v.rules
+	v.lock.RUnlock()
+
+	if env == nil {
+		env = &ValidateEnv{
+			Now: DefaultClock().Now(),
+		}
+	}
+
+	for _, rule := range rules {
+		if err := rule.Check(meta, env); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var (
+	defaultMetadataValidator = NewMetadataValidator(DefaultMetadataRules()...)
+)
+
+// DefaultMetadataValidator 默认的元数据校验器
+//
+// Metadata.Validate 使用此校验器
+func DefaultMetadataValidator() *MetadataValidator {
+	return defaultMetadataValidator
+}
+
+// AddMetadataRule 向默认的元数据校验器添加规则
+func AddMetadataRule(rule MetadataRule) {
+	defaultMetadataValidator.AddRule(rule)
+}
+
+// RemoveMetadataRule 从默认的元数据校验器移除规则
+func RemoveMetadataRule(name string) bool {
+	return defaultMetadataValidator.RemoveRule(name)
+}
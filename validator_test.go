@@ -0,0 +1,37 @@
+package ebus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventTimeNotFuture(t *testing.T) {
+	now := time.Unix(1000, int64(500*time.Millisecond))
+
+	tests := []struct {
+		name      string
+		eventTime int64
+		skew      time.Duration
+		wantErr   bool
+	}{
+		{name: "sub-second skew", eventTime: 1001, skew: 400 * time.Millisecond, wantErr: true},
+		{name: "within sub-second skew", eventTime: 1001, skew: 600 * time.Millisecond, wantErr: false},
+		{name: "zero tolerance", eventTime: 1001, skew: time.Nanosecond, wantErr: true},
+		{name: "zero tolerance same second", eventTime: 1000, skew: time.Nanosecond, wantErr: false},
+		{name: "default", eventTime: 1000 + 3600, skew: 0, wantErr: true},
+		{name: "disabled", eventTime: 1000 + 3600, skew: NoClockSkewCheck, wantErr: false},
+	}
+
+	validator := NewMetadataValidator(DefaultMetadataRules()...)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := NewMetadata(testSource, testType, testVersion)
+			meta.EventTime = tt.eventTime
+
+			err := validator.ValidateWith(meta, &ValidateEnv{Now: now, MaxClockSkew: tt.skew})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("校验错误 = %v, 期望出错 %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package ebus
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SchemaVersionVetoFunc 模型版本回退的否决函数
+//
+// 返回 true 表示否决, 不使用候选版本的事件工厂
+// - requested 请求的模型版本
+// - candidate 候选的模型版本 (已注册的最高版本)
+type SchemaVersionVetoFunc func(requested SchemaVersion, candidate SchemaVersion, evtSource EventSource, evtType EventType) bool
+
+// EnableSchemaVersionFallback 在全局注册表中开启模型版本回退 (参考 Registry.EnableSchemaVersionFallback)
+func EnableSchemaVersionFallback(veto SchemaVersionVetoFunc) {
+	defaultRegistry.EnableSchemaVersionFallback(veto)
+}
+
+// DisableSchemaVersionFallback 在全局注册表中关闭模型版本回退
+func DisableSchemaVersionFallback() {
+	defaultRegistry.DisableSchemaVersionFallback()
+}
+
+// EnableSchemaVersionFallback 开启模型版本回退
+//
+// 开启后, 请求的模型版本没有注册事件工厂时, 使用同一事件来源和事件类型已注册的最高模型版本的事件工厂
+// 用于滚动升级: 生产者先升级了模型版本, 消费者尚未全部升级
+// - veto 否决函数, 可以为 nil
+func (r *Registry) EnableSchemaVersionFallback(veto SchemaVersionVetoFunc) {
+	r.convertLock.Lock()
+	defer r.convertLock.Unlock()
+
+	r.versionFallback = true
+	r.versionVeto = veto
+}
+
+// DisableSchemaVersionFallback 关闭模型版本回退
+func (r *Registry) DisableSchemaVersionFallback() {
+	r.convertLock.Lock()
+	defer r.convertLock.Unlock()
+
+	r.versionFallback = false
+	r.versionVeto = nil
+}
+
+// getVersionFallback 获取最高模型版本的事件工厂, 参数已经规范化
+func (r *Registry) getVersionFallback(scmVersion SchemaVersion, evtSource EventSource, evtType EventType) (EventFactory, bool) {
+	r.convertLock.RLock()
+	enabled, veto := r.versionFallback, r.versionVeto
+	r.convertLock.RUnlock()
+
+	if !enabled {
+		return nil, false
+	}
+
+	var (
+		candidate SchemaVersion
+		factory   EventFactory
+	)
+
+	for _, entry := range r.snapshot() {
+		if entry.info.EventSource != evtSource || entry.info.EventType != evtType {
+			continue
+		}
+
+		if factory == nil || CompareSchemaVersion(entry.info.SchemaVersion, candidate) > 0 {
+			candidate = entry.info.SchemaVersion
+			factory = entry.factory
+		}
+	}
+
+	if factory == nil {
+		return nil, false
+	}
+
+	// 在锁外面调用否决函数, 避免否决函数注册事件工厂时死锁
+	if veto != nil && veto(scmVersion, candidate, evtSource, evtType) {
+		return nil, false
+	}
+
+	return factory, true
+}
+
+// CompareSchemaVersion 比较模型版本
+//
+// 去掉前缀 "v" 之后按 "." 分段, 数字段按数值比较, 其它段按字符串比较
+// 例如: v2 < v10, v1.2 < v1.10
+// 返回 -1 表示 a < b, 0 表示 a == b, 1 表示 a > b
+func CompareSchemaVersion(a SchemaVersion, b SchemaVersion) int {
+	partsA := strings.Split(strings.TrimPrefix(a.Normalize().String(), "v"), ".")
+	partsB := strings.Split(strings.TrimPrefix(b.Normalize().String(), "v"), ".")
+
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		var partA, partB string
+		if i < len(partsA) {
+			partA = partsA[i]
+		}
+		if i < len(partsB) {
+			partB = partsB[i]
+		}
+
+		numA, errA := strconv.Atoi(partA)
+		numB, errB := strconv.Atoi(partB)
+
+		switch {
+		case errA == nil && errB == nil:
+			if numA != numB {
+				if numA < numB {
+					return -1
+				}
+				return 1
+			}
+		case partA != partB:
+			return strings.Compare(partA, partB)
+		}
+	}
+
+	return 0
+}
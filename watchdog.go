@@ -0,0 +1,231 @@
+package ebus
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// minWatchdogCheckInterval 看门狗最小检查间隔
+	minWatchdogCheckInterval = time.Second
+)
+
+// StaleAlert 订阅停滞告警
+type StaleAlert struct {
+	SubscriptionId string        // 订阅ID
+	Topic          string        // 订阅主题
+	Group          string        // 订阅组
+	Interval       time.Duration // 期望的事件间隔
+	SubscribeTime  time.Time     // 订阅时间
+	LastReceived   time.Time     // 最后一次收到事件的时间, 零值表示从未收到
+	LastProcessed  time.Time     // 最后一次成功处理事件的时间, 零值表示从未成功
+}
+
+// StaleAlertFunc 订阅停滞告警回调
+type StaleAlertFunc func(alert StaleAlert)
+
+// watchEntry 看门狗的订阅记录
+type watchEntry struct {
+	topic         string
+	group         string
+	subscribeTime time.Time
+
+	lock          sync.Mutex
+	lastReceived  time.Time
+	lastProcessed time.Time
+	alerted       bool // 当前停滞周期是否已经告警
+}
+
+func (entry *watchEntry) touchReceived() {
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+
+	entry.lastReceived = time.Now()
+}
+
+func (entry *watchEntry) touchProcessed() {
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+
+	entry.lastProcessed = time.Now()
+	entry.alerted = false
+}
+
+// Watchdog 订阅看门狗
+//
+// 如果某个订阅在期望的间隔内没有成功处理任何事件, 则调用告警回调
+// 每个停滞周期只告警一次, 订阅恢复处理后重新计时
+type Watchdog struct {
+	alert           StaleAlertFunc
+	defaultInterval time.Duration
+
+	lock      sync.Mutex
+	intervals map[string]time.Duration // 按主题设置的期望间隔
+	entries   map[string]*watchEntry   // 订阅ID -> 订阅记录
+
+	runLock    sync.Mutex
+	cancelFunc context.CancelFunc
+	wait       sync.WaitGroup
+}
+
+// NewWatchdog 创建订阅看门狗
+// - interval 默认的期望事件间隔
+// - alert    告警回调
+func NewWatchdog(interval time.Duration, alert StaleAlertFunc) *Watchdog {
+	return &Watchdog{
+		alert:           alert,
+		defaultInterval: interval,
+		intervals:       make(map[string]time.Duration),
+		entries:         make(map[string]*watchEntry),
+	}
+}
+
+// SetTopicInterval 设置指定主题的期望事件间隔
+//
+// - interval <= 0 表示不监控该主题
+func (wd *Watchdog) SetTopicInterval(topic string, interval time.Duration) {
+	topic = strings.TrimSpace(topic)
+
+	wd.lock.Lock()
+	defer wd.lock.Unlock()
+
+	wd.intervals[topic] = interval
+}
+
+func (wd *Watchdog) topicInterval(topic string) time.Duration {
+	if interval, exists := wd.intervals[topic]; exists {
+		return interval
+	}
+	return wd.defaultInterval
+}
+
+// newEntry 新建订阅记录 (尚未加入监控)
+func (wd *Watchdog) newEntry(topic string, group string) *watchEntry {
+	return &watchEntry{
+		topic:         topic,
+		group:         group,
+		subscribeTime: time.Now(),
+	}
+}
+
+// add 加入监控
+func (wd *Watchdog) add(subscriptionId string, entry *watchEntry) {
+	wd.lock.Lock()
+	defer wd.lock.Unlock()
+
+	wd.entries[subscriptionId] = entry
+}
+
+// remove 移出监控
+func (wd *Watchdog) remove(subscriptionId string) {
+	wd.lock.Lock()
+	defer wd.lock.Unlock()
+
+	delete(wd.entries, subscriptionId)
+}
+
+// Check 立即检查所有订阅, 返回本次触发告警的数量
+func (wd *Watchdog) Check() int {
+	now := time.Now()
+
+	var alerts []StaleAlert
+
+	wd.lock.Lock()
+	for subscriptionId, entry := range wd.entries {
+		interval := wd.topicInterval(entry.topic)
+		if interval <= 0 {
+			continue
+		}
+
+		entry.lock.Lock()
+		lastActive := entry.lastProcessed
+		if lastActive.IsZero() {
+			lastActive = entry.subscribeTime
+		}
+
+		if !entry.alerted && now.Sub(lastActive) > interval {
+			entry.alerted = true
+			alerts = append(alerts, StaleAlert{
+				SubscriptionId: subscriptionId,
+				Topic:          entry.topic,
+				Group:          entry.group,
+				Interval:       interval,
+				SubscribeTime:  entry.subscribeTime,
+				LastReceived:   entry.lastReceived,
+				LastProcessed:  entry.lastProcessed,
+			})
+		}
+		entry.lock.Unlock()
+	}
+	wd.lock.Unlock()
+
+	// 在锁外面执行回调, 避免回调阻塞监控
+	if wd.alert != nil {
+		for _, alert := range alerts {
+			wd.alert(alert)
+		}
+	}
+
+	return len(alerts)
+}
+
+// checkInterval 计算检查间隔 (最小期望间隔的一半)
+func (wd *Watchdog) checkInterval() time.Duration {
+	wd.lock.Lock()
+	defer wd.lock.Unlock()
+
+	interval := wd.defaultInterval
+	for _, topicInterval := range wd.intervals {
+		if topicInterval > 0 && (interval <= 0 || topicInterval < interval) {
+			interval = topicInterval
+		}
+	}
+
+	return max(interval/2, minWatchdogCheckInterval)
+}
+
+// Start 启动后台检查
+func (wd *Watchdog) Start() {
+	wd.runLock.Lock()
+	defer wd.runLock.Unlock()
+
+	if wd.cancelFunc != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wd.cancelFunc = cancel
+
+	wd.wait.Add(1)
+	go func() {
+		defer wd.wait.Done()
+
+		ticker := time.NewTicker(wd.checkInterval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				wd.Check()
+			}
+		}
+	}()
+}
+
+// Stop 停止后台检查
+func (wd *Watchdog) Stop() {
+	wd.runLock.Lock()
+	defer wd.runLock.Unlock()
+
+	if wd.cancelFunc == nil {
+		return
+	}
+
+	wd.cancelFunc()
+	wd.cancelFunc = nil
+	wd.wait.Wait()
+}